@@ -0,0 +1,50 @@
+package imgutil
+
+import "fmt"
+
+// AccessFailure is the reason why an image cannot be read or written.
+type AccessFailure int
+
+const (
+	// AccessDenied means that the credentials are missing or don't grant the access (e.g. registry token scopes,
+	// file permissions).
+	AccessDenied AccessFailure = iota + 1
+	// AccessUnreachable means that the image store could not be reached (e.g. network failures, daemon not running).
+	AccessUnreachable
+	// AccessNotFound means that the image store reported the repository as missing, and it cannot be created.
+	AccessNotFound
+)
+
+func (f AccessFailure) String() string {
+	switch f {
+	case AccessDenied:
+		return "access denied"
+	case AccessUnreachable:
+		return "unreachable"
+	case AccessNotFound:
+		return "not found"
+	default:
+		return "unknown failure"
+	}
+}
+
+// AccessError is returned by the CheckReadAccess and CheckWriteAccess methods of the image implementations
+// when the image cannot be read or written.
+type AccessError struct {
+	Name   string
+	Write  bool
+	Reason AccessFailure
+	Err    error
+}
+
+func (e AccessError) Error() string {
+	op := "read"
+	if e.Write {
+		op = "write"
+	}
+	return fmt.Sprintf("failed to %s image %q (%s): %s", op, e.Name, e.Reason, e.Err)
+}
+
+func (e AccessError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,96 @@
+package imgutil
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Annotations and media types of attestation manifests, stored in an index alongside the images they describe
+// (see https://docs.docker.com/build/attestations/attestation-storage/).
+const (
+	ReferenceTypeAnnotation       = "vnd.docker.reference.type"
+	ReferenceDigestAnnotation     = "vnd.docker.reference.digest"
+	AttestationManifestType       = "attestation-manifest"
+	InTotoMediaType               = "application/vnd.in-toto+json"
+	InTotoPredicateTypeAnnotation = "in-toto.io/predicate-type"
+)
+
+// Attestation is an in-toto statement (e.g. a SLSA provenance or an SPDX SBOM) about an image.
+type Attestation struct {
+	PredicateType string
+	Statement     []byte
+}
+
+// NewAttestationManifest returns an attestation manifest holding each attestation as a layer,
+// annotated with its predicate type. The config of the manifest has an `unknown` platform, as attestation manifests describe
+// the image referencing them rather than running on a platform.
+func NewAttestationManifest(attestations ...Attestation) (v1.Image, error) {
+	additions := make([]mutate.Addendum, 0, len(attestations))
+	for _, attestation := range attestations {
+		additions = append(additions, mutate.Addendum{
+			Layer:       static.NewLayer(attestation.Statement, InTotoMediaType),
+			MediaType:   InTotoMediaType,
+			Annotations: map[string]string{InTotoPredicateTypeAnnotation: attestation.PredicateType},
+		})
+	}
+	manifest, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), additions...)
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := manifest.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	configFile.OS = "unknown"
+	configFile.Architecture = "unknown"
+	return mutate.ConfigFile(manifest, configFile)
+}
+
+// AppendAttestation returns the index with the attestation manifest appended, annotated as describing
+// the image of the index with the given digest. It is an error if the index has no such image.
+func AppendAttestation(index v1.ImageIndex, subject v1.Hash, attestation v1.Image) (v1.ImageIndex, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index manifest: %w", err)
+	}
+	found := false
+	for _, desc := range indexManifest.Manifests {
+		if desc.Digest == subject && desc.MediaType.IsImage() && !isAttestation(desc) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("index has no image with digest %s", subject)
+	}
+	return mutate.AppendManifests(index, mutate.IndexAddendum{
+		Add: attestation,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{
+				ReferenceTypeAnnotation:   AttestationManifestType,
+				ReferenceDigestAnnotation: subject.String(),
+			},
+		},
+	}), nil
+}
+
+// AttestationsFor returns the descriptors of the attestation manifests of the index describing the image with the given digest.
+func AttestationsFor(index v1.ImageIndex, subject v1.Hash) ([]v1.Descriptor, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index manifest: %w", err)
+	}
+	var attestations []v1.Descriptor
+	for _, desc := range indexManifest.Manifests {
+		if desc.Annotations[ReferenceTypeAnnotation] == AttestationManifestType && desc.Annotations[ReferenceDigestAnnotation] == subject.String() {
+			attestations = append(attestations, desc)
+		}
+	}
+	return attestations, nil
+}
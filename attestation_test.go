@@ -0,0 +1,80 @@
+package imgutil_test
+
+import (
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestAttestation(t *testing.T) {
+	spec.Run(t, "Attestation", testAttestation, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testAttestation(t *testing.T, when spec.G, it spec.S) {
+	var (
+		image v1.Image
+		index v1.ImageIndex
+	)
+
+	it.Before(func() {
+		var err error
+		image, err = random.Image(1024, 1)
+		h.AssertNil(t, err)
+		index = mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		)
+	})
+
+	it("appends attestation manifests describing the images of the index", func() {
+		statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`)
+		attestation, err := imgutil.NewAttestationManifest(imgutil.Attestation{
+			PredicateType: "https://slsa.dev/provenance/v0.2",
+			Statement:     statement,
+		})
+		h.AssertNil(t, err)
+		digest, err := image.Digest()
+		h.AssertNil(t, err)
+		index, err = imgutil.AppendAttestation(index, digest, attestation)
+		h.AssertNil(t, err)
+		h.AssertNil(t, imgutil.ValidateIndex(index))
+
+		attestations, err := imgutil.AttestationsFor(index, digest)
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(attestations), 1)
+		h.AssertEq(t, attestations[0].Platform.String(), "unknown/unknown")
+
+		appended, err := index.Image(attestations[0].Digest)
+		h.AssertNil(t, err)
+		manifest, err := appended.Manifest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(manifest.Layers), 1)
+		h.AssertEq(t, string(manifest.Layers[0].MediaType), imgutil.InTotoMediaType)
+		h.AssertEq(t, manifest.Layers[0].Annotations[imgutil.InTotoPredicateTypeAnnotation], "https://slsa.dev/provenance/v0.2")
+		layers, err := appended.Layers()
+		h.AssertNil(t, err)
+		rc, err := layers[0].Compressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		h.AssertNil(t, err)
+		h.AssertEq(t, content, statement)
+	})
+
+	it("fails if the index has no image with the digest", func() {
+		attestation, err := imgutil.NewAttestationManifest()
+		h.AssertNil(t, err)
+		digest, err := attestation.Digest()
+		h.AssertNil(t, err)
+		_, err = imgutil.AppendAttestation(index, digest, attestation)
+		h.AssertError(t, err, "index has no image with digest")
+	})
+}
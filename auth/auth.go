@@ -0,0 +1,163 @@
+// Package auth resolves registry credentials from the sources platforms usually combine:
+// explicit credentials, the CNB_REGISTRY_AUTH environment variable (as used by the lifecycle), the Docker config,
+// and the docker-credential-* helpers installed on PATH.
+package auth
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// EnvRegistryAuth is the environment variable holding registry credentials as a JSON object,
+// mapping each registry to the value of an Authorization header, e.g. `{"registry.example.com": "Basic dXNlcjpwYXNz"}`.
+const EnvRegistryAuth = "CNB_REGISTRY_AUTH"
+
+// Credentials are the credentials for a registry.
+// IdentityToken is a refresh token that is exchanged for an access token (e.g. for Azure Container Registry),
+// and RegistryToken is a bearer token sent to the registry as is.
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string
+	RegistryToken string
+}
+
+type Option func(*Options)
+
+type Options struct {
+	// Credentials holds explicit credentials for each registry.
+	Credentials map[string]Credentials
+	// EnvVar is the environment variable holding credentials as JSON; it defaults to EnvRegistryAuth.
+	EnvVar string
+	// DockerConfigDir is the directory holding the Docker config.json; it defaults to DOCKER_CONFIG or ~/.docker.
+	DockerConfigDir string
+	// WithoutDockerConfig skips the Docker config.
+	WithoutDockerConfig bool
+	// CredentialHelpers enables the credential helpers found on PATH.
+	CredentialHelpers bool
+	// Helpers maps registries to the credential helper serving them, overriding the known helpers.
+	Helpers map[string]string
+}
+
+// WithCredentials provides the credentials for the given registry (e.g. `registry.example.com` or `docker.io`).
+// It may be provided more than once.
+func WithCredentials(registry string, credentials Credentials) func(*Options) {
+	return func(o *Options) {
+		if o.Credentials == nil {
+			o.Credentials = map[string]Credentials{}
+		}
+		o.Credentials[registry] = credentials
+	}
+}
+
+// WithEnvVar reads the credentials from the given environment variable instead of CNB_REGISTRY_AUTH.
+func WithEnvVar(envVar string) func(*Options) {
+	return func(o *Options) {
+		o.EnvVar = envVar
+	}
+}
+
+// WithDockerConfigDir reads the Docker config.json (and the credential helpers it configures) from the given directory.
+func WithDockerConfigDir(dir string) func(*Options) {
+	return func(o *Options) {
+		o.DockerConfigDir = dir
+	}
+}
+
+// WithoutDockerConfig ignores the Docker config, so that only explicit and environment credentials are used.
+func WithoutDockerConfig() func(*Options) {
+	return func(o *Options) {
+		o.WithoutDockerConfig = true
+	}
+}
+
+// WithCredentialHelpers resolves credentials by running the docker-credential-* helpers found on PATH
+// for the registries of cloud providers (`ecr-login` for Amazon ECR, `gcr` or `gcloud` for Google, `acr-env` or `acr` for Azure),
+// so that cloud credentials are picked up without a Docker config.
+func WithCredentialHelpers() func(*Options) {
+	return func(o *Options) {
+		o.CredentialHelpers = true
+	}
+}
+
+// WithCredentialHelper resolves the credentials for the given registry by running the given helper (e.g. `ecr-login`) found on PATH.
+// It implies WithCredentialHelpers and may be provided more than once.
+func WithCredentialHelper(registry, helper string) func(*Options) {
+	return func(o *Options) {
+		if o.Helpers == nil {
+			o.Helpers = map[string]string{}
+		}
+		o.Helpers[registry] = helper
+		o.CredentialHelpers = true
+	}
+}
+
+// ResolveKeychain returns a keychain resolving the credentials for a registry from, in order of precedence:
+// the explicit credentials, the environment variable, the Docker config, and the credential helpers (if enabled).
+// Registries without credentials are accessed anonymously.
+// An error is returned if the explicit credentials name an invalid registry, or if the environment variable can't be parsed.
+func ResolveKeychain(ops ...Option) (authn.Keychain, error) {
+	options := &Options{EnvVar: EnvRegistryAuth}
+	for _, op := range ops {
+		op(options)
+	}
+
+	explicit, err := newStaticKeychain(options.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	env, err := newEnvKeychain(options.EnvVar)
+	if err != nil {
+		return nil, err
+	}
+	keychains := []authn.Keychain{explicit, env}
+	if !options.WithoutDockerConfig {
+		keychains = append(keychains, &dockerConfigKeychain{dir: options.DockerConfigDir})
+	}
+	if options.CredentialHelpers {
+		helpers, err := newHelperKeychain(options.Helpers)
+		if err != nil {
+			return nil, err
+		}
+		keychains = append(keychains, helpers)
+	}
+	return authn.NewMultiKeychain(keychains...), nil
+}
+
+// staticKeychain resolves the credentials of a registry from a map keyed by registry name.
+type staticKeychain map[string]authn.Authenticator
+
+func (k staticKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k[target.RegistryStr()]; ok {
+		return auth, nil
+	}
+	return authn.Anonymous, nil
+}
+
+func newStaticKeychain(credentials map[string]Credentials) (staticKeychain, error) {
+	keychain := staticKeychain{}
+	for registry, creds := range credentials {
+		key, err := registryKey(registry)
+		if err != nil {
+			return nil, err
+		}
+		keychain[key] = authn.FromConfig(authn.AuthConfig{
+			Username:      creds.Username,
+			Password:      creds.Password,
+			IdentityToken: creds.IdentityToken,
+			RegistryToken: creds.RegistryToken,
+		})
+	}
+	return keychain, nil
+}
+
+// registryKey normalizes the name of a registry (e.g. `docker.io` is `index.docker.io`).
+func registryKey(registry string) (string, error) {
+	reg, err := name.NewRegistry(registry, name.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	return reg.RegistryStr(), nil
+}
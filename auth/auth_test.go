@@ -0,0 +1,166 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/auth"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestAuth(t *testing.T) {
+	spec.Run(t, "Auth", testAuth, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testAuth(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir string
+		envVar = "IMGUTIL_TEST_REGISTRY_AUTH"
+	)
+
+	resolve := func(keychain authn.Keychain, repoName string) *authn.AuthConfig {
+		t.Helper()
+		repo, err := name.NewRepository(repoName, name.WeakValidation)
+		h.AssertNil(t, err)
+		authenticator, err := keychain.Resolve(repo)
+		h.AssertNil(t, err)
+		cfg, err := authenticator.Authorization()
+		h.AssertNil(t, err)
+		return cfg
+	}
+
+	basic := func(username, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "auth")
+		h.AssertNil(t, err)
+		h.AssertNil(t, os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("hub-user:hub-pass"))+`"},
+    "config.example.com": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("config-user:config-pass"))+`"},
+    "token.example.com": {"identitytoken": "some-identity-token"}
+  }
+}`), 0600))
+	})
+
+	it.After(func() {
+		os.Unsetenv(envVar)
+		os.RemoveAll(tmpDir)
+	})
+
+	it("resolves credentials from the Docker config directory", func() {
+		keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithDockerConfigDir(tmpDir))
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, resolve(keychain, "config.example.com/some-repo"), &authn.AuthConfig{Username: "config-user", Password: "config-pass"})
+		h.AssertEq(t, resolve(keychain, "some-repo"), &authn.AuthConfig{Username: "hub-user", Password: "hub-pass"})
+		h.AssertEq(t, resolve(keychain, "token.example.com/some-repo"), &authn.AuthConfig{IdentityToken: "some-identity-token"})
+		h.AssertEq(t, resolve(keychain, "other.example.com/some-repo"), &authn.AuthConfig{})
+	})
+
+	it("prefers the environment variable over the Docker config", func() {
+		h.AssertNil(t, os.Setenv(envVar, `{"config.example.com": "`+basic("env-user", "env-pass")+`", "bearer.example.com": "Bearer some-token"}`))
+		keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithDockerConfigDir(tmpDir))
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, resolve(keychain, "config.example.com/some-repo"), &authn.AuthConfig{Username: "env-user", Password: "env-pass"})
+		h.AssertEq(t, resolve(keychain, "bearer.example.com/some-repo"), &authn.AuthConfig{RegistryToken: "some-token"})
+	})
+
+	it("prefers explicit credentials over the environment variable", func() {
+		h.AssertNil(t, os.Setenv(envVar, `{"index.docker.io": "`+basic("env-user", "env-pass")+`"}`))
+		keychain, err := auth.ResolveKeychain(
+			auth.WithEnvVar(envVar),
+			auth.WithDockerConfigDir(tmpDir),
+			auth.WithCredentials("docker.io", auth.Credentials{Username: "explicit-user", Password: "explicit-pass"}),
+			auth.WithCredentials("token.example.com", auth.Credentials{RegistryToken: "explicit-token"}),
+		)
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, resolve(keychain, "some-repo"), &authn.AuthConfig{Username: "explicit-user", Password: "explicit-pass"})
+		h.AssertEq(t, resolve(keychain, "token.example.com/some-repo"), &authn.AuthConfig{RegistryToken: "explicit-token"})
+	})
+
+	it("ignores the Docker config if asked", func() {
+		keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithDockerConfigDir(tmpDir), auth.WithoutDockerConfig())
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, resolve(keychain, "config.example.com/some-repo"), &authn.AuthConfig{})
+	})
+
+	when("credential helpers are enabled", func() {
+		var binDir string
+
+		writeHelper := func(helper, output string) {
+			t.Helper()
+			script := "#!/bin/sh\nread server\n" + output + "\n"
+			h.AssertNil(t, os.WriteFile(filepath.Join(binDir, "docker-credential-"+helper), []byte(script), 0700))
+		}
+
+		it.Before(func() {
+			binDir = filepath.Join(tmpDir, "bin")
+			h.AssertNil(t, os.MkdirAll(binDir, 0755))
+			t.Setenv("PATH", binDir)
+		})
+
+		it("runs the helper known to serve the registry", func() {
+			writeHelper("ecr-login", `echo '{"ServerURL":"'$server'","Username":"AWS","Secret":"ecr-pass"}'`)
+			writeHelper("gcloud", `echo '{"ServerURL":"'$server'","Username":"<token>","Secret":"gcloud-token"}'`)
+			keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithoutDockerConfig(), auth.WithCredentialHelpers())
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, resolve(keychain, "123456789012.dkr.ecr.us-east-1.amazonaws.com/some-repo"), &authn.AuthConfig{Username: "AWS", Password: "ecr-pass"})
+			h.AssertEq(t, resolve(keychain, "us-docker.pkg.dev/some-project/some-repo"), &authn.AuthConfig{IdentityToken: "gcloud-token"})
+			h.AssertEq(t, resolve(keychain, "some-registry.azurecr.io/some-repo"), &authn.AuthConfig{})
+			h.AssertEq(t, resolve(keychain, "other.example.com/some-repo"), &authn.AuthConfig{})
+		})
+
+		it("runs the helper configured for the registry", func() {
+			writeHelper("custom", `echo '{"ServerURL":"'$server'","Username":"custom-user","Secret":"custom-pass"}'`)
+			keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithoutDockerConfig(), auth.WithCredentialHelper("other.example.com", "docker-credential-custom"))
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, resolve(keychain, "other.example.com/some-repo"), &authn.AuthConfig{Username: "custom-user", Password: "custom-pass"})
+		})
+
+		it("accesses the registry anonymously if the helper has no credentials", func() {
+			writeHelper("ecr-login", `echo "credentials not found in native keychain"; exit 1`)
+			keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithoutDockerConfig(), auth.WithCredentialHelpers())
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, resolve(keychain, "123456789012.dkr.ecr.us-east-1.amazonaws.com/some-repo"), &authn.AuthConfig{})
+		})
+
+		it("prefers the Docker config over the helpers", func() {
+			writeHelper("custom", `echo '{"ServerURL":"'$server'","Username":"custom-user","Secret":"custom-pass"}'`)
+			keychain, err := auth.ResolveKeychain(auth.WithEnvVar(envVar), auth.WithDockerConfigDir(tmpDir), auth.WithCredentialHelper("config.example.com", "custom"))
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, resolve(keychain, "config.example.com/some-repo"), &authn.AuthConfig{Username: "config-user", Password: "config-pass"})
+		})
+	})
+
+	when("the environment variable is invalid", func() {
+		it("fails for invalid JSON", func() {
+			h.AssertNil(t, os.Setenv(envVar, `{not-json`))
+			_, err := auth.ResolveKeychain(auth.WithEnvVar(envVar))
+			h.AssertError(t, err, "failed to parse "+envVar)
+		})
+
+		it("fails for an unsupported scheme", func() {
+			h.AssertNil(t, os.Setenv(envVar, `{"config.example.com": "Digest some-value"}`))
+			_, err := auth.ResolveKeychain(auth.WithEnvVar(envVar))
+			h.AssertError(t, err, `unsupported authorization scheme "Digest"`)
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// dockerConfigKeychain resolves credentials from the Docker config.json in the given directory
+// (or DOCKER_CONFIG or ~/.docker if it is empty), including the credential helpers it configures.
+type dockerConfigKeychain struct {
+	dir string
+}
+
+func (k *dockerConfigKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if k.dir == "" {
+		return authn.DefaultKeychain.Resolve(target)
+	}
+	cf, err := config.Load(k.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the credentials of Docker Hub are stored with a legacy key
+	var cfg, empty types.AuthConfig
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+		if cfg, err = cf.GetAuthConfig(key); err != nil {
+			return nil, err
+		}
+		cfg.ServerAddress = "" // set by GetAuthConfig
+		if cfg != empty {
+			break
+		}
+	}
+	if cfg == empty {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	}), nil
+}
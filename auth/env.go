@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// newEnvKeychain returns a keychain with the credentials held by the given environment variable,
+// which maps registries to the value of an Authorization header (`Basic <base64 of username:password>` or `Bearer <token>`).
+func newEnvKeychain(envVar string) (staticKeychain, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return staticKeychain{}, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(value), &headers); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", envVar, err)
+	}
+	keychain := staticKeychain{}
+	for registry, header := range headers {
+		key, err := registryKey(registry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", envVar, err)
+		}
+		if keychain[key], err = authenticatorFromHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to parse %s credentials for registry %q: %w", envVar, registry, err)
+		}
+	}
+	return keychain, nil
+}
+
+func authenticatorFromHeader(header string) (authn.Authenticator, error) {
+	scheme, value, found := strings.Cut(header, " ")
+	if !found {
+		return nil, fmt.Errorf("expected `Basic <credentials>` or `Bearer <token>`")
+	}
+	switch strings.ToLower(scheme) {
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode basic credentials: %w", err)
+		}
+		username, password, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return nil, fmt.Errorf("expected basic credentials of the form `username:password`")
+		}
+		return &authn.Basic{Username: username, Password: password}, nil
+	case "bearer":
+		return &authn.Bearer{Token: value}, nil
+	default:
+		return nil, fmt.Errorf("unsupported authorization scheme %q", scheme)
+	}
+}
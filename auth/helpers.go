@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// helperPrefix is the prefix of the executables implementing the Docker credential helper protocol.
+const helperPrefix = "docker-credential-"
+
+// knownHelpers maps the registries of cloud providers to the credential helpers serving them, in order of preference.
+var knownHelpers = []struct {
+	registry *regexp.Regexp
+	helpers  []string
+}{
+	{regexp.MustCompile(`^\d+\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`), []string{"ecr-login"}},
+	{regexp.MustCompile(`^([a-z0-9-]+\.)?gcr\.io$|^[a-z0-9-]+-docker\.pkg\.dev$`), []string{"gcr", "gcloud"}},
+	{regexp.MustCompile(`^[a-z0-9-]+\.azurecr\.(io|cn|us)$`), []string{"acr-env", "acr"}},
+}
+
+// helperKeychain resolves credentials by running the credential helper installed on PATH for the registry:
+// either the one configured explicitly for it, or the one known to serve the registries of its cloud provider.
+type helperKeychain struct {
+	helpers map[string]string
+}
+
+func newHelperKeychain(helpers map[string]string) (*helperKeychain, error) {
+	keychain := &helperKeychain{helpers: map[string]string{}}
+	for registry, helper := range helpers {
+		key, err := registryKey(registry)
+		if err != nil {
+			return nil, err
+		}
+		keychain.helpers[key] = strings.TrimPrefix(helper, helperPrefix)
+	}
+	return keychain, nil
+}
+
+func (k *helperKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	program, ok := k.find(registry)
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	creds, err := client.Get(client.NewShellProgramFunc(program), registry)
+	if err != nil {
+		if credentials.IsErrCredentialsNotFound(err) {
+			return authn.Anonymous, nil
+		}
+		return nil, fmt.Errorf("failed to get credentials for registry %q from %s: %w", registry, program, err)
+	}
+	// credential helpers return identity tokens with a fixed username
+	if creds.Username == "<token>" {
+		return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+}
+
+// find returns the path of the credential helper for the given registry, if one is installed.
+func (k *helperKeychain) find(registry string) (string, bool) {
+	if helper, ok := k.helpers[registry]; ok {
+		path, err := exec.LookPath(helperPrefix + helper)
+		return path, err == nil
+	}
+	for _, known := range knownHelpers {
+		if !known.registry.MatchString(registry) {
+			continue
+		}
+		for _, helper := range known.helpers {
+			if path, err := exec.LookPath(helperPrefix + helper); err == nil {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
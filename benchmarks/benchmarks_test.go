@@ -0,0 +1,316 @@
+package benchmarks_test
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layer"
+	"github.com/buildpacks/imgutil/layout"
+	"github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+// fixture describes the synthetic layers of a benchmark image.
+type fixture struct {
+	name      string
+	layers    int
+	layerSize int64
+	files     int
+	// seed generates the content of the first layer, and is incremented for the next ones
+	seed int64
+}
+
+var (
+	fixtures = []fixture{
+		{name: "many-small-layers", layers: 100, layerSize: 16 * 1024, files: 4},
+		{name: "few-huge-layers", layers: 2, layerSize: 64 * 1024 * 1024, files: 1},
+	}
+	appFixture = fixture{name: "app", layers: 1, layerSize: 16 * 1024, files: 4, seed: 1000}
+)
+
+// withNewContent returns a fixture with the same shape as f, and layers of different content.
+func (f fixture) withNewContent() fixture {
+	f.name += "-new"
+	f.seed += 2000
+	return f
+}
+
+var (
+	fixturesDir  string
+	fixturesOnce sync.Once
+	layerPaths   = map[string][]string{}
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if fixturesDir != "" {
+		_ = os.RemoveAll(fixturesDir)
+	}
+	os.Exit(code)
+}
+
+// layersOf returns the paths of the layer tarballs of the fixture, which are written once and shared by every benchmark.
+func layersOf(b *testing.B, f fixture) []string {
+	b.Helper()
+	fixturesOnce.Do(func() {
+		var err error
+		if fixturesDir, err = os.MkdirTemp("", "imgutil-benchmarks"); err != nil {
+			b.Fatal(err)
+		}
+	})
+	if paths, ok := layerPaths[f.name]; ok {
+		return paths
+	}
+	var paths []string
+	for i := 0; i < f.layers; i++ {
+		path := filepath.Join(fixturesDir, fmt.Sprintf("%s-%d.tar", f.name, i))
+		if err := writeLayer(path, f.seed+int64(i), f.layerSize, f.files); err != nil {
+			b.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	layerPaths[f.name] = paths
+	return paths
+}
+
+// writeLayer writes a tar layer with the given number of files of random content, generated from seed.
+func writeLayer(path string, seed, size int64, files int) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	tw := tar.NewWriter(fh)
+	rnd := rand.New(rand.NewSource(seed)) // #nosec G404
+	for i := 0; i < files; i++ {
+		fileSize := size / int64(files)
+		if err = tw.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf("/layer-%d/file-%d", seed, i),
+			Mode:     0644,
+			Size:     fileSize,
+			ModTime:  layer.NormalizedModTime,
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return err
+		}
+		if _, err = io.CopyN(tw, rnd, fileSize); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// backend creates images of one kind.
+type backend struct {
+	name string
+	// repoName returns the name of the image with the given short name, e.g. a layout path.
+	repoName func(name string) string
+	newImage func(repoName string, ops ...imgutil.ImageOption) (imgutil.Image, error)
+	fromBase func(repoName string) func(*imgutil.ImageOptions)
+}
+
+// backends returns the backends to benchmark, skipping the local backend when no docker daemon is reachable.
+func backends(b *testing.B) []backend {
+	b.Helper()
+	dir := b.TempDir()
+	registry := h.NewFakeRegistry()
+	b.Cleanup(registry.Close)
+
+	result := []backend{
+		{
+			name:     "layout",
+			repoName: func(name string) string { return filepath.Join(dir, name) },
+			newImage: func(repoName string, ops ...imgutil.ImageOption) (imgutil.Image, error) {
+				return layout.NewImage(repoName, ops...)
+			},
+			fromBase: layout.FromBaseImagePath,
+		},
+		{
+			name:     "remote",
+			repoName: registry.RepoName,
+			newImage: func(repoName string, ops ...imgutil.ImageOption) (imgutil.Image, error) {
+				return remote.NewImage(repoName, authn.DefaultKeychain, ops...)
+			},
+			fromBase: remote.FromBaseImage,
+		},
+	}
+
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err == nil {
+		if _, err = dockerClient.Ping(context.Background()); err == nil {
+			var names []string
+			b.Cleanup(func() { _ = h.DockerRmi(dockerClient, names...) })
+			result = append(result, backend{
+				name: "local",
+				repoName: func(name string) string {
+					repoName := "imgutil-benchmarks/" + name
+					names = append(names, repoName)
+					return repoName
+				},
+				newImage: func(repoName string, ops ...imgutil.ImageOption) (imgutil.Image, error) {
+					return local.NewImage(repoName, dockerClient, ops...)
+				},
+				fromBase: local.FromBaseImage,
+			})
+		}
+	}
+	return result
+}
+
+// saveFixture saves an image with the layers of the fixture, and returns its name.
+func saveFixture(b *testing.B, be backend, name string, f fixture) string {
+	b.Helper()
+	repoName := be.repoName(name)
+	img, err := be.newImage(repoName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, path := range layersOf(b, f) {
+		if err = img.AddLayer(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err = img.Save(); err != nil {
+		b.Fatal(err)
+	}
+	return repoName
+}
+
+// run runs fn as a sub-benchmark for each backend and fixture.
+func run(b *testing.B, fn func(b *testing.B, be backend, f fixture)) {
+	for _, be := range backends(b) {
+		be := be
+		for _, f := range fixtures {
+			f := f
+			b.Run(be.name+"/"+f.name, func(b *testing.B) {
+				fn(b, be, f)
+			})
+		}
+	}
+}
+
+func BenchmarkAddLayer(b *testing.B) {
+	run(b, func(b *testing.B, be backend, f fixture) {
+		paths := layersOf(b, f)
+		repoName := be.repoName("add-layer-" + f.name)
+		b.SetBytes(int64(f.layers) * f.layerSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			img, err := be.newImage(repoName)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, path := range paths {
+				if err = img.AddLayer(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkSave(b *testing.B) {
+	run(b, func(b *testing.B, be backend, f fixture) {
+		paths := layersOf(b, f)
+		b.SetBytes(int64(f.layers) * f.layerSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// every iteration saves to a new image, so that no blob is reused
+			b.StopTimer()
+			img, err := be.newImage(be.repoName(fmt.Sprintf("save-%s-%d", f.name, i)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, path := range paths {
+				if err = img.AddLayer(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StartTimer()
+			if err = img.Save(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPull(b *testing.B) {
+	run(b, func(b *testing.B, be backend, f fixture) {
+		repoName := saveFixture(b, be, "pull-"+f.name, f)
+		b.SetBytes(int64(f.layers) * f.layerSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			img, err := be.newImage(repoName, be.fromBase(repoName))
+			if err != nil {
+				b.Fatal(err)
+			}
+			layers, err := img.UnderlyingImage().Layers()
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, l := range layers {
+				rc, err := l.Uncompressed()
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = io.Copy(io.Discard, rc)
+				rc.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkRebase(b *testing.B) {
+	run(b, func(b *testing.B, be backend, f fixture) {
+		baseName := saveFixture(b, be, "rebase-base-"+f.name, f)
+		newBaseName := saveFixture(b, be, "rebase-new-base-"+f.name, f.withNewContent())
+		appName := be.repoName("rebase-app-" + f.name)
+		app, err := be.newImage(appName, be.fromBase(baseName))
+		if err != nil {
+			b.Fatal(err)
+		}
+		baseTopLayer, err := app.TopLayer()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = app.AddLayer(layersOf(b, appFixture)[0]); err != nil {
+			b.Fatal(err)
+		}
+		if err = app.Save(); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			img, err := be.newImage(appName, be.fromBase(appName))
+			if err != nil {
+				b.Fatal(err)
+			}
+			newBase, err := be.newImage(newBaseName, be.fromBase(newBaseName))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err = img.Rebase(baseTopLayer, newBase); err != nil {
+				b.Fatal(err)
+			}
+			if err = img.Save(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,10 @@
+// Package benchmarks measures saving, pulling, rebasing, and adding layers to images across backends,
+// with synthetic fixtures of many small layers and of few huge layers, so that performance-motivated changes
+// (e.g. parallel pushes or caching) can be validated and regressions caught:
+//
+//	make bench  # or: go test -run ^$ -bench . -benchmem ./benchmarks
+//
+// Remote images are saved to an in-memory registry (see testhelpers.NewFakeRegistry), and local images to the docker daemon
+// when one is reachable; benchmarks of the local backend are skipped otherwise.
+// Compare runs with benchstat (golang.org/x/perf/cmd/benchstat).
+package benchmarks
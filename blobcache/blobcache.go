@@ -0,0 +1,230 @@
+// Package blobcache provides a content-addressed on-disk cache for layer blobs
+// that can be shared by the local, remote, and layout image implementations (see imgutil.WithBlobCache).
+package blobcache
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+const tmpPrefix = "tmp-"
+
+// Cache is a content-addressed on-disk cache of layer blobs.
+// Compressed blobs are stored by digest and uncompressed blobs by diff ID.
+// When the total size of the cached blobs exceeds the maximum size, the least recently used blobs are evicted.
+// Cache implements cache.Cache from go-containerregistry.
+type Cache struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+}
+
+var _ cache.Cache = &Cache{}
+
+// New returns a cache storing blobs in the given directory, which is created if it doesn't exist.
+// If maxSize is not positive, the cache is unbounded.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, maxSize: maxSize}, nil
+}
+
+// Put returns a layer that writes its blobs to the cache as they are read.
+// A blob is only added to the cache once it was read completely and its hash was verified.
+func (c *Cache) Put(l v1.Layer) (v1.Layer, error) {
+	digest, err := l.Digest()
+	if err != nil {
+		return nil, err
+	}
+	diffID, err := l.DiffID()
+	if err != nil {
+		return nil, err
+	}
+	return &cachingLayer{Layer: l, cache: c, digest: digest, diffID: diffID}, nil
+}
+
+// Get returns the layer cached by the given digest or diff ID, or cache.ErrNotFound.
+func (c *Cache) Get(h v1.Hash) (v1.Layer, error) {
+	path := c.path(h)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil { // mark as recently used
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, cache.ErrNotFound
+		}
+		return nil, err
+	}
+	return tarball.LayerFromFile(path)
+}
+
+// Delete removes the blob with the given digest or diff ID from the cache.
+func (c *Cache) Delete(h v1.Hash) error {
+	err := os.Remove(c.path(h))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache.ErrNotFound
+	}
+	return err
+}
+
+// Size returns the total size in bytes of the cached blobs.
+func (c *Cache) Size() (int64, error) {
+	blobs, err := c.blobs()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, blob := range blobs {
+		size += blob.Size()
+	}
+	return size, nil
+}
+
+// Evict removes the least recently used blobs until the cache is within its maximum size.
+func (c *Cache) Evict() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blobs, err := c.blobs()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, blob := range blobs {
+		size += blob.Size()
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].ModTime().Before(blobs[j].ModTime())
+	})
+	for _, blob := range blobs {
+		if size <= c.maxSize {
+			break
+		}
+		if err = os.Remove(filepath.Join(c.dir, blob.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to evict blob %s: %w", blob.Name(), err)
+		}
+		size -= blob.Size()
+	}
+	return nil
+}
+
+func (c *Cache) blobs() ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	var blobs []fs.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tmpPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) { // removed concurrently
+				continue
+			}
+			return nil, err
+		}
+		blobs = append(blobs, info)
+	}
+	return blobs, nil
+}
+
+func (c *Cache) path(h v1.Hash) string {
+	return filepath.Join(c.dir, h.Algorithm+"-"+h.Hex)
+}
+
+type cachingLayer struct {
+	v1.Layer
+	cache          *Cache
+	digest, diffID v1.Hash
+}
+
+func (l *cachingLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return l.cache.tee(rc, l.digest)
+}
+
+func (l *cachingLayer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return l.cache.tee(rc, l.diffID)
+}
+
+// tee returns a reader that writes the blob with the given hash to the cache as it is read.
+// Blobs whose hash is unknown (e.g. the digest of layers extracted from a daemon) are not cached.
+func (c *Cache) tee(rc io.ReadCloser, h v1.Hash) (io.ReadCloser, error) {
+	if h.Algorithm != "sha256" {
+		return rc, nil
+	}
+	f, err := os.CreateTemp(c.dir, tmpPrefix)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return &teeReadCloser{source: rc, tmp: f, hasher: sha256.New(), cache: c, hash: h}, nil
+}
+
+type teeReadCloser struct {
+	source io.ReadCloser
+	tmp    *os.File
+	hasher hash.Hash
+	cache  *Cache
+	hash   v1.Hash
+	err    error
+	done   bool
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.source.Read(p)
+	if n > 0 && t.err == nil {
+		if _, t.err = t.tmp.Write(p[:n]); t.err == nil {
+			t.hasher.Write(p[:n])
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		t.done = true
+	}
+	return n, err
+}
+
+func (t *teeReadCloser) Close() error {
+	sourceErr := t.source.Close()
+	if err := t.tmp.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	complete := t.done && t.err == nil && fmt.Sprintf("%x", t.hasher.Sum(nil)) == t.hash.Hex
+	if !complete {
+		os.Remove(t.tmp.Name())
+		return sourceErr
+	}
+	if err := os.Rename(t.tmp.Name(), t.cache.path(t.hash)); err != nil {
+		os.Remove(t.tmp.Name())
+		return sourceErr
+	}
+	if err := t.cache.Evict(); err != nil {
+		return err
+	}
+	return sourceErr
+}
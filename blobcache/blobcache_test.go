@@ -0,0 +1,276 @@
+package blobcache_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/blobcache"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestBlobCache(t *testing.T) {
+	spec.Run(t, "BlobCache", testBlobCache, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testBlobCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir   string
+		cacheDir string
+		err      error
+	)
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "blobcache")
+		h.AssertNil(t, err)
+		cacheDir = filepath.Join(tmpDir, "cache")
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	readAll := func(layer v1.Layer, uncompressed bool) {
+		t.Helper()
+		var rc io.ReadCloser
+		if uncompressed {
+			rc, err = layer.Uncompressed()
+		} else {
+			rc, err = layer.Compressed()
+		}
+		h.AssertNil(t, err)
+		_, err = io.ReadAll(rc)
+		h.AssertNil(t, err)
+		h.AssertNil(t, rc.Close())
+	}
+
+	when("#Put", func() {
+		it("caches blobs once they are read", func() {
+			c, err := blobcache.New(cacheDir, 0)
+			h.AssertNil(t, err)
+			layer, err := random.Layer(1024, types.OCILayer)
+			h.AssertNil(t, err)
+			digest, err := layer.Digest()
+			h.AssertNil(t, err)
+			diffID, err := layer.DiffID()
+			h.AssertNil(t, err)
+
+			cachingLayer, err := c.Put(layer)
+			h.AssertNil(t, err)
+			_, err = c.Get(digest)
+			h.AssertEq(t, errors.Is(err, cache.ErrNotFound), true)
+
+			readAll(cachingLayer, false)
+			readAll(cachingLayer, true)
+
+			cached, err := c.Get(digest)
+			h.AssertNil(t, err)
+			cachedDigest, err := cached.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, cachedDigest, digest)
+			cached, err = c.Get(diffID)
+			h.AssertNil(t, err)
+			cachedDiffID, err := cached.DiffID()
+			h.AssertNil(t, err)
+			h.AssertEq(t, cachedDiffID, diffID)
+		})
+
+		it("does not cache partially read blobs", func() {
+			c, err := blobcache.New(cacheDir, 0)
+			h.AssertNil(t, err)
+			layer, err := random.Layer(1024, types.OCILayer)
+			h.AssertNil(t, err)
+			digest, err := layer.Digest()
+			h.AssertNil(t, err)
+
+			cachingLayer, err := c.Put(layer)
+			h.AssertNil(t, err)
+			rc, err := cachingLayer.Compressed()
+			h.AssertNil(t, err)
+			_, err = rc.Read(make([]byte, 10))
+			h.AssertNil(t, err)
+			h.AssertNil(t, rc.Close())
+
+			_, err = c.Get(digest)
+			h.AssertEq(t, errors.Is(err, cache.ErrNotFound), true)
+			size, err := c.Size()
+			h.AssertNil(t, err)
+			h.AssertEq(t, size, int64(0))
+		})
+	})
+
+	when("#Evict", func() {
+		it("removes the least recently used blobs when the cache is full", func() {
+			var layers []v1.Layer
+			var sizes []int64
+			for i := 0; i < 3; i++ {
+				layer, err := random.Layer(1024, types.OCILayer)
+				h.AssertNil(t, err)
+				size, err := layer.Size()
+				h.AssertNil(t, err)
+				layers = append(layers, layer)
+				sizes = append(sizes, size)
+			}
+			c, err := blobcache.New(cacheDir, sizes[0]+sizes[1]+sizes[2]-1)
+			h.AssertNil(t, err)
+
+			for _, layer := range layers[:2] {
+				cachingLayer, err := c.Put(layer)
+				h.AssertNil(t, err)
+				readAll(cachingLayer, false)
+				time.Sleep(10 * time.Millisecond)
+			}
+			// use the first layer, so that the second one is the least recently used
+			digest0, err := layers[0].Digest()
+			h.AssertNil(t, err)
+			_, err = c.Get(digest0)
+			h.AssertNil(t, err)
+			time.Sleep(10 * time.Millisecond)
+
+			cachingLayer, err := c.Put(layers[2])
+			h.AssertNil(t, err)
+			readAll(cachingLayer, false)
+
+			digest1, err := layers[1].Digest()
+			h.AssertNil(t, err)
+			_, err = c.Get(digest1)
+			h.AssertEq(t, errors.Is(err, cache.ErrNotFound), true)
+			_, err = c.Get(digest0)
+			h.AssertNil(t, err)
+			size, err := c.Size()
+			h.AssertNil(t, err)
+			h.AssertEq(t, size, sizes[0]+sizes[2])
+		})
+	})
+
+	when("used with imgutil.WithBlobCache", func() {
+		it("serves the layers of the base image from the cache", func() {
+			c, err := blobcache.New(cacheDir, 0)
+			h.AssertNil(t, err)
+			basePath := filepath.Join(tmpDir, "base-image")
+			base, err := layout.NewImage(basePath)
+			h.AssertNil(t, err)
+			layerPath, diffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, base.AddLayer(layerPath))
+			h.AssertNil(t, base.Save())
+
+			img, err := layout.NewImage(filepath.Join(tmpDir, "some-image"), layout.FromBaseImagePath(basePath), imgutil.WithBlobCache(c))
+			h.AssertNil(t, err)
+			rc, err := img.GetLayer(diffID)
+			h.AssertNil(t, err)
+			_, err = io.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertNil(t, rc.Close())
+
+			hash, err := v1.NewHash(diffID)
+			h.AssertNil(t, err)
+			_, err = c.Get(hash)
+			h.AssertNil(t, err)
+		})
+
+		it("doesn't fetch the layers of the base image again for a second build", func() {
+			c, err := blobcache.New(cacheDir, 0)
+			h.AssertNil(t, err)
+			randomImage, err := random.Image(1024, 2)
+			h.AssertNil(t, err)
+			base := &countingImage{Image: randomImage}
+			configFile, err := base.ConfigFile()
+			h.AssertNil(t, err)
+			diffID := configFile.RootFS.DiffIDs[0].String()
+
+			build := func(name string) {
+				img, err := layout.NewImage(filepath.Join(tmpDir, name), imgutil.FromBaseImageInstance(base), imgutil.WithBlobCache(c))
+				h.AssertNil(t, err)
+				rc, err := img.GetLayer(diffID)
+				h.AssertNil(t, err)
+				_, err = io.ReadAll(rc)
+				h.AssertNil(t, err)
+				h.AssertNil(t, rc.Close())
+				h.AssertNil(t, img.Save())
+			}
+
+			build("first-image")
+			h.AssertEq(t, base.resetFetches() > 0, true)
+
+			build("second-image")
+			h.AssertEq(t, base.resetFetches(), 0)
+		})
+	})
+}
+
+// countingImage counts the layer blobs read from the image.
+type countingImage struct {
+	v1.Image
+	mu      sync.Mutex
+	fetches int
+}
+
+func (i *countingImage) fetched() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.fetches++
+}
+
+// resetFetches returns the number of layer blobs read since the last call.
+func (i *countingImage) resetFetches() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	fetches := i.fetches
+	i.fetches = 0
+	return fetches
+}
+
+func (i *countingImage) Layers() ([]v1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for idx, layer := range layers {
+		layers[idx] = &countingLayer{Layer: layer, image: i}
+	}
+	return layers, nil
+}
+
+func (i *countingImage) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	layer, err := i.Image.LayerByDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	return &countingLayer{Layer: layer, image: i}, nil
+}
+
+func (i *countingImage) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
+	layer, err := i.Image.LayerByDiffID(h)
+	if err != nil {
+		return nil, err
+	}
+	return &countingLayer{Layer: layer, image: i}, nil
+}
+
+type countingLayer struct {
+	v1.Layer
+	image *countingImage
+}
+
+func (l *countingLayer) Compressed() (io.ReadCloser, error) {
+	l.image.fetched()
+	return l.Layer.Compressed()
+}
+
+func (l *countingLayer) Uncompressed() (io.ReadCloser, error) {
+	l.image.fetched()
+	return l.Layer.Uncompressed()
+}
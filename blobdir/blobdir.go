@@ -0,0 +1,162 @@
+// Package blobdir reads images from directories of raw blobs, as seeded by caching tools (e.g. warm caches),
+// so that lifecycle-like consumers can use them as base or previous images fully offline.
+//
+// The directory holds the manifest of the image in a `manifest.json` file, and each blob in a file named after its digest,
+// either `<algorithm>:<hex>` or `<hex>` (e.g. `sha256:9f86d0...` or `9f86d0...`).
+// The directory can be sparse: layer blobs are only opened when their content is read, and may be missing
+// if the image is only used where its layers are not read (e.g. as the base image of an image saved to a registry holding them).
+package blobdir
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// ManifestFile is the name of the file holding the manifest of the image in the directory.
+const ManifestFile = "manifest.json"
+
+// Image is an image read from a directory of blobs.
+// Like tarball.Image, it is read-only: it implements imgutil.ReadOnlyImage to inspect the image, and v1.Image so that
+// it can be provided as the base image (see imgutil.FromBaseImageInstance) or the previous image (see imgutil.WithPreviousImageInstance)
+// of an image of any implementation.
+type Image struct {
+	v1.Image
+	imgutil.ReadOnlyImage
+}
+
+var (
+	_ imgutil.ReadOnlyImage = (*Image)(nil)
+	_ v1.Image              = (*Image)(nil)
+)
+
+// NewImage returns the image of the directory of blobs at the given path.
+// The manifest and the config are read eagerly; the layers are read lazily.
+func NewImage(dir string) (*Image, error) {
+	rawManifest, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	rawConfig, err := readBlob(dir, manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	image, err := partial.CompressedToImage(&compressedImage{dir: dir, manifest: manifest, rawManifest: rawManifest, rawConfig: rawConfig})
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		Image:         image,
+		ReadOnlyImage: imgutil.NewReadOnlyImage(dir, configFile, digest),
+	}, nil
+}
+
+// Kind returns `blobdir`.
+func (i *Image) Kind() string {
+	return "blobdir"
+}
+
+// UnderlyingImage returns the image read from the directory.
+func (i *Image) UnderlyingImage() v1.Image {
+	return i.Image
+}
+
+// blobPath returns the path of the file holding the blob with the given digest, or an error if there is no such file.
+func blobPath(dir string, digest v1.Hash) (string, error) {
+	for _, name := range []string{digest.String(), digest.Hex} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("blob %s not found in %q: %w", digest, dir, fs.ErrNotExist)
+}
+
+func readBlob(dir string, digest v1.Hash) ([]byte, error) {
+	path, err := blobPath(dir, digest)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+type compressedImage struct {
+	dir         string
+	manifest    *v1.Manifest
+	rawManifest []byte
+	rawConfig   []byte
+}
+
+func (i *compressedImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *compressedImage) MediaType() (types.MediaType, error) {
+	return i.manifest.MediaType, nil
+}
+
+func (i *compressedImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *compressedImage) LayerByDigest(digest v1.Hash) (partial.CompressedLayer, error) {
+	if digest == i.manifest.Config.Digest {
+		return &blobLayer{dir: i.dir, desc: i.manifest.Config}, nil
+	}
+	for _, desc := range i.manifest.Layers {
+		if desc.Digest == digest {
+			return &blobLayer{dir: i.dir, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("blob %s is not referenced by the manifest", digest)
+}
+
+// blobLayer is a layer whose blob file is opened when its content is read.
+type blobLayer struct {
+	dir  string
+	desc v1.Descriptor
+}
+
+func (l *blobLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *blobLayer) Compressed() (io.ReadCloser, error) {
+	path, err := blobPath(l.dir, l.desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (l *blobLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *blobLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
@@ -0,0 +1,113 @@
+package blobdir_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/blobdir"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestBlobDir(t *testing.T) {
+	spec.Run(t, "BlobDir", testBlobDir, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testBlobDir(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir  string
+		dir     string
+		image   v1.Image
+		diffIDs []string
+	)
+
+	writeBlob := func(name string, rc io.ReadCloser) {
+		defer rc.Close()
+		f, err := os.Create(filepath.Join(dir, name))
+		h.AssertNil(t, err)
+		defer f.Close()
+		_, err = io.Copy(f, rc)
+		h.AssertNil(t, err)
+	}
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "blobdir")
+		h.AssertNil(t, err)
+		dir = filepath.Join(tmpDir, "cache")
+		h.AssertNil(t, os.MkdirAll(dir, 0755))
+
+		image, err = random.Image(64, 2)
+		h.AssertNil(t, err)
+		rawManifest, err := image.RawManifest()
+		h.AssertNil(t, err)
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, blobdir.ManifestFile), rawManifest, 0600))
+		rawConfig, err := image.RawConfigFile()
+		h.AssertNil(t, err)
+		configName, err := image.ConfigName()
+		h.AssertNil(t, err)
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, configName.String()), rawConfig, 0600))
+
+		// only the top layer is in the directory
+		layers, err := image.Layers()
+		h.AssertNil(t, err)
+		digest, err := layers[1].Digest()
+		h.AssertNil(t, err)
+		rc, err := layers[1].Compressed()
+		h.AssertNil(t, err)
+		writeBlob(digest.Hex, rc)
+
+		diffIDs = nil
+		for _, l := range layers {
+			diffID, err := l.DiffID()
+			h.AssertNil(t, err)
+			diffIDs = append(diffIDs, diffID.String())
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("reads the image of the directory", func() {
+		img, err := blobdir.NewImage(dir)
+		h.AssertNil(t, err)
+		h.AssertEq(t, img.Found(), true)
+		topLayer, err := img.TopLayer()
+		h.AssertNil(t, err)
+		h.AssertEq(t, topLayer, diffIDs[1])
+		digest, err := img.Digest()
+		h.AssertNil(t, err)
+		expected, err := image.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, digest, expected)
+	})
+
+	it("reads the layers lazily", func() {
+		img, err := blobdir.NewImage(dir)
+		h.AssertNil(t, err)
+		layers, err := img.Layers()
+		h.AssertNil(t, err)
+		_, err = layers[0].Compressed()
+		h.AssertError(t, err, "not found")
+
+		next, err := layout.NewImage(filepath.Join(tmpDir, "next"), imgutil.WithPreviousImageInstance(img))
+		h.AssertNil(t, err)
+		h.AssertNil(t, next.ReuseLayer(diffIDs[1]))
+		h.AssertNil(t, next.Save())
+		h.AssertDiffIDs(t, next, diffIDs[1])
+	})
+
+	it("fails without a manifest", func() {
+		_, err := blobdir.NewImage(tmpDir)
+		h.AssertError(t, err, "failed to read manifest")
+	})
+}
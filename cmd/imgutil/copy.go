@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/buildpacks/imgutil"
+)
+
+func runCopy(args []string, stdout io.Writer) error {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return err
+	}
+	src, err := parseReference(args[0]).open()
+	if err != nil {
+		return err
+	}
+	return saveCopy(src, parseReference(args[1]), stdout)
+}
+
+// saveCopy saves a copy of img with the given reference, keeping its creation time and history,
+// and prints the identifier of the copy.
+func saveCopy(img imgutil.Image, dst reference, stdout io.Writer) error {
+	destination, err := dst.copyDestination()
+	if err != nil {
+		return err
+	}
+	createdAt, err := img.CreatedAt()
+	if err != nil {
+		return err
+	}
+	copied, err := imgutil.Copy(img, dst.kind, dst.name,
+		destination,
+		imgutil.WithCopyImageOptions(imgutil.WithCreatedAt(createdAt), imgutil.WithHistory()),
+	)
+	if err != nil {
+		return err
+	}
+	return printIdentifier(copied, stdout)
+}
+
+func printIdentifier(img imgutil.Image, stdout io.Writer) error {
+	identifier, err := img.Identifier()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, identifier.String())
+	return err
+}
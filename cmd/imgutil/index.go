@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layout"
+)
+
+func runIndex(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "create":
+		return runIndexCreate(args[1:], stdout)
+	case "annotate":
+		return runIndexAnnotate(args[1:], stdout)
+	case "push":
+		return runIndexPush(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown index command %q\n%s", args[0], usage)
+	}
+}
+
+// runIndexCreate writes an OCI layout at the given path whose index references the given images,
+// with the descriptors returned by imgutil.DescriptorFor.
+func runIndexCreate(args []string, stdout io.Writer) error {
+	if err := checkArgs(args, 2, -1); err != nil {
+		return err
+	}
+	var additions []mutate.IndexAddendum
+	for _, ref := range args[1:] {
+		img, err := parseReference(ref).open()
+		if err != nil {
+			return err
+		}
+		desc, err := imgutil.DescriptorFor(img)
+		if err != nil {
+			return err
+		}
+		additions = append(additions, mutate.IndexAddendum{
+			Add: img.UnderlyingImage(),
+			Descriptor: v1.Descriptor{
+				Platform:    desc.Platform,
+				Annotations: desc.Annotations,
+				URLs:        desc.URLs,
+			},
+		})
+	}
+	index := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), additions...)
+	if err := imgutil.ValidateIndex(index); err != nil {
+		return err
+	}
+	return writeIndex(args[0], index, stdout)
+}
+
+// runIndexAnnotate adds annotations to the index of the OCI layout at the given path,
+// or to the descriptor of the manifest with the given digest.
+func runIndexAnnotate(args []string, stdout io.Writer) error {
+	if err := checkArgs(args, 2, -1); err != nil {
+		return err
+	}
+	path, pairs := args[0], args[1:]
+	var digest string
+	if !strings.Contains(pairs[0], "=") {
+		digest, pairs = pairs[0], pairs[1:]
+	}
+	annotations, err := parseAnnotations(pairs)
+	if err != nil {
+		return err
+	}
+	if _, err = readIndex(path); err != nil {
+		return err
+	}
+	index, err := layout.NewIndex(path)
+	if err != nil {
+		return err
+	}
+	if digest == "" {
+		err = index.Annotate(annotations)
+	} else {
+		var hash v1.Hash
+		if hash, err = v1.NewHash(digest); err != nil {
+			return err
+		}
+		err = index.AnnotateManifest(hash, annotations)
+	}
+	if err != nil {
+		return err
+	}
+	saved, err := index.Save()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, saved.String())
+	return err
+}
+
+// runIndexPush pushes the index of the OCI layout at the given path, and the manifests it references, to a registry.
+func runIndexPush(args []string, stdout io.Writer) error {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return err
+	}
+	index, err := readIndex(args[0])
+	if err != nil {
+		return err
+	}
+	ref, err := name.ParseReference(args[1], name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	keychain, err := newKeychain()
+	if err != nil {
+		return err
+	}
+	if err = ggcrremote.WriteIndex(ref, index, ggcrremote.WithAuthFromKeychain(keychain)); err != nil {
+		return fmt.Errorf("pushing index to %s: %w", ref, err)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, ref.Context().Digest(digest.String()).String())
+	return err
+}
+
+func parseAnnotations(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New(usage)
+	}
+	annotations := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid annotation %q, expected KEY=VALUE", pair)
+		}
+		annotations[key] = val
+	}
+	return annotations, nil
+}
+
+func readIndex(path string) (v1.ImageIndex, error) {
+	layoutPath, err := layout.FromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading layout %s: %w", path, err)
+	}
+	return layoutPath.ImageIndex()
+}
+
+// writeIndex writes the index as the index of the OCI layout at the given path, and prints its digest.
+func writeIndex(path string, index v1.ImageIndex, stdout io.Writer) error {
+	if _, err := layout.Write(path, index); err != nil {
+		return fmt.Errorf("writing layout %s: %w", path, err)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, digest.String())
+	return err
+}
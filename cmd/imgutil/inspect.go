@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
+)
+
+type inspectOutput struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Descriptor is the descriptor that an image index entry referencing the image would have (see imgutil.DescriptorFor).
+	Descriptor v1.Descriptor     `json:"descriptor"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Layers     []inspectLayer    `json:"layers"`
+}
+
+type inspectLayer struct {
+	DiffID    string          `json:"diffID"`
+	Digest    string          `json:"digest"`
+	MediaType types.MediaType `json:"mediaType"`
+	Size      int64           `json:"size"`
+}
+
+func runInspect(args []string, stdout io.Writer) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+	img, err := parseReference(args[0]).open()
+	if err != nil {
+		return err
+	}
+	output, err := inspect(img)
+	if err != nil {
+		return fmt.Errorf("inspecting %s: %w", img.Name(), err)
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+func inspect(img imgutil.Image) (inspectOutput, error) {
+	desc, err := imgutil.DescriptorFor(img)
+	if err != nil {
+		return inspectOutput{}, err
+	}
+	underlying := img.UnderlyingImage()
+	configFile, err := underlying.ConfigFile()
+	if err != nil {
+		return inspectOutput{}, err
+	}
+	layers, err := underlying.Layers()
+	if err != nil {
+		return inspectOutput{}, err
+	}
+	output := inspectOutput{
+		Name:       img.Name(),
+		Kind:       img.Kind(),
+		Descriptor: desc,
+		Labels:     configFile.Config.Labels,
+		Layers:     make([]inspectLayer, 0, len(layers)),
+	}
+	for _, l := range layers {
+		diffID, err := l.DiffID()
+		if err != nil {
+			return inspectOutput{}, err
+		}
+		layerDigest, err := l.Digest()
+		if err != nil {
+			return inspectOutput{}, err
+		}
+		layerType, err := l.MediaType()
+		if err != nil {
+			return inspectOutput{}, err
+		}
+		size, err := l.Size()
+		if err != nil {
+			return inspectOutput{}, err
+		}
+		output.Layers = append(output.Layers, inspectLayer{
+			DiffID:    diffID.String(),
+			Digest:    layerDigest.String(),
+			MediaType: layerType,
+			Size:      size,
+		})
+	}
+	return output, nil
+}
@@ -0,0 +1,55 @@
+// Command imgutil inspects, copies, and rebases images, and creates, annotates, and pushes image indexes
+// with the imgutil library, e.g. to reproduce issues outside of the tools built on it.
+//
+// Images are referenced as <kind>:<name>, where kind is `remote` (the default, e.g. `registry.example.com/app:latest`),
+// `layout` (the path of an OCI layout), `local` (an image of the docker daemon), `tarball` (the path of a `docker save` tarball),
+// or `blobdir` (a directory of blobs, see the blobdir package). Tarballs and blob directories are only read.
+// Registry credentials are resolved from the environment, the docker config, and credential helpers (see auth.ResolveKeychain).
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage:
+  imgutil inspect IMAGE
+  imgutil copy SRC DST
+  imgutil rebase -base-top-layer DIFF_ID [-o DST] IMAGE NEW_BASE
+  imgutil index create LAYOUT IMAGE...
+  imgutil index annotate LAYOUT [DIGEST] KEY=VALUE...
+  imgutil index push LAYOUT REPO_NAME`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "imgutil:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "inspect":
+		return runInspect(args[1:], stdout)
+	case "copy":
+		return runCopy(args[1:], stdout)
+	case "rebase":
+		return runRebase(args[1:], stdout)
+	case "index":
+		return runIndex(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func checkArgs(args []string, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return errors.New(usage)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestCLI(t *testing.T) {
+	spec.Run(t, "CLI", testCLI, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testCLI(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir     string
+		basePath   string
+		baseDiffID string
+		appPath    string
+		appDiffID  string
+		runCLI     = func(args ...string) string {
+			var stdout bytes.Buffer
+			h.AssertNil(t, run(args, &stdout))
+			return strings.TrimSpace(stdout.String())
+		}
+		saveImage = func(path, basePath string) string {
+			var (
+				img *layout.Image
+				err error
+			)
+			if basePath == "" {
+				img, err = layout.NewImage(path)
+			} else {
+				img, err = layout.NewImage(path, layout.FromBaseImagePath(basePath))
+			}
+			h.AssertNil(t, err)
+			layerPath, diffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.SetLabel("some-label", filepath.Base(path)))
+			h.AssertNil(t, img.Save())
+			return diffID
+		}
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "imgutil-cli")
+		h.AssertNil(t, err)
+		basePath = filepath.Join(tmpDir, "base")
+		baseDiffID = saveImage(basePath, "")
+		appPath = filepath.Join(tmpDir, "app")
+		appDiffID = saveImage(appPath, basePath)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	when("#parseReference", func() {
+		it("defaults to remote images", func() {
+			h.AssertEq(t, parseReference("localhost:5000/app:latest").String(), "remote:localhost:5000/app:latest")
+			h.AssertEq(t, parseReference("layout:/tmp/app").String(), "layout:/tmp/app")
+			h.AssertEq(t, parseReference("local:app").String(), "local:app")
+		})
+	})
+
+	when("inspect", func() {
+		it("prints the labels, platform, and layers of the image", func() {
+			var output inspectOutput
+			h.AssertNil(t, json.Unmarshal([]byte(runCLI("inspect", "layout:"+appPath)), &output))
+
+			h.AssertEq(t, output.Kind, "layout")
+			h.AssertEq(t, output.Labels["some-label"], "app")
+			h.AssertEq(t, output.Descriptor.Platform.OS, "linux")
+			h.AssertEq(t, output.Descriptor.Platform.Architecture, "amd64")
+			h.AssertEq(t, len(output.Layers), 2)
+			h.AssertEq(t, output.Layers[0].DiffID, baseDiffID)
+			h.AssertEq(t, output.Layers[1].DiffID, appDiffID)
+		})
+
+		it("fails for missing images", func() {
+			h.AssertError(t, run([]string{"inspect", "layout:" + filepath.Join(tmpDir, "missing")}, &bytes.Buffer{}), "not found")
+		})
+	})
+
+	when("copy", func() {
+		it("copies the image between backends, keeping its digest", func() {
+			copyPath := filepath.Join(tmpDir, "copy")
+			runCLI("copy", "layout:"+appPath, "layout:"+copyPath)
+
+			original, err := layout.NewReadOnlyImage(appPath)
+			h.AssertNil(t, err)
+			copied, err := layout.NewReadOnlyImage(copyPath)
+			h.AssertNil(t, err)
+			originalID, err := original.Identifier()
+			h.AssertNil(t, err)
+			copiedID, err := copied.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, strings.SplitN(copiedID.String(), "@", 2)[1], strings.SplitN(originalID.String(), "@", 2)[1])
+		})
+
+		it("fails for read-only destinations", func() {
+			h.AssertError(t, run([]string{"copy", "layout:" + appPath, "tarball:" + filepath.Join(tmpDir, "app.tar")}, &bytes.Buffer{}), "can't save tarball images")
+		})
+	})
+
+	when("rebase", func() {
+		it("rebases the image onto the new base", func() {
+			newBasePath := filepath.Join(tmpDir, "new-base")
+			newBaseDiffID := saveImage(newBasePath, "")
+			rebasedPath := filepath.Join(tmpDir, "rebased")
+
+			runCLI("rebase", "-base-top-layer", baseDiffID, "-o", "layout:"+rebasedPath, "layout:"+appPath, "layout:"+newBasePath)
+
+			rebased, err := layout.NewImage(rebasedPath, layout.FromBaseImagePath(rebasedPath))
+			h.AssertNil(t, err)
+			h.AssertDiffIDs(t, rebased.UnderlyingImage(), newBaseDiffID, appDiffID)
+		})
+
+		it("requires the top layer of the base image", func() {
+			h.AssertError(t, run([]string{"rebase", "layout:" + appPath, "layout:" + basePath}, &bytes.Buffer{}), "-base-top-layer is required")
+		})
+	})
+
+	when("index", func() {
+		var indexPath string
+
+		it.Before(func() {
+			indexPath = filepath.Join(tmpDir, "index")
+			runCLI("index", "create", indexPath, "layout:"+appPath)
+		})
+
+		it("creates an index referencing the images", func() {
+			indexManifest := h.ReadIndexManifest(t, indexPath)
+			h.AssertEq(t, len(indexManifest.Manifests), 1)
+			h.AssertEq(t, indexManifest.Manifests[0].Platform.OS, "linux")
+		})
+
+		it("annotates the index and its descriptors", func() {
+			digest := h.ReadIndexManifest(t, indexPath).Manifests[0].Digest.String()
+			runCLI("index", "annotate", indexPath, "some-key=some-value")
+			runCLI("index", "annotate", indexPath, digest, "other-key=other-value")
+
+			indexManifest := h.ReadIndexManifest(t, indexPath)
+			h.AssertEq(t, indexManifest.Annotations["some-key"], "some-value")
+			h.AssertEq(t, indexManifest.Manifests[0].Annotations["other-key"], "other-value")
+		})
+
+		it("pushes the index to a registry", func() {
+			registry := h.NewFakeRegistry()
+			defer registry.Close()
+			repoName := registry.RepoName("some-index:latest")
+
+			digestRef := runCLI("index", "push", indexPath, repoName)
+
+			ref, err := name.ParseReference(repoName, name.WeakValidation)
+			h.AssertNil(t, err)
+			pushed, err := ggcrremote.Index(ref)
+			h.AssertNil(t, err)
+			digest, err := pushed.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, digestRef, ref.Context().Digest(digest.String()).String())
+		})
+	})
+
+	it("fails for unknown commands", func() {
+		h.AssertError(t, run([]string{"some-command"}, &bytes.Buffer{}), `unknown command "some-command"`)
+	})
+}
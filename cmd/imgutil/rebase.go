@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+
+	"github.com/buildpacks/imgutil"
+)
+
+func runRebase(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("rebase", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	baseTopLayer := flags.String("base-top-layer", "", "diff ID of the top layer of the current base image")
+	output := flags.String("o", "", "image to save the rebased image as, instead of replacing IMAGE")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkArgs(flags.Args(), 2, 2); err != nil {
+		return err
+	}
+	if *baseTopLayer == "" {
+		return errors.New("-base-top-layer is required")
+	}
+
+	ref := parseReference(flags.Arg(0))
+	img, err := openPreservingCreatedAt(ref)
+	if err != nil {
+		return err
+	}
+	newBase, err := parseReference(flags.Arg(1)).open()
+	if err != nil {
+		return err
+	}
+	if err = img.Rebase(*baseTopLayer, newBase); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		return saveCopy(img, parseReference(*output), stdout)
+	}
+	if ref.kind == kindTarball || ref.kind == kindBlobdir {
+		return errors.New("can't save " + ref.kind + " images, use -o")
+	}
+	if err = img.Save(); err != nil {
+		return err
+	}
+	return printIdentifier(img, stdout)
+}
+
+// openPreservingCreatedAt opens the image with the given reference so that saving it keeps its creation time and history,
+// rather than normalizing them.
+func openPreservingCreatedAt(ref reference) (imgutil.Image, error) {
+	img, err := ref.open()
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := img.CreatedAt()
+	if err != nil {
+		return nil, err
+	}
+	return ref.open(imgutil.WithCreatedAt(createdAt), imgutil.WithHistory())
+}
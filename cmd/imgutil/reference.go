@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/auth"
+	"github.com/buildpacks/imgutil/blobdir"
+	"github.com/buildpacks/imgutil/layout"
+	"github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/remote"
+	"github.com/buildpacks/imgutil/tarball"
+)
+
+const (
+	kindBlobdir = "blobdir"
+	kindLayout  = "layout"
+	kindLocal   = "local"
+	kindRemote  = "remote"
+	kindTarball = "tarball"
+)
+
+// reference names an image of a kind, e.g. `layout:/tmp/app` or `registry.example.com/app:latest` (a remote image).
+type reference struct {
+	kind string
+	name string
+}
+
+// parseReference splits ref into its kind and name. A prefix is only taken as the kind if it is a known kind,
+// as remote references may contain colons (e.g. `localhost:5000/app`).
+func parseReference(ref string) reference {
+	if kind, name, ok := strings.Cut(ref, ":"); ok {
+		switch kind {
+		case kindBlobdir, kindLayout, kindLocal, kindRemote, kindTarball:
+			return reference{kind: kind, name: name}
+		}
+	}
+	return reference{kind: kindRemote, name: ref}
+}
+
+func (r reference) String() string {
+	return r.kind + ":" + r.name
+}
+
+// open returns the existing image with the reference, which is modified and saved as images of its kind are.
+// Tarballs and blob directories can't be saved, and are only read.
+func (r reference) open(ops ...imgutil.ImageOption) (imgutil.Image, error) {
+	var (
+		img imgutil.Image
+		err error
+	)
+	switch r.kind {
+	case kindRemote:
+		var keychain authn.Keychain
+		if keychain, err = newKeychain(); err != nil {
+			return nil, err
+		}
+		img, err = remote.NewImage(r.name, keychain, append([]imgutil.ImageOption{remote.FromBaseImage(r.name)}, ops...)...)
+	case kindLayout:
+		img, err = layout.NewImage(r.name, append([]imgutil.ImageOption{layout.FromBaseImagePath(r.name)}, ops...)...)
+	case kindLocal:
+		var dockerClient *client.Client
+		if dockerClient, err = newDockerClient(); err != nil {
+			return nil, err
+		}
+		img, err = local.NewImage(r.name, dockerClient, append([]imgutil.ImageOption{local.FromBaseImage(r.name)}, ops...)...)
+	case kindTarball, kindBlobdir:
+		return r.openReadOnly(ops...)
+	default:
+		return nil, fmt.Errorf("unknown image kind %q", r.kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", r, err)
+	}
+	if !img.Found() {
+		return nil, fmt.Errorf("image %s not found", r)
+	}
+	return img, nil
+}
+
+func (r reference) openReadOnly(ops ...imgutil.ImageOption) (imgutil.Image, error) {
+	var (
+		base v1.Image
+		err  error
+	)
+	if r.kind == kindTarball {
+		base, err = tarball.NewImage(r.name)
+	} else {
+		base, err = blobdir.NewImage(r.name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", r, err)
+	}
+	// the image is never saved to the path, it only provides the imgutil.Image interface
+	return layout.NewImage(r.name, append([]imgutil.ImageOption{imgutil.FromBaseImageInstance(base)}, ops...)...)
+}
+
+// copyDestination returns the destination that imgutil.Copy creates the copies of images of the reference's kind with.
+func (r reference) copyDestination() (imgutil.CopyOption, error) {
+	switch r.kind {
+	case kindRemote:
+		keychain, err := newKeychain()
+		if err != nil {
+			return nil, err
+		}
+		return remote.CopyDestination(keychain), nil
+	case kindLayout:
+		return layout.CopyDestination(), nil
+	case kindLocal:
+		dockerClient, err := newDockerClient()
+		if err != nil {
+			return nil, err
+		}
+		return local.CopyDestination(dockerClient), nil
+	default:
+		return nil, fmt.Errorf("can't save %s images", r.kind)
+	}
+}
+
+func newKeychain() (authn.Keychain, error) {
+	return auth.ResolveKeychain(auth.WithCredentialHelpers())
+}
+
+func newDockerClient() (*client.Client, error) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("creating docker client: %w", err)
+	}
+	return dockerClient, nil
+}
@@ -4,12 +4,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/buildpacks/imgutil/internal/v1util"
+	"github.com/buildpacks/imgutil/layer"
 )
 
 // CNBImageCore wraps a v1.Image and provides most of the methods necessary for the image to satisfy the Image interface.
@@ -17,32 +25,83 @@ import (
 // such as Identifier() and Found().
 // The working image could be any v1.Image,
 // but in practice will start off as a pointer to a local.v1ImageFacade (or similar).
+//
+// CNBImageCore is safe for concurrent use. Getters may be called concurrently with each other and with modifiers,
+// and each modifier (such as SetLabel, AddLayerWithHistory, or Rebase) is applied atomically to the working image,
+// in an undefined order when modifiers are called concurrently.
+// Saving the image concurrently with modifiers does not race, but the saved image may not include the concurrent modifications,
+// so callers should finish modifying the image before saving it.
 type CNBImageCore struct {
 	// required
 	v1.Image // the working image
 	// optional
 	createdAt           time.Time
+	explicitCreatedAt   bool      // whether createdAt was provided with WithCreatedAt, rather than being the normalized date time
+	normalizedDateTime  time.Time // see WithNormalizedDateTime
 	preferredMediaTypes MediaTypes
 	preserveHistory     bool
+	historyDetails      bool
+	createdByTemplate   *template.Template
+	lastLayer           v1.Layer
+	wrapWindowsLayers   bool
 	previousImage       v1.Image
+	additionalPrevious  []v1.Image // searched for reusable layers after the previous image
+	automaticReuse      bool       // layers added with a diff ID are reused from the previous images when they have them
+	verifyDiffIDs       bool       // layers added with a diff ID are hashed to verify it, rather than trusting it
+	subject             *v1.Descriptor
+	estargzLayers       bool
+	withoutLayers       bool // the image is saved without layers, so layers can't be added to it
+	eventHandlers       []EventHandler
+	secretMatchers      []SecretMatcher
+	labelValidator      LabelValidator
+	sizeLimits          SizeLimits
+	logger              Logger
+	addedLayers         []v1.Layer // the layers added with AddLayerWithHistory, which are scanned for credentials
+	// mu guards the working image and the state derived from it: the cached config file and manifest, the subject, and the last layer
+	mu sync.RWMutex
+	// cacheMu guards the config file and manifest of the working image, which are cached by readers holding the read lock
+	cacheMu          sync.Mutex
+	cachedConfigFile *v1.ConfigFile
+	cachedManifest   *v1.Manifest
 }
 
-var _ v1.Image = &CNBImageCore{}
+var (
+	_ v1.Image       = &CNBImageCore{}
+	_ OptionsRebaser = &CNBImageCore{}
+	_ LayerTracker   = &CNBImageCore{}
+	_ AnnotatedImage = &CNBImageCore{}
+)
+
+// Annotations describing the base image of the working image, stamped on its manifest when WithBaseImageAnnotations is provided.
+const (
+	BaseNameAnnotation   = "org.opencontainers.image.base.name"
+	BaseDigestAnnotation = "org.opencontainers.image.base.digest"
+)
 
 // FIXME: mark deprecated methods as deprecated on the interface when other packages (remote, layout) expose a v1.Image
 
 // TBD Deprecated: Architecture
 func (i *CNBImageCore) Architecture() (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
 	return configFile.Architecture, nil
 }
 
+// ArgsEscaped reports whether the command line of the entrypoint and cmd is already escaped,
+// as in Windows images whose entrypoint or cmd is a single command line (`args_escaped` in the Docker image spec).
+func (i *CNBImageCore) ArgsEscaped() (bool, error) {
+	configFile, err := i.configFile()
+	if err != nil {
+		return false, err
+	}
+	return configFile.Config.ArgsEscaped, nil
+}
+
 // TBD Deprecated: CreatedAt
 func (i *CNBImageCore) CreatedAt() (time.Time, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -51,15 +110,15 @@ func (i *CNBImageCore) CreatedAt() (time.Time, error) {
 
 // TBD Deprecated: Entrypoint
 func (i *CNBImageCore) Entrypoint() ([]string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return nil, err
 	}
-	return configFile.Config.Entrypoint, nil
+	return slices.Clone(configFile.Config.Entrypoint), nil
 }
 
 func (i *CNBImageCore) Env(key string) (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
@@ -73,36 +132,78 @@ func (i *CNBImageCore) Env(key string) (string, error) {
 }
 
 func (i *CNBImageCore) GetAnnotateRefName() (string, error) {
-	manifest, err := getManifest(i.Image)
+	manifest, err := i.manifest()
 	if err != nil {
 		return "", err
 	}
 	return manifest.Annotations["org.opencontainers.image.ref.name"], nil
 }
 
+// Annotations returns the annotations of the manifest of the working image.
+func (i *CNBImageCore) Annotations() (map[string]string, error) {
+	manifest, err := i.manifest()
+	if err != nil {
+		return nil, err
+	}
+	annotations := make(map[string]string, len(manifest.Annotations))
+	for k, v := range manifest.Annotations {
+		annotations[k] = v
+	}
+	return annotations, nil
+}
+
+// LastLayer returns the diff ID, digest, and size of the layer most recently added or reused.
+// The digest is empty and the size is zero when they are not known before the image is saved (e.g. for the daemon).
+func (i *CNBImageCore) LastLayer() (LayerInfo, error) {
+	i.mu.RLock()
+	lastLayer := i.lastLayer
+	i.mu.RUnlock()
+	if lastLayer == nil {
+		return LayerInfo{}, errors.New("no layer was added")
+	}
+	diffID, err := lastLayer.DiffID()
+	if err != nil {
+		return LayerInfo{}, err
+	}
+	info := LayerInfo{DiffID: diffID.String()}
+	if digest, err := lastLayer.Digest(); err == nil && digest != (v1.Hash{}) {
+		info.Digest = digest.String()
+		if info.Size, err = lastLayer.Size(); err != nil {
+			return LayerInfo{}, err
+		}
+	}
+	return info, nil
+}
+
 func (i *CNBImageCore) GetLayer(diffID string) (io.ReadCloser, error) {
 	layerHash, err := v1.NewHash(diffID)
 	if err != nil {
 		return nil, err
 	}
-	configFile, err := i.ConfigFile()
+	configFile, err := i.configFile()
 	if err != nil {
 		return nil, err
 	}
 	if !contains(configFile.RootFS.DiffIDs, layerHash) {
 		return nil, ErrLayerNotFound{DiffID: layerHash.String()}
 	}
-	hash, err := v1.NewHash(diffID)
-	if err != nil {
-		return nil, err
-	}
-	layer, err := i.LayerByDiffID(hash)
+	// the layers of the base image are read through the blob cache, if any (see ImageOptions.CacheImages)
+	layer, err := i.LayerByDiffID(layerHash)
 	if err != nil {
 		return nil, err
 	}
 	return layer.Uncompressed()
 }
 
+// CachedLayer returns the layer with the given digest or diff ID from the blob cache.
+// It returns cache.ErrNotFound if there is no cache or the layer is not cached.
+func CachedLayer(blobCache cache.Cache, hash v1.Hash) (v1.Layer, error) {
+	if blobCache == nil {
+		return nil, cache.ErrNotFound
+	}
+	return blobCache.Get(hash)
+}
+
 func contains(diffIDs []v1.Hash, hash v1.Hash) bool {
 	for _, diffID := range diffIDs {
 		if diffID.String() == hash.String() {
@@ -114,16 +215,16 @@ func contains(diffIDs []v1.Hash, hash v1.Hash) bool {
 
 // TBD Deprecated: History
 func (i *CNBImageCore) History() ([]v1.History, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return nil, err
 	}
-	return configFile.History, nil
+	return slices.Clone(configFile.History), nil
 }
 
 // TBD Deprecated: Label
 func (i *CNBImageCore) Label(key string) (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
@@ -132,21 +233,21 @@ func (i *CNBImageCore) Label(key string) (string, error) {
 
 // TBD Deprecated: Labels
 func (i *CNBImageCore) Labels() (map[string]string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return nil, err
 	}
-	return configFile.Config.Labels, nil
+	return maps.Clone(configFile.Config.Labels), nil
 }
 
 // TBD Deprecated: ManifestSize
 func (i *CNBImageCore) ManifestSize() (int64, error) {
-	return i.Image.Size()
+	return i.Size()
 }
 
 // TBD Deprecated: OS
 func (i *CNBImageCore) OS() (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
@@ -155,7 +256,7 @@ func (i *CNBImageCore) OS() (string, error) {
 
 // TBD Deprecated: OSVersion
 func (i *CNBImageCore) OSVersion() (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
@@ -163,29 +264,55 @@ func (i *CNBImageCore) OSVersion() (string, error) {
 }
 
 func (i *CNBImageCore) TopLayer() (string, error) {
-	layers, err := i.Image.Layers()
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
-	if len(layers) == 0 {
+	if len(configFile.RootFS.DiffIDs) == 0 {
 		return "", errors.New("image has no layers")
 	}
-	topLayer := layers[len(layers)-1]
-	hex, err := topLayer.DiffID()
+	return configFile.RootFS.DiffIDs[len(configFile.RootFS.DiffIDs)-1].String(), nil
+}
+
+// ReadFile returns the content of the file at the given absolute path in the image filesystem,
+// scanning the layers from the top down.
+func (i *CNBImageCore) ReadFile(path string) ([]byte, error) {
+	configFile, err := i.configFile()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return NewFileWalker(configFile, i.GetLayer).ReadFile(path)
+}
+
+// WalkFiles calls fn for each entry of the image filesystem, scanning the layers from the top down.
+func (i *CNBImageCore) WalkFiles(fn WalkFunc) error {
+	configFile, err := i.configFile()
+	if err != nil {
+		return err
 	}
-	return hex.String(), nil
+	return NewFileWalker(configFile, i.GetLayer).Walk(fn)
 }
 
 // UnderlyingImage is used to expose a v1.Image from an imgutil.Image, which can be useful in certain situations (such as rebase).
 func (i *CNBImageCore) UnderlyingImage() v1.Image {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.Image
 }
 
+// User returns the user the image runs as: a name, a UID (and GID) on Linux,
+// or a user name or SID (e.g. `ContainerUser`, or `S-1-5-93-2-1`) on Windows.
+func (i *CNBImageCore) User() (string, error) {
+	configFile, err := i.configFile()
+	if err != nil {
+		return "", err
+	}
+	return configFile.Config.User, nil
+}
+
 // TBD Deprecated: Variant
 func (i *CNBImageCore) Variant() (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
@@ -194,7 +321,7 @@ func (i *CNBImageCore) Variant() (string, error) {
 
 // TBD Deprecated: WorkingDir
 func (i *CNBImageCore) WorkingDir() (string, error) {
-	configFile, err := getConfigFile(i.Image)
+	configFile, err := i.configFile()
 	if err != nil {
 		return "", err
 	}
@@ -202,23 +329,72 @@ func (i *CNBImageCore) WorkingDir() (string, error) {
 }
 
 func (i *CNBImageCore) AnnotateRefName(refName string) error {
-	manifest, err := getManifest(i.Image)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.annotate(map[string]string{"org.opencontainers.image.ref.name": refName})
+}
+
+// SetAnnotations adds the given annotations to the manifest of the working image, replacing the values of existing keys.
+// The layout and remote implementations save the annotations on the manifest;
+// the local implementation records them in a label, as the daemon doesn't store manifests.
+func (i *CNBImageCore) SetAnnotations(annotations map[string]string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.annotate(annotations)
+}
+
+func (i *CNBImageCore) annotateBaseImage(baseName, baseDigest string) error {
+	annotations := map[string]string{BaseDigestAnnotation: baseDigest}
+	if baseName != "" {
+		annotations[BaseNameAnnotation] = baseName
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.annotate(annotations)
+}
+
+// annotate adds the given annotations to the manifest of the working image. Callers must hold the lock.
+func (i *CNBImageCore) annotate(annotations map[string]string) error {
+	manifest, err := i.manifestLocked()
 	if err != nil {
 		return err
 	}
+	manifest = manifest.DeepCopy()
 	if manifest.Annotations == nil {
 		manifest.Annotations = make(map[string]string)
 	}
-	manifest.Annotations["org.opencontainers.image.ref.name"] = refName
+	for k, v := range annotations {
+		manifest.Annotations[k] = v
+	}
 	mutated := mutate.Annotations(i.Image, manifest.Annotations)
 	image, ok := mutated.(v1.Image)
 	if !ok {
-		return fmt.Errorf("failed to add annotation")
+		return fmt.Errorf("failed to add annotations")
 	}
-	i.Image = image
+	i.setImage(image)
+	i.ensureSubject()
 	return nil
 }
 
+// SetSubject sets the `subject` of the working image manifest,
+// declaring that the image (for example, an attestation or an SBOM artifact) refers to the image described by the given descriptor.
+func (i *CNBImageCore) SetSubject(subject v1.Descriptor) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.subject = &subject
+	i.ensureSubject()
+	return nil
+}
+
+// Subject returns the `subject` of the working image manifest, or nil if the manifest has no subject.
+func (i *CNBImageCore) Subject() (*v1.Descriptor, error) {
+	manifest, err := i.manifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Subject, nil
+}
+
 // TBD Deprecated: SetArchitecture
 func (i *CNBImageCore) SetArchitecture(architecture string) error {
 	return i.MutateConfigFile(func(c *v1.ConfigFile) {
@@ -226,6 +402,13 @@ func (i *CNBImageCore) SetArchitecture(architecture string) error {
 	})
 }
 
+// SetArgsEscaped sets whether the command line of the entrypoint and cmd is already escaped (see ArgsEscaped).
+func (i *CNBImageCore) SetArgsEscaped(argsEscaped bool) error {
+	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+		c.Config.ArgsEscaped = argsEscaped
+	})
+}
+
 // TBD Deprecated: SetCmd
 func (i *CNBImageCore) SetCmd(cmd ...string) error {
 	return i.MutateConfigFile(func(c *v1.ConfigFile) {
@@ -241,25 +424,53 @@ func (i *CNBImageCore) SetEntrypoint(ep ...string) error {
 }
 
 func (i *CNBImageCore) SetEnv(key, val string) error {
+	return i.setEnv(key, func(string, bool) string {
+		return val
+	})
+}
+
+// SetEnvAppend appends the given value to the value of the environment variable with the given key,
+// joined with the given delimiter (e.g. `:` for PATH-style variables), as for the CNB `.append` environment files.
+// If the variable is not set, it is set to the given value. The order of the environment variables is preserved.
+func (i *CNBImageCore) SetEnvAppend(key, val, delim string) error {
+	return i.setEnv(key, func(existing string, found bool) string {
+		if !found || existing == "" {
+			return val
+		}
+		return existing + delim + val
+	})
+}
+
+// SetEnvPrepend prepends the given value to the value of the environment variable with the given key,
+// joined with the given delimiter (e.g. `:` for PATH-style variables), as for the CNB `.prepend` environment files.
+// If the variable is not set, it is set to the given value. The order of the environment variables is preserved.
+func (i *CNBImageCore) SetEnvPrepend(key, val, delim string) error {
+	return i.setEnv(key, func(existing string, found bool) string {
+		if !found || existing == "" {
+			return val
+		}
+		return val + delim + existing
+	})
+}
+
+// setEnv sets the environment variable with the given key to the value returned by newValue for its current value,
+// in place if it is set (matching keys case-insensitively on Windows), or at the end otherwise.
+func (i *CNBImageCore) setEnv(key string, newValue func(existing string, found bool) string) error {
 	return i.MutateConfigFile(func(c *v1.ConfigFile) {
 		ignoreCase := c.OS == "windows"
 		for idx, e := range c.Config.Env {
-			parts := strings.Split(e, "=")
-			if len(parts) < 1 {
-				continue
-			}
-			foundKey := parts[0]
+			foundKey, existing, _ := strings.Cut(e, "=")
 			searchKey := key
 			if ignoreCase {
 				foundKey = strings.ToUpper(foundKey)
 				searchKey = strings.ToUpper(searchKey)
 			}
 			if foundKey == searchKey {
-				c.Config.Env[idx] = fmt.Sprintf("%s=%s", key, val)
+				c.Config.Env[idx] = fmt.Sprintf("%s=%s", key, newValue(existing, true))
 				return
 			}
 		}
-		c.Config.Env = append(c.Config.Env, fmt.Sprintf("%s=%s", key, val))
+		c.Config.Env = append(c.Config.Env, fmt.Sprintf("%s=%s", key, newValue("", false)))
 	})
 }
 
@@ -270,13 +481,36 @@ func (i *CNBImageCore) SetHistory(histories []v1.History) error {
 	})
 }
 
+// RepairHistory reconciles the history of the image with its layers (see imgutil.RepairHistory),
+// so that history entries line up with the layers (e.g. for ReuseLayer), and reports what it fixed.
+func (i *CNBImageCore) RepairHistory() (HistoryRepair, error) {
+	var repair HistoryRepair
+	err := i.MutateConfigFile(func(c *v1.ConfigFile) {
+		var repaired []v1.History
+		repaired, repair = RepairHistory(c.History, len(c.RootFS.DiffIDs))
+		if repair.Repaired() {
+			c.History = repaired
+		}
+	})
+	return repair, err
+}
+
 func (i *CNBImageCore) SetLabel(key, val string) error {
-	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+	if i.labelValidator != nil {
+		if err := i.labelValidator(key, val); err != nil {
+			return LabelError{Key: key, Err: err}
+		}
+	}
+	if err := i.MutateConfigFile(func(c *v1.ConfigFile) {
 		if c.Config.Labels == nil {
 			c.Config.Labels = make(map[string]string)
 		}
 		c.Config.Labels[key] = val
-	})
+	}); err != nil {
+		return err
+	}
+	i.Emit(Event{Type: EventSetLabel, Key: key, Value: val})
+	return nil
 }
 
 func (i *CNBImageCore) SetOS(osVal string) error {
@@ -299,6 +533,13 @@ func (i *CNBImageCore) SetVariant(variant string) error {
 	})
 }
 
+// SetUser sets the user the image runs as (see User).
+func (i *CNBImageCore) SetUser(user string) error {
+	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+		c.Config.User = user
+	})
+}
+
 // TBD Deprecated: SetWorkingDir
 func (i *CNBImageCore) SetWorkingDir(dir string) error {
 	return i.MutateConfigFile(func(c *v1.ConfigFile) {
@@ -314,33 +555,119 @@ func (i *CNBImageCore) AddLayer(path string) error {
 	return i.AddLayerWithDiffIDAndHistory(path, "ignored", emptyHistory)
 }
 
-func (i *CNBImageCore) AddLayerWithDiffID(path, _ string) error {
-	return i.AddLayerWithDiffIDAndHistory(path, "ignored", emptyHistory)
+func (i *CNBImageCore) AddLayerWithDiffID(path, diffID string) error {
+	return i.AddLayerWithDiffIDAndHistory(path, diffID, emptyHistory)
 }
 
-func (i *CNBImageCore) AddLayerWithDiffIDAndHistory(path, _ string, history v1.History) error {
-	layer, err := tarball.LayerFromFile(path)
-	if err != nil {
+// AddLayerWithDiffIDAndHistory adds the layer at the given path with the provided diff ID, unless it is "ignored",
+// or the layer is converted to eStargz or wrapped as a Windows layer, which changes its diff ID.
+// The diff ID is trusted, so that the file is only read when the image is saved, unless WithDiffIDVerification is provided.
+func (i *CNBImageCore) AddLayerWithDiffIDAndHistory(path, diffID string, history v1.History) error {
+	if reused, err := i.ReuseLayerIfAutomatic(diffID, history); err != nil || reused {
 		return err
 	}
-	return i.AddLayerWithHistory(layer, history)
+	layer, err := i.layerFromFile(path, diffID)
+	if err != nil {
+		return fmt.Errorf("failed to add layer at path %s: %w", path, err)
+	}
+	return i.AddNamedLayerWithHistory(layer, LayerName(path), history)
+}
+
+// LayerName returns the name of the layer at the given path, available to the created by template (see WithCreatedByTemplate):
+// the base name of the file without its tar extension, e.g. `launcher` for `/layers/launcher.tar`.
+func LayerName(path string) string {
+	name := filepath.Base(path)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// ReuseLayerIfAutomatic reuses the layer with the given diff ID with the given history, and reports whether it did,
+// if automatic layer reuse is enabled (see WithAutomaticLayerReuse) and a previous image has the layer.
+// Implementations adding layers with a diff ID call it before reading the layer.
+func (i *CNBImageCore) ReuseLayerIfAutomatic(diffID string, history v1.History) (bool, error) {
+	if !i.automaticReuse || diffID == "ignored" {
+		return false, nil
+	}
+	found, err := i.PreviousImageHasLayer(diffID)
+	if err != nil || !found {
+		return false, err
+	}
+	return true, i.ReuseLayerWithHistory(diffID, history)
+}
+
+func (i *CNBImageCore) layerFromFile(path, diffID string) (v1.Layer, error) {
+	if i.estargzLayers {
+		return layer.EstargzFromFile(path)
+	}
+	var ops []layer.FileOption
+	if diffID != "ignored" {
+		expected, err := v1.NewHash(diffID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid diff ID %q: %w", diffID, err)
+		}
+		ops = append(ops, layer.WithDiffID(expected))
+		if i.verifyDiffIDs {
+			ops = append(ops, layer.VerifyDiffID())
+		}
+	}
+	if i.wrapWindowsLayers {
+		os, err := i.OS()
+		if err != nil {
+			return nil, err
+		}
+		if os == "windows" {
+			return layer.WindowsFromFile(path, ops...)
+		}
+	}
+	return layer.FromFile(path, ops...)
 }
 
 func (i *CNBImageCore) AddLayerWithHistory(layer v1.Layer, history v1.History) error {
-	var err error
-	// ensure existing history
-	if err = i.MutateConfigFile(func(c *v1.ConfigFile) {
-		c.History = NormalizedHistory(c.History, len(c.RootFS.DiffIDs))
-	}); err != nil {
+	return i.AddNamedLayerWithHistory(layer, "", history)
+}
+
+// AddNamedLayerWithHistory is AddLayerWithHistory for a layer with the given name (see LayerName),
+// which the created by template can refer to. Implementations adding layers from files call it.
+func (i *CNBImageCore) AddNamedLayerWithHistory(layer v1.Layer, name string, history v1.History) error {
+	diffID, err := layer.DiffID()
+	if err != nil {
 		return err
 	}
-
-	if !i.preserveHistory {
-		history = emptyHistory
+	if history, err = i.layerHistory(history, diffID, name); err != nil {
+		return err
 	}
 	history.Created = v1.Time{Time: i.createdAt}
+	if err = i.appendLayer(layer, history); err != nil {
+		return err
+	}
+	if len(i.secretMatchers) > 0 {
+		i.mu.Lock()
+		i.addedLayers = append(i.addedLayers, layer)
+		i.mu.Unlock()
+	}
+	i.Emit(Event{Type: EventAddLayer, DiffID: diffID.String()})
+	return nil
+}
 
-	i.Image, err = mutate.Append(
+// appendLayer appends the layer with the given history to the working image,
+// after ensuring the working image has a history entry for each of its layers.
+func (i *CNBImageCore) appendLayer(layer v1.Layer, history v1.History) error {
+	if i.withoutLayers {
+		return errors.New("layers cannot be added to an image that is saved without layers")
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	// ensure existing history
+	if err := i.mutateConfigFile(func(c *v1.ConfigFile) {
+		c.History = NormalizedHistory(c.History, len(c.RootFS.DiffIDs))
+	}); err != nil {
+		return err
+	}
+	image, err := mutate.Append(
 		i.Image,
 		mutate.Addendum{
 			Layer:     layer,
@@ -348,7 +675,36 @@ func (i *CNBImageCore) AddLayerWithHistory(layer v1.Layer, history v1.History) e
 			MediaType: i.preferredMediaTypes.LayerType(),
 		},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	i.setImage(image)
+	i.ensureSubject()
+	i.lastLayer = layer
+	return nil
+}
+
+// layerHistory returns the history to record for an added or reused layer with the given name, according to the history options.
+// Without history options, the history is empty, except for the created by if there is a created by template.
+func (i *CNBImageCore) layerHistory(history v1.History, diffID v1.Hash, name string) (v1.History, error) {
+	switch {
+	case i.preserveHistory:
+	case i.historyDetails:
+		history = v1.History{CreatedBy: history.CreatedBy, Comment: history.Comment}
+	case i.createdByTemplate != nil:
+		history = v1.History{Created: emptyHistory.Created, CreatedBy: history.CreatedBy}
+	default:
+		return emptyHistory, nil
+	}
+	if history.CreatedBy == "" && i.createdByTemplate != nil {
+		var createdBy strings.Builder
+		data := struct{ DiffID, LayerName string }{DiffID: diffID.String(), LayerName: name}
+		if err := i.createdByTemplate.Execute(&createdBy, data); err != nil {
+			return v1.History{}, fmt.Errorf("failed to execute created by template: %w", err)
+		}
+		history.CreatedBy = createdBy.String()
+	}
+	return history, nil
 }
 
 func (i *CNBImageCore) AddOrReuseLayerWithHistory(path string, diffID string, history v1.History) error {
@@ -362,44 +718,138 @@ func (i *CNBImageCore) AddOrReuseLayerWithHistory(path string, diffID string, hi
 	return i.ReuseLayerWithHistory(diffID, history)
 }
 
+// PreviousImageHasLayer reports whether the previous image, or one of the additional previous images (see WithAdditionalPreviousImages),
+// has the layer with the given diff ID, so that callers can choose between ReuseLayer and adding the layer
+// without relying on the error of ReuseLayer.
+// It is false if no previous image was provided.
 func (i *CNBImageCore) PreviousImageHasLayer(diffID string) (bool, error) {
-	if i.previousImage == nil {
-		return false, nil
-	}
 	layerHash, err := v1.NewHash(diffID)
 	if err != nil {
 		return false, fmt.Errorf("failed to get layer hash: %w", err)
 	}
-	prevConfigFile, err := getConfigFile(i.previousImage)
+	image, _, err := i.previousImageWithLayer(layerHash)
 	if err != nil {
-		return false, fmt.Errorf("failed to get previous image config: %w", err)
+		return false, err
 	}
-	return contains(prevConfigFile.RootFS.DiffIDs, layerHash), nil
+	return image != nil, nil
+}
+
+// ListReusableLayers returns the diff IDs of the layers of the previous image, which can be reused with ReuseLayer,
+// from the bottom layer to the top layer, followed by the other layers of the additional previous images.
+// It is empty if no previous image was provided.
+func (i *CNBImageCore) ListReusableLayers() ([]string, error) {
+	var (
+		diffIDs []string
+		seen    = map[v1.Hash]bool{}
+	)
+	for _, image := range i.previousImages() {
+		prevConfigFile, err := getConfigFile(image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get previous image config: %w", err)
+		}
+		for _, diffID := range prevConfigFile.RootFS.DiffIDs {
+			if !seen[diffID] {
+				seen[diffID] = true
+				diffIDs = append(diffIDs, diffID.String())
+			}
+		}
+	}
+	return diffIDs, nil
+}
+
+// previousImages returns the images that layers are reused from, in the order they are searched.
+func (i *CNBImageCore) previousImages() []v1.Image {
+	var images []v1.Image
+	if i.previousImage != nil {
+		images = append(images, i.previousImage)
+	}
+	return append(images, i.additionalPrevious...)
+}
+
+// previousImageWithLayer returns the first previous image having the layer with the given diff ID, with the index of the layer,
+// or a nil image if none has it.
+func (i *CNBImageCore) previousImageWithLayer(layerHash v1.Hash) (v1.Image, int, error) {
+	for _, image := range i.previousImages() {
+		prevConfigFile, err := getConfigFile(image)
+		if err != nil {
+			return nil, -1, fmt.Errorf("failed to get previous image config: %w", err)
+		}
+		for idx, diffID := range prevConfigFile.RootFS.DiffIDs {
+			if diffID == layerHash {
+				return image, idx, nil
+			}
+		}
+	}
+	return nil, -1, nil
 }
 
 func (i *CNBImageCore) Rebase(baseTopLayerDiffID string, withNewBase Image) error {
+	return i.RebaseWithOptions(baseTopLayerDiffID, withNewBase)
+}
+
+// RebaseWithOptions rebases the image as Rebase does, adopting the config values of the new base image selected by the options.
+func (i *CNBImageCore) RebaseWithOptions(baseTopLayerDiffID string, withNewBase Image, ops ...RebaseOption) error {
+	options := GetRebaseOptions(ops...)
 	newBase := withNewBase.UnderlyingImage() // FIXME: when all imgutil.Images are v1.Images, we can remove this part
-	var err error
-	i.Image, err = mutate.Rebase(i.Image, i.newV1ImageFacade(baseTopLayerDiffID), newBase)
+	newBaseConfigFile, err := getConfigFile(newBase)
 	if err != nil {
 		return err
 	}
 
-	// ensure new config matches provided image
-	newBaseConfigFile, err := getConfigFile(newBase)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	manifest, err := i.manifestLocked()
 	if err != nil {
 		return err
 	}
-	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+	image, err := mutate.Rebase(i.Image, i.newV1ImageFacade(baseTopLayerDiffID), newBase)
+	if err != nil {
+		return err
+	}
+	i.setImage(image)
+	// mutate.Rebase drops the annotations and the config descriptor platform and URLs of the manifest; keep them,
+	// except for the annotations describing the previous base image
+	annotations := map[string]string{}
+	for k, v := range manifest.Annotations {
+		if k != BaseNameAnnotation && k != BaseDigestAnnotation {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) > 0 {
+		i.setImage(mutate.Annotations(i.Image, annotations).(v1.Image))
+	}
+	if manifest.Config.Platform != nil || len(manifest.Config.URLs) > 0 {
+		i.setImage(v1util.WithConfigDescriptor(i.Image, manifest.Config))
+	}
+	i.ensureSubject()
+
+	// ensure new config matches provided image
+	return i.mutateConfigFile(func(c *v1.ConfigFile) {
 		c.Architecture = newBaseConfigFile.Architecture
 		c.OS = newBaseConfigFile.OS
 		c.OSVersion = newBaseConfigFile.OSVersion
+		options.mergeConfig(c, newBaseConfigFile)
 	})
 }
 
+// TransformLayers rewrites the entries of each layer of the image with the given transforms (see imgutil.TransformLayers),
+// e.g. to drop temporary files or normalize timestamps before the image is saved.
+func (i *CNBImageCore) TransformLayers(transforms ...layer.EntryTransform) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	image, err := TransformLayers(i.Image, transforms...)
+	if err != nil {
+		return err
+	}
+	i.setImage(image)
+	i.ensureSubject()
+	return nil
+}
+
+// newV1ImageFacade returns the working image up to the given layer. Callers must hold the lock.
 func (i *CNBImageCore) newV1ImageFacade(topLayerDiffID string) v1.Image {
 	return &v1ImageFacade{
-		Image:          i,
+		Image:          i.Image,
 		topLayerDiffID: topLayerDiffID,
 	}
 }
@@ -433,35 +883,25 @@ func (i *CNBImageCore) RemoveLabel(key string) error {
 }
 
 func (i *CNBImageCore) ReuseLayer(diffID string) error {
-	if i.previousImage == nil {
+	if len(i.previousImages()) == 0 {
 		return errors.New("failed to reuse layer because no previous image was provided")
 	}
-	idx, err := getLayerIndex(diffID, i.previousImage)
+	layerHash, err := v1.NewHash(diffID)
 	if err != nil {
-		return fmt.Errorf("failed to get index for previous image layer: %w", err)
+		return fmt.Errorf("failed to get layer hash: %w", err)
 	}
-	previousHistory, err := getHistory(idx, i.previousImage)
+	image, idx, err := i.previousImageWithLayer(layerHash)
 	if err != nil {
-		return fmt.Errorf("failed to get history for previous image layer: %w", err)
+		return err
 	}
-	return i.ReuseLayerWithHistory(diffID, previousHistory)
-}
-
-func getLayerIndex(forDiffID string, fromImage v1.Image) (int, error) {
-	layerHash, err := v1.NewHash(forDiffID)
-	if err != nil {
-		return -1, fmt.Errorf("failed to get layer hash: %w", err)
+	if image == nil {
+		return fmt.Errorf("failed to get index for previous image layer: failed to find diffID %s in config file", layerHash.String())
 	}
-	configFile, err := getConfigFile(fromImage)
+	previousHistory, err := getHistory(idx, image)
 	if err != nil {
-		return -1, fmt.Errorf("failed to get config file: %w", err)
-	}
-	for idx, configHash := range configFile.RootFS.DiffIDs {
-		if layerHash.String() == configHash.String() {
-			return idx, nil
-		}
+		return fmt.Errorf("failed to get history for previous image layer: %w", err)
 	}
-	return -1, fmt.Errorf("failed to find diffID %s in config file", layerHash.String())
+	return i.ReuseLayerWithHistory(diffID, previousHistory)
 }
 
 func getHistory(forIndex int, fromImage v1.Image) (v1.History, error) {
@@ -477,79 +917,166 @@ func getHistory(forIndex int, fromImage v1.Image) (v1.History, error) {
 }
 
 func (i *CNBImageCore) ReuseLayerWithHistory(diffID string, history v1.History) error {
-	var err error
-	// ensure existing history
-	if err = i.MutateConfigFile(func(c *v1.ConfigFile) {
-		c.History = NormalizedHistory(c.History, len(c.RootFS.DiffIDs))
-	}); err != nil {
-		return err
-	}
-
 	layerHash, err := v1.NewHash(diffID)
 	if err != nil {
 		return fmt.Errorf("failed to get layer hash: %w", err)
 	}
-	layer, err := i.previousImage.LayerByDiffID(layerHash)
+	image, _, err := i.previousImageWithLayer(layerHash)
+	if err != nil {
+		return err
+	}
+	if image == nil {
+		if len(i.previousImages()) == 0 {
+			return errors.New("failed to reuse layer because no previous image was provided")
+		}
+		return fmt.Errorf("failed to get layer by diffID: previous image has no layer with diffID %s", layerHash.String())
+	}
+	layer, err := image.LayerByDiffID(layerHash)
 	if err != nil {
 		return fmt.Errorf("failed to get layer by diffID: %w", err)
 	}
-	if i.preserveHistory {
+	if history, err = i.layerHistory(history, layerHash, ""); err != nil {
+		return err
+	}
+	if i.preserveHistory || i.historyDetails {
 		history.Created = v1.Time{Time: i.createdAt}
-	} else {
-		history = emptyHistory
 	}
-	i.Image, err = mutate.Append(
-		i.Image,
-		mutate.Addendum{
-			Layer:     layer,
-			History:   history,
-			MediaType: i.preferredMediaTypes.LayerType(),
-		},
-	)
-	return err
+	if err = i.appendLayer(layer, history); err != nil {
+		return err
+	}
+	i.Emit(Event{Type: EventReuseLayer, DiffID: layerHash.String()})
+	return nil
 }
 
 // helpers
 
+// MutateConfigFile applies withFunc to a copy of the config file of the working image, and replaces the working image
+// with an image having the modified config file. As the image is locked while withFunc runs, withFunc must not call methods of the image.
 func (i *CNBImageCore) MutateConfigFile(withFunc func(c *v1.ConfigFile)) error {
 	// FIXME: put MutateConfigFile on the interface when `remote` and `layout` packages also support it.
-	configFile, err := getConfigFile(i.Image)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.mutateConfigFile(withFunc)
+}
+
+// mutateConfigFile is MutateConfigFile for callers holding the lock.
+func (i *CNBImageCore) mutateConfigFile(withFunc func(c *v1.ConfigFile)) error {
+	configFile, err := i.configFileLocked()
 	if err != nil {
 		return err
 	}
+	configFile = configFile.DeepCopy()
 	withFunc(configFile)
-	i.Image, err = mutate.ConfigFile(i.Image, configFile)
-	return err
+	image, err := mutate.ConfigFile(i.Image, configFile)
+	if err != nil {
+		return err
+	}
+	i.setImage(image)
+	i.ensureSubject()
+	return nil
+}
+
+// ensureSubject re-applies the subject (if any) to the working image,
+// because any mutation of a v1.Image drops the `subject` of the original manifest. Callers must hold the lock.
+func (i *CNBImageCore) ensureSubject() {
+	if i.subject == nil {
+		return
+	}
+	i.setImage(mutate.Subject(i.Image, *i.subject).(v1.Image))
 }
 
 func (i *CNBImageCore) SetCreatedAtAndHistory() error {
-	var err error
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.mutateConfigFile(i.setCreatedAtAndHistory)
+}
+
+// setCreatedAtAndHistory sets the creation time of the config file and of its history,
+// zeroing the history (but keeping created by and comment if requested, or created by with a created by template) unless it is preserved.
+func (i *CNBImageCore) setCreatedAtAndHistory(c *v1.ConfigFile) {
 	// set created at
-	if err = i.MutateConfigFile(func(c *v1.ConfigFile) {
-		c.Created = v1.Time{Time: i.createdAt}
-		c.Container = ""
-	}); err != nil {
-		return err
-	}
+	c.Created = v1.Time{Time: i.createdAt}
+	c.Container = ""
 	// set history
-	if i.preserveHistory {
-		// set created at for each history
-		err = i.MutateConfigFile(func(c *v1.ConfigFile) {
-			c.History = NormalizedHistory(c.History, len(c.RootFS.DiffIDs))
-			for j := range c.History {
-				c.History[j].Created = v1.Time{Time: i.createdAt}
-			}
-		})
-	} else {
-		// zero history
-		err = i.MutateConfigFile(func(c *v1.ConfigFile) {
-			c.History = NormalizedHistory(c.History, len(c.RootFS.DiffIDs))
-			for j := range c.History {
-				c.History[j] = v1.History{Created: v1.Time{Time: i.createdAt}}
-			}
-		})
+	c.History = NormalizedHistory(c.History, len(c.RootFS.DiffIDs))
+	for j := range c.History {
+		if i.preserveHistory {
+			c.History[j].Created = v1.Time{Time: i.createdAt}
+			continue
+		}
+		history := v1.History{Created: v1.Time{Time: i.createdAt}}
+		if i.historyDetails || i.createdByTemplate != nil {
+			history.CreatedBy = c.History[j].CreatedBy
+		}
+		if i.historyDetails {
+			history.Comment = c.History[j].Comment
+		}
+		c.History[j] = history
+	}
+}
+
+// SetNormalizedDateTime sets the time that the following saves normalize the created time of the config and history to,
+// as WithNormalizedDateTime does at construction. A created time provided with WithCreatedAt still takes precedence.
+func (i *CNBImageCore) SetNormalizedDateTime(t time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.normalizedDateTime = t
+	if !i.explicitCreatedAt {
+		i.createdAt = t
+	}
+}
+
+// FinalizeDigest applies the changes that saving the image makes (see SetCreatedAtAndHistory) and returns its digest,
+// which is the digest saving the image produces unless the image is modified again.
+// Saving applies the same changes again without effect, so platforms can emit references to the image
+// (e.g. in manifests or BOMs) before pushing it.
+func (i *CNBImageCore) FinalizeDigest() (v1.Hash, error) {
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return v1.Hash{}, err
+	}
+	return i.Digest()
+}
+
+// RawConfigJSON returns the exact bytes of the config file that saving the image will write, without saving or modifying it:
+// the config file of the working image with the creation time and history set by SetCreatedAtAndHistory.
+// Once the image is saved, it returns the bytes that were written, until the image is modified.
+// It lets callers sign the config or pre-compute the digest of the image before saving it.
+func (i *CNBImageCore) RawConfigJSON() ([]byte, error) {
+	image, err := i.imageToSave()
+	if err != nil {
+		return nil, err
+	}
+	return image.RawConfigFile()
+}
+
+// RawManifestJSON returns the exact bytes of the manifest that saving the image will write, without saving or modifying it
+// (see RawConfigJSON). Its digest is the digest the image will have.
+func (i *CNBImageCore) RawManifestJSON() ([]byte, error) {
+	image, err := i.imageToSave()
+	if err != nil {
+		return nil, err
+	}
+	return image.RawManifest()
+}
+
+// imageToSave returns the working image with the changes of SetCreatedAtAndHistory, without modifying it.
+func (i *CNBImageCore) imageToSave() (v1.Image, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	configFile, err := i.configFileLocked()
+	if err != nil {
+		return nil, err
+	}
+	configFile = configFile.DeepCopy()
+	i.setCreatedAtAndHistory(configFile)
+	image, err := mutate.ConfigFile(i.Image, configFile)
+	if err != nil {
+		return nil, err
+	}
+	if i.subject != nil {
+		image = mutate.Subject(image, *i.subject).(v1.Image)
 	}
-	return err
+	return image, nil
 }
 
 func getConfigFile(image v1.Image) (*v1.ConfigFile, error) {
@@ -0,0 +1,368 @@
+package imgutil_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestCNBImageCore(t *testing.T) {
+	spec.Run(t, "CNBImageCore", testCNBImageCore, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+// countingImage counts the reads of the config file and manifest of the image,
+// which for a remote image may each be a request to the registry.
+type countingImage struct {
+	v1.Image
+	configFileReads int
+	manifestReads   int
+}
+
+func (i *countingImage) ConfigFile() (*v1.ConfigFile, error) {
+	i.configFileReads++
+	return i.Image.ConfigFile()
+}
+
+func (i *countingImage) Manifest() (*v1.Manifest, error) {
+	i.manifestReads++
+	return i.Image.Manifest()
+}
+
+func testCNBImageCore(t *testing.T, when spec.G, it spec.S) {
+	when("the config cache", func() {
+		var (
+			base *countingImage
+			img  *testImage
+		)
+
+		it.Before(func() {
+			randomImage, err := random.Image(1024, 1)
+			h.AssertNil(t, err)
+			randomImage, err = mutate.Config(randomImage, v1.Config{Labels: map[string]string{"some-label": "some-value"}})
+			h.AssertNil(t, err)
+			base = &countingImage{Image: randomImage}
+			img = newTestImage(t, imgutil.FromBaseImageInstance(base))
+		})
+
+		it("reads the config file and manifest once for a sequence of getters", func() {
+			label, err := img.Label("some-label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "some-value")
+			_, err = img.Subject()
+			h.AssertNil(t, err)
+			configFileReads, manifestReads := base.configFileReads, base.manifestReads
+
+			_, err = img.Labels()
+			h.AssertNil(t, err)
+			_, err = img.OS()
+			h.AssertNil(t, err)
+			_, err = img.Architecture()
+			h.AssertNil(t, err)
+			_, err = img.Env("PATH")
+			h.AssertNil(t, err)
+			_, err = img.History()
+			h.AssertNil(t, err)
+			_, err = img.TopLayer()
+			h.AssertNil(t, err)
+			_, err = img.GetAnnotateRefName()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, base.configFileReads, configFileReads)
+			h.AssertEq(t, base.manifestReads, manifestReads)
+		})
+
+		it("reads the config file of the mutated image after a mutation", func() {
+			_, err := img.Label("some-label")
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("some-label", "some-other-value"))
+
+			label, err := img.Label("some-label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "some-other-value")
+		})
+
+		it("does not share the labels it returns with the cache", func() {
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			labels["some-label"] = "modified"
+
+			label, err := img.Label("some-label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "some-value")
+		})
+	})
+
+	when("it is modified concurrently", func() {
+		// run with -race to check for data races
+		const goroutines = 8
+
+		var img *testImage
+
+		it.Before(func() {
+			base, err := random.Image(1024, 1)
+			h.AssertNil(t, err)
+			img = newTestImage(t, imgutil.FromBaseImageInstance(base))
+		})
+
+		it("applies each concurrent modification", func() {
+			var (
+				wg   sync.WaitGroup
+				errs = make(chan error, 6*goroutines)
+			)
+			for n := 0; n < goroutines; n++ {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					errs <- img.SetLabel(fmt.Sprintf("some-label-%d", n), "some-value")
+					errs <- img.SetEnv(fmt.Sprintf("SOME_KEY_%d", n), "some-value")
+					layer, err := random.Layer(64, "application/vnd.oci.image.layer.v1.tar+gzip")
+					if err != nil {
+						errs <- err
+						return
+					}
+					errs <- img.AddLayerWithHistory(layer, v1.History{})
+				}(n)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := img.Labels()
+					errs <- err
+					_, err = img.TopLayer()
+					errs <- err
+					_, err = img.Digest()
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				h.AssertNil(t, err)
+			}
+
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			for n := 0; n < goroutines; n++ {
+				h.AssertEq(t, labels[fmt.Sprintf("some-label-%d", n)], "some-value")
+				value, err := img.Env(fmt.Sprintf("SOME_KEY_%d", n))
+				h.AssertNil(t, err)
+				h.AssertEq(t, value, "some-value")
+			}
+			layers, err := img.Layers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(layers), 1+goroutines)
+			history, err := img.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(history), 1+goroutines)
+		})
+	})
+
+	when("#RepairHistory", func() {
+		newImageWith := func(history []v1.History) *testImage {
+			base, err := random.Image(64, 3)
+			h.AssertNil(t, err)
+			configFile, err := base.ConfigFile()
+			h.AssertNil(t, err)
+			configFile.History = history
+			base, err = mutate.ConfigFile(base, configFile)
+			h.AssertNil(t, err)
+			return newTestImage(t, imgutil.FromBaseImageInstance(base), imgutil.WithHistory())
+		}
+
+		it("adds entries for the bottom layers when entries are missing", func() {
+			img := newImageWith([]v1.History{{CreatedBy: "layer 2"}, {CreatedBy: "layer 3"}})
+
+			repair, err := img.RepairHistory()
+			h.AssertNil(t, err)
+			h.AssertEq(t, repair, imgutil.HistoryRepair{Layers: 3, Entries: 2, Added: 1})
+			history, err := img.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, history, []v1.History{{}, {CreatedBy: "layer 2"}, {CreatedBy: "layer 3"}})
+		})
+
+		it("marks the extra entries at the bottom as empty layers", func() {
+			img := newImageWith([]v1.History{{CreatedBy: "ENV"}, {CreatedBy: "layer 1"}, {CreatedBy: "CMD", EmptyLayer: true}, {CreatedBy: "layer 2"}, {CreatedBy: "layer 3"}})
+
+			repair, err := img.RepairHistory()
+			h.AssertNil(t, err)
+			h.AssertEq(t, repair, imgutil.HistoryRepair{Layers: 3, Entries: 4, MarkedEmpty: 1})
+			history, err := img.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, imgutil.NormalizedHistory(history, 3), []v1.History{{CreatedBy: "layer 1"}, {CreatedBy: "layer 2"}, {CreatedBy: "layer 3"}})
+		})
+
+		it("leaves a history matching the layers unchanged", func() {
+			img := newImageWith([]v1.History{{CreatedBy: "layer 1"}, {CreatedBy: "layer 2"}, {CreatedBy: "layer 3"}})
+
+			repair, err := img.RepairHistory()
+			h.AssertNil(t, err)
+			h.AssertEq(t, repair.Repaired(), false)
+		})
+	})
+
+	when("#ListReusableLayers", func() {
+		it("lists the layers that can be reused from the previous image", func() {
+			previous, err := random.Image(64, 2)
+			h.AssertNil(t, err)
+			configFile, err := previous.ConfigFile()
+			h.AssertNil(t, err)
+
+			img := newTestImage(t, imgutil.WithPreviousImageInstance(previous))
+			reusable, err := img.ListReusableLayers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, reusable, []string{configFile.RootFS.DiffIDs[0].String(), configFile.RootFS.DiffIDs[1].String()})
+			found, err := img.PreviousImageHasLayer(configFile.RootFS.DiffIDs[1].String())
+			h.AssertNil(t, err)
+			h.AssertEq(t, found, true)
+			found, err = img.PreviousImageHasLayer(addLayerWithFiles(t, img, map[string]string{"some-file": "some-content"}))
+			h.AssertNil(t, err)
+			h.AssertEq(t, found, false)
+		})
+
+		it("lists no layers without a previous image", func() {
+			img := newTestImage(t)
+			reusable, err := img.ListReusableLayers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(reusable), 0)
+		})
+	})
+
+	when("#WithCreatedByTemplate", func() {
+		namedLayer := func(name string) (string, string) {
+			tmpDir := t.TempDir()
+			path, diffID, _ := h.RandomLayer(t, tmpDir)
+			namedPath := filepath.Join(tmpDir, name)
+			h.AssertNil(t, os.Rename(path, namedPath))
+			return namedPath, diffID
+		}
+
+		it("keeps the created by of each layer without history options", func() {
+			img := newTestImage(t, imgutil.WithCreatedByTemplate("cnb: {{.LayerName}}"))
+			launcherPath, _ := namedLayer("launcher.tar")
+			h.AssertNil(t, img.AddLayer(launcherPath))
+			appPath, appDiffID := namedLayer("app.tgz")
+			h.AssertNil(t, img.AddLayerWithDiffIDAndHistory(appPath, appDiffID, v1.History{
+				Author:    "some-author",
+				CreatedBy: "some-created-by",
+				Comment:   "some-comment",
+			}))
+			h.AssertNil(t, img.SetCreatedAtAndHistory())
+
+			history, err := img.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, history, []v1.History{
+				{Created: v1.Time{Time: imgutil.NormalizedDateTime}, CreatedBy: "cnb: launcher"},
+				{Created: v1.Time{Time: imgutil.NormalizedDateTime}, CreatedBy: "some-created-by"},
+			})
+		})
+
+		it("provides the diff ID of the layer", func() {
+			img := newTestImage(t, imgutil.WithCreatedByTemplate("layer {{.DiffID}}"))
+			layer, err := random.Layer(1024, types.OCILayer)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayerWithHistory(layer, v1.History{}))
+			h.AssertNil(t, img.SetCreatedAtAndHistory())
+
+			diffID, err := layer.DiffID()
+			h.AssertNil(t, err)
+			history, err := img.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, history[0].CreatedBy, "layer "+diffID.String())
+		})
+
+		it("zeroes the history without a template", func() {
+			img := newTestImage(t)
+			appPath, appDiffID := namedLayer("app.tar")
+			h.AssertNil(t, img.AddLayerWithDiffIDAndHistory(appPath, appDiffID, v1.History{CreatedBy: "some-created-by"}))
+			h.AssertNil(t, img.SetCreatedAtAndHistory())
+
+			history, err := img.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, history, []v1.History{{Created: v1.Time{Time: imgutil.NormalizedDateTime}}})
+		})
+	})
+
+	when("#LayerName", func() {
+		it("is the base name of the file without its tar extension", func() {
+			h.AssertEq(t, imgutil.LayerName("/layers/launcher.tar"), "launcher")
+			h.AssertEq(t, imgutil.LayerName("/layers/app.tar.gz"), "app")
+			h.AssertEq(t, imgutil.LayerName("/layers/app.tgz"), "app")
+			h.AssertEq(t, imgutil.LayerName("/layers/sha256:abc"), "sha256:abc")
+		})
+	})
+
+	when("the created times are normalized", func() {
+		var (
+			epoch       = time.Unix(0, 0).UTC()
+			assertTimes = func(img *testImage, expected time.Time) {
+				t.Helper()
+				configFile, err := img.ConfigFile()
+				h.AssertNil(t, err)
+				h.AssertEq(t, configFile.Created.Time.UTC(), expected)
+				h.AssertEq(t, len(configFile.History), 1)
+				h.AssertEq(t, configFile.History[0].Created.Time.UTC(), expected)
+			}
+			newImage = func(ops ...imgutil.ImageOption) *testImage {
+				t.Helper()
+				img := newTestImage(t, ops...)
+				layer, err := random.Layer(1024, types.OCILayer)
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.AddLayerWithHistory(layer, v1.History{Created: v1.Time{Time: time.Now()}}))
+				return img
+			}
+		)
+
+		it("normalizes to NormalizedDateTime by default", func() {
+			img := newImage()
+			h.AssertNil(t, img.SetCreatedAtAndHistory())
+			assertTimes(img, imgutil.NormalizedDateTime)
+		})
+
+		when("#WithNormalizedDateTime", func() {
+			it("normalizes the created times to the given time", func() {
+				img := newImage(imgutil.WithNormalizedDateTime(epoch))
+				h.AssertNil(t, img.SetCreatedAtAndHistory())
+				assertTimes(img, epoch)
+
+				saveReport, err := img.Report()
+				h.AssertNil(t, err)
+				h.AssertEq(t, saveReport.Reproducible, true)
+			})
+
+			it("is overridden by WithCreatedAt", func() {
+				createdAt := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+				img := newImage(imgutil.WithNormalizedDateTime(epoch), imgutil.WithCreatedAt(createdAt))
+				h.AssertNil(t, img.SetCreatedAtAndHistory())
+				assertTimes(img, createdAt)
+
+				saveReport, err := img.Report()
+				h.AssertNil(t, err)
+				h.AssertEq(t, saveReport.Reproducible, false)
+			})
+		})
+
+		when("#SetNormalizedDateTime", func() {
+			it("normalizes the created times of the following saves", func() {
+				img := newImage()
+				h.AssertNil(t, img.SetCreatedAtAndHistory())
+				assertTimes(img, imgutil.NormalizedDateTime)
+
+				img.SetNormalizedDateTime(epoch)
+				h.AssertNil(t, img.SetCreatedAtAndHistory())
+				assertTimes(img, epoch)
+			})
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package imgutil
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil/internal/v1util"
+)
+
+// Features returns the `features` of the platform of the config descriptor of the working image manifest.
+func (i *CNBImageCore) Features() ([]string, error) {
+	manifest, err := i.manifest()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Config.Platform == nil {
+		return nil, nil
+	}
+	return append([]string(nil), manifest.Config.Platform.Features...), nil
+}
+
+// SetFeatures sets the `features` of the platform of the config descriptor of the working image manifest.
+// They are saved on the manifest by the layout and remote implementations,
+// and used for the platform of the descriptor of the image when it is added to an index (see DescriptorFor).
+// The local implementation doesn't save them, as the daemon doesn't store manifests.
+func (i *CNBImageCore) SetFeatures(features []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	configFile, err := i.configFileLocked()
+	if err != nil {
+		return err
+	}
+	return i.mutateConfigDescriptor(func(desc *v1.Descriptor) {
+		if len(features) == 0 {
+			desc.Platform = nil
+			return
+		}
+		desc.Platform = &v1.Platform{
+			OS:           configFile.OS,
+			Architecture: configFile.Architecture,
+			Variant:      configFile.Variant,
+			OSVersion:    configFile.OSVersion,
+			OSFeatures:   configFile.OSFeatures,
+			Features:     features,
+		}
+	})
+}
+
+// URLs returns the `urls` of the config descriptor of the working image manifest.
+func (i *CNBImageCore) URLs() ([]string, error) {
+	manifest, err := i.manifest()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), manifest.Config.URLs...), nil
+}
+
+// SetURLs sets the `urls` of the config descriptor of the working image manifest.
+// As with SetFeatures, they are saved by the layout and remote implementations and used by DescriptorFor.
+func (i *CNBImageCore) SetURLs(urls []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.mutateConfigDescriptor(func(desc *v1.Descriptor) {
+		desc.URLs = urls
+	})
+}
+
+// mutateConfigDescriptor replaces the working image with one whose config descriptor is modified by the given function.
+// Callers must hold the lock.
+func (i *CNBImageCore) mutateConfigDescriptor(mutate func(*v1.Descriptor)) error {
+	manifest, err := i.manifestLocked()
+	if err != nil {
+		return err
+	}
+	config := *manifest.Config.DeepCopy()
+	mutate(&config)
+	i.setImage(v1util.WithConfigDescriptor(i.Image, config))
+	i.ensureSubject()
+	return nil
+}
@@ -0,0 +1,79 @@
+package imgutil
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil/layer"
+)
+
+// NewImageFunc returns a new image with the given name (e.g. a repository name or a layout path) starting from the given base image,
+// which is how Copy creates the copies of images of a kind.
+type NewImageFunc func(name string, base v1.Image, ops ...ImageOption) (Image, error)
+
+type CopyOption func(*CopyOptions)
+
+// CopyOptions describe how Copy creates the copies of images.
+type CopyOptions struct {
+	Destinations    map[string]NewImageFunc
+	ImageOptions    []ImageOption
+	LayerTransforms []layer.EntryTransform
+}
+
+// WithCopyDestination makes Copy create the copies of images of the given kind (e.g. `remote`) with newImage.
+// The packages implementing imgutil.Image provide it, e.g. as remote.CopyDestination.
+func WithCopyDestination(kind string, newImage NewImageFunc) CopyOption {
+	return func(o *CopyOptions) {
+		if o.Destinations == nil {
+			o.Destinations = map[string]NewImageFunc{}
+		}
+		o.Destinations[kind] = newImage
+	}
+}
+
+// WithCopyImageOptions provides options to create the copy with (e.g. WithCreatedAt or WithHistory).
+func WithCopyImageOptions(ops ...ImageOption) CopyOption {
+	return func(o *CopyOptions) {
+		o.ImageOptions = append(o.ImageOptions, ops...)
+	}
+}
+
+// WithCopyLayerTransforms rewrites the entries of each layer of the copy with the given transforms (see TransformLayers),
+// e.g. to enforce image hygiene policies.
+func WithCopyLayerTransforms(transforms ...layer.EntryTransform) CopyOption {
+	return func(o *CopyOptions) {
+		o.LayerTransforms = append(o.LayerTransforms, transforms...)
+	}
+}
+
+// Copy saves a copy of src as an image of the given kind (e.g. `layout`) with the given name,
+// and returns the copy. The copy is created from the underlying image of src, so that its blobs are reused where possible
+// (e.g. layers that already exist in the destination repository are not pushed again), and is saved as images of that kind are
+// (e.g. its creation time and history are normalized unless options such as WithCreatedAt and WithHistory are provided).
+// The destination of the kind must be provided, e.g. with remote.CopyDestination.
+func Copy(src Image, dstKind, dstRef string, ops ...CopyOption) (Image, error) {
+	options := CopyOptions{}
+	for _, op := range ops {
+		op(&options)
+	}
+	newImage, ok := options.Destinations[dstKind]
+	if !ok {
+		return nil, fmt.Errorf("no destination provided to copy images to %s images", dstKind)
+	}
+	base := src.UnderlyingImage()
+	if len(options.LayerTransforms) > 0 {
+		var err error
+		if base, err = TransformLayers(base, options.LayerTransforms...); err != nil {
+			return nil, fmt.Errorf("transforming layers of %s: %w", src.Name(), err)
+		}
+	}
+	dst, err := newImage(dstRef, base, options.ImageOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("creating copy of %s: %w", src.Name(), err)
+	}
+	if err = dst.Save(); err != nil {
+		return nil, fmt.Errorf("saving copy of %s to %s: %w", src.Name(), dstRef, err)
+	}
+	return dst, nil
+}
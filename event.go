@@ -0,0 +1,34 @@
+package imgutil
+
+// EventType identifies a change made to an image.
+type EventType string
+
+const (
+	EventAddLayer   EventType = "add-layer"
+	EventReuseLayer EventType = "reuse-layer"
+	EventSetLabel   EventType = "set-label"
+	EventSave       EventType = "save"
+)
+
+// Event describes a change made to an image, so that callers can keep an audit log of the exported image.
+type Event struct {
+	Type EventType
+	// DiffID is the layer added or reused, for EventAddLayer and EventReuseLayer.
+	DiffID string
+	// Key and Value are the label set, for EventSetLabel.
+	Key   string
+	Value string
+	// Names are the names the image was saved as, and Identifier is the saved image's identifier, for EventSave.
+	Names      []string
+	Identifier string
+}
+
+// EventHandler is called synchronously with each event, in the order the changes are made.
+type EventHandler func(Event)
+
+// Emit calls each registered event handler with the given event.
+func (i *CNBImageCore) Emit(event Event) {
+	for _, handler := range i.eventHandlers {
+		handler(event)
+	}
+}
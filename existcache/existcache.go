@@ -0,0 +1,77 @@
+// Package existcache provides an on-disk record of the blobs known to exist in registry repositories,
+// so that pushes can skip checking for them (see remote.WithBlobExistenceCache).
+package existcache
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// Cache records which blobs exist in which repositories, in a directory holding an empty file for each blob
+// (named after the digest of the blob) in a directory for each repository (named after the hash of the repository name).
+// A blob is known to exist for the duration of the TTL after it was last recorded,
+// as registries may remove blobs (e.g. with garbage collection).
+// Cache can be shared by processes using the same directory.
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+var _ imgutil.BlobExistenceCache = &Cache{}
+
+// New returns a cache recording blobs in the given directory, which is created if it doesn't exist.
+// If ttl is not positive, recorded blobs never expire.
+func New(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, ttl: ttl}, nil
+}
+
+// Exists reports whether the blob with the given digest was recorded in the given repository within the TTL.
+func (c *Cache) Exists(repo string, digest v1.Hash) bool {
+	info, err := os.Stat(c.path(repo, digest))
+	if err != nil {
+		return false
+	}
+	return c.ttl <= 0 || time.Since(info.ModTime()) < c.ttl
+}
+
+// Record records that the blob with the given digest exists in the given repository.
+func (c *Cache) Record(repo string, digest v1.Hash) error {
+	path := c.path(repo, digest)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Forget removes the record of the blob with the given digest in the given repository.
+func (c *Cache) Forget(repo string, digest v1.Hash) error {
+	err := os.Remove(c.path(repo, digest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (c *Cache) path(repo string, digest v1.Hash) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%x", sha256.Sum256([]byte(repo))), digest.Algorithm+"-"+digest.Hex)
+}
@@ -0,0 +1,76 @@
+package existcache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/existcache"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestExistCache(t *testing.T) {
+	spec.Run(t, "ExistCache", testExistCache, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testExistCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir string
+		digest = v1.Hash{Algorithm: "sha256", Hex: "1111111111111111111111111111111111111111111111111111111111111111"}
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "existcache")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("records blobs per repository", func() {
+		c, err := existcache.New(filepath.Join(tmpDir, "cache"), 0)
+		h.AssertNil(t, err)
+		h.AssertEq(t, c.Exists("registry.example.com/some-repo", digest), false)
+
+		h.AssertNil(t, c.Record("registry.example.com/some-repo", digest))
+		h.AssertEq(t, c.Exists("registry.example.com/some-repo", digest), true)
+		h.AssertEq(t, c.Exists("registry.example.com/other-repo", digest), false)
+
+		h.AssertNil(t, c.Forget("registry.example.com/some-repo", digest))
+		h.AssertEq(t, c.Exists("registry.example.com/some-repo", digest), false)
+	})
+
+	it("is shared by caches using the same directory", func() {
+		c, err := existcache.New(filepath.Join(tmpDir, "cache"), 0)
+		h.AssertNil(t, err)
+		h.AssertNil(t, c.Record("registry.example.com/some-repo", digest))
+
+		other, err := existcache.New(filepath.Join(tmpDir, "cache"), 0)
+		h.AssertNil(t, err)
+		h.AssertEq(t, other.Exists("registry.example.com/some-repo", digest), true)
+	})
+
+	when("the TTL has passed", func() {
+		it("no longer reports the blob", func() {
+			c, err := existcache.New(filepath.Join(tmpDir, "cache"), time.Hour)
+			h.AssertNil(t, err)
+			h.AssertNil(t, c.Record("registry.example.com/some-repo", digest))
+			h.AssertEq(t, c.Exists("registry.example.com/some-repo", digest), true)
+
+			expired, err := existcache.New(filepath.Join(tmpDir, "cache"), time.Nanosecond)
+			h.AssertNil(t, err)
+			time.Sleep(time.Millisecond)
+			h.AssertEq(t, expired.Exists("registry.example.com/some-repo", digest), false)
+
+			h.AssertNil(t, expired.Record("registry.example.com/some-repo", digest))
+			h.AssertEq(t, c.Exists("registry.example.com/some-repo", digest), true)
+		})
+	})
+}
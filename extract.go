@@ -0,0 +1,205 @@
+package imgutil
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil/layer"
+)
+
+type ExtractOption func(*extractOptions)
+
+type extractOptions struct {
+	aboveDiffID string
+	maxFileSize int64
+}
+
+// DefaultExtractMaxFileSize is the size in bytes above which Extract rejects a file, unless ExtractMaxFileSize is provided.
+const DefaultExtractMaxFileSize int64 = 10 << 30
+
+// ExtractMaxFileSize rejects the files of the layers larger than the given size in bytes,
+// so that extracting untrusted layers can't fill the disk with a single entry. A size of zero or less disables the limit.
+func ExtractMaxFileSize(size int64) ExtractOption {
+	return func(o *extractOptions) {
+		o.maxFileSize = size
+	}
+}
+
+// ExtractAboveLayer extracts only the layers above the layer with the given diff ID
+// (e.g. to inspect the layers added on top of a base image).
+func ExtractAboveLayer(diffID string) ExtractOption {
+	return func(o *extractOptions) {
+		o.aboveDiffID = diffID
+	}
+}
+
+// Extract applies the layers of the image, from the bottom up, to the given directory.
+// Whiteouts remove the files extracted from lower layers, and entries that would be written outside of the directory
+// (e.g. through a symbolic link) or larger than the maximum file size (see ExtractMaxFileSize) are rejected.
+// The modes of directories are applied once all the layers are extracted, so that read-only directories can be extracted into.
+// File ownership is not preserved.
+func Extract(img Image, dest string, ops ...ExtractOption) error {
+	options := &extractOptions{maxFileSize: DefaultExtractMaxFileSize}
+	for _, op := range ops {
+		op(options)
+	}
+
+	configFile, err := img.UnderlyingImage().ConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to get config file: %w", err)
+	}
+	diffIDs := configFile.RootFS.DiffIDs
+	if options.aboveDiffID != "" {
+		idx := indexOf(diffIDs, options.aboveDiffID)
+		if idx < 0 {
+			return ErrLayerNotFound{DiffID: options.aboveDiffID}
+		}
+		diffIDs = diffIDs[idx+1:]
+	}
+
+	if err = os.MkdirAll(dest, 0750); err != nil {
+		return err
+	}
+	dirModes := map[string]os.FileMode{}
+	for _, diffID := range diffIDs {
+		if err = extractLayer(img, diffID.String(), dest, configFile.OS == "windows", options, dirModes); err != nil {
+			return fmt.Errorf("failed to extract layer %s: %w", diffID, err)
+		}
+	}
+	return applyDirModes(dest, dirModes)
+}
+
+// applyDirModes sets the modes of the extracted directories, from the deepest up,
+// so that a directory is still searchable when the modes of its subdirectories are set.
+func applyDirModes(dest string, dirModes map[string]os.FileMode) error {
+	names := make([]string, 0, len(dirModes))
+	for name := range dirModes {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		target, err := layer.SecureJoin(dest, name)
+		if err != nil {
+			return err
+		}
+		// the directory may have been removed or replaced by an upper layer
+		if info, err := os.Lstat(target); err != nil || !info.IsDir() {
+			continue
+		}
+		if err = os.Chmod(target, dirModes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexOf(diffIDs []v1.Hash, diffID string) int {
+	for idx, h := range diffIDs {
+		if h.String() == diffID {
+			return idx
+		}
+	}
+	return -1
+}
+
+func extractLayer(img Image, diffID, dest string, windows bool, options *extractOptions, dirModes map[string]os.FileMode) error {
+	rc, err := img.GetLayer(diffID)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	written := map[string]bool{} // entries extracted from this layer must survive its opaque whiteouts
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := entryPath(header.Name, windows)
+		if !ok {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname, _ = entryPath(header.Linkname, windows)
+		}
+		if whiteout, ok := layer.ParseWhiteout(name); ok {
+			if err = whiteout.ApplyKeeping(dest, func(posixPath string) bool { return written[posixPath] }); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = extractEntry(tr, header, name, dest, options); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		written[name] = true
+		if header.Typeflag == tar.TypeDir {
+			dirModes[name] = header.FileInfo().Mode().Perm()
+		}
+	}
+}
+
+func extractEntry(tr *tar.Reader, header *tar.Header, name, dest string, options *extractOptions) error {
+	target, err := layer.SecureJoin(dest, name)
+	if err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeDir {
+		if err = os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return err
+		}
+		// replace what lower layers extracted, without following symbolic links
+		if err = os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+			if err = os.Remove(target); err != nil {
+				return err
+			}
+		}
+		// the mode is applied once all the layers are extracted (see applyDirModes)
+		return os.MkdirAll(target, 0750)
+	case tar.TypeReg:
+		if options.maxFileSize > 0 && header.Size > options.maxFileSize {
+			return fmt.Errorf("file is %d bytes, larger than the limit of %d bytes", header.Size, options.maxFileSize)
+		}
+		f, err := os.OpenFile(filepath.Clean(target), os.O_CREATE|os.O_EXCL|os.O_WRONLY, header.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.CopyN(f, tr, header.Size); err != nil {
+			f.Close()
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(target, header.ModTime, header.ModTime)
+	case tar.TypeSymlink:
+		return os.Symlink(header.Linkname, target)
+	case tar.TypeLink:
+		linkTarget, err := layer.SecureJoin(dest, header.Linkname)
+		if err != nil {
+			return err
+		}
+		return os.Link(linkTarget, target)
+	default:
+		// devices, fifos, ... are not extracted
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package imgutil_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestExtract(t *testing.T) {
+	spec.Run(t, "Extract", testExtract, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testExtract(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir     string
+		dest       string
+		img        *testImage
+		baseDiffID string
+		err        error
+	)
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "extract")
+		h.AssertNil(t, err)
+		dest = filepath.Join(tmpDir, "rootfs")
+		img = newTestImage(t)
+
+		baseDiffID = addLayerWithFiles(t, img, map[string]string{
+			"etc/some-file":       "base",
+			"etc/removed-file":    "base",
+			"opaque/removed-file": "base",
+		})
+		whiteouts, err := layer.WhiteoutLayer([]layer.Whiteout{
+			{Path: "/etc/removed-file"},
+			{Path: "/opaque", Opaque: true},
+		})
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.AddLayerWithHistory(whiteouts, v1.History{}))
+		addLayerWithFiles(t, img, map[string]string{"etc/some-file": "top", "opaque/new-file": "top"})
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	assertFile := func(name, expected string) {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(dest, name))
+		h.AssertNil(t, err)
+		h.AssertEq(t, string(content), expected)
+	}
+
+	assertMissing := func(name string) {
+		t.Helper()
+		_, err := os.Lstat(filepath.Join(dest, name))
+		h.AssertEq(t, os.IsNotExist(err), true)
+	}
+
+	when("#Extract", func() {
+		it("applies all layers and whiteouts", func() {
+			h.AssertNil(t, imgutil.Extract(img, dest))
+
+			assertFile("etc/some-file", "top")
+			assertFile("opaque/new-file", "top")
+			assertMissing("etc/removed-file")
+			assertMissing("opaque/removed-file")
+		})
+
+		it("extracts only the layers above the given layer", func() {
+			h.AssertNil(t, imgutil.Extract(img, dest, imgutil.ExtractAboveLayer(baseDiffID)))
+
+			assertFile("etc/some-file", "top")
+			assertMissing("etc/removed-file")
+			assertMissing("opaque/removed-file")
+		})
+
+		it("applies the modes of directories once all the layers are extracted", func() {
+			for _, headers := range [][]*tar.Header{
+				{{Name: "read-only/", Typeflag: tar.TypeDir, Mode: 0555}},
+				{{Name: "read-only/some-file", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("top"))}},
+			} {
+				buf := &bytes.Buffer{}
+				tw := tar.NewWriter(buf)
+				for _, header := range headers {
+					h.AssertNil(t, tw.WriteHeader(header))
+					if header.Size > 0 {
+						_, err = tw.Write([]byte("top"))
+						h.AssertNil(t, err)
+					}
+				}
+				h.AssertNil(t, tw.Close())
+				l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+				})
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.AddLayerWithHistory(l, v1.History{}))
+			}
+
+			h.AssertNil(t, imgutil.Extract(img, dest))
+			defer os.Chmod(filepath.Join(dest, "read-only"), 0755)
+
+			assertFile("read-only/some-file", "top")
+			info, err := os.Stat(filepath.Join(dest, "read-only"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, info.Mode().Perm(), os.FileMode(0555))
+		})
+
+		it("does not empty directories outside of the destination through a symbolic link", func() {
+			outside, err := os.MkdirTemp("", "extract-outside")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(outside)
+			h.AssertNil(t, os.WriteFile(filepath.Join(outside, "some-file"), []byte{}, 0600))
+
+			for _, header := range []*tar.Header{
+				{Name: "linked", Typeflag: tar.TypeSymlink, Linkname: outside},
+				{Name: "linked/" + layer.OpaqueWhiteout, Typeflag: tar.TypeReg, Mode: 0644},
+			} {
+				buf := &bytes.Buffer{}
+				tw := tar.NewWriter(buf)
+				h.AssertNil(t, tw.WriteHeader(header))
+				h.AssertNil(t, tw.Close())
+				l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+				})
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.AddLayerWithHistory(l, v1.History{}))
+			}
+
+			h.AssertNil(t, imgutil.Extract(img, dest))
+
+			_, err = os.Stat(filepath.Join(outside, "some-file"))
+			h.AssertNil(t, err)
+		})
+
+		it("rejects files larger than the maximum file size", func() {
+			err := imgutil.Extract(img, dest, imgutil.ExtractMaxFileSize(2))
+			h.AssertError(t, err, "larger than the limit of 2 bytes")
+		})
+
+		it("fails when the given layer does not exist", func() {
+			err := imgutil.Extract(img, dest, imgutil.ExtractAboveLayer("sha256:0000000000000000000000000000000000000000000000000000000000000000"))
+			h.AssertError(t, err, "failed to find layer")
+		})
+	})
+
+	when("#ReadFile", func() {
+		it("returns the effective content of the file", func() {
+			content, err := img.ReadFile("/etc/some-file")
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(content), "top")
+		})
+
+		it("fails when the file was removed", func() {
+			_, err := img.ReadFile("/opaque/removed-file")
+			h.AssertEq(t, errors.Is(err, fs.ErrNotExist), true)
+		})
+
+		it("follows symbolic links to the directories in the path", func() {
+			buf := &bytes.Buffer{}
+			tw := tar.NewWriter(buf)
+			for _, header := range []*tar.Header{
+				{Name: "lib/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "lib/some-lib", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("lib"))},
+				{Name: "usr/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "usr/lib", Typeflag: tar.TypeSymlink, Linkname: "../lib"},
+				{Name: "loop", Typeflag: tar.TypeSymlink, Linkname: "/loop/dir"},
+			} {
+				h.AssertNil(t, tw.WriteHeader(header))
+				if header.Size > 0 {
+					_, err = tw.Write([]byte("lib"))
+					h.AssertNil(t, err)
+				}
+			}
+			h.AssertNil(t, tw.Close())
+			l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+			})
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayerWithHistory(l, v1.History{}))
+
+			content, err := img.ReadFile("/usr/lib/some-lib")
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(content), "lib")
+
+			_, err = img.ReadFile("/usr/lib/missing-lib")
+			h.AssertEq(t, errors.Is(err, fs.ErrNotExist), true)
+
+			_, err = img.ReadFile("/loop/some-file")
+			h.AssertError(t, err, "too many links")
+		})
+	})
+
+	when("#WalkFiles", func() {
+		it("visits each effective entry once", func() {
+			var files []string
+			h.AssertNil(t, img.WalkFiles(func(path string, header *tar.Header, _ io.Reader) error {
+				if header.Typeflag == tar.TypeReg {
+					files = append(files, path)
+				}
+				return nil
+			}))
+			sort.Strings(files)
+			h.AssertEq(t, files, []string{"/etc/some-file", "/opaque/new-file"})
+		})
+	})
+}
@@ -18,7 +18,13 @@ import (
 	"github.com/buildpacks/imgutil"
 )
 
-var _ imgutil.Image = &Image{}
+var (
+	_ imgutil.Image          = &Image{}
+	_ imgutil.OptionsRebaser = &Image{}
+	_ imgutil.LayerTracker   = &Image{}
+	_ imgutil.Reporter       = &Image{}
+	_ imgutil.AnnotatedImage = &Image{}
+)
 
 func NewImage(name, topLayerSha string, identifier imgutil.Identifier) *Image {
 	return &Image{
@@ -35,6 +41,7 @@ func NewImage(name, topLayerSha string, identifier imgutil.Identifier) *Image {
 		os:               "linux",
 		osVersion:        "",
 		architecture:     "amd64",
+		annotations:      map[string]string{},
 		savedAnnotations: map[string]string{},
 	}
 }
@@ -64,7 +71,11 @@ type Image struct {
 	savedNames       map[string]bool
 	manifestSize     int64
 	refName          string
+	annotations      map[string]string
 	savedAnnotations map[string]string
+	subject          *v1.Descriptor
+	events           []imgutil.Event
+	lastLayer        *imgutil.LayerInfo
 }
 
 func (i *Image) CreatedAt() (time.Time, error) {
@@ -123,8 +134,22 @@ func (i *Image) UnderlyingImage() v1.Image {
 	return nil
 }
 
-func (i *Image) Rebase(_ string, newBase imgutil.Image) error {
+func (i *Image) Rebase(baseTopLayerDiffID string, newBase imgutil.Image) error {
+	return i.RebaseWithOptions(baseTopLayerDiffID, newBase)
+}
+
+func (i *Image) RebaseWithOptions(_ string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	options := imgutil.GetRebaseOptions(ops...)
 	i.base = newBase.Name()
+	if fakeBase, ok := newBase.(*Image); ok {
+		i.labels = imgutil.MergeLabels(options.Labels, i.labels, fakeBase.labels)
+		i.entryPoint = imgutil.MergeEntrypoint(options.Entrypoint, i.entryPoint, fakeBase.entryPoint)
+		for key, val := range fakeBase.env {
+			if _, ok := i.env[key]; options.Env == imgutil.PreferNewBaseValues || (options.Env == imgutil.PreferImageValues && !ok) {
+				i.env[key] = val
+			}
+		}
+	}
 	return nil
 }
 
@@ -133,6 +158,7 @@ func (i *Image) SetLabel(k string, v string) error {
 		i.labels = map[string]string{}
 	}
 	i.labels[k] = v
+	i.events = append(i.events, imgutil.Event{Type: imgutil.EventSetLabel, Key: k, Value: v})
 	return nil
 }
 
@@ -161,6 +187,15 @@ func (i *Image) SetOSVersion(v string) error {
 	return nil
 }
 
+func (i *Image) Subject() (*v1.Descriptor, error) {
+	return i.subject, nil
+}
+
+func (i *Image) SetSubject(subject v1.Descriptor) error {
+	i.subject = &subject
+	return nil
+}
+
 func (i *Image) SetArchitecture(a string) error {
 	i.architecture = a
 	return nil
@@ -208,6 +243,8 @@ func (i *Image) AddLayer(path string) error {
 	i.layersMap["sha256:"+sha] = path
 	i.layers = append(i.layers, path)
 	i.history = append(i.history, v1.History{})
+	i.events = append(i.events, imgutil.Event{Type: imgutil.EventAddLayer, DiffID: "sha256:" + sha})
+	i.setLastLayer("sha256:"+sha, path)
 	return nil
 }
 
@@ -215,6 +252,8 @@ func (i *Image) AddLayerWithDiffID(path string, diffID string) error {
 	i.layersMap[diffID] = path
 	i.layers = append(i.layers, path)
 	i.history = append(i.history, v1.History{})
+	i.events = append(i.events, imgutil.Event{Type: imgutil.EventAddLayer, DiffID: diffID})
+	i.setLastLayer(diffID, path)
 	return nil
 }
 
@@ -222,9 +261,33 @@ func (i *Image) AddLayerWithDiffIDAndHistory(path, diffID string, history v1.His
 	i.layersMap[diffID] = path
 	i.layers = append(i.layers, path)
 	i.history = append(i.history, history)
+	i.events = append(i.events, imgutil.Event{Type: imgutil.EventAddLayer, DiffID: diffID})
+	i.setLastLayer(diffID, path)
 	return nil
 }
 
+// AddLayerWithHistory writes the uncompressed content of the layer to a temporary file, as fake layers are files.
+func (i *Image) AddLayerWithHistory(layer v1.Layer, history v1.History) error {
+	diffID, err := layer.DiffID()
+	if err != nil {
+		return errors.Wrap(err, "failed to get layer diff ID")
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return errors.Wrap(err, "failed to read layer")
+	}
+	defer rc.Close()
+	f, err := os.CreateTemp("", "fake-layer-*.tar")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, rc); err != nil {
+		return errors.Wrap(err, "failed to write layer")
+	}
+	return i.AddLayerWithDiffIDAndHistory(f.Name(), diffID.String(), history)
+}
+
 func shaForFile(path string) (string, error) {
 	rc, err := os.Open(filepath.Clean(path))
 	if err != nil {
@@ -260,9 +323,27 @@ func (i *Image) ReuseLayer(sha string) error {
 	}
 	i.reusedLayers = append(i.reusedLayers, sha)
 	i.layersMap[sha] = prevLayer
+	i.events = append(i.events, imgutil.Event{Type: imgutil.EventReuseLayer, DiffID: sha})
+	i.setLastLayer(sha, prevLayer)
 	return nil
 }
 
+// setLastLayer records the layer at path as the last layer; as fake layers are not compressed, the digest is the diff ID.
+func (i *Image) setLastLayer(diffID, path string) {
+	info := imgutil.LayerInfo{DiffID: diffID, Digest: diffID}
+	if fi, err := os.Stat(path); err == nil {
+		info.Size = fi.Size()
+	}
+	i.lastLayer = &info
+}
+
+func (i *Image) LastLayer() (imgutil.LayerInfo, error) {
+	if i.lastLayer == nil {
+		return imgutil.LayerInfo{}, errors.New("no layer was added")
+	}
+	return *i.lastLayer, nil
+}
+
 func (i *Image) ReuseLayerWithHistory(sha string, history v1.History) error {
 	if err := i.ReuseLayer(sha); err != nil {
 		return err
@@ -294,24 +375,38 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 	}
 
 	allNames := append([]string{name}, additionalNames...)
+	for k, v := range i.annotations {
+		i.savedAnnotations[k] = v
+	}
 	if i.refName != "" {
 		i.savedAnnotations["org.opencontainers.image.ref.name"] = i.refName
 	}
 
-	var errs []imgutil.SaveDiagnostic
+	var (
+		errs  []imgutil.SaveDiagnostic
+		saved = map[string]string{}
+	)
 	for _, n := range allNames {
 		_, err := registryName.ParseReference(n, registryName.WeakValidation)
 		if err != nil {
 			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
 		} else {
 			i.savedNames[n] = true
+			if i.identifier != nil {
+				saved[n] = i.identifier.String()
+			}
 		}
 	}
 
 	if len(errs) > 0 {
-		return imgutil.SaveError{Errors: errs}
+		return imgutil.SaveError{Errors: errs, Saved: saved}
 	}
 
+	event := imgutil.Event{Type: imgutil.EventSave, Names: allNames}
+	if i.identifier != nil {
+		event.Identifier = i.identifier.String()
+	}
+	i.events = append(i.events, event)
 	return nil
 }
 
@@ -361,6 +456,24 @@ func (i *Image) GetAnnotateRefName() (string, error) {
 	return i.refName, nil
 }
 
+func (i *Image) Annotations() (map[string]string, error) {
+	annotations := make(map[string]string, len(i.annotations)+1)
+	for k, v := range i.annotations {
+		annotations[k] = v
+	}
+	if i.refName != "" {
+		annotations["org.opencontainers.image.ref.name"] = i.refName
+	}
+	return annotations, nil
+}
+
+func (i *Image) SetAnnotations(annotations map[string]string) error {
+	for k, v := range annotations {
+		i.annotations[k] = v
+	}
+	return nil
+}
+
 // test methods
 
 func (i *Image) SetIdentifier(identifier imgutil.Identifier) {
@@ -395,6 +508,25 @@ func (i *Image) WorkingDir() (string, error) {
 	return i.workingDir, nil
 }
 
+func (i *Image) ReadFile(path string) ([]byte, error) {
+	return i.fileWalker().ReadFile(path)
+}
+
+func (i *Image) WalkFiles(fn imgutil.WalkFunc) error {
+	return i.fileWalker().Walk(fn)
+}
+
+func (i *Image) fileWalker() imgutil.FileWalker {
+	walker := imgutil.FileWalker{Windows: i.os == "windows"}
+	for _, layerPath := range i.layers {
+		layerPath := layerPath
+		walker.Layers = append(walker.Layers, func() (io.ReadCloser, error) {
+			return os.Open(filepath.Clean(layerPath))
+		})
+	}
+	return walker
+}
+
 func (i *Image) AddPreviousLayer(sha, path string) {
 	i.prevLayersMap[sha] = path
 }
@@ -504,6 +636,39 @@ func (i *Image) ManifestSize() (int64, error) {
 	return i.manifestSize, nil
 }
 
+// Report describes the layers of the fake image; the digest is the identifier of the image, if any.
+func (i *Image) Report() (imgutil.Report, error) {
+	report := imgutil.Report{
+		LayerCount:   len(i.layers),
+		Reproducible: i.createdAt.Equal(imgutil.NormalizedDateTime),
+	}
+	if i.identifier != nil {
+		report.Digest = i.identifier.String()
+	}
+	for _, path := range i.layers {
+		if fi, err := os.Stat(path); err == nil {
+			report.CompressedSize += fi.Size()
+		}
+	}
+	return report, nil
+}
+
 func (i *Image) SavedAnnotations() map[string]string {
 	return i.savedAnnotations
 }
+
+// Events returns the changes made to the image (labels set, layers added or reused, and saves), in order.
+func (i *Image) Events() []imgutil.Event {
+	return i.events
+}
+
+// EventsOfType returns the changes of the given type made to the image, in order.
+func (i *Image) EventsOfType(eventType imgutil.EventType) []imgutil.Event {
+	var events []imgutil.Event
+	for _, event := range i.events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -9,6 +9,8 @@ import (
 	"sort"
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -133,6 +135,77 @@ Layers
 		})
 	})
 
+	when("#ReadFile", func() {
+		var (
+			image      *fakes.Image
+			layer1Path string
+			layer2Path string
+		)
+
+		it.Before(func() {
+			var err error
+
+			image = fakes.NewImage("some-image", "", nil)
+
+			layer1Path, err = createLayerTar(map[string]string{
+				"/cnb/order.toml":   "[[order]]",
+				"/cnb/removed.toml": "[[removed]]",
+			})
+			h.AssertNil(t, err)
+			h.AssertNil(t, image.AddLayer(layer1Path))
+
+			layer2Path, err = createLayerTar(map[string]string{
+				"/cnb/order.toml":       "[[order.group]]",
+				"/cnb/.wh.removed.toml": "",
+			})
+			h.AssertNil(t, err)
+			h.AssertNil(t, image.AddLayer(layer2Path))
+		})
+
+		it.After(func() {
+			os.RemoveAll(layer1Path)
+			os.RemoveAll(layer2Path)
+		})
+
+		it("returns the content from the topmost layer", func() {
+			content, err := image.ReadFile("/cnb/order.toml")
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(content), "[[order.group]]")
+		})
+
+		it("honors whiteouts", func() {
+			_, err := image.ReadFile("/cnb/removed.toml")
+			h.AssertError(t, err, `failed to find file "/cnb/removed.toml"`)
+		})
+	})
+
+	when("#AddLayerWithHistory", func() {
+		var repoName = newRepoName()
+
+		it("adds the layer with the given history", func() {
+			layerPath, err := createLayerTar(map[string]string{"/some-file": "some-content"})
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			layer, err := tarball.LayerFromFile(layerPath)
+			h.AssertNil(t, err)
+			diffID, err := layer.DiffID()
+			h.AssertNil(t, err)
+
+			image := fakes.NewImage(repoName, "", nil)
+			h.AssertNil(t, image.AddLayerWithHistory(layer, v1.History{CreatedBy: "some-history"}))
+
+			content, err := image.ReadFile("/some-file")
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(content), "some-content")
+			history, err := image.History()
+			h.AssertNil(t, err)
+			h.AssertEq(t, history, []v1.History{{CreatedBy: "some-history"}})
+			h.AssertEq(t, image.EventsOfType(imgutil.EventAddLayer), []imgutil.Event{
+				{Type: imgutil.EventAddLayer, DiffID: diffID.String()},
+			})
+		})
+	})
+
 	when("#AnnotateRefName", func() {
 		var repoName = newRepoName()
 
@@ -147,6 +220,34 @@ Layers
 			h.AssertEq(t, annotations["org.opencontainers.image.ref.name"], refName)
 		})
 	})
+
+	when("#Events", func() {
+		var repoName = newRepoName()
+
+		it("records the changes made to the image in order", func() {
+			layerPath, err := createLayerTar(map[string]string{"/some-file": "some-content"})
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			diffID := h.FileDiffID(t, layerPath)
+
+			image := fakes.NewImage(repoName, "", nil)
+			image.AddPreviousLayer("sha256:previous", layerPath)
+			h.AssertNil(t, image.SetLabel("some-key", "some-value"))
+			h.AssertNil(t, image.AddLayer(layerPath))
+			h.AssertNil(t, image.ReuseLayer("sha256:previous"))
+			h.AssertNil(t, image.Save("other-name"))
+
+			h.AssertEq(t, image.Events(), []imgutil.Event{
+				{Type: imgutil.EventSetLabel, Key: "some-key", Value: "some-value"},
+				{Type: imgutil.EventAddLayer, DiffID: diffID},
+				{Type: imgutil.EventReuseLayer, DiffID: "sha256:previous"},
+				{Type: imgutil.EventSave, Names: []string{repoName, "other-name"}},
+			})
+			h.AssertEq(t, image.EventsOfType(imgutil.EventReuseLayer), []imgutil.Event{
+				{Type: imgutil.EventReuseLayer, DiffID: "sha256:previous"},
+			})
+		})
+	})
 }
 
 func createLayerTar(contents map[string]string) (string, error) {
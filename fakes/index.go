@@ -0,0 +1,87 @@
+package fakes
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.ImageIndex = &Index{}
+
+// NewIndex returns an empty OCI image index that is kept in memory, and saved in memory too.
+func NewIndex() *Index {
+	return &Index{index: mutate.IndexMediaType(empty.Index, types.OCIImageIndex)}
+}
+
+type Index struct {
+	index      v1.ImageIndex
+	savedIndex v1.ImageIndex
+	saveErr    error
+}
+
+func (i *Index) AddImage(img v1.Image, ops ...imgutil.IndexAddOption) error {
+	index, err := imgutil.AppendImage(i.index, img, ops...)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+func (i *Index) Remove(digest v1.Hash) error {
+	index, err := imgutil.RemoveManifest(i.index, digest)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+func (i *Index) Annotate(annotations map[string]string) error {
+	index, err := imgutil.AnnotateIndex(i.index, annotations)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+func (i *Index) AnnotateManifest(digest v1.Hash, annotations map[string]string) error {
+	index, err := imgutil.AnnotateManifest(i.index, digest, annotations)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+func (i *Index) Save() (v1.Hash, error) {
+	if i.saveErr != nil {
+		return v1.Hash{}, i.saveErr
+	}
+	i.savedIndex = i.index
+	return i.index.Digest()
+}
+
+func (i *Index) UnderlyingIndex() v1.ImageIndex {
+	return i.index
+}
+
+// test methods
+
+// SetSaveError makes Save fail with the given error.
+func (i *Index) SetSaveError(err error) {
+	i.saveErr = err
+}
+
+func (i *Index) IsSaved() bool {
+	return i.savedIndex != nil
+}
+
+// SavedIndex returns the index as it was last saved, or nil if it wasn't saved.
+func (i *Index) SavedIndex() v1.ImageIndex {
+	return i.savedIndex
+}
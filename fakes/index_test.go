@@ -0,0 +1,125 @@
+package fakes_test
+
+import (
+	"errors"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/fakes"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestFakeIndex(t *testing.T) {
+	spec.Run(t, "FakeIndex", testFakeIndex, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testFakeIndex(t *testing.T, when spec.G, it spec.S) {
+	var (
+		index      *fakes.Index
+		amd64Image v1.Image
+		arm64Image v1.Image
+	)
+
+	newImage := func(arch string) v1.Image {
+		img, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+		configFile, err := img.ConfigFile()
+		h.AssertNil(t, err)
+		configFile.OS = "linux"
+		configFile.Architecture = arch
+		img, err = mutate.ConfigFile(img, configFile)
+		h.AssertNil(t, err)
+		return img
+	}
+
+	digestOf := func(img v1.Image) v1.Hash {
+		digest, err := img.Digest()
+		h.AssertNil(t, err)
+		return digest
+	}
+
+	manifests := func() []v1.Descriptor {
+		indexManifest, err := index.UnderlyingIndex().IndexManifest()
+		h.AssertNil(t, err)
+		return indexManifest.Manifests
+	}
+
+	it.Before(func() {
+		index = fakes.NewIndex()
+		amd64Image = newImage("amd64")
+		arm64Image = newImage("arm64")
+	})
+
+	it("implements imgutil.ImageIndex", func() {
+		var _ imgutil.ImageIndex = fakes.NewIndex()
+	})
+
+	it("starts as an empty OCI image index", func() {
+		mediaType, err := index.UnderlyingIndex().MediaType()
+		h.AssertNil(t, err)
+		h.AssertEq(t, mediaType, types.OCIImageIndex)
+		h.AssertEq(t, len(manifests()), 0)
+	})
+
+	it("adds and removes images", func() {
+		h.AssertNil(t, index.AddImage(amd64Image))
+		h.AssertNil(t, index.AddImage(arm64Image, imgutil.WithIndexPlatform(imgutil.Platform{Variant: "v8"})))
+		h.AssertEq(t, len(manifests()), 2)
+		h.AssertEq(t, manifests()[1].Platform.String(), "linux/arm64/v8")
+
+		h.AssertNil(t, index.Remove(digestOf(amd64Image)))
+		h.AssertEq(t, len(manifests()), 1)
+		h.AssertEq(t, manifests()[0].Digest, digestOf(arm64Image))
+
+		h.AssertError(t, index.Remove(digestOf(amd64Image)), "index has no manifest with digest")
+	})
+
+	it("annotates the index and its manifests", func() {
+		h.AssertNil(t, index.AddImage(amd64Image))
+		h.AssertNil(t, index.AddImage(arm64Image))
+
+		h.AssertNil(t, index.Annotate(map[string]string{"some.key": "some-value"}))
+		h.AssertNil(t, index.AnnotateManifest(digestOf(arm64Image), map[string]string{"other.key": "other-value"}))
+
+		indexManifest, err := index.UnderlyingIndex().IndexManifest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, indexManifest.Annotations["some.key"], "some-value")
+		h.AssertEq(t, len(indexManifest.Manifests), 2)
+		h.AssertEq(t, indexManifest.Manifests[0].Digest, digestOf(amd64Image))
+		h.AssertEq(t, len(indexManifest.Manifests[0].Annotations), 0)
+		h.AssertEqAnnotation(t, indexManifest.Manifests[1], "other.key", "other-value")
+	})
+
+	it("saves the index in memory", func() {
+		h.AssertNil(t, index.AddImage(amd64Image))
+		h.AssertEq(t, index.IsSaved(), false)
+
+		digest, err := index.Save()
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, index.IsSaved(), true)
+		savedDigest, err := index.SavedIndex().Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, savedDigest, digest)
+
+		h.AssertNil(t, index.AddImage(arm64Image))
+		savedDigest, err = index.SavedIndex().Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, savedDigest, digest)
+	})
+
+	it("fails to save with the error set", func() {
+		index.SetSaveError(errors.New("some-error"))
+
+		_, err := index.Save()
+		h.AssertError(t, err, "some-error")
+		h.AssertEq(t, index.IsSaved(), false)
+	})
+}
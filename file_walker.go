@@ -0,0 +1,205 @@
+package imgutil
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil/layer"
+)
+
+const maxSymlinkHops = 255
+
+// LayerOpener returns a reader of the uncompressed contents of a layer.
+type LayerOpener func() (io.ReadCloser, error)
+
+// WalkFunc is called for each entry of an image filesystem, with the contents of the entry if it is a regular file.
+// Returning fs.SkipAll stops the walk without error.
+type WalkFunc func(path string, header *tar.Header, content io.Reader) error
+
+// FileWalker reads files from the filesystem composed by a stack of layers, without extracting it.
+type FileWalker struct {
+	// Layers are ordered from the bottom up.
+	Layers []LayerOpener
+	// Windows is true if the layers hold their filesystem under `Files/`.
+	Windows bool
+}
+
+// NewFileWalker returns a FileWalker for the layers listed in the config file, opened with the given function
+// (e.g. Image#GetLayer).
+func NewFileWalker(configFile *v1.ConfigFile, getLayer func(diffID string) (io.ReadCloser, error)) FileWalker {
+	walker := FileWalker{Windows: configFile.OS == "windows"}
+	for _, diffID := range configFile.RootFS.DiffIDs {
+		diffID := diffID.String()
+		walker.Layers = append(walker.Layers, func() (io.ReadCloser, error) {
+			return getLayer(diffID)
+		})
+	}
+	return walker
+}
+
+// Walk calls fn once for each entry of the composed filesystem, scanning the layers from the top down.
+// Entries are visited in the order they appear in the layers, so parent directories are not guaranteed to be
+// visited before their children. Entries hidden by upper layers (replaced, or removed with whiteouts) are skipped.
+func (w FileWalker) Walk(fn WalkFunc) error {
+	var (
+		seen    = map[string]bool{} // paths visited in upper layers
+		nonDirs = map[string]bool{} // paths that are not directories in upper layers: they hide everything below them
+		removed = map[string]bool{} // paths removed with whiteouts in upper layers
+		opaque  = map[string]bool{} // directories emptied with opaque whiteouts in upper layers
+	)
+	hidden := func(name string) bool {
+		if seen[name] || removed[name] {
+			return true
+		}
+		for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+			if nonDirs[dir] || removed[dir] || opaque[dir] {
+				return true
+			}
+		}
+		return opaque["/"]
+	}
+
+	for idx := len(w.Layers) - 1; idx >= 0; idx-- {
+		var whiteouts []layer.Whiteout
+		var visited []*tar.Header
+		err := w.walkLayer(w.Layers[idx], func(name string, header *tar.Header, tr io.Reader) error {
+			if whiteout, ok := layer.ParseWhiteout(name); ok {
+				whiteouts = append(whiteouts, whiteout)
+				return nil
+			}
+			if hidden(name) {
+				return nil
+			}
+			header.Name = name
+			visited = append(visited, header)
+			return fn(name, header, tr)
+		})
+		if errors.Is(err, fs.SkipAll) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// whiteouts and entries of this layer only apply to the layers below it
+		for _, header := range visited {
+			seen[header.Name] = true
+			if header.Typeflag != tar.TypeDir {
+				nonDirs[header.Name] = true
+			}
+		}
+		for _, whiteout := range whiteouts {
+			if whiteout.Opaque {
+				opaque[whiteout.Path] = true
+			} else {
+				removed[whiteout.Path] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (w FileWalker) walkLayer(open LayerOpener, fn func(name string, header *tar.Header, tr io.Reader) error) error {
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := entryPath(header.Name, w.Windows)
+		if !ok {
+			continue
+		}
+		if err = fn(name, header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// entryPath returns the absolute path in the image filesystem of a layer entry.
+// Entries of Windows layers that are not under `Files/` (e.g. `Hives/`) are not part of the filesystem.
+func entryPath(name string, windows bool) (string, bool) {
+	entry := path.Join("/", name)
+	if !windows {
+		return entry, true
+	}
+	if !strings.HasPrefix(entry, "/Files/") {
+		return "", false
+	}
+	return strings.TrimPrefix(entry, "/Files"), true
+}
+
+// ReadFile returns the content of the file at the given absolute path in the composed filesystem.
+// Symbolic links and hard links to files are followed, as are symbolic links to the directories in the path.
+// If the file doesn't exist, the error wraps fs.ErrNotExist.
+func (w FileWalker) ReadFile(filePath string) ([]byte, error) {
+	target := path.Join("/", filePath)
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		var (
+			content []byte
+			link    string
+			found   bool
+		)
+		err := w.Walk(func(name string, header *tar.Header, r io.Reader) error {
+			if name != target {
+				// a symbolic link to a directory in the path replaces the directory, e.g. /usr/lib -> /lib
+				if header.Typeflag != tar.TypeSymlink || !strings.HasPrefix(target, name+"/") {
+					return nil
+				}
+				found = true
+				link = path.Join(symlinkTarget(name, header.Linkname), strings.TrimPrefix(target, name))
+				return fs.SkipAll
+			}
+			found = true
+			switch header.Typeflag {
+			case tar.TypeReg:
+				var err error
+				content, err = io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+			case tar.TypeSymlink:
+				link = symlinkTarget(name, header.Linkname)
+			case tar.TypeLink:
+				link, _ = entryPath(header.Linkname, w.Windows)
+			default:
+				return fmt.Errorf("%q is not a regular file", filePath)
+			}
+			return fs.SkipAll
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("failed to find file %q: %w", filePath, fs.ErrNotExist)
+		}
+		if link == "" {
+			return content, nil
+		}
+		target = link
+	}
+	return nil, fmt.Errorf("failed to read file %q: too many links", filePath)
+}
+
+// symlinkTarget returns the absolute path that the symbolic link at the given path points to.
+func symlinkTarget(name, linkname string) string {
+	if path.IsAbs(linkname) {
+		return path.Clean(linkname)
+	}
+	return path.Join(path.Dir(name), linkname)
+}
@@ -1,6 +1,7 @@
 package imgutil
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -33,12 +34,18 @@ type Image interface {
 	Name() string
 	OS() (string, error)
 	OSVersion() (string, error)
+	// ReadFile returns the content of the file at the given absolute path in the image filesystem, honoring whiteouts.
+	ReadFile(path string) ([]byte, error)
+	// Subject returns the `subject` of the manifest (see SetSubject), or nil if it has none.
+	Subject() (*v1.Descriptor, error)
 	// TopLayer returns the diff id for the top layer
 	TopLayer() (string, error)
 	UnderlyingImage() v1.Image
 	// Valid returns true if the image is well-formed (e.g. all manifest layers exist on the registry).
 	Valid() bool
 	Variant() (string, error)
+	// WalkFiles calls fn for each entry of the image filesystem, honoring whiteouts.
+	WalkFiles(fn WalkFunc) error
 	WorkingDir() (string, error)
 
 	// setters
@@ -54,6 +61,11 @@ type Image interface {
 	SetLabel(string, string) error
 	SetOS(string) error
 	SetOSVersion(string) error
+	// SetSubject sets the `subject` of the manifest, declaring per OCI 1.1 that the image (e.g. an attestation) refers to
+	// the image with the given descriptor. The remote implementation registers the image with the referrers of the subject
+	// (through the referrers API, or the fallback referrers tag for registries that don't support it);
+	// the local implementation can't save it, as the daemon doesn't store manifests.
+	SetSubject(v1.Descriptor) error
 	SetVariant(string) error
 	SetWorkingDir(string) error
 
@@ -62,6 +74,10 @@ type Image interface {
 	AddLayer(path string) error
 	AddLayerWithDiffID(path, diffID string) error
 	AddLayerWithDiffIDAndHistory(path, diffID string, history v1.History) error
+	// AddLayerWithHistory adds the given layer, so that a layer that is streamed or compressed lazily
+	// doesn't need to be written to disk first. The diff ID of the layer is read when it is added,
+	// and its content when the image is saved.
+	AddLayerWithHistory(layer v1.Layer, history v1.History) error
 	AddOrReuseLayerWithHistory(path, diffID string, history v1.History) error
 	Delete() error
 	Rebase(string, Image) error
@@ -78,30 +94,149 @@ type Image interface {
 
 type Identifier fmt.Stringer
 
-// Platform represents the target arch/os/os_version for an image construction and querying.
+// LayerInfo describes a layer of an image.
+type LayerInfo struct {
+	DiffID string
+	Digest string
+	Size   int64
+}
+
+// AnnotatedImage is implemented by images whose manifest annotations can be read and set.
+// The local, remote, and layout images implement it; callers holding an Image type-assert for it.
+type AnnotatedImage interface {
+	// Annotations returns the annotations of the manifest, including the ones set with SetAnnotations.
+	Annotations() (map[string]string, error)
+	// SetAnnotations adds annotations to the manifest, which are saved by every implementation
+	// (the local implementation records them in a label).
+	SetAnnotations(map[string]string) error
+}
+
+// LayerTracker is implemented by images that keep track of the layers added to them.
+// The local, remote, and layout images implement it; callers holding an Image type-assert for it.
+type LayerTracker interface {
+	// LastLayer returns the diff ID, digest, and size of the layer most recently added or reused.
+	LastLayer() (LayerInfo, error)
+}
+
+// Platform represents the target platform for an image construction and querying,
+// with the same fields as an OCI platform.
 type Platform struct {
 	Architecture string
 	OS           string
 	OSVersion    string
+	Variant      string
+	OSFeatures   []string
+	Features     []string
+}
+
+// SaveFailure is the known reason why an image could not be saved under a name.
+// It implements error, so that callers can check for it with errors.Is (e.g. `errors.Is(err, imgutil.SaveTagImmutable)`).
+type SaveFailure int
+
+const (
+	// SaveUnauthorized means that the credentials are missing or don't grant write access to the repository.
+	SaveUnauthorized SaveFailure = iota + 1
+	// SaveTagImmutable means that the tag already exists and the repository doesn't allow it to be overwritten.
+	SaveTagImmutable
+	// SaveBlobTooLarge means that the image store rejected a blob (e.g. a layer) because of its size.
+	SaveBlobTooLarge
+	// SaveTagExists means that the tag already exists for another image, and the OverwritePolicy is FailIfExists.
+	SaveTagExists
+	// SaveTimedOut means that the save deadline passed, or that transferring a blob took longer than the blob timeout.
+	SaveTimedOut
+)
+
+func (f SaveFailure) String() string {
+	switch f {
+	case SaveUnauthorized:
+		return "unauthorized"
+	case SaveTagImmutable:
+		return "tag is immutable"
+	case SaveBlobTooLarge:
+		return "blob too large"
+	case SaveTagExists:
+		return "tag already exists"
+	case SaveTimedOut:
+		return "timed out"
+	default:
+		return "unknown failure"
+	}
+}
+
+func (f SaveFailure) Error() string {
+	return f.String()
+}
+
+// SecretsFoundError is returned when saving an image scanned with WithSecretScanning finds credentials in the layers added to it.
+// Nothing is saved.
+type SecretsFoundError struct {
+	Findings []SecretFinding
+}
+
+func (e SecretsFoundError) Error() string {
+	var findings []string
+	for _, finding := range e.Findings {
+		findings = append(findings, fmt.Sprintf("%s in %s (layer %s)", finding.Description, finding.Path, finding.DiffID))
+	}
+	return "found credentials in image layers: " + strings.Join(findings, ", ")
 }
 
 type SaveDiagnostic struct {
 	ImageName string
-	Cause     error
+	// Reason is set when the cause is a known failure, and is zero otherwise.
+	Reason SaveFailure
+	Cause  error
+}
+
+func (d SaveDiagnostic) Error() string {
+	switch {
+	case d.Cause == nil && d.Reason != 0:
+		return fmt.Sprintf("%s: %s", d.ImageName, d.Reason)
+	case d.Cause == nil:
+		return fmt.Sprintf("%s: failed to save", d.ImageName)
+	case d.Reason != 0:
+		return fmt.Sprintf("%s: %s (%s)", d.ImageName, d.Cause.Error(), d.Reason)
+	default:
+		return fmt.Sprintf("%s: %s", d.ImageName, d.Cause.Error())
+	}
+}
+
+// Unwrap returns the cause and, when known, the reason, so both can be matched with errors.Is and errors.As.
+func (d SaveDiagnostic) Unwrap() []error {
+	var errs []error
+	if d.Cause != nil {
+		errs = append(errs, d.Cause)
+	}
+	if d.Reason != 0 {
+		errs = append(errs, d.Reason)
+	}
+	return errs
 }
 
 type SaveError struct {
 	Errors []SaveDiagnostic
+	// Saved maps the names the image was saved under despite the errors to the digest they resolve to
+	// (the image ID for the docker daemon).
+	Saved map[string]string
 }
 
 func (e SaveError) Error() string {
 	var errors []string
 	for _, d := range e.Errors {
-		errors = append(errors, fmt.Sprintf("[%s: %s]", d.ImageName, d.Cause.Error()))
+		errors = append(errors, fmt.Sprintf("[%s]", d.Error()))
 	}
 	return fmt.Sprintf("failed to write image to the following tags: %s", strings.Join(errors, ","))
 }
 
+// Unwrap returns the diagnostics, so that errors.Is and errors.As match the cause of any of them.
+func (e SaveError) Unwrap() []error {
+	var errs []error
+	for _, d := range e.Errors {
+		errs = append(errs, d)
+	}
+	return errs
+}
+
 type ErrLayerNotFound struct {
 	DiffID string
 }
@@ -109,3 +244,21 @@ type ErrLayerNotFound struct {
 func (e ErrLayerNotFound) Error() string {
 	return fmt.Sprintf("failed to find layer with diff ID %q", e.DiffID)
 }
+
+// BlobTimeoutError means that transferring a blob (e.g. a layer) to or from a registry took longer than the blob timeout.
+// It matches context.DeadlineExceeded with errors.Is.
+type BlobTimeoutError struct {
+	// Blob is the digest of the blob, or the location it was being uploaded to when its digest was not sent yet.
+	Blob    string
+	Timeout time.Duration
+	// Transferred are the digests of the blobs the operation transferred before the timeout.
+	Transferred []string
+}
+
+func (e BlobTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s transferring blob %s (%d blobs transferred before)", e.Timeout, e.Blob, len(e.Transferred))
+}
+
+func (e BlobTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
@@ -0,0 +1,73 @@
+package imgutil_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+// testImage is an imgutil.Image kept in memory, to test the backend-neutral features of imgutil without a backend.
+type testImage struct {
+	*imgutil.CNBImageCore
+	name string
+}
+
+func newTestImage(t *testing.T, ops ...imgutil.ImageOption) *testImage {
+	t.Helper()
+	options, err := imgutil.NewImageOptions(ops...)
+	h.AssertNil(t, err)
+	core, err := imgutil.NewCNBImage(*options)
+	h.AssertNil(t, err)
+	return &testImage{CNBImageCore: core, name: "some-image"}
+}
+
+// addLayerWithFiles adds a layer with the given files to the image, and returns the diff ID of the layer.
+func addLayerWithFiles(t *testing.T, image imgutil.Image, files map[string]string, ops ...layer.DirectoryOption) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		h.AssertNil(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	l, err := layer.FromDirectory(dir, ops...)
+	h.AssertNil(t, err)
+	h.AssertNil(t, image.AddLayerWithHistory(l, v1.History{}))
+	diffID, err := l.DiffID()
+	h.AssertNil(t, err)
+	return diffID.String()
+}
+
+func (i *testImage) Found() bool                             { return true }
+func (i *testImage) Identifier() (imgutil.Identifier, error) { return i.CNBImageCore.Digest() }
+func (i *testImage) Kind() string                            { return "test" }
+func (i *testImage) Name() string                            { return i.name }
+func (i *testImage) Valid() bool                             { return true }
+func (i *testImage) Rename(name string)                      { i.name = name }
+func (i *testImage) Delete() error                           { return nil }
+func (i *testImage) Save(...string) error                    { return nil }
+func (i *testImage) SaveAs(string, ...string) error          { return nil }
+func (i *testImage) SaveFile() (string, error)               { return "", errors.New("not supported") }
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, v ...interface{}) {
+	l.messages = append(l.messages, "DEBUG "+fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Infof(format string, v ...interface{}) {
+	l.messages = append(l.messages, "INFO "+fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Warnf(format string, v ...interface{}) {
+	l.messages = append(l.messages, "WARN "+fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,665 @@
+package imgutil
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ValidateIndex checks the provided index against the OCI image index schema before it is pushed or saved.
+// It reports every problem found rather than stopping at the first one:
+//   - the index must declare schema version 2 and an index media type
+//   - every descriptor must have a media type, a digest, and a size
+//   - every image descriptor must declare a platform with at least `os` and `architecture`
+//   - no two image descriptors may declare the same platform
+//   - the digest and size of every descriptor must match the manifest that the index holds for it
+func ValidateIndex(index v1.ImageIndex) error {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("failed to get index manifest: %w", err)
+	}
+
+	var errs []error
+	if indexManifest.SchemaVersion != 2 {
+		errs = append(errs, fmt.Errorf("index has schema version %d; expected 2", indexManifest.SchemaVersion))
+	}
+	if !indexManifest.MediaType.IsIndex() {
+		errs = append(errs, fmt.Errorf("index has media type %q; expected an image index or manifest list", indexManifest.MediaType))
+	}
+
+	seenPlatforms := make(map[string]v1.Hash)
+	for _, desc := range indexManifest.Manifests {
+		if err := validateDescriptorFields(desc); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if desc.MediaType.IsImage() && !isAttestation(desc) {
+			if err := validatePlatform(desc, seenPlatforms); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := validateDescriptorContent(index, desc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateDescriptorFields(desc v1.Descriptor) error {
+	switch {
+	case desc.MediaType == "":
+		return fmt.Errorf("descriptor %s is missing a media type", desc.Digest)
+	case desc.Digest == (v1.Hash{}):
+		return fmt.Errorf("descriptor with media type %q is missing a digest", desc.MediaType)
+	case desc.Size <= 0:
+		return fmt.Errorf("descriptor %s has invalid size %d", desc.Digest, desc.Size)
+	}
+	return nil
+}
+
+// isAttestation reports if the descriptor references an attestation manifest (see https://docs.docker.com/build/attestations/attestation-storage/),
+// such manifests are expected to have an `unknown` platform.
+func isAttestation(desc v1.Descriptor) bool {
+	_, ok := desc.Annotations[ReferenceTypeAnnotation]
+	return ok
+}
+
+func validatePlatform(desc v1.Descriptor, seenPlatforms map[string]v1.Hash) error {
+	if desc.Platform == nil {
+		return fmt.Errorf("image descriptor %s is missing a platform", desc.Digest)
+	}
+	if desc.Platform.OS == "" || desc.Platform.Architecture == "" {
+		return fmt.Errorf("image descriptor %s has incomplete platform %q: os and architecture are required", desc.Digest, desc.Platform.String())
+	}
+	key := desc.Platform.String()
+	if other, ok := seenPlatforms[key]; ok {
+		return fmt.Errorf("image descriptors %s and %s declare the same platform %q", other, desc.Digest, key)
+	}
+	seenPlatforms[key] = desc.Digest
+	return nil
+}
+
+func validateDescriptorContent(index v1.ImageIndex, desc v1.Descriptor) error {
+	var (
+		manifest partial.Describable
+		err      error
+	)
+	switch {
+	case desc.MediaType.IsImage():
+		manifest, err = index.Image(desc.Digest)
+	case desc.MediaType.IsIndex():
+		manifest, err = index.ImageIndex(desc.Digest)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find manifest for descriptor %s: %w", desc.Digest, err)
+	}
+	digest, err := manifest.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to get digest for descriptor %s: %w", desc.Digest, err)
+	}
+	if digest != desc.Digest {
+		return fmt.Errorf("descriptor %s references a manifest with digest %s", desc.Digest, digest)
+	}
+	size, err := manifest.Size()
+	if err != nil {
+		return fmt.Errorf("failed to get size for descriptor %s: %w", desc.Digest, err)
+	}
+	if size != desc.Size {
+		return fmt.Errorf("descriptor %s has size %d; manifest has size %d", desc.Digest, desc.Size, size)
+	}
+	return nil
+}
+
+// NewReferrersIndex returns an OCI image index listing the provided artifacts (for example, attestations or SBOMs)
+// that describe the given subject image. The index and every artifact manifest have their `subject` set to the subject image,
+// so that registries supporting the referrers API associate them with the image they describe.
+func NewReferrersIndex(subject Image, artifacts ...v1.Image) (v1.ImageIndex, error) {
+	subjectDesc, err := descriptorFor(subject.UnderlyingImage())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get descriptor for subject %q: %w", subject.Name(), err)
+	}
+
+	var additions []mutate.IndexAddendum
+	for _, artifact := range artifacts {
+		withSubject, err := artifactWithSubject(artifact, subjectDesc)
+		if err != nil {
+			return nil, err
+		}
+		additions = append(additions, mutate.IndexAddendum{Add: withSubject})
+	}
+	index := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+	index = mutate.AppendManifests(index, additions...)
+	return SetIndexSubject(index, subjectDesc), nil
+}
+
+// artifactWithSubject returns the artifact with its `subject` set to the given descriptor, keeping its artifact type.
+func artifactWithSubject(artifact v1.Image, subject v1.Descriptor) (v1.Image, error) {
+	manifest, err := artifact.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artifact manifest: %w", err)
+	}
+	if manifest.Subject != nil && manifest.Subject.Digest == subject.Digest {
+		return artifact, nil
+	}
+	artifactType, err := partial.ArtifactType(artifact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artifact type: %w", err)
+	}
+	return &typedArtifact{Image: mutate.Subject(artifact, subject).(v1.Image), artifactType: artifactType}, nil
+}
+
+// SetIndexSubject returns the index with its `subject` set to the given descriptor, declaring per OCI 1.1 that the index
+// (e.g. an index of attestations, see NewReferrersIndex) refers to the image or index with that descriptor.
+// Pushing the index with go-containerregistry registers it with the referrers of the subject.
+func SetIndexSubject(index v1.ImageIndex, subject v1.Descriptor) v1.ImageIndex {
+	return mutate.Subject(index, subject).(v1.ImageIndex)
+}
+
+// Annotations recording the build provenance of an image index (see AnnotateProvenance).
+const (
+	CreatedAnnotation  = "org.opencontainers.image.created"
+	SourceAnnotation   = "org.opencontainers.image.source"
+	RevisionAnnotation = "org.opencontainers.image.revision"
+)
+
+// Provenance is the build provenance of an image index, recorded in its annotations
+// so that multi-platform publishing pipelines can stamp it in one place rather than on each image.
+type Provenance struct {
+	// Created is the time the index was built.
+	Created time.Time
+	// Source is the URL of the source the images were built from (e.g. a git repository).
+	Source string
+	// Revision is the revision of the source (e.g. a git commit).
+	Revision string
+}
+
+// AnnotateProvenance returns the index with the annotations recording the given provenance set at the index level,
+// keeping its other annotations. Empty fields leave their annotation unchanged.
+func AnnotateProvenance(index v1.ImageIndex, provenance Provenance) (v1.ImageIndex, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index manifest: %w", err)
+	}
+	annotations := make(map[string]string, len(indexManifest.Annotations)+3)
+	for key, val := range indexManifest.Annotations {
+		annotations[key] = val
+	}
+	if !provenance.Created.IsZero() {
+		annotations[CreatedAnnotation] = provenance.Created.UTC().Format(time.RFC3339)
+	}
+	if provenance.Source != "" {
+		annotations[SourceAnnotation] = provenance.Source
+	}
+	if provenance.Revision != "" {
+		annotations[RevisionAnnotation] = provenance.Revision
+	}
+	return mutate.Annotations(index, annotations).(v1.ImageIndex), nil
+}
+
+// ProvenanceOf returns the provenance recorded in the annotations of the index; missing annotations leave their field empty.
+func ProvenanceOf(index v1.ImageIndex) (Provenance, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return Provenance{}, fmt.Errorf("failed to get index manifest: %w", err)
+	}
+	provenance := Provenance{
+		Source:   indexManifest.Annotations[SourceAnnotation],
+		Revision: indexManifest.Annotations[RevisionAnnotation],
+	}
+	if created := indexManifest.Annotations[CreatedAnnotation]; created != "" {
+		if provenance.Created, err = time.Parse(time.RFC3339, created); err != nil {
+			return Provenance{}, fmt.Errorf("failed to parse annotation %s: %w", CreatedAnnotation, err)
+		}
+	}
+	return provenance, nil
+}
+
+// DescriptorFor returns the descriptor that an image index entry referencing img should have:
+// the digest, size, and media type of its manifest, its platform (including `os.features` from the config,
+// and the `features` set with SetFeatures), the URLs set with SetURLs, and the annotations of its manifest (such as the ref name set with AnnotateRefName).
+func DescriptorFor(img Image) (v1.Descriptor, error) {
+	underlying := img.UnderlyingImage()
+	if underlying == nil {
+		return v1.Descriptor{}, fmt.Errorf("image %q does not expose an underlying image", img.Name())
+	}
+	desc, err := descriptorFor(underlying)
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("failed to get descriptor for image %q: %w", img.Name(), err)
+	}
+
+	configFile, err := underlying.ConfigFile()
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("failed to get config file for image %q: %w", img.Name(), err)
+	}
+	desc.Platform = &v1.Platform{
+		OS:           configFile.OS,
+		Architecture: configFile.Architecture,
+		Variant:      configFile.Variant,
+		OSVersion:    configFile.OSVersion,
+		OSFeatures:   configFile.OSFeatures,
+	}
+
+	manifest, err := underlying.Manifest()
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("failed to get manifest for image %q: %w", img.Name(), err)
+	}
+	if manifest.Config.Platform != nil {
+		desc.Platform.Features = manifest.Config.Platform.Features
+	}
+	desc.URLs = manifest.Config.URLs
+	annotations := make(map[string]string, len(manifest.Annotations))
+	for key, val := range manifest.Annotations {
+		annotations[key] = val
+	}
+	if len(annotations) > 0 {
+		desc.Annotations = annotations
+	}
+	return desc, nil
+}
+
+func descriptorFor(image v1.Image) (v1.Descriptor, error) {
+	desc, err := partial.Descriptor(image)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	return *desc, nil
+}
+
+// IndexAddOption configures how AppendImage adds an image to an index.
+type IndexAddOption func(*IndexAddOptions)
+
+// IndexAddOptions describe the descriptor that AppendImage gives an image.
+type IndexAddOptions struct {
+	// Platform overrides the platform of the descriptor; its empty fields are taken from the config of the image.
+	Platform Platform
+	// Annotations are set on the descriptor.
+	Annotations map[string]string
+	// RewriteConfig sets the fields of Platform that the config of the image lacks on the config,
+	// so that the config and the descriptor agree.
+	RewriteConfig bool
+}
+
+// WithIndexPlatform overrides the platform of the descriptor, e.g. to add a variant or OS version that the config lacks.
+func WithIndexPlatform(platform Platform) IndexAddOption {
+	return func(o *IndexAddOptions) {
+		o.Platform = platform
+	}
+}
+
+// WithIndexAnnotations sets annotations on the descriptor.
+func WithIndexAnnotations(annotations map[string]string) IndexAddOption {
+	return func(o *IndexAddOptions) {
+		o.Annotations = annotations
+	}
+}
+
+// WithConfigRewrite makes AppendImage set the platform override on the config of the image where the config lacks it.
+func WithConfigRewrite() IndexAddOption {
+	return func(o *IndexAddOptions) {
+		o.RewriteConfig = true
+	}
+}
+
+// GetIndexAddOptions returns the options resulting from applying ops.
+func GetIndexAddOptions(ops ...IndexAddOption) IndexAddOptions {
+	options := IndexAddOptions{}
+	for _, op := range ops {
+		op(&options)
+	}
+	return options
+}
+
+// AppendImage returns the index with img appended, described by the platform of its config.
+// A platform override (see WithIndexPlatform) can only add the fields that the config lacks:
+// it is an error if a field is set in both and the values differ.
+// With WithConfigRewrite, the appended image is img with the added fields set on its config, which changes its digest.
+func AppendImage(index v1.ImageIndex, img v1.Image, ops ...IndexAddOption) (v1.ImageIndex, error) {
+	options := GetIndexAddOptions(ops...)
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config file: %w", err)
+	}
+	configPlatform := PlatformFromConfig(configFile)
+	if err = checkPlatformOverride(configPlatform, options.Platform); err != nil {
+		return nil, err
+	}
+	platform := mergePlatform(configPlatform, options.Platform)
+
+	if options.RewriteConfig && !configPlatform.Equal(withoutFeatures(platform)) {
+		configFile = configFile.DeepCopy()
+		configFile.OS = platform.OS
+		configFile.Architecture = platform.Architecture
+		configFile.Variant = platform.Variant
+		configFile.OSVersion = platform.OSVersion
+		configFile.OSFeatures = platform.OSFeatures
+		if img, err = mutate.ConfigFile(img, configFile); err != nil {
+			return nil, fmt.Errorf("failed to rewrite config file: %w", err)
+		}
+	}
+
+	v1Platform := platform.V1()
+	return mutate.AppendManifests(index, mutate.IndexAddendum{
+		Add: img,
+		Descriptor: v1.Descriptor{
+			Platform:    &v1Platform,
+			Annotations: options.Annotations,
+		},
+	}), nil
+}
+
+// checkPlatformOverride returns an error if a field of the override conflicts with the platform of the config.
+func checkPlatformOverride(config, override Platform) error {
+	fields := []struct{ name, config, override string }{
+		{"os", config.OS, override.OS},
+		{"architecture", config.Architecture, override.Architecture},
+		{"variant", config.Variant, override.Variant},
+		{"os.version", config.OSVersion, override.OSVersion},
+	}
+	for _, field := range fields {
+		if field.config != "" && field.override != "" && field.config != field.override {
+			return fmt.Errorf("platform override has %s %q; the config of the image has %q", field.name, field.override, field.config)
+		}
+	}
+	if len(config.OSFeatures) > 0 && len(override.OSFeatures) > 0 &&
+		!(containsAll(config.OSFeatures, override.OSFeatures) && containsAll(override.OSFeatures, config.OSFeatures)) {
+		return fmt.Errorf("platform override has os.features %v; the config of the image has %v", override.OSFeatures, config.OSFeatures)
+	}
+	return nil
+}
+
+// mergePlatform returns the platform of the config with the fields it lacks taken from the override.
+func mergePlatform(config, override Platform) Platform {
+	merged := config
+	if merged.OS == "" {
+		merged.OS = override.OS
+	}
+	if merged.Architecture == "" {
+		merged.Architecture = override.Architecture
+	}
+	if merged.Variant == "" {
+		merged.Variant = override.Variant
+	}
+	if merged.OSVersion == "" {
+		merged.OSVersion = override.OSVersion
+	}
+	if len(merged.OSFeatures) == 0 {
+		merged.OSFeatures = override.OSFeatures
+	}
+	merged.Features = override.Features
+	return merged
+}
+
+func withoutFeatures(platform Platform) Platform {
+	platform.Features = nil
+	return platform
+}
+
+// ImageIndex is a multi-platform index that can be modified and saved, e.g. the index of an OCI layout (see layout.NewIndex).
+// Changes are kept in memory until the index is saved.
+type ImageIndex interface {
+	// AddImage appends the image to the index as with AppendImage.
+	AddImage(img v1.Image, ops ...IndexAddOption) error
+	// Remove removes the descriptors of the manifest with the given digest from the index.
+	Remove(digest v1.Hash) error
+	// Annotate adds the given annotations to the index itself, replacing the values of existing keys.
+	Annotate(annotations map[string]string) error
+	// AnnotateManifest adds the given annotations to the descriptor of the manifest with the given digest.
+	AnnotateManifest(digest v1.Hash, annotations map[string]string) error
+	// Save saves the index and returns its digest.
+	Save() (v1.Hash, error)
+	// UnderlyingIndex returns the index, including the changes that are not saved yet.
+	UnderlyingIndex() v1.ImageIndex
+}
+
+// RemoveManifest returns the index without the descriptors of the manifest with the given digest.
+// It is an error if the index has no such manifest.
+func RemoveManifest(index v1.ImageIndex, digest v1.Hash) (v1.ImageIndex, error) {
+	if _, err := findDescriptor(index, digest); err != nil {
+		return nil, err
+	}
+	return mutate.RemoveManifests(index, match.Digests(digest)), nil
+}
+
+// AnnotateIndex returns the index with the given annotations added to it, replacing the values of existing keys.
+func AnnotateIndex(index v1.ImageIndex, annotations map[string]string) (v1.ImageIndex, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	return mutate.Annotations(index, mergeAnnotations(indexManifest.Annotations, annotations)).(v1.ImageIndex), nil
+}
+
+// AnnotateManifest returns the index with the given annotations added to the descriptor of the manifest with the given digest,
+// replacing the values of existing keys and keeping the order of the descriptors.
+func AnnotateManifest(index v1.ImageIndex, digest v1.Hash, annotations map[string]string) (v1.ImageIndex, error) {
+	if _, err := findDescriptor(index, digest); err != nil {
+		return nil, err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	additions := make([]mutate.IndexAddendum, 0, len(indexManifest.Manifests))
+	for _, desc := range indexManifest.Manifests {
+		var add mutate.Appendable
+		switch {
+		case desc.MediaType.IsImage():
+			add, err = index.Image(desc.Digest)
+		case desc.MediaType.IsIndex():
+			add, err = index.ImageIndex(desc.Digest)
+		default:
+			err = fmt.Errorf("descriptor %s has unsupported media type %q", desc.Digest, desc.MediaType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		descAnnotations := desc.Annotations
+		if desc.Digest == digest {
+			descAnnotations = mergeAnnotations(desc.Annotations, annotations)
+		}
+		additions = append(additions, mutate.IndexAddendum{
+			Add: add,
+			Descriptor: v1.Descriptor{
+				Platform:    desc.Platform,
+				Annotations: descAnnotations,
+				URLs:        desc.URLs,
+			},
+		})
+	}
+
+	annotated := mutate.IndexMediaType(empty.Index, indexManifest.MediaType)
+	if len(indexManifest.Annotations) > 0 {
+		annotated = mutate.Annotations(annotated, indexManifest.Annotations).(v1.ImageIndex)
+	}
+	return mutate.AppendManifests(annotated, additions...), nil
+}
+
+func findDescriptor(index v1.ImageIndex, digest v1.Hash) (v1.Descriptor, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	for _, desc := range indexManifest.Manifests {
+		if desc.Digest == digest {
+			return desc, nil
+		}
+	}
+	return v1.Descriptor{}, fmt.Errorf("index has no manifest with digest %s", digest)
+}
+
+func mergeAnnotations(existing, added map[string]string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(added))
+	for key, val := range existing {
+		merged[key] = val
+	}
+	for key, val := range added {
+		merged[key] = val
+	}
+	return merged
+}
+
+// BaseTopLayerFunc returns the diff ID of the top layer of the base image of img
+// (for CNB images, it is recorded in the lifecycle metadata label).
+type BaseTopLayerFunc func(img v1.Image) (string, error)
+
+// RebaseIndex rebases every image of index onto the image of newBaseIndex with a matching platform,
+// and returns an index holding the rebased images, in the same order and with the same descriptor annotations.
+// Images keep their media types, and options select the config values adopted from the new base images, as with Rebase.
+// The platform of the descriptor of a rebased image is taken from its rebased config, as with AppendImage.
+// Attestation manifests describing a rebased image are dropped, as they describe the image before the rebase;
+// other descriptors without a platform and nested indexes are kept unchanged.
+// It is an error if newBaseIndex has no image matching the platform of an image of index.
+func RebaseIndex(index, newBaseIndex v1.ImageIndex, baseTopLayer BaseTopLayerFunc, ops ...RebaseOption) (v1.ImageIndex, error) {
+	options := GetRebaseOptions(ops...)
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index manifest: %w", err)
+	}
+	newBaseManifest, err := newBaseIndex.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index manifest for new base: %w", err)
+	}
+
+	rebasedImages := map[int]v1.Image{}
+	rebasedDigests := map[string]bool{}
+	for idx, desc := range indexManifest.Manifests {
+		if !desc.MediaType.IsImage() || desc.Platform == nil || isAttestation(desc) {
+			continue
+		}
+		if rebasedImages[idx], err = rebaseIndexImage(index, desc, newBaseIndex, newBaseManifest, baseTopLayer, options); err != nil {
+			return nil, err
+		}
+		rebasedDigests[desc.Digest.String()] = true
+	}
+
+	var additions []mutate.IndexAddendum
+	for idx, desc := range indexManifest.Manifests {
+		var add mutate.Appendable
+		platform := desc.Platform
+		switch {
+		case rebasedImages[idx] != nil:
+			add = rebasedImages[idx]
+			if platform, err = rebasedPlatform(rebasedImages[idx], desc); err != nil {
+				return nil, err
+			}
+		case isAttestation(desc) && rebasedDigests[desc.Annotations[ReferenceDigestAnnotation]]:
+			continue
+		case desc.MediaType.IsImage():
+			add, err = index.Image(desc.Digest)
+		case desc.MediaType.IsIndex():
+			add, err = index.ImageIndex(desc.Digest)
+		default:
+			err = fmt.Errorf("descriptor %s has unsupported media type %q", desc.Digest, desc.MediaType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		additions = append(additions, mutate.IndexAddendum{
+			Add: add,
+			Descriptor: v1.Descriptor{
+				Platform:    platform,
+				Annotations: desc.Annotations,
+				URLs:        desc.URLs,
+			},
+		})
+	}
+
+	rebased := mutate.IndexMediaType(empty.Index, indexManifest.MediaType)
+	if len(indexManifest.Annotations) > 0 {
+		rebased = mutate.Annotations(rebased, indexManifest.Annotations).(v1.ImageIndex)
+	}
+	return mutate.AppendManifests(rebased, additions...), nil
+}
+
+// rebasedPlatform returns the platform of the config of the rebased image, with the fields it lacks taken from the descriptor
+// of the image before the rebase (e.g. its features), as AppendImage does with a platform override.
+func rebasedPlatform(rebased v1.Image, desc v1.Descriptor) (*v1.Platform, error) {
+	configFile, err := rebased.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	platform := mergePlatform(PlatformFromConfig(configFile), PlatformFromV1(desc.Platform)).V1()
+	return &platform, nil
+}
+
+func rebaseIndexImage(index v1.ImageIndex, desc v1.Descriptor, newBaseIndex v1.ImageIndex, newBaseManifest *v1.IndexManifest, baseTopLayer BaseTopLayerFunc, options RebaseOptions) (v1.Image, error) {
+	platform := PlatformFromV1(desc.Platform)
+	newBaseDesc, ok := findRebaseBase(newBaseManifest, platform)
+	if !ok {
+		return nil, fmt.Errorf("new base index has no image for platform %s", platform)
+	}
+	img, err := index.Image(desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image for platform %s: %w", platform, err)
+	}
+	newBase, err := newBaseIndex.Image(newBaseDesc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new base image for platform %s: %w", platform, err)
+	}
+	topLayer, err := baseTopLayer(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base top layer for platform %s: %w", platform, err)
+	}
+
+	rebased, err := mutate.Rebase(img, &v1ImageFacade{Image: img, topLayerDiffID: topLayer}, newBase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebase image for platform %s: %w", platform, err)
+	}
+	configFile, err := rebased.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	newBaseConfigFile, err := newBase.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	configFile = configFile.DeepCopy()
+	configFile.Architecture = newBaseConfigFile.Architecture
+	configFile.OS = newBaseConfigFile.OS
+	configFile.OSVersion = newBaseConfigFile.OSVersion
+	options.mergeConfig(configFile, newBaseConfigFile)
+	if rebased, err = mutate.ConfigFile(rebased, configFile); err != nil {
+		return nil, err
+	}
+
+	mediaTypes := DockerTypes
+	if desc.MediaType == types.OCIManifestSchema1 {
+		mediaTypes = OCITypes
+	}
+	rebased, _, err = EnsureMediaTypesAndLayers(rebased, mediaTypes, PreserveLayers)
+	return rebased, err
+}
+
+// findRebaseBase returns the descriptor of the image of the new base index for the given platform,
+// preferring an exact match over one that only matches the OS and architecture.
+func findRebaseBase(newBaseManifest *v1.IndexManifest, platform Platform) (v1.Descriptor, bool) {
+	var (
+		compatible v1.Descriptor
+		found      bool
+	)
+	for _, desc := range newBaseManifest.Manifests {
+		if !desc.MediaType.IsImage() || desc.Platform == nil {
+			continue
+		}
+		basePlatform := PlatformFromV1(desc.Platform)
+		if checkRebasePlatform(platform, basePlatform) != nil {
+			continue
+		}
+		if basePlatform.Equal(platform) {
+			return desc, true
+		}
+		if !found {
+			compatible, found = desc, true
+		}
+	}
+	return compatible, found
+}
@@ -0,0 +1,395 @@
+package imgutil_test
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestIndex(t *testing.T) {
+	spec.Run(t, "Index", testIndex, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+// imageIndex names the embedded index so that it doesn't collide with the ImageIndex method.
+type imageIndex = v1.ImageIndex
+
+// tamperedIndex serves an edited copy of the manifest of the index it wraps,
+// while the referenced manifests stay untouched.
+type tamperedIndex struct {
+	imageIndex
+	tamper func(*v1.IndexManifest)
+}
+
+func (i tamperedIndex) IndexManifest() (*v1.IndexManifest, error) {
+	indexManifest, err := i.imageIndex.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	indexManifest = indexManifest.DeepCopy()
+	i.tamper(indexManifest)
+	return indexManifest, nil
+}
+
+func testIndex(t *testing.T, when spec.G, it spec.S) {
+	when("#ValidateIndex", func() {
+		var index v1.ImageIndex
+
+		it.Before(func() {
+			amd64Image, err := random.Image(1024, 1)
+			h.AssertNil(t, err)
+			arm64Image, err := random.Image(1024, 1)
+			h.AssertNil(t, err)
+			index = mutate.AppendManifests(empty.Index,
+				mutate.IndexAddendum{Add: amd64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+				mutate.IndexAddendum{Add: arm64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+			)
+		})
+
+		tampered := func(tamper func(*v1.IndexManifest)) v1.ImageIndex {
+			return tamperedIndex{imageIndex: index, tamper: tamper}
+		}
+
+		it("accepts a valid index", func() {
+			h.AssertNil(t, imgutil.ValidateIndex(index))
+		})
+
+		it("rejects an image descriptor without a platform", func() {
+			err := imgutil.ValidateIndex(tampered(func(m *v1.IndexManifest) {
+				m.Manifests[1].Platform = nil
+			}))
+			h.AssertError(t, err, "is missing a platform")
+		})
+
+		it("rejects image descriptors that declare the same platform", func() {
+			err := imgutil.ValidateIndex(tampered(func(m *v1.IndexManifest) {
+				m.Manifests[1].Platform = &v1.Platform{OS: "linux", Architecture: "amd64"}
+			}))
+			h.AssertError(t, err, `declare the same platform "linux/amd64"`)
+		})
+
+		it("rejects a descriptor whose size doesn't match the manifest", func() {
+			err := imgutil.ValidateIndex(tampered(func(m *v1.IndexManifest) {
+				m.Manifests[0].Size++
+			}))
+			h.AssertError(t, err, "has size")
+		})
+
+		it("rejects a descriptor whose digest doesn't match a manifest", func() {
+			err := imgutil.ValidateIndex(tampered(func(m *v1.IndexManifest) {
+				m.Manifests[0].Digest = v1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
+			}))
+			h.AssertError(t, err, "failed to find manifest for descriptor sha256:0000000000000000000000000000000000000000000000000000000000000000")
+		})
+
+		it("rejects an index with a schema version other than 2", func() {
+			err := imgutil.ValidateIndex(tampered(func(m *v1.IndexManifest) {
+				m.SchemaVersion = 1
+			}))
+			h.AssertError(t, err, "index has schema version 1; expected 2")
+		})
+
+		it("reports every problem found", func() {
+			err := imgutil.ValidateIndex(tampered(func(m *v1.IndexManifest) {
+				m.SchemaVersion = 1
+				m.Manifests[1].Platform = nil
+			}))
+			h.AssertError(t, err, "expected 2")
+			h.AssertError(t, err, "is missing a platform")
+		})
+	})
+
+	when("#RebaseIndex", func() {
+		var (
+			linuxAMD64 = imgutil.Platform{OS: "linux", Architecture: "amd64"}
+			linuxARM64 = imgutil.Platform{OS: "linux", Architecture: "arm64"}
+			imageFor   = func(platform imgutil.Platform, layers ...map[string]string) (v1.Image, []string) {
+				image := newTestImage(t, imgutil.WithDefaultPlatform(platform))
+				var diffIDs []string
+				for _, files := range layers {
+					diffIDs = append(diffIDs, addLayerWithFiles(t, image, files))
+				}
+				return image.UnderlyingImage(), diffIDs
+			}
+			newIndex = func(images map[string]v1.Image) v1.ImageIndex {
+				var additions []mutate.IndexAddendum
+				for _, platform := range []imgutil.Platform{linuxAMD64, linuxARM64} {
+					if images[platform.Architecture] == nil {
+						continue
+					}
+					v1Platform := platform.V1()
+					additions = append(additions, mutate.IndexAddendum{
+						Add:        images[platform.Architecture],
+						Descriptor: v1.Descriptor{Platform: &v1Platform},
+					})
+				}
+				return mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), additions...)
+			}
+		)
+
+		it("rebases each image onto the new base image with the same platform", func() {
+			amd64App, amd64Layers := imageFor(linuxAMD64, map[string]string{"base": "amd64"}, map[string]string{"app": "amd64"})
+			arm64App, arm64Layers := imageFor(linuxARM64, map[string]string{"base": "arm64"}, map[string]string{"app": "arm64"})
+			amd64Base, amd64BaseLayers := imageFor(linuxAMD64, map[string]string{"new-base": "amd64"})
+			arm64Base, arm64BaseLayers := imageFor(linuxARM64, map[string]string{"new-base": "arm64"})
+			baseTops := map[string]string{"amd64": amd64Layers[0], "arm64": arm64Layers[0]}
+
+			rebased, err := imgutil.RebaseIndex(
+				newIndex(map[string]v1.Image{"amd64": amd64App, "arm64": arm64App}),
+				newIndex(map[string]v1.Image{"amd64": amd64Base, "arm64": arm64Base}),
+				func(img v1.Image) (string, error) {
+					configFile, err := img.ConfigFile()
+					if err != nil {
+						return "", err
+					}
+					return baseTops[configFile.Architecture], nil
+				},
+			)
+			h.AssertNil(t, err)
+			h.AssertNil(t, imgutil.ValidateIndex(rebased))
+
+			rebasedManifest, err := rebased.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(rebasedManifest.Manifests), 2)
+			expectedLayers := map[string][]string{
+				"amd64": {amd64BaseLayers[0], amd64Layers[1]},
+				"arm64": {arm64BaseLayers[0], arm64Layers[1]},
+			}
+			for _, desc := range rebasedManifest.Manifests {
+				h.AssertEq(t, desc.MediaType, types.OCIManifestSchema1)
+				image, err := rebased.Image(desc.Digest)
+				h.AssertNil(t, err)
+				h.AssertDiffIDs(t, image, expectedLayers[desc.Platform.Architecture]...)
+			}
+		})
+
+		it("describes each rebased image with the platform of the new base image", func() {
+			windowsImage := func(osVersion string, layers int64) v1.Image {
+				image, err := random.Image(1024, layers)
+				h.AssertNil(t, err)
+				configFile, err := image.ConfigFile()
+				h.AssertNil(t, err)
+				configFile = configFile.DeepCopy()
+				configFile.OS, configFile.Architecture, configFile.OSVersion = "windows", "amd64", osVersion
+				image, err = mutate.ConfigFile(image, configFile)
+				h.AssertNil(t, err)
+				return image
+			}
+			indexWith := func(image v1.Image, osVersion string) v1.ImageIndex {
+				return mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), mutate.IndexAddendum{
+					Add:        image,
+					Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: osVersion}},
+				})
+			}
+			app := windowsImage("10.0.17763.1", 2)
+			appConfigFile, err := app.ConfigFile()
+			h.AssertNil(t, err)
+
+			rebased, err := imgutil.RebaseIndex(
+				indexWith(app, "10.0.17763.1"),
+				indexWith(windowsImage("10.0.17763.5000", 1), "10.0.17763.5000"),
+				func(v1.Image) (string, error) { return appConfigFile.RootFS.DiffIDs[0].String(), nil },
+			)
+			h.AssertNil(t, err)
+
+			rebasedManifest, err := rebased.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(rebasedManifest.Manifests), 1)
+			h.AssertEq(t, rebasedManifest.Manifests[0].Platform.OSVersion, "10.0.17763.5000")
+		})
+
+		it("drops the attestation manifests of the rebased images", func() {
+			amd64App, amd64Layers := imageFor(linuxAMD64, map[string]string{"base": "amd64"}, map[string]string{"app": "amd64"})
+			amd64Base, _ := imageFor(linuxAMD64, map[string]string{"new-base": "amd64"})
+			index := newIndex(map[string]v1.Image{"amd64": amd64App})
+			appDigest, err := amd64App.Digest()
+			h.AssertNil(t, err)
+			attestation, err := imgutil.NewAttestationManifest(imgutil.Attestation{PredicateType: "https://slsa.dev/provenance/v1", Statement: []byte(`{}`)})
+			h.AssertNil(t, err)
+			index, err = imgutil.AppendAttestation(index, appDigest, attestation)
+			h.AssertNil(t, err)
+
+			rebased, err := imgutil.RebaseIndex(
+				index,
+				newIndex(map[string]v1.Image{"amd64": amd64Base}),
+				func(v1.Image) (string, error) { return amd64Layers[0], nil },
+			)
+			h.AssertNil(t, err)
+
+			rebasedManifest, err := rebased.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(rebasedManifest.Manifests), 1)
+			h.AssertNotEq(t, rebasedManifest.Manifests[0].Digest, appDigest)
+			_, ok := rebasedManifest.Manifests[0].Annotations[imgutil.ReferenceTypeAnnotation]
+			h.AssertEq(t, ok, false)
+		})
+
+		it("fails when the new base index has no image for a platform", func() {
+			amd64App, amd64Layers := imageFor(linuxAMD64, map[string]string{"base": "amd64"}, map[string]string{"app": "amd64"})
+			arm64Base, _ := imageFor(linuxARM64, map[string]string{"new-base": "arm64"})
+
+			_, err := imgutil.RebaseIndex(
+				newIndex(map[string]v1.Image{"amd64": amd64App}),
+				newIndex(map[string]v1.Image{"arm64": arm64Base}),
+				func(v1.Image) (string, error) { return amd64Layers[0], nil },
+			)
+			h.AssertError(t, err, "new base index has no image for platform linux/amd64")
+		})
+	})
+
+	when("#AnnotateProvenance", func() {
+		var index v1.ImageIndex
+
+		it.Before(func() {
+			image, err := random.Image(1024, 1)
+			h.AssertNil(t, err)
+			index = mutate.AppendManifests(empty.Index,
+				mutate.IndexAddendum{Add: image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+			)
+			index = mutate.Annotations(index, map[string]string{"some-key": "some-value"}).(v1.ImageIndex)
+		})
+
+		it("records the provenance in the annotations of the index", func() {
+			created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			annotated, err := imgutil.AnnotateProvenance(index, imgutil.Provenance{
+				Created:  created,
+				Source:   "https://github.com/some-org/some-repo",
+				Revision: "some-revision",
+			})
+			h.AssertNil(t, err)
+
+			provenance, err := imgutil.ProvenanceOf(annotated)
+			h.AssertNil(t, err)
+			h.AssertEq(t, provenance, imgutil.Provenance{Created: created, Source: "https://github.com/some-org/some-repo", Revision: "some-revision"})
+
+			indexManifest, err := annotated.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, indexManifest.Annotations["some-key"], "some-value")
+			h.AssertEq(t, indexManifest.Annotations[imgutil.CreatedAnnotation], "2024-01-02T03:04:05Z")
+		})
+
+		it("leaves the annotations of empty fields unchanged", func() {
+			annotated, err := imgutil.AnnotateProvenance(index, imgutil.Provenance{Revision: "some-revision"})
+			h.AssertNil(t, err)
+			annotated, err = imgutil.AnnotateProvenance(annotated, imgutil.Provenance{Source: "some-source"})
+			h.AssertNil(t, err)
+
+			provenance, err := imgutil.ProvenanceOf(annotated)
+			h.AssertNil(t, err)
+			h.AssertEq(t, provenance, imgutil.Provenance{Source: "some-source", Revision: "some-revision"})
+		})
+	})
+
+	when("#ProvenanceOf", func() {
+		it("fails if the created annotation is not a timestamp", func() {
+			invalid := mutate.Annotations(empty.Index, map[string]string{imgutil.CreatedAnnotation: "yesterday"}).(v1.ImageIndex)
+
+			_, err := imgutil.ProvenanceOf(invalid)
+			h.AssertError(t, err, "failed to parse annotation "+imgutil.CreatedAnnotation)
+		})
+	})
+
+	when("#DescriptorFor", func() {
+		it("describes the platform, features, URLs, and annotations of the image", func() {
+			img := newTestImage(t, imgutil.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "amd64"}))
+			h.AssertNil(t, img.SetFeatures([]string{"some-feature"}))
+			h.AssertNil(t, img.SetURLs([]string{"https://example.com/some-config"}))
+			h.AssertNil(t, img.AnnotateRefName("some-ref"))
+			addLayerWithFiles(t, img, map[string]string{"some-file": "some-content"})
+
+			desc, err := imgutil.DescriptorFor(img)
+			h.AssertNil(t, err)
+			h.AssertEq(t, desc.Platform.OS, "linux")
+			h.AssertEq(t, desc.Platform.Architecture, "amd64")
+			h.AssertEq(t, desc.Platform.Features, []string{"some-feature"})
+			h.AssertEq(t, desc.URLs, []string{"https://example.com/some-config"})
+			h.AssertEq(t, desc.Annotations["org.opencontainers.image.ref.name"], "some-ref")
+			digest, err := img.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, desc.Digest, digest)
+		})
+	})
+
+	when("#AppendImage", func() {
+		var image v1.Image
+
+		it.Before(func() {
+			var err error
+			image, err = random.Image(1024, 1)
+			h.AssertNil(t, err)
+			configFile, err := image.ConfigFile()
+			h.AssertNil(t, err)
+			configFile.OS = "windows"
+			configFile.Architecture = "amd64"
+			image, err = mutate.ConfigFile(image, configFile)
+			h.AssertNil(t, err)
+		})
+
+		appended := func(index v1.ImageIndex) (v1.Descriptor, v1.Image) {
+			indexManifest, err := index.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(indexManifest.Manifests), 1)
+			desc := indexManifest.Manifests[0]
+			img, err := index.Image(desc.Digest)
+			h.AssertNil(t, err)
+			return desc, img
+		}
+
+		it("describes the image with the platform of its config", func() {
+			index, err := imgutil.AppendImage(empty.Index, image, imgutil.WithIndexAnnotations(map[string]string{"some-key": "some-value"}))
+			h.AssertNil(t, err)
+
+			desc, _ := appended(index)
+			h.AssertEq(t, desc.Platform.OS, "windows")
+			h.AssertEq(t, desc.Platform.Architecture, "amd64")
+			h.AssertEq(t, desc.Annotations["some-key"], "some-value")
+			digest, err := image.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, desc.Digest, digest)
+			h.AssertNil(t, imgutil.ValidateIndex(index))
+		})
+
+		it("adds the fields of the override that the config lacks to the descriptor", func() {
+			index, err := imgutil.AppendImage(empty.Index, image, imgutil.WithIndexPlatform(imgutil.Platform{OS: "windows", OSVersion: "10.0.17763.1"}))
+			h.AssertNil(t, err)
+
+			desc, img := appended(index)
+			h.AssertEq(t, desc.Platform.OSVersion, "10.0.17763.1")
+			configFile, err := img.ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, configFile.OSVersion, "")
+		})
+
+		it("rewrites the config to match the descriptor", func() {
+			index, err := imgutil.AppendImage(empty.Index, image,
+				imgutil.WithIndexPlatform(imgutil.Platform{OSVersion: "10.0.17763.1"}),
+				imgutil.WithConfigRewrite(),
+			)
+			h.AssertNil(t, err)
+
+			desc, img := appended(index)
+			h.AssertEq(t, desc.Platform.OSVersion, "10.0.17763.1")
+			configFile, err := img.ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, configFile.OSVersion, "10.0.17763.1")
+			digest, err := image.Digest()
+			h.AssertNil(t, err)
+			h.AssertNotEq(t, desc.Digest, digest)
+			h.AssertNil(t, imgutil.ValidateIndex(index))
+		})
+
+		it("fails if the override conflicts with the config", func() {
+			_, err := imgutil.AppendImage(empty.Index, image, imgutil.WithIndexPlatform(imgutil.Platform{OS: "linux"}))
+			h.AssertError(t, err, `platform override has os "linux"; the config of the image has "windows"`)
+		})
+	})
+}
@@ -0,0 +1,59 @@
+package imgutil
+
+import "time"
+
+// Operation identifies an instrumented image operation.
+type Operation string
+
+const (
+	// OperationPull is reported when a base or previous image is fetched from a registry.
+	OperationPull Operation = "pull"
+	// OperationLayerUpload is reported when the blobs of an image are written to a registry.
+	OperationLayerUpload Operation = "layer-upload"
+	// OperationDaemonLoad is reported when an image is loaded into the docker daemon.
+	OperationDaemonLoad Operation = "daemon-load"
+	// OperationIndexPush is reported when an index is written to a registry.
+	OperationIndexPush Operation = "index-push"
+)
+
+// OperationResult describes a completed operation.
+// Bytes is the number of bytes transferred, or -1 if unknown.
+type OperationResult struct {
+	Err      error
+	Duration time.Duration
+	Bytes    int64
+}
+
+// Instrumentation receives hooks around long-running image operations,
+// so that callers can record metrics or open tracing spans.
+// Start is called when op begins for the named image; the returned function is called exactly once when op completes.
+type Instrumentation interface {
+	Start(op Operation, name string) func(OperationResult)
+}
+
+// GetInstrumentation returns the instrumentation provided with WithInstrumentation, or instrumentation discarding all operations.
+func GetInstrumentation(options ImageOptions) Instrumentation {
+	if options.Instrumentation != nil {
+		return options.Instrumentation
+	}
+	return discardInstrumentation{}
+}
+
+// StartOperation starts op on instrumentation and returns a function that reports
+// the elapsed time along with the given byte count and error.
+func StartOperation(instrumentation Instrumentation, op Operation, name string) func(bytes int64, err error) {
+	if instrumentation == nil {
+		instrumentation = discardInstrumentation{}
+	}
+	start := time.Now()
+	end := instrumentation.Start(op, name)
+	return func(bytes int64, err error) {
+		end(OperationResult{Err: err, Duration: time.Since(start), Bytes: bytes})
+	}
+}
+
+type discardInstrumentation struct{}
+
+func (discardInstrumentation) Start(Operation, string) func(OperationResult) {
+	return func(OperationResult) {}
+}
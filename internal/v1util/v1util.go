@@ -0,0 +1,67 @@
+// Package v1util has helpers for v1 images that are shared by imgutil and its subpackages.
+package v1util
+
+import (
+	"encoding/json"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+)
+
+// NormalizedHistory returns the history entries of the given history that describe layers (i.e. without the entries of empty layers),
+// so that there is one entry per layer; if the history doesn't match the given number of layers, it returns empty entries.
+func NormalizedHistory(history []v1.History, nLayers int) []v1.History {
+	if history == nil {
+		return make([]v1.History, nLayers)
+	}
+	// ensure we remove history for empty layers
+	var normalizedHistory []v1.History
+	for _, h := range history {
+		if !h.EmptyLayer {
+			normalizedHistory = append(normalizedHistory, h)
+		}
+	}
+	if len(normalizedHistory) == nLayers {
+		return normalizedHistory
+	}
+	return make([]v1.History, nLayers)
+}
+
+// WithConfigDescriptor returns the image with the platform and URLs of the given config descriptor.
+// The mutations of the go-containerregistry mutate package keep them, as they only update the digest and size of the config descriptor.
+func WithConfigDescriptor(image v1.Image, config v1.Descriptor) v1.Image {
+	return &configDescriptorImage{Image: image, platform: config.Platform, urls: config.URLs}
+}
+
+type configDescriptorImage struct {
+	v1.Image
+	platform *v1.Platform
+	urls     []string
+}
+
+func (i *configDescriptorImage) Manifest() (*v1.Manifest, error) {
+	manifest, err := i.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	manifest = manifest.DeepCopy()
+	manifest.Config.Platform = i.platform
+	manifest.Config.URLs = i.urls
+	return manifest, nil
+}
+
+func (i *configDescriptorImage) RawManifest() ([]byte, error) {
+	manifest, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(manifest)
+}
+
+func (i *configDescriptorImage) Digest() (v1.Hash, error) {
+	return partial.Digest(i)
+}
+
+func (i *configDescriptorImage) Size() (int64, error) {
+	return partial.Size(i)
+}
@@ -0,0 +1,69 @@
+package imgutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// LabelValidator returns an error if the label with the given key and value is invalid.
+type LabelValidator func(key, value string) error
+
+// LabelError describes an invalid label.
+type LabelError struct {
+	Key string
+	Err error
+}
+
+func (e LabelError) Error() string {
+	return fmt.Sprintf("invalid label %q: %s", e.Key, e.Err)
+}
+
+func (e LabelError) Unwrap() error {
+	return e.Err
+}
+
+// OCILabelValidator returns a validator accepting keys made of printable characters without whitespace
+// (as the OCI annotation keys labels are often copied to), and values of at most maxValueSize bytes
+// (some registries reject larger values, e.g. 4096 bytes); a maxValueSize of zero or less doesn't limit values.
+func OCILabelValidator(maxValueSize int) LabelValidator {
+	return func(key, value string) error {
+		if key == "" {
+			return errors.New("key is empty")
+		}
+		for _, r := range key {
+			if !unicode.IsPrint(r) || unicode.IsSpace(r) {
+				return fmt.Errorf("key contains invalid character %q", r)
+			}
+		}
+		if maxValueSize > 0 && len(value) > maxValueSize {
+			return fmt.Errorf("value is %d bytes, larger than the limit of %d bytes", len(value), maxValueSize)
+		}
+		return nil
+	}
+}
+
+// ValidateLabels validates the labels of the image with the validator provided with WithLabelValidator,
+// returning a LabelError for each invalid label. Images are validated when they are saved.
+func (i *CNBImageCore) ValidateLabels() error {
+	if i.labelValidator == nil {
+		return nil
+	}
+	labels, err := i.Labels()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		if err := i.labelValidator(key, labels[key]); err != nil {
+			errs = append(errs, LabelError{Key: key, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package imgutil_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLabels(t *testing.T) {
+	spec.Run(t, "Labels", testLabels, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testLabels(t *testing.T, when spec.G, it spec.S) {
+	when("#SetLabel", func() {
+		it("rejects invalid labels", func() {
+			img := newTestImage(t, imgutil.WithLabelValidator(imgutil.OCILabelValidator(16)))
+
+			h.AssertNil(t, img.SetLabel("some.label", "some-value"))
+			err := img.SetLabel("some label", "some-value")
+			h.AssertError(t, err, `invalid label "some label": key contains invalid character ' '`)
+			err = img.SetLabel("some.label", strings.Repeat("x", 17))
+			var labelErr imgutil.LabelError
+			h.AssertEq(t, errors.As(err, &labelErr), true)
+			h.AssertEq(t, labelErr.Key, "some.label")
+
+			label, err := img.Label("some.label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "some-value")
+		})
+	})
+
+	when("#ValidateLabels", func() {
+		it("validates the labels of the base image", func() {
+			base, err := mutate.Config(empty.Image, v1.Config{
+				Labels: map[string]string{
+					"base.label":  strings.Repeat("x", 32),
+					"other.label": "some-value",
+				},
+			})
+			h.AssertNil(t, err)
+			img := newTestImage(t, imgutil.FromBaseImageInstance(base), imgutil.WithLabelValidator(imgutil.OCILabelValidator(16)))
+
+			err = img.ValidateLabels()
+			h.AssertError(t, err, `invalid label "base.label": value is 32 bytes, larger than the limit of 16 bytes`)
+			h.AssertNil(t, img.RemoveLabel("base.label"))
+			h.AssertNil(t, img.ValidateLabels())
+		})
+
+		it("accepts any labels without a validator", func() {
+			img := newTestImage(t)
+			h.AssertNil(t, img.SetLabel("some label", strings.Repeat("x", 32)))
+
+			h.AssertNil(t, img.ValidateLabels())
+		})
+	})
+}
@@ -0,0 +1,208 @@
+package layer
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// NormalizedModTime is the modification time given to the entries of layers created with FromDirectory;
+// it matches imgutil.NormalizedDateTime.
+var NormalizedModTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+type DirectoryOption func(*directoryOptions)
+
+type directoryOptions struct {
+	prefix  string
+	os      string
+	uid     int
+	gid     int
+	modTime time.Time
+}
+
+// WithPrefix places the contents of the directory under the given absolute path in the layer (e.g. `/layers/some-buildpack`).
+// If not provided, the contents are placed at the root of the layer.
+func WithPrefix(prefix string) DirectoryOption {
+	return func(o *directoryOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithOS sets the OS of the image the layer is created for.
+// Windows layers hold their files under `Files/`, with the entries expected by the Windows daemon.
+func WithOS(os string) DirectoryOption {
+	return func(o *directoryOptions) {
+		o.os = os
+	}
+}
+
+// WithOwner sets the user and group ID of every entry in the layer. If not provided, entries are owned by root.
+func WithOwner(uid, gid int) DirectoryOption {
+	return func(o *directoryOptions) {
+		o.uid = uid
+		o.gid = gid
+	}
+}
+
+// WithModTime sets the modification time of every entry in the layer. If not provided, NormalizedModTime is used.
+func WithModTime(t time.Time) DirectoryOption {
+	return func(o *directoryOptions) {
+		o.modTime = t
+	}
+}
+
+// FromDirectory returns a layer with the contents of the given directory, that can be added to an image
+// with CNBImageCore#AddLayerWithHistory.
+// The layer is reproducible: entries are written in lexical order, with normalized ownership, timestamps,
+// and permissions (0755 for directories and executable files, 0644 for other files).
+// Symbolic links are kept as links; other special files (sockets, devices, ...) are skipped.
+// The directory is read every time the layer contents are read, so it should not change while the layer is in use.
+func FromDirectory(dir string, ops ...DirectoryOption) (v1.Layer, error) {
+	options := directoryOptions{modTime: NormalizedModTime}
+	for _, op := range ops {
+		op(&options)
+	}
+	if options.prefix != "" && !path.IsAbs(options.prefix) {
+		return nil, fmt.Errorf("invalid prefix: must be absolute, posix path: %s", options.prefix)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeDirectory(pw, dir, options))
+		}()
+		return pr, nil
+	})
+}
+
+type tarWriter interface {
+	WriteHeader(header *tar.Header) error
+	Write(content []byte) (int, error)
+	Close() error
+}
+
+func writeDirectory(w io.Writer, dir string, options directoryOptions) error {
+	var tw tarWriter
+	if options.os == "windows" {
+		tw = NewWindowsWriter(w)
+	} else {
+		tw = tar.NewWriter(w)
+		if err := writePrefixDirs(tw, options); err != nil {
+			return err
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, fullPath)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return writeEntry(tw, fullPath, entryName(options, filepath.ToSlash(relPath)), info, options)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writePrefixDirs(tw tarWriter, options directoryOptions) error {
+	if options.prefix == "" || options.prefix == "/" {
+		return nil
+	}
+	var parentDir string
+	for _, pathPart := range strings.Split(strings.TrimPrefix(path.Clean(options.prefix), "/"), "/") {
+		parentDir = path.Join(parentDir, pathPart)
+		if err := tw.WriteHeader(normalizedHeader(&tar.Header{
+			Name:     parentDir + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+		}, options)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryName returns the name of the entry in the layer: an absolute path for Windows layers (as required by WindowsWriter),
+// and a relative path otherwise.
+func entryName(options directoryOptions, relPath string) string {
+	name := path.Join("/", options.prefix, relPath)
+	if options.os == "windows" {
+		return name
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+func writeEntry(tw tarWriter, fullPath, name string, info fs.FileInfo, options directoryOptions) error {
+	header := &tar.Header{Name: name}
+	switch {
+	case info.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Mode = 0755
+		if options.os != "windows" {
+			header.Name += "/"
+		}
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return err
+		}
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = filepath.ToSlash(target)
+		header.Mode = 0777
+	case info.Mode().IsRegular():
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+		header.Mode = 0644
+		if info.Mode()&0111 != 0 {
+			header.Mode = 0755
+		}
+	default:
+		return nil
+	}
+
+	if err := tw.WriteHeader(normalizedHeader(header, options)); err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	f, err := os.Open(filepath.Clean(fullPath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func normalizedHeader(header *tar.Header, options directoryOptions) *tar.Header {
+	header.Uid = options.uid
+	header.Gid = options.gid
+	header.ModTime = options.modTime
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	return header
+}
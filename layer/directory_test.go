@@ -0,0 +1,121 @@
+package layer_test
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestDirectory(t *testing.T) {
+	spec.Run(t, "directory", testDirectory, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDirectory(t *testing.T, when spec.G, it spec.S) {
+	var dir string
+
+	it.Before(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "layer-directory")
+		h.AssertNil(t, err)
+		h.AssertNil(t, os.MkdirAll(filepath.Join(dir, "bin"), 0700))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, "bin", "run"), []byte("#!/bin/sh"), 0700))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, "some-file"), []byte("some-content"), 0600))
+		h.AssertNil(t, os.Symlink("some-file", filepath.Join(dir, "some-link")))
+	})
+
+	it.After(func() {
+		os.RemoveAll(dir)
+	})
+
+	readHeaders := func(l v1.Layer) []*tar.Header {
+		rc, err := l.Uncompressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		var headers []*tar.Header
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return headers
+			}
+			h.AssertNil(t, err)
+			headers = append(headers, header)
+		}
+	}
+
+	when("#FromDirectory", func() {
+		it("creates a layer with normalized entries", func() {
+			l, err := layer.FromDirectory(dir, layer.WithPrefix("/layers/some-buildpack"), layer.WithOwner(1000, 1001))
+			h.AssertNil(t, err)
+
+			headers := readHeaders(l)
+			var names []string
+			for _, header := range headers {
+				names = append(names, header.Name)
+				h.AssertEq(t, header.Uid, 1000)
+				h.AssertEq(t, header.Gid, 1001)
+				h.AssertEq(t, header.ModTime.Equal(layer.NormalizedModTime), true)
+			}
+			h.AssertEq(t, names, []string{
+				"layers/",
+				"layers/some-buildpack/",
+				"layers/some-buildpack/bin/",
+				"layers/some-buildpack/bin/run",
+				"layers/some-buildpack/some-file",
+				"layers/some-buildpack/some-link",
+			})
+			h.AssertEq(t, headers[2].Mode, int64(0755))
+			h.AssertEq(t, headers[3].Mode, int64(0755))
+			h.AssertEq(t, headers[4].Mode, int64(0644))
+			h.AssertEq(t, headers[5].Typeflag, byte(tar.TypeSymlink))
+			h.AssertEq(t, headers[5].Linkname, "some-file")
+		})
+
+		it("is reproducible", func() {
+			l1, err := layer.FromDirectory(dir)
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.Chtimes(filepath.Join(dir, "some-file"), layer.NormalizedModTime, layer.NormalizedModTime))
+			l2, err := layer.FromDirectory(dir)
+			h.AssertNil(t, err)
+
+			digest1, err := l1.Digest()
+			h.AssertNil(t, err)
+			digest2, err := l2.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, digest1, digest2)
+		})
+
+		it("creates Windows layers", func() {
+			l, err := layer.FromDirectory(dir, layer.WithOS("windows"), layer.WithPrefix("/cnb"))
+			h.AssertNil(t, err)
+
+			var names []string
+			for _, header := range readHeaders(l) {
+				names = append(names, header.Name)
+			}
+			h.AssertEq(t, names, []string{
+				"Files",
+				"Hives",
+				"Files/cnb",
+				"Files/cnb/bin",
+				"Files/cnb/bin/run",
+				"Files/cnb/some-file",
+				"Files/cnb/some-link",
+			})
+		})
+
+		it("fails when the prefix is not absolute", func() {
+			_, err := layer.FromDirectory(dir, layer.WithPrefix("layers"))
+			h.AssertError(t, err, "invalid prefix")
+		})
+	})
+}
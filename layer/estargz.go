@@ -0,0 +1,82 @@
+package layer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// EstargzTOCDigestAnnotation is the layer annotation holding the digest of the table of contents of an eStargz layer.
+const EstargzTOCDigestAnnotation = estargz.TOCJSONDigestAnnotation
+
+// EstargzFromFile returns a layer with the contents of the given tar file, compressed in the eStargz format.
+// eStargz layers are regular gzip layers with a table of contents (TOC) at the end, which lets runtimes
+// pull them lazily and lets readers fetch single files with range requests.
+// The diff ID of the layer differs from the diff ID of the tar file, as the TOC is part of the uncompressed contents.
+func EstargzFromFile(path string) (v1.Layer, error) {
+	blob, err := buildEstargz(path)
+	if err != nil {
+		return nil, err
+	}
+	tocDigest := blob.TOCDigest().String()
+	if err = blob.Close(); err != nil {
+		return nil, err
+	}
+
+	// the blob is rebuilt each time it is read: eStargz builds are reproducible
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return buildEstargz(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &estargzLayer{Layer: layer, tocDigest: tocDigest}, nil
+}
+
+func buildEstargz(path string) (_ *estargz.Blob, err error) {
+	defer func() {
+		// the eStargz footer is checked with a panic, which depends on the output of compress/gzip
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to build eStargz layer from %s: %v", path, r)
+		}
+	}()
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := estargz.Build(io.NewSectionReader(f, 0, fi.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build eStargz layer from %s: %w", path, err)
+	}
+	return blob, nil
+}
+
+type estargzLayer struct {
+	v1.Layer
+	tocDigest string
+}
+
+// Descriptor adds the TOC digest annotation to the descriptor of the layer, so it is kept in the image manifest.
+func (l *estargzLayer) Descriptor() (*v1.Descriptor, error) {
+	desc, err := partial.Descriptor(l.Layer)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]string{EstargzTOCDigestAnnotation: l.tocDigest}
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	desc.Annotations = annotations
+	return desc, nil
+}
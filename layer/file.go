@@ -0,0 +1,201 @@
+package layer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// FileOption configures a layer created with FromFile.
+type FileOption func(*fileLayer)
+
+// WithDiffID provides the diff ID of the layer (e.g. computed by the lifecycle when it wrote the tar file).
+// The diff ID is trusted, so that the file is not read by FromFile: it is only hashed when the digest or size of
+// the compressed layer is first needed (e.g. on upload, but not when saving to a daemon). See VerifyDiffID.
+func WithDiffID(diffID v1.Hash) FileOption {
+	return func(l *fileLayer) {
+		l.expectedDiffID = diffID
+	}
+}
+
+// VerifyDiffID makes FromFile hash the file, and fail if its diff ID doesn't match the one provided with WithDiffID.
+func VerifyDiffID() FileOption {
+	return func(l *fileLayer) {
+		l.verifyDiffID = true
+	}
+}
+
+// FromFile returns a layer with the contents of the given uncompressed tar file.
+// The file is read once to compute both the diff ID and the digest and size of the gzip-compressed layer,
+// where tarball.LayerFromFile reads it twice; it is compressed again, with the same result, when the compressed
+// contents are read (e.g. on upload). With a trusted diff ID (see WithDiffID), the file is read lazily instead.
+// Files that are already gzip-compressed are read with tarball.LayerFromFile.
+func FromFile(path string, ops ...FileOption) (v1.Layer, error) {
+	compressed, err := isGzipped(path)
+	if err != nil {
+		return nil, err
+	}
+	if compressed {
+		return tarball.LayerFromFile(path)
+	}
+
+	l := &fileLayer{path: path}
+	for _, op := range ops {
+		op(l)
+	}
+	if l.expectedDiffID != (v1.Hash{}) && !l.verifyDiffID {
+		l.diffID = l.expectedDiffID
+		return l, nil
+	}
+	if err = l.ensureHashed(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func isGzipped(path string) (bool, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, 2)
+	if _, err = io.ReadFull(f, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(magic, []byte{0x1f, 0x8b}), nil
+}
+
+type fileLayer struct {
+	path           string
+	expectedDiffID v1.Hash
+	verifyDiffID   bool
+	diffID         v1.Hash
+
+	once   sync.Once
+	err    error
+	digest v1.Hash
+	size   int64
+}
+
+func (l *fileLayer) ensureHashed() error {
+	l.once.Do(func() {
+		l.err = l.hash()
+	})
+	return l.err
+}
+
+// hash computes the digest and size of the compressed layer, and the diff ID unless it is trusted, in a single read of the file.
+func (l *fileLayer) hash() error {
+	f, err := os.Open(filepath.Clean(l.path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	digestHasher := sha256.New()
+	counter := &countingWriter{}
+	zw, err := gzip.NewWriterLevel(io.MultiWriter(digestHasher, counter), gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	trusted := l.diffID != (v1.Hash{})
+	diffIDHasher := sha256.New()
+	var w io.Writer = zw
+	if !trusted {
+		w = io.MultiWriter(diffIDHasher, zw)
+	}
+	if _, err = io.Copy(w, bufio.NewReader(f)); err != nil {
+		return fmt.Errorf("failed to hash layer at path %s: %w", l.path, err)
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	if !trusted {
+		l.diffID = sha256Hash(diffIDHasher)
+		if l.expectedDiffID != (v1.Hash{}) && l.diffID != l.expectedDiffID {
+			return fmt.Errorf("layer at path %s has diff ID %s; expected %s", l.path, l.diffID, l.expectedDiffID)
+		}
+	}
+	l.digest = sha256Hash(digestHasher)
+	l.size = counter.n
+	return nil
+}
+
+func sha256Hash(h hash.Hash) v1.Hash {
+	return v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))}
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func (l *fileLayer) Digest() (v1.Hash, error) {
+	if err := l.ensureHashed(); err != nil {
+		return v1.Hash{}, err
+	}
+	return l.digest, nil
+}
+
+func (l *fileLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *fileLayer) Size() (int64, error) {
+	if err := l.ensureHashed(); err != nil {
+		return 0, err
+	}
+	return l.size, nil
+}
+
+func (l *fileLayer) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
+
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error) {
+	return os.Open(filepath.Clean(l.path))
+}
+
+// Compressed compresses the file as it is read, with the same settings used to compute the digest.
+func (l *fileLayer) Compressed() (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Clean(l.path))
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		defer f.Close()
+		zw, err := gzip.NewWriterLevel(pw, gzip.BestSpeed)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err = io.Copy(zw, bufio.NewReader(f)); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(zw.Close())
+	}()
+	return pr, nil
+}
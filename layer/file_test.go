@@ -0,0 +1,201 @@
+package layer_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestFile(t *testing.T) {
+	spec.Run(t, "file", testFile, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testFile(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir string
+		path   string
+		diffID string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "layer-file")
+		h.AssertNil(t, err)
+		path, diffID = h.SeededRandomLayer(t, tmpDir, 1, 1024*1024, 4)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("has the same diff ID, digest, and size as a tarball layer", func() {
+		fileLayer, err := layer.FromFile(path)
+		h.AssertNil(t, err)
+		tarballLayer, err := tarball.LayerFromFile(path)
+		h.AssertNil(t, err)
+
+		actualDiffID, err := fileLayer.DiffID()
+		h.AssertNil(t, err)
+		h.AssertEq(t, actualDiffID.String(), diffID)
+
+		digest, err := fileLayer.Digest()
+		h.AssertNil(t, err)
+		expectedDigest, err := tarballLayer.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, digest, expectedDigest)
+
+		size, err := fileLayer.Size()
+		h.AssertNil(t, err)
+		expectedSize, err := tarballLayer.Size()
+		h.AssertNil(t, err)
+		h.AssertEq(t, size, expectedSize)
+	})
+
+	it("compresses to the content described by the digest", func() {
+		fileLayer, err := layer.FromFile(path)
+		h.AssertNil(t, err)
+
+		rc, err := fileLayer.Compressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		hasher := sha256.New()
+		n, err := io.Copy(hasher, rc)
+		h.AssertNil(t, err)
+
+		digest, err := fileLayer.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, "sha256:"+hex.EncodeToString(hasher.Sum(nil)), digest.String())
+		size, err := fileLayer.Size()
+		h.AssertNil(t, err)
+		h.AssertEq(t, n, size)
+	})
+
+	when("#WithDiffID", func() {
+		it("accepts the diff ID of the file", func() {
+			expected, err := v1.NewHash(diffID)
+			h.AssertNil(t, err)
+			_, err = layer.FromFile(path, layer.WithDiffID(expected))
+			h.AssertNil(t, err)
+		})
+
+		it("trusts the diff ID, and hashes the file only when the digest is needed", func() {
+			wrong := v1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
+			fileLayer, err := layer.FromFile(path, layer.WithDiffID(wrong))
+			h.AssertNil(t, err)
+			actualDiffID, err := fileLayer.DiffID()
+			h.AssertNil(t, err)
+			h.AssertEq(t, actualDiffID, wrong)
+
+			h.AssertNil(t, os.Rename(path, path+".moved"))
+			_, err = fileLayer.Digest()
+			h.AssertError(t, err, "no such file or directory")
+		})
+
+		it("fails when the diff ID does not match the file and it is verified", func() {
+			wrong := v1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
+			_, err := layer.FromFile(path, layer.WithDiffID(wrong), layer.VerifyDiffID())
+			h.AssertError(t, err, "expected "+wrong.String())
+		})
+
+		it("computes the same digest and size lazily", func() {
+			expected, err := v1.NewHash(diffID)
+			h.AssertNil(t, err)
+			eager, err := layer.FromFile(path)
+			h.AssertNil(t, err)
+			lazy, err := layer.FromFile(path, layer.WithDiffID(expected))
+			h.AssertNil(t, err)
+
+			eagerDigest, err := eager.Digest()
+			h.AssertNil(t, err)
+			lazyDigest, err := lazy.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, lazyDigest, eagerDigest)
+			eagerSize, err := eager.Size()
+			h.AssertNil(t, err)
+			lazySize, err := lazy.Size()
+			h.AssertNil(t, err)
+			h.AssertEq(t, lazySize, eagerSize)
+		})
+	})
+
+	when("the file is already compressed", func() {
+		it("reads it as a tarball layer", func() {
+			gzPath := filepath.Join(tmpDir, "layer.tar.gz")
+			f, err := os.Create(gzPath)
+			h.AssertNil(t, err)
+			zw := gzip.NewWriter(f)
+			src, err := os.Open(path)
+			h.AssertNil(t, err)
+			_, err = io.Copy(zw, src)
+			h.AssertNil(t, err)
+			h.AssertNil(t, src.Close())
+			h.AssertNil(t, zw.Close())
+			h.AssertNil(t, f.Close())
+
+			fileLayer, err := layer.FromFile(gzPath)
+			h.AssertNil(t, err)
+			actualDiffID, err := fileLayer.DiffID()
+			h.AssertNil(t, err)
+			h.AssertEq(t, actualDiffID.String(), diffID)
+		})
+	})
+}
+
+func BenchmarkFromFile(b *testing.B) {
+	path := benchmarkLayer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := layer.FromFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTarballLayerFromFile(b *testing.B) {
+	path := benchmarkLayer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tarball.LayerFromFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkLayer writes a 64MB tar file of random content
+func benchmarkLayer(b *testing.B) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "layer.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	const size = 64 * 1024 * 1024
+	tw := tar.NewWriter(f)
+	if err = tw.WriteHeader(&tar.Header{Name: "/some-file", Mode: 0644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		b.Fatal(err)
+	}
+	if _, err = io.CopyN(tw, rand.New(rand.NewSource(1)), size); err != nil { // #nosec G404
+		b.Fatal(err)
+	}
+	if err = tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(size)
+	return path
+}
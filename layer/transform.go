@@ -0,0 +1,102 @@
+package layer
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// EntryTransform rewrites an entry of a layer, returning the header and contents to write in its place,
+// or a nil header to drop the entry. When the contents are replaced, the size in the returned header must match them.
+type EntryTransform func(header *tar.Header, contents io.Reader) (*tar.Header, io.Reader, error)
+
+// Transform returns a layer with the entries of the given layer rewritten by each of the given transforms in turn,
+// e.g. to drop temporary files or normalize timestamps.
+// The returned layer has the media type of the given layer, and new digests and diff ID when its entries changed.
+// The given layer is read every time the contents of the returned layer are read.
+func Transform(layer v1.Layer, transforms ...EntryTransform) (v1.Layer, error) {
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	ops := []tarball.LayerOption{tarball.WithMediaType(mediaType)}
+	if mediaType == types.OCILayerZStd {
+		ops = append(ops, tarball.WithCompression(compression.ZStd))
+	}
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			defer rc.Close()
+			pw.CloseWithError(transformEntries(pw, rc, transforms))
+		}()
+		return pr, nil
+	}, ops...)
+}
+
+func transformEntries(w io.Writer, r io.Reader, transforms []EntryTransform) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		var contents io.Reader = tr
+		for _, transform := range transforms {
+			if header, contents, err = transform(header, contents); err != nil {
+				return err
+			}
+			if header == nil {
+				break
+			}
+		}
+		if header == nil {
+			continue
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, contents); err != nil {
+			return err
+		}
+	}
+}
+
+// DropPaths drops the entries at or under the given absolute paths (e.g. `/tmp`).
+func DropPaths(paths ...string) EntryTransform {
+	return func(header *tar.Header, contents io.Reader) (*tar.Header, io.Reader, error) {
+		name := path.Clean("/" + header.Name)
+		for _, p := range paths {
+			p = path.Clean(p)
+			if name == p || strings.HasPrefix(name, strings.TrimSuffix(p, "/")+"/") {
+				return nil, nil, nil
+			}
+		}
+		return header, contents, nil
+	}
+}
+
+// SetModTime sets the modification time of every entry to the given time (e.g. NormalizedModTime),
+// and clears their access and change times.
+func SetModTime(t time.Time) EntryTransform {
+	return func(header *tar.Header, contents io.Reader) (*tar.Header, io.Reader, error) {
+		header.ModTime = t
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+		return header, contents, nil
+	}
+}
@@ -0,0 +1,103 @@
+package layer_test
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestTransform(t *testing.T) {
+	spec.Run(t, "transform", testTransform, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testTransform(t *testing.T, when spec.G, it spec.S) {
+	var (
+		dir      string
+		original v1.Layer
+	)
+
+	it.Before(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "layer-transform")
+		h.AssertNil(t, err)
+		h.AssertNil(t, os.MkdirAll(filepath.Join(dir, "tmp", "cache"), 0700))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, "tmp", "cache", "some-file"), []byte("cached"), 0600))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, "tmpfile"), []byte("kept"), 0600))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("some-secret"), 0600))
+		original, err = layer.FromDirectory(dir)
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(dir)
+	})
+
+	readEntries := func(l v1.Layer) map[string]string {
+		rc, err := l.Uncompressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		entries := map[string]string{}
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return entries
+			}
+			h.AssertNil(t, err)
+			contents, err := io.ReadAll(tr)
+			h.AssertNil(t, err)
+			entries[header.Name] = header.ModTime.UTC().Format(time.RFC3339) + " " + string(contents)
+		}
+	}
+
+	it("rewrites the entries of the layer with each transform", func() {
+		modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		redact := func(header *tar.Header, contents io.Reader) (*tar.Header, io.Reader, error) {
+			if header.Name != "secret" {
+				return header, contents, nil
+			}
+			header.Size = int64(len("redacted"))
+			return header, strings.NewReader("redacted"), nil
+		}
+
+		transformed, err := layer.Transform(original, layer.DropPaths("/tmp"), layer.SetModTime(modTime), redact)
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, readEntries(transformed), map[string]string{
+			"tmpfile": "2024-01-02T03:04:05Z kept",
+			"secret":  "2024-01-02T03:04:05Z redacted",
+		})
+		originalDiffID, err := original.DiffID()
+		h.AssertNil(t, err)
+		diffID, err := transformed.DiffID()
+		h.AssertNil(t, err)
+		h.AssertNotEq(t, diffID, originalDiffID)
+		mediaType, err := transformed.MediaType()
+		h.AssertNil(t, err)
+		originalMediaType, err := original.MediaType()
+		h.AssertNil(t, err)
+		h.AssertEq(t, mediaType, originalMediaType)
+	})
+
+	it("keeps the layer unchanged without transforms", func() {
+		transformed, err := layer.Transform(original)
+		h.AssertNil(t, err)
+
+		originalDiffID, err := original.DiffID()
+		h.AssertNil(t, err)
+		diffID, err := transformed.DiffID()
+		h.AssertNil(t, err)
+		h.AssertEq(t, diffID, originalDiffID)
+	})
+}
@@ -0,0 +1,169 @@
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+const (
+	// WhiteoutPrefix prefixes the name of an entry that removes the file with the same name (without the prefix)
+	// from the filesystem composed by the layers below.
+	WhiteoutPrefix = ".wh."
+	// OpaqueWhiteout is the name of an entry that removes the contents of its parent directory
+	// from the filesystem composed by the layers below.
+	OpaqueWhiteout = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+)
+
+// Whiteout describes the removal of an absolute path from the filesystem composed by the layers below.
+type Whiteout struct {
+	Path string
+	// Opaque keeps the directory at Path, but removes its contents.
+	Opaque bool
+}
+
+// Name returns the name of the whiteout entry.
+func (w Whiteout) Name() string {
+	cleanPath := path.Clean(w.Path)
+	if w.Opaque {
+		return path.Join(cleanPath, OpaqueWhiteout)
+	}
+	return path.Join(path.Dir(cleanPath), WhiteoutPrefix+path.Base(cleanPath))
+}
+
+// ParseWhiteout returns the whiteout described by the given layer entry name, if the entry is a whiteout.
+func ParseWhiteout(name string) (Whiteout, bool) {
+	name = path.Join("/", filepath.ToSlash(name))
+	dir, base := path.Split(name)
+	switch {
+	case base == OpaqueWhiteout:
+		return Whiteout{Path: path.Clean(dir), Opaque: true}, true
+	case strings.HasPrefix(base, WhiteoutPrefix):
+		return Whiteout{Path: path.Join(dir, strings.TrimPrefix(base, WhiteoutPrefix))}, true
+	default:
+		return Whiteout{}, false
+	}
+}
+
+// Apply removes the whiteout path from the filesystem extracted at root.
+// Paths resolving outside of root are rejected, and an opaque whiteout doesn't empty a symbolic link to a directory.
+func (w Whiteout) Apply(root string) error {
+	return w.ApplyKeeping(root, nil)
+}
+
+// ApplyKeeping is Apply, but an opaque whiteout keeps the entries of the directory
+// whose absolute posix path keep returns true for (e.g. the entries extracted from the same layer).
+func (w Whiteout) ApplyKeeping(root string, keep func(posixPath string) bool) error {
+	target, err := SecureJoin(root, w.Path)
+	if err != nil {
+		return err
+	}
+	if !w.Opaque {
+		return os.RemoveAll(target)
+	}
+	// SecureJoin only checks the parents of the target, so a symbolic link at the target must not be followed
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if keep != nil && keep(path.Join(path.Clean(w.Path), entry.Name())) {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(target, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecureJoin joins the posix path to root, as if root was the filesystem root.
+// It fails if the closest existing parent directory of the result resolves (through symbolic links) outside of root.
+func SecureJoin(root, posixPath string) (string, error) {
+	target := filepath.Join(root, filepath.FromSlash(path.Join("/", posixPath)))
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	for dir := filepath.Dir(target); ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if os.IsNotExist(err) && len(dir) > len(root) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if rel, err := filepath.Rel(realRoot, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("path %q resolves outside of the root directory", posixPath)
+		}
+		return target, nil
+	}
+}
+
+// WhiteoutLayer returns a layer that removes the given paths from the filesystem composed by the layers below.
+// WithOS, WithOwner, and WithModTime options apply to the layer entries; WithPrefix is ignored.
+func WhiteoutLayer(whiteouts []Whiteout, ops ...DirectoryOption) (v1.Layer, error) {
+	options := directoryOptions{modTime: NormalizedModTime}
+	for _, op := range ops {
+		op(&options)
+	}
+	names := make([]string, 0, len(whiteouts))
+	for _, whiteout := range whiteouts {
+		if !path.IsAbs(whiteout.Path) {
+			return nil, fmt.Errorf("invalid whiteout path: must be absolute, posix path: %s", whiteout.Path)
+		}
+		names = append(names, whiteout.Name())
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	if err := writeWhiteouts(buf, names, options); err != nil {
+		return nil, err
+	}
+	content := buf.Bytes()
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	})
+}
+
+func writeWhiteouts(w io.Writer, names []string, options directoryOptions) error {
+	var tw tarWriter
+	if options.os == "windows" {
+		tw = NewWindowsWriter(w)
+	} else {
+		tw = tar.NewWriter(w)
+	}
+	// parent directories are not written, so that the directories of the layers below keep their mode and owner
+	for _, name := range names {
+		if options.os != "windows" {
+			name = strings.TrimPrefix(name, "/")
+		}
+		if err := tw.WriteHeader(normalizedHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+		}, options)); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
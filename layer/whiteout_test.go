@@ -0,0 +1,152 @@
+package layer_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestWhiteout(t *testing.T) {
+	spec.Run(t, "whiteout", testWhiteout, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testWhiteout(t *testing.T, when spec.G, it spec.S) {
+	when("#WhiteoutLayer", func() {
+		it("creates whiteout entries", func() {
+			l, err := layer.WhiteoutLayer([]layer.Whiteout{
+				{Path: "/workspace/some-file"},
+				{Path: "/layers/some-dir", Opaque: true},
+			})
+			h.AssertNil(t, err)
+
+			rc, err := l.Uncompressed()
+			h.AssertNil(t, err)
+			defer rc.Close()
+			var names []string
+			tr := tar.NewReader(rc)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				h.AssertNil(t, err)
+				names = append(names, header.Name)
+			}
+			h.AssertEq(t, names, []string{
+				"layers/some-dir/.wh..wh..opq",
+				"workspace/.wh.some-file",
+			})
+		})
+
+		it("keeps the mode of the parent directories of the layers below", func() {
+			buf := &bytes.Buffer{}
+			tw := tar.NewWriter(buf)
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "tmp/", Typeflag: tar.TypeDir, Mode: 01777}))
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "tmp/some-file", Typeflag: tar.TypeReg, Mode: 0644}))
+			h.AssertNil(t, tw.Close())
+			l, err := layer.WhiteoutLayer([]layer.Whiteout{{Path: "/tmp/some-file"}})
+			h.AssertNil(t, err)
+			rc, err := l.Uncompressed()
+			h.AssertNil(t, err)
+			defer rc.Close()
+
+			modes := map[string]int64{}
+			for _, r := range []io.Reader{buf, rc} {
+				tr := tar.NewReader(r)
+				for {
+					header, err := tr.Next()
+					if err == io.EOF {
+						break
+					}
+					h.AssertNil(t, err)
+					modes[header.Name] = header.Mode
+				}
+			}
+			h.AssertEq(t, modes["tmp/"], int64(01777))
+		})
+
+		it("fails when a path is not absolute", func() {
+			_, err := layer.WhiteoutLayer([]layer.Whiteout{{Path: "some-file"}})
+			h.AssertError(t, err, "invalid whiteout path")
+		})
+	})
+
+	when("#ParseWhiteout", func() {
+		it("parses whiteout entries", func() {
+			whiteout, ok := layer.ParseWhiteout("workspace/.wh.some-file")
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, whiteout, layer.Whiteout{Path: "/workspace/some-file"})
+
+			whiteout, ok = layer.ParseWhiteout("layers/some-dir/.wh..wh..opq")
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, whiteout, layer.Whiteout{Path: "/layers/some-dir", Opaque: true})
+
+			_, ok = layer.ParseWhiteout("workspace/some-file")
+			h.AssertEq(t, ok, false)
+		})
+	})
+
+	when("#Apply", func() {
+		var root string
+
+		it.Before(func() {
+			var err error
+			root, err = os.MkdirTemp("", "whiteout")
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.MkdirAll(filepath.Join(root, "some-dir", "nested"), 0755))
+			h.AssertNil(t, os.WriteFile(filepath.Join(root, "some-dir", "some-file"), []byte{}, 0600))
+			h.AssertNil(t, os.WriteFile(filepath.Join(root, "other-file"), []byte{}, 0600))
+		})
+
+		it.After(func() {
+			os.RemoveAll(root)
+		})
+
+		it("removes the path", func() {
+			h.AssertNil(t, layer.Whiteout{Path: "/other-file"}.Apply(root))
+			_, err := os.Stat(filepath.Join(root, "other-file"))
+			h.AssertEq(t, os.IsNotExist(err), true)
+		})
+
+		it("empties opaque directories", func() {
+			h.AssertNil(t, layer.Whiteout{Path: "/some-dir", Opaque: true}.Apply(root))
+			entries, err := os.ReadDir(filepath.Join(root, "some-dir"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 0)
+		})
+
+		it("does not follow symbolic links outside of the root", func() {
+			outside, err := os.MkdirTemp("", "whiteout-outside")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(outside)
+			h.AssertNil(t, os.WriteFile(filepath.Join(outside, "some-file"), []byte{}, 0600))
+			h.AssertNil(t, os.Symlink(outside, filepath.Join(root, "some-link")))
+
+			err = layer.Whiteout{Path: "/some-link/some-file"}.Apply(root)
+			h.AssertError(t, err, "outside of the root directory")
+			_, err = os.Stat(filepath.Join(outside, "some-file"))
+			h.AssertNil(t, err)
+		})
+
+		it("does not empty directories outside of the root through a symbolic link", func() {
+			outside, err := os.MkdirTemp("", "whiteout-outside")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(outside)
+			h.AssertNil(t, os.WriteFile(filepath.Join(outside, "some-file"), []byte{}, 0600))
+			h.AssertNil(t, os.Symlink(outside, filepath.Join(root, "some-link")))
+
+			h.AssertNil(t, layer.Whiteout{Path: "/some-link", Opaque: true}.Apply(root))
+			_, err = os.Stat(filepath.Join(outside, "some-file"))
+			h.AssertNil(t, err)
+		})
+	})
+}
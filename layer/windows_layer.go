@@ -0,0 +1,87 @@
+package layer
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// WindowsFromFile returns a Windows layer with the contents of the given tar file.
+// A tar file holding a Linux-style filesystem is rewritten with a WindowsWriter each time the layer is read,
+// which puts its entries under `Files/` with the PAX headers expected by the Windows daemon.
+// A tar file that already is a Windows layer (its first entry is `Files` or `Hives`) is used as-is, with the given options.
+func WindowsFromFile(filePath string, ops ...FileOption) (v1.Layer, error) {
+	wrapped, err := IsWindowsLayer(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if wrapped {
+		return FromFile(filePath, ops...)
+	}
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		f, err := os.Open(filepath.Clean(filePath))
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			defer f.Close()
+			pw.CloseWithError(writeWindowsLayer(pw, f))
+		}()
+		return pr, nil
+	})
+}
+
+// IsWindowsLayer reports whether the tar file at the given path is a Windows layer, i.e. its first entry is `Files` or `Hives`.
+func IsWindowsLayer(filePath string) (bool, error) {
+	f, err := os.Open(filepath.Clean(filePath))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	header, err := tar.NewReader(f).Next()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	first := strings.SplitN(path.Clean(strings.TrimPrefix(header.Name, "/")), "/", 2)[0]
+	return first == "Files" || first == "Hives", nil
+}
+
+func writeWindowsLayer(w io.Writer, r io.Reader) error {
+	tw := NewWindowsWriter(w)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Join("/", header.Name)
+		if name == "/" {
+			continue
+		}
+		header.Name = name
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = layerFilesPath(path.Join("/", header.Linkname))
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, tr); err != nil { // #nosec G110
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,108 @@
+package layer_test
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestWindowsLayer(t *testing.T) {
+	spec.Run(t, "windows-layer", testWindowsLayer, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testWindowsLayer(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "windows-layer")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	writeTar := func(name string, headers ...*tar.Header) string {
+		path := filepath.Join(tmpDir, name)
+		f, err := os.Create(path)
+		h.AssertNil(t, err)
+		defer f.Close()
+		tw := tar.NewWriter(f)
+		for _, header := range headers {
+			h.AssertNil(t, tw.WriteHeader(header))
+			_, err = tw.Write(make([]byte, header.Size))
+			h.AssertNil(t, err)
+		}
+		h.AssertNil(t, tw.Close())
+		return path
+	}
+
+	readHeaders := func(path string) []*tar.Header {
+		l, err := layer.WindowsFromFile(path)
+		h.AssertNil(t, err)
+		rc, err := l.Uncompressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		var headers []*tar.Header
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return headers
+			}
+			h.AssertNil(t, err)
+			headers = append(headers, header)
+		}
+	}
+
+	when("#WindowsFromFile", func() {
+		it("wraps a Linux-style tar file", func() {
+			path := writeTar("linux.tar",
+				&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+				&tar.Header{Name: "cnb/some-file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+				&tar.Header{Name: "cnb/some-link", Typeflag: tar.TypeLink, Linkname: "cnb/some-file"},
+			)
+			wrapped, err := layer.IsWindowsLayer(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, wrapped, false)
+
+			var names []string
+			for _, header := range readHeaders(path) {
+				names = append(names, header.Name)
+				if header.Name == "Files/cnb/some-file" {
+					h.AssertEq(t, header.PAXRecords["MSWINDOWS.rawsd"], layer.AdministratratorOwnerAndGroupSID)
+				}
+				if header.Name == "Files/cnb/some-link" {
+					h.AssertEq(t, header.Linkname, "Files/cnb/some-file")
+				}
+			}
+			h.AssertEq(t, names, []string{"Files", "Hives", "Files/cnb", "Files/cnb/some-file", "Files/cnb/some-link"})
+		})
+
+		it("keeps a Windows layer unchanged", func() {
+			path := writeTar("windows.tar",
+				&tar.Header{Name: "Files", Typeflag: tar.TypeDir},
+				&tar.Header{Name: "Hives", Typeflag: tar.TypeDir},
+				&tar.Header{Name: "Files/some-file", Typeflag: tar.TypeReg, Size: 4},
+			)
+			wrapped, err := layer.IsWindowsLayer(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, wrapped, true)
+
+			var names []string
+			for _, header := range readHeaders(path) {
+				names = append(names, header.Name)
+			}
+			h.AssertEq(t, names, []string{"Files", "Hives", "Files/some-file"})
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SizeError is returned when more data is written to a Writer than its maximum size (see WithMaxSize).
+type SizeError struct {
+	Limit int64
+}
+
+func (e SizeError) Error() string {
+	return fmt.Sprintf("layer is larger than the limit of %d bytes", e.Limit)
+}
+
+type WriterOption func(*writerOptions)
+
+type writerOptions struct {
+	maxSize int64
+}
+
+// WithMaxSize makes the Writer fail as soon as the uncompressed layer (tar headers included) exceeds the given size in bytes,
+// with a SizeError. A size of zero or less is not checked.
+func WithMaxSize(size int64) WriterOption {
+	return func(o *writerOptions) {
+		o.maxSize = size
+	}
+}
+
+// Writer writes a layer as a tar stream, computing its diff ID, and its compressed digest and size, as the contents are written,
+// so that layers can be created without temporary files. The gzip compressed layer is kept in memory.
+// Once the Writer is closed, Layer returns the layer, which can be added to an image with CNBImageCore#AddLayerWithHistory.
+type Writer struct {
+	tarWriter    *tar.Writer
+	gzipWriter   *gzip.Writer
+	uncompressed *limitedWriter
+	diffID       hash.Hash
+	digest       hash.Hash
+	compressed   bytes.Buffer
+	closed       bool
+}
+
+// NewWriter returns a Writer for a new layer.
+func NewWriter(ops ...WriterOption) *Writer {
+	options := writerOptions{}
+	for _, op := range ops {
+		op(&options)
+	}
+	w := &Writer{
+		diffID: sha256.New(),
+		digest: sha256.New(),
+	}
+	w.gzipWriter, _ = gzip.NewWriterLevel(io.MultiWriter(&w.compressed, w.digest), gzip.BestSpeed) // BestSpeed is a valid level
+	w.uncompressed = &limitedWriter{
+		w:     io.MultiWriter(w.gzipWriter, w.diffID),
+		limit: options.maxSize,
+	}
+	w.tarWriter = tar.NewWriter(w.uncompressed)
+	return w
+}
+
+// WriteHeader writes the header of the next entry of the layer, as with tar.Writer.
+func (w *Writer) WriteHeader(header *tar.Header) error {
+	if w.closed {
+		return errors.New("layer writer is closed")
+	}
+	return w.tarWriter.WriteHeader(header)
+}
+
+// Write writes the contents of the current entry of the layer, as with tar.Writer.
+func (w *Writer) Write(content []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("layer writer is closed")
+	}
+	return w.tarWriter.Write(content)
+}
+
+// Size returns the number of bytes of the uncompressed layer written so far.
+func (w *Writer) Size() int64 {
+	return w.uncompressed.n
+}
+
+// Close finishes the layer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.tarWriter.Close(); err != nil {
+		return err
+	}
+	return w.gzipWriter.Close()
+}
+
+// Layer returns the layer written to the closed Writer.
+func (w *Writer) Layer() (v1.Layer, error) {
+	if !w.closed {
+		return nil, errors.New("layer writer must be closed before the layer is read")
+	}
+	return &writtenLayer{
+		compressed:       w.compressed.Bytes(),
+		diffID:           sha256Hash(w.diffID),
+		digest:           sha256Hash(w.digest),
+		uncompressedSize: w.uncompressed.n,
+	}, nil
+}
+
+// limitedWriter counts the bytes written to w, and fails writes that would exceed the limit.
+type limitedWriter struct {
+	w     io.Writer
+	n     int64
+	limit int64
+}
+
+func (c *limitedWriter) Write(p []byte) (int, error) {
+	if c.limit > 0 && c.n+int64(len(p)) > c.limit {
+		return 0, SizeError{Limit: c.limit}
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type writtenLayer struct {
+	compressed       []byte
+	diffID           v1.Hash
+	digest           v1.Hash
+	uncompressedSize int64
+}
+
+func (l *writtenLayer) Digest() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *writtenLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *writtenLayer) Compressed() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(l.compressed)), nil
+}
+
+func (l *writtenLayer) Uncompressed() (io.ReadCloser, error) {
+	return gzip.NewReader(bytes.NewReader(l.compressed))
+}
+
+// Size returns the size of the compressed layer.
+func (l *writtenLayer) Size() (int64, error) {
+	return int64(len(l.compressed)), nil
+}
+
+func (l *writtenLayer) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
@@ -0,0 +1,113 @@
+package layer_test
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestWriter(t *testing.T) {
+	spec.Run(t, "writer", testWriter, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+type tarEntryWriter interface {
+	WriteHeader(header *tar.Header) error
+	Write(content []byte) (int, error)
+}
+
+func testWriter(t *testing.T, when spec.G, it spec.S) {
+	writeEntries := func(tw tarEntryWriter, contents ...string) error {
+		for idx, content := range contents {
+			if err := tw.WriteHeader(&tar.Header{
+				Name:     filepath.ToSlash(filepath.Join("some-dir", string(rune('a'+idx)))),
+				Typeflag: tar.TypeReg,
+				Mode:     0644,
+				Size:     int64(len(content)),
+			}); err != nil {
+				return err
+			}
+			if _, err := tw.Write([]byte(content)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	it("has the same diff ID, digest, and size as a layer from a file", func() {
+		w := layer.NewWriter()
+		h.AssertNil(t, writeEntries(w, "some-content", "other-content"))
+		h.AssertNil(t, w.Close())
+		written, err := w.Layer()
+		h.AssertNil(t, err)
+
+		tmpDir, err := os.MkdirTemp("", "layer-writer")
+		h.AssertNil(t, err)
+		defer os.RemoveAll(tmpDir)
+		path := filepath.Join(tmpDir, "layer.tar")
+		f, err := os.Create(path)
+		h.AssertNil(t, err)
+		tw := tar.NewWriter(f)
+		h.AssertNil(t, writeEntries(tw, "some-content", "other-content"))
+		h.AssertNil(t, tw.Close())
+		h.AssertNil(t, f.Close())
+		fileLayer, err := layer.FromFile(path)
+		h.AssertNil(t, err)
+
+		diffID, err := written.DiffID()
+		h.AssertNil(t, err)
+		expectedDiffID, err := fileLayer.DiffID()
+		h.AssertNil(t, err)
+		h.AssertEq(t, diffID, expectedDiffID)
+		digest, err := written.Digest()
+		h.AssertNil(t, err)
+		expectedDigest, err := fileLayer.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, digest, expectedDigest)
+		size, err := written.Size()
+		h.AssertNil(t, err)
+		expectedSize, err := fileLayer.Size()
+		h.AssertNil(t, err)
+		h.AssertEq(t, size, expectedSize)
+
+		info, err := os.Stat(path)
+		h.AssertNil(t, err)
+		h.AssertEq(t, w.Size(), info.Size())
+		rc, err := written.Uncompressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		tr := tar.NewReader(rc)
+		header, err := tr.Next()
+		h.AssertNil(t, err)
+		h.AssertEq(t, header.Name, "some-dir/a")
+		content, err := io.ReadAll(tr)
+		h.AssertNil(t, err)
+		h.AssertEq(t, string(content), "some-content")
+	})
+
+	it("fails once the layer exceeds the maximum size", func() {
+		w := layer.NewWriter(layer.WithMaxSize(2048))
+		err := writeEntries(w, string(make([]byte, 4096)))
+		var sizeErr layer.SizeError
+		h.AssertEq(t, errors.As(err, &sizeErr), true)
+		h.AssertEq(t, sizeErr.Limit, int64(2048))
+		h.AssertEq(t, w.Size() <= 2048, true)
+	})
+
+	it("fails to return the layer before it is closed", func() {
+		w := layer.NewWriter()
+		_, err := w.Layer()
+		h.AssertError(t, err, "layer writer must be closed before the layer is read")
+		h.AssertNil(t, w.Close())
+		_, err = w.Write([]byte("some-content"))
+		h.AssertError(t, err, "layer writer is closed")
+	})
+}
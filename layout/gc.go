@@ -0,0 +1,111 @@
+package layout
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// PruneResult describes the blobs removed from a layout by Prune.
+type PruneResult struct {
+	RemovedBlobs   []v1.Hash
+	ReclaimedBytes int64
+}
+
+// Prune removes every blob in the `blobs` directory that is not referenced from the layout's `index.json`,
+// either directly or through the manifests, configs, layers, and nested indexes it references.
+// Saving a new image to an existing path replaces `index.json` but leaves the blobs of the previous image behind,
+// so long-lived layout directories (e.g. caches on CI machines) should be pruned periodically.
+func (l Path) Prune() (PruneResult, error) {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return PruneResult{}, fmt.Errorf("failed to load index: %w", err)
+	}
+	referenced := make(map[v1.Hash]bool)
+	if err = referencedByIndex(index, referenced); err != nil {
+		return PruneResult{}, err
+	}
+
+	var result PruneResult
+	err = l.walkBlobs(func(hash v1.Hash, path string, info fs.FileInfo) error {
+		if referenced[hash] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove blob %s: %w", hash, err)
+		}
+		result.RemovedBlobs = append(result.RemovedBlobs, hash)
+		result.ReclaimedBytes += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+// BlobsSize returns the total size in bytes of the blobs stored in the layout.
+func (l Path) BlobsSize() (int64, error) {
+	var size int64
+	err := l.walkBlobs(func(_ v1.Hash, _ string, info fs.FileInfo) error {
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func (l Path) walkBlobs(fn func(hash v1.Hash, path string, info fs.FileInfo) error) error {
+	blobsDir := l.append("blobs")
+	if !pathExists(blobsDir) {
+		return nil
+	}
+	return filepath.WalkDir(blobsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		hash, err := v1.NewHash(filepath.Base(filepath.Dir(path)) + ":" + d.Name())
+		if err != nil {
+			// not a blob, e.g. a temporary file left behind by an interrupted write
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(hash, path, info)
+	})
+}
+
+func referencedByIndex(index v1.ImageIndex, referenced map[v1.Hash]bool) error {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range indexManifest.Manifests {
+		referenced[desc.Digest] = true
+		switch {
+		case desc.MediaType.IsImage():
+			image, err := index.Image(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to load image %s: %w", desc.Digest, err)
+			}
+			manifest, err := image.Manifest()
+			if err != nil {
+				return fmt.Errorf("failed to load manifest %s: %w", desc.Digest, err)
+			}
+			referenced[manifest.Config.Digest] = true
+			for _, layer := range manifest.Layers {
+				referenced[layer.Digest] = true
+			}
+		case desc.MediaType.IsIndex():
+			childIndex, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to load index %s: %w", desc.Digest, err)
+			}
+			if err = referencedByIndex(childIndex, referenced); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package layout
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.ImageIndex = &Index{}
+
+// Index is the index of an OCI layout, i.e. its `index.json`, that can be modified and saved,
+// so that the layout can be handled as a multi-platform artifact rather than a store of single images.
+// Changes are kept in memory until the index is saved.
+type Index struct {
+	path  string
+	index v1.ImageIndex
+}
+
+// NewIndex returns the index of the OCI layout at the given path, or an empty OCI image index if there is no layout at the path.
+func NewIndex(path string) (*Index, error) {
+	if !imageExists(path) {
+		return &Index{path: path, index: mutate.IndexMediaType(empty.Index, types.OCIImageIndex)}, nil
+	}
+	layoutPath, err := FromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load layout from path: %w", err)
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	return &Index{path: path, index: index}, nil
+}
+
+// Path returns the path of the layout.
+func (i *Index) Path() string {
+	return i.path
+}
+
+// UnderlyingIndex returns the index, including the changes that are not saved yet.
+func (i *Index) UnderlyingIndex() v1.ImageIndex {
+	return i.index
+}
+
+// AddImage appends the image to the index as with imgutil.AppendImage.
+func (i *Index) AddImage(img v1.Image, ops ...imgutil.IndexAddOption) error {
+	index, err := imgutil.AppendImage(i.index, img, ops...)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+// AddImageFromPath appends the image of the layout at the given path to the index as with AppendImageToIndex.
+func (i *Index) AddImageFromPath(path string, ops ...imgutil.IndexAddOption) error {
+	index, err := AppendImageToIndex(i.index, path, ops...)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+// Remove removes the descriptors of the manifest with the given digest from the index.
+// The blobs of the manifest are left in the layout until it is pruned (see Path.Prune).
+func (i *Index) Remove(digest v1.Hash) error {
+	index, err := imgutil.RemoveManifest(i.index, digest)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+// Annotate adds the given annotations to the index itself, replacing the values of existing keys.
+func (i *Index) Annotate(annotations map[string]string) error {
+	index, err := imgutil.AnnotateIndex(i.index, annotations)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+// AnnotateManifest adds the given annotations to the descriptor of the manifest with the given digest,
+// replacing the values of existing keys and keeping the order of the descriptors.
+func (i *Index) AnnotateManifest(digest v1.Hash, annotations map[string]string) error {
+	index, err := imgutil.AnnotateManifest(i.index, digest, annotations)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
+// Save writes the index to the layout as its `index.json`, along with the blobs of the manifests added to it,
+// and returns the digest of the index.
+func (i *Index) Save() (v1.Hash, error) {
+	if _, err := Write(i.path, i.index); err != nil {
+		return v1.Hash{}, fmt.Errorf("failed to write layout at path %q: %w", i.path, err)
+	}
+	return i.index.Digest()
+}
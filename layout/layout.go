@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/imgutil"
@@ -14,14 +15,24 @@ var _ imgutil.Image = (*Image)(nil)
 type Image struct {
 	*imgutil.CNBImageCore
 	repoPath          string
+	baseIndex         v1.ImageIndex
 	saveWithoutLayers bool
+	remoteBaseLayers  []v1.Hash // layers of a remote new base, which are not written when the image is saved
 	preserveDigest    bool
+	sboms             []imgutil.SBOM
+	logger            imgutil.Logger
 }
 
 func (i *Image) Kind() string {
 	return "layout"
 }
 
+// UnderlyingIndex returns the index the base image was selected from, which may reference other platforms of the base image
+// or artifacts (such as attestations) attached to it. It returns nil if the base image was not loaded from a path.
+func (i *Image) UnderlyingIndex() v1.ImageIndex {
+	return i.baseIndex
+}
+
 func (i *Image) Name() string {
 	return i.repoPath
 }
@@ -55,11 +66,37 @@ func pathExists(path string) bool {
 	return false
 }
 
+// Rebase replaces the layers up to and including the given base top layer with the layers of the new base image.
+// When the new base image is a remote image, only its manifest and config are fetched:
+// its layers are not downloaded, and are not written to the `blobs` directory when the image is saved (as with a sparse image).
+func (i *Image) Rebase(baseTopLayerDiffID string, withNewBase imgutil.Image) error {
+	return i.RebaseWithOptions(baseTopLayerDiffID, withNewBase)
+}
+
+// RebaseWithOptions rebases the image as Rebase does, adopting the config values of the new base image selected by the options.
+func (i *Image) RebaseWithOptions(baseTopLayerDiffID string, withNewBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	if err := i.CNBImageCore.RebaseWithOptions(baseTopLayerDiffID, withNewBase, ops...); err != nil {
+		return err
+	}
+	i.remoteBaseLayers = nil
+	if withNewBase.Kind() != "remote" {
+		return nil
+	}
+	manifest, err := withNewBase.UnderlyingImage().Manifest()
+	if err != nil {
+		return errors.Wrap(err, "getting manifest of new base image")
+	}
+	for _, layer := range manifest.Layers {
+		i.remoteBaseLayers = append(i.remoteBaseLayers, layer.Digest)
+	}
+	return nil
+}
+
 // Identifier
 // Each image's ID is given by the SHA256 hash of its configuration JSON. It is represented as a hexadecimal encoding of 256 bits,
 // e.g., sha256:a9561eb1b190625c9adb5a9513e72c4dedafc1cb2d4c5236c9a6957ec7dfd5a9.
 func (i *Image) Identifier() (imgutil.Identifier, error) {
-	hash, err := i.Image.Digest()
+	hash, err := i.Digest()
 	if err != nil {
 		return nil, errors.Wrapf(err, "getting identifier for image at path %q", i.repoPath)
 	}
@@ -74,3 +111,41 @@ func (i *Image) Valid() bool {
 func (i *Image) Delete() error {
 	return os.RemoveAll(i.repoPath)
 }
+
+// CheckReadAccess reports whether the layout can be read; an image that doesn't exist is readable.
+// Failures are returned as an imgutil.AccessError.
+func (i *Image) CheckReadAccess() (bool, error) {
+	f, err := os.Open(filepath.Join(i.repoPath, "index.json"))
+	if err == nil {
+		f.Close()
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	return false, layoutAccessError(i.repoPath, false, err)
+}
+
+// CheckWriteAccess reports whether the image can be saved at its path, by creating a file in the layout directory,
+// or in its closest existing parent directory if the layout does not exist yet.
+// Failures are returned as an imgutil.AccessError.
+func (i *Image) CheckWriteAccess() (bool, error) {
+	dir := filepath.Clean(i.repoPath)
+	for !pathExists(dir) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+	}
+	f, err := os.CreateTemp(dir, ".imgutil-write-check-*")
+	if err != nil {
+		return false, layoutAccessError(i.repoPath, true, err)
+	}
+	f.Close()
+	return true, os.Remove(f.Name())
+}
+
+func layoutAccessError(path string, write bool, err error) error {
+	reason := imgutil.AccessUnreachable
+	if os.IsPermission(err) {
+		reason = imgutil.AccessDenied
+	}
+	return imgutil.AccessError{Name: path, Write: write, Reason: reason, Err: err}
+}
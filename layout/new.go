@@ -9,41 +9,59 @@ import (
 )
 
 func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
-	options := &imgutil.ImageOptions{}
-	for _, op := range ops {
-		op(options)
+	options, err := imgutil.NewImageOptions(ops...)
+	if err != nil {
+		return nil, err
 	}
 
 	options.Platform = processPlatformOption(options.Platform)
+	logger := imgutil.GetLogger(*options)
 
-	var err error
-
-	if options.BaseImage == nil && options.BaseImageRepoName != "" { // options.BaseImage supersedes options.BaseImageRepoName
-		options.BaseImage, err = newImageFromPath(options.BaseImageRepoName, options.Platform)
+	var baseIndex v1.ImageIndex
+	if options.BaseImageRepoName != "" {
+		options.BaseImage, baseIndex, err = newImageFromPath(options.BaseImageRepoName, options.PinnedDigest, options.Platform)
 		if err != nil {
 			return nil, err
 		}
+		logResolvedImage(logger, "base", options.BaseImageRepoName, options.BaseImage)
 	}
-	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
-	if options.BaseImage != nil {
-		options.BaseImage, err = newImageFacadeFrom(options.BaseImage, options.MediaTypes)
+	if options.PreviousImageRepoName != "" {
+		options.PreviousImage, _, err = newImageFromPath(options.PreviousImageRepoName, v1.Hash{}, options.Platform)
 		if err != nil {
 			return nil, err
 		}
+		logResolvedImage(logger, "previous", options.PreviousImageRepoName, options.PreviousImage)
+	}
+	if err = options.VerifyImages(); err != nil {
+		return nil, err
+	}
+	if err = options.CheckBasePlatform(); err != nil {
+		return nil, err
+	}
+	if err = options.RecordBaseImage(); err != nil {
+		return nil, err
 	}
+	options.CacheImages()
 
-	if options.PreviousImageRepoName != "" {
-		options.PreviousImage, err = newImageFromPath(options.PreviousImageRepoName, options.Platform)
+	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
+	if options.BaseImage != nil {
+		options.BaseImage, err = newImageFacadeFrom(options.BaseImage, options.MediaTypes)
 		if err != nil {
 			return nil, err
 		}
 	}
+
 	if options.PreviousImage != nil {
 		options.PreviousImage, err = newImageFacadeFrom(options.PreviousImage, options.MediaTypes)
 		if err != nil {
 			return nil, err
 		}
 	}
+	for idx, image := range options.AdditionalPreviousImages {
+		if options.AdditionalPreviousImages[idx], err = newImageFacadeFrom(image, options.MediaTypes); err != nil {
+			return nil, err
+		}
+	}
 
 	cnbImage, err := imgutil.NewCNBImage(*options)
 	if err != nil {
@@ -53,14 +71,81 @@ func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
 	return &Image{
 		CNBImageCore:      cnbImage,
 		repoPath:          path,
+		baseIndex:         baseIndex,
 		saveWithoutLayers: options.WithoutLayers,
 		preserveDigest:    options.PreserveDigest,
+		logger:            logger,
 	}, nil
 }
 
+// CopyDestination makes imgutil.Copy create the copies of images as layouts at the given paths.
+func CopyDestination() imgutil.CopyOption {
+	return imgutil.WithCopyDestination("layout", func(path string, base v1.Image, ops ...imgutil.ImageOption) (imgutil.Image, error) {
+		return NewImage(path, append([]imgutil.ImageOption{FromBaseImageInstance(base)}, ops...)...)
+	})
+}
+
+// NewReadOnlyImage returns the image at the given path for inspection, selected from an index according to the platform option
+// (or the digest option).
+// Unlike NewImage, it reads the config of the image without preparing it to be modified.
+func NewReadOnlyImage(path string, ops ...imgutil.ImageOption) (imgutil.ReadOnlyImage, error) {
+	// the image is read as a base image, so that the digest option selects it from the index with the given name
+	options, err := imgutil.NewImageOptions(append([]imgutil.ImageOption{imgutil.FromBaseImage(path)}, ops...)...)
+	if err != nil {
+		return nil, err
+	}
+	image, _, err := newImageFromPath(path, options.PinnedDigest, processPlatformOption(options.Platform))
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return imgutil.NewReadOnlyImage(path, nil, nil), nil
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config for image at path %q: %w", path, err)
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest for image at path %q: %w", path, err)
+	}
+	layoutPath, _ := imgutil.SplitDigest(path)
+	identifier, err := newLayoutIdentifier(layoutPath, digest)
+	if err != nil {
+		return nil, err
+	}
+	return imgutil.NewReadOnlyImage(path, configFile, identifier), nil
+}
+
+// AppendImageToIndex returns the index with the image at the given path appended as with imgutil.AppendImage,
+// without loading the image into a daemon or a registry first (e.g. for the per-platform images of a multi-platform build).
+// The image is selected from the layout according to the OS and architecture of the platform override
+// (or linux/amd64 without them), as the override may add fields that the layout lacks, or by digest if the path ends with @<digest>.
+func AppendImageToIndex(index v1.ImageIndex, path string, ops ...imgutil.IndexAddOption) (v1.ImageIndex, error) {
+	options := imgutil.GetIndexAddOptions(ops...)
+	platform := imgutil.Platform{OS: options.Platform.OS, Architecture: options.Platform.Architecture}
+	image, _, err := newImageFromPath(path, v1.Hash{}, processPlatformOption(platform))
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, fmt.Errorf("failed to find image at path %q", path)
+	}
+	return imgutil.AppendImage(index, image, ops...)
+}
+
+func logResolvedImage(logger imgutil.Logger, kind, path string, image v1.Image) {
+	if image == nil {
+		logger.Debugf("%s image not found at %q", kind, path)
+		return
+	}
+	if digest, err := image.Digest(); err == nil {
+		logger.Debugf("resolved %s image at %q to %s", kind, path, digest)
+	}
+}
+
 func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform {
-	var emptyPlatform imgutil.Platform
-	if requestedPlatform != emptyPlatform {
+	if !requestedPlatform.IsZero() {
 		return requestedPlatform
 	}
 	return imgutil.Platform{
@@ -69,54 +154,131 @@ func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform
 	}
 }
 
-// newImageFromPath creates a layout image from the given path.
+// newImageFromPath creates a layout image from the given path, and returns it with the index it was selected from.
 // * If an image index for multiple platforms exists, it will try to select the image according to the platform provided.
+// * If the path ends with @<digest> and the path without it is an OCI layout, the manifest with that digest is selected.
+// * Otherwise, if the given pinned digest is not empty, the manifest with that digest is selected.
 // * If the image does not exist, then nothing is returned.
-func newImageFromPath(path string, withPlatform imgutil.Platform) (v1.Image, error) {
+func newImageFromPath(path string, pinnedDigest v1.Hash, withPlatform imgutil.Platform) (v1.Image, v1.ImageIndex, error) {
+	var digest string
 	if !imageExists(path) {
-		return nil, nil
+		path, digest = imgutil.SplitDigest(path)
+		if digest == "" || !imageExists(path) {
+			return nil, nil, nil
+		}
+	}
+	if digest == "" && pinnedDigest != (v1.Hash{}) {
+		digest = pinnedDigest.String()
 	}
 
 	layoutPath, err := FromPath(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load layout from path: %w", err)
+		return nil, nil, fmt.Errorf("failed to load layout from path: %w", err)
 	}
 	index, err := layoutPath.ImageIndex()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load index: %w", err)
+		return nil, nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	var image v1.Image
+	if digest != "" {
+		image, index, err = imageFromIndexByDigest(index, digest, withPlatform)
+	} else {
+		image, err = imageFromIndex(index, withPlatform)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load image from index: %w", err)
+	}
+	return image, index, nil
+}
+
+// imageFromIndexByDigest creates a v1.Image from the manifest with the given digest, which may be referenced by the given index
+// or by any index nested within it. If the digest is that of an index, the image is selected from it according to the given platform.
+// The index the image was selected from is returned with the image.
+func imageFromIndexByDigest(index v1.ImageIndex, digest string, platform imgutil.Platform) (v1.Image, v1.ImageIndex, error) {
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	desc, parent, err := findDescriptor(index, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if desc == nil {
+		return nil, nil, fmt.Errorf("failed to find manifest with digest %s", digest)
+	}
+	if desc.MediaType.IsIndex() {
+		child, err := parent.ImageIndex(hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		image, err := imageFromIndex(child, platform)
+		return image, child, err
 	}
-	image, err := imageFromIndex(index, withPlatform)
+	image, err := parent.Image(hash)
+	return image, parent, err
+}
+
+func findDescriptor(index v1.ImageIndex, hash v1.Hash) (*v1.Descriptor, v1.ImageIndex, error) {
+	indexManifest, err := index.IndexManifest()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load image from index: %w", err)
+		return nil, nil, err
+	}
+	for idx, m := range indexManifest.Manifests {
+		if m.Digest == hash {
+			return &indexManifest.Manifests[idx], index, nil
+		}
+	}
+	for _, m := range indexManifest.Manifests {
+		if !m.MediaType.IsIndex() {
+			continue
+		}
+		child, err := index.ImageIndex(m.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		if desc, parent, err := findDescriptor(child, hash); err != nil || desc != nil {
+			return desc, parent, err
+		}
 	}
-	return image, nil
+	return nil, nil, nil
 }
 
 // imageFromIndex creates a v1.Image from the given Image Index, selecting the image manifest
-// that matches the given OS and architecture.
+// that matches the given platform:
+// * Artifact manifests (e.g. attached SBOMs) are never selected.
+// * If the index contains a single image manifest, that manifest is selected.
+// * Otherwise, the first manifest whose platform satisfies the given platform (see imgutil.Platform.Satisfies) is selected.
+// * If no manifest matches, an error is returned.
 func imageFromIndex(index v1.ImageIndex, platform imgutil.Platform) (v1.Image, error) {
 	manifestList, err := index.IndexManifest()
 	if err != nil {
 		return nil, err
 	}
-	if len(manifestList.Manifests) == 0 {
+	var candidates []v1.Descriptor
+	for _, m := range manifestList.Manifests {
+		if m.ArtifactType == "" && !imgutil.IsSBOMArtifact(m) {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) == 0 {
 		return nil, fmt.Errorf("failed to find manifest at index")
 	}
 
 	// find manifest for platform
-	var manifest v1.Descriptor
-	if len(manifestList.Manifests) == 1 {
-		manifest = manifestList.Manifests[0]
-	} else {
-		for _, m := range manifestList.Manifests {
-			if m.Platform.OS == platform.OS &&
-				m.Platform.Architecture == platform.Architecture {
-				manifest = m
-				break
-			}
+	if len(candidates) == 1 {
+		return index.Image(candidates[0].Digest)
+	}
+	for _, m := range candidates {
+		if matchesPlatform(m, platform) {
+			return index.Image(m.Digest)
 		}
-		return nil, fmt.Errorf("failed to find manifest matching platform %v", platform)
 	}
+	return nil, fmt.Errorf("failed to find manifest matching platform %v", platform)
+}
 
-	return index.Image(manifest.Digest)
+func matchesPlatform(manifest v1.Descriptor, platform imgutil.Platform) bool {
+	if manifest.Platform == nil {
+		return false
+	}
+	return imgutil.PlatformFromV1(manifest.Platform).Satisfies(platform)
 }
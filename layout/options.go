@@ -11,9 +11,7 @@ import (
 // FromBaseImageInstance loads the provided image as the manifest, config, and layers for the working image.
 // If the image is not found, it does nothing.
 func FromBaseImageInstance(image v1.Image) func(*imgutil.ImageOptions) {
-	return func(o *imgutil.ImageOptions) {
-		o.BaseImage = image
-	}
+	return imgutil.FromBaseImageInstance(image)
 }
 
 // WithoutLayersWhenSaved (layout only) if provided will cause the image to be written without layers in the `blobs` directory.
@@ -1,11 +1,39 @@
 package layout
 
 import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 
 	"github.com/buildpacks/imgutil"
 )
 
+// FinalizeDigest applies the changes that saving the image makes and returns its digest (see imgutil.CNBImageCore.FinalizeDigest).
+// When the digest is preserved, the image is saved unchanged.
+func (i *Image) FinalizeDigest() (v1.Hash, error) {
+	if i.preserveDigest {
+		return i.Digest()
+	}
+	return i.CNBImageCore.FinalizeDigest()
+}
+
+// RawConfigJSON returns the exact bytes of the config file that saving the image will write (see imgutil.CNBImageCore.RawConfigJSON).
+// When the digest is preserved, the image is saved unchanged.
+func (i *Image) RawConfigJSON() ([]byte, error) {
+	if i.preserveDigest {
+		return i.RawConfigFile()
+	}
+	return i.CNBImageCore.RawConfigJSON()
+}
+
+// RawManifestJSON returns the exact bytes of the manifest that saving the image will write (see imgutil.CNBImageCore.RawManifestJSON).
+// When the digest is preserved, the image is saved unchanged.
+func (i *Image) RawManifestJSON() ([]byte, error) {
+	if i.preserveDigest {
+		return i.RawManifest()
+	}
+	return i.CNBImageCore.RawManifestJSON()
+}
+
 func (i *Image) Save(additionalNames ...string) error {
 	return i.SaveAs(i.Name(), additionalNames...)
 }
@@ -17,6 +45,15 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 			return err
 		}
 	}
+	if err := i.ScanAddedLayers(); err != nil {
+		return err
+	}
+	if err := i.ValidateLabels(); err != nil {
+		return err
+	}
+	if err := i.CheckSizeLimits(); err != nil {
+		return err
+	}
 
 	refName, err := i.GetAnnotateRefName()
 	if err != nil {
@@ -24,12 +61,18 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 	}
 	ops := []AppendOption{WithAnnotations(ImageRefAnnotation(refName))}
 	if i.saveWithoutLayers {
+		i.logger.Debugf("skipping layer blobs when saving %q", name)
 		ops = append(ops, WithoutLayers())
+	} else if len(i.remoteBaseLayers) > 0 {
+		i.logger.Debugf("skipping the layer blobs of the remote base image when saving %q", name)
+		ops = append(ops, WithoutLayerDigests(i.remoteBaseLayers...))
 	}
 
+	image := i.UnderlyingImage()
 	var (
 		pathsToSave = append([]string{name}, additionalNames...)
 		diagnostics []imgutil.SaveDiagnostic
+		saved       = map[string]string{}
 	)
 	for _, path := range pathsToSave {
 		layoutPath, err := initEmptyIndexAt(path)
@@ -37,14 +80,26 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 			return err
 		}
 		if err = layoutPath.AppendImage(
-			i.Image,
+			image,
 			ops...,
 		); err != nil {
-			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: i.Name(), Cause: err})
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+			continue
+		}
+		if err = layoutPath.appendSBOMs(image, i.sboms); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+			continue
+		}
+		if digest, err := image.Digest(); err == nil {
+			i.logger.Infof("saved image %s to %q", digest, path)
+			saved[path] = digest.String()
 		}
 	}
 	if len(diagnostics) > 0 {
-		return imgutil.SaveError{Errors: diagnostics}
+		return imgutil.SaveError{Errors: diagnostics, Saved: saved}
+	}
+	if digest, err := image.Digest(); err == nil {
+		i.Emit(imgutil.Event{Type: imgutil.EventSave, Names: pathsToSave, Identifier: digest.String()})
 	}
 
 	return nil
@@ -0,0 +1,98 @@
+package layout
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.SBOMStore = (*Image)(nil)
+
+// AttachSBOM records the SBOM; when the image is saved, the SBOM is written to the layout as an artifact manifest
+// whose subject is the saved image.
+func (i *Image) AttachSBOM(mediaType string, content []byte) error {
+	i.sboms = append(i.sboms, imgutil.SBOM{MediaType: mediaType, Content: content})
+	return nil
+}
+
+// SBOMs returns the SBOMs stored in the layout at `Name()` for the working image, followed by the SBOMs attached with AttachSBOM.
+func (i *Image) SBOMs() ([]imgutil.SBOM, error) {
+	var sboms []imgutil.SBOM
+	if imageExists(i.repoPath) {
+		digest, err := i.Digest()
+		if err != nil {
+			return nil, err
+		}
+		saved, err := readSBOMs(i.repoPath, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SBOMs from path %q: %w", i.repoPath, err)
+		}
+		for _, sbom := range saved {
+			if !imgutil.ContainsSBOM(i.sboms, sbom) {
+				sboms = append(sboms, sbom)
+			}
+		}
+	}
+	return append(sboms, i.sboms...), nil
+}
+
+func readSBOMs(path string, subject v1.Hash) ([]imgutil.SBOM, error) {
+	layoutPath, err := FromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	var sboms []imgutil.SBOM
+	for _, desc := range indexManifest.Manifests {
+		if !imgutil.IsSBOMArtifact(desc) {
+			continue
+		}
+		artifact, err := index.Image(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := artifact.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		if manifest.Subject == nil || manifest.Subject.Digest != subject {
+			continue
+		}
+		sbom, err := imgutil.SBOMFromArtifact(artifact)
+		if err != nil {
+			return nil, err
+		}
+		sboms = append(sboms, sbom)
+	}
+	return sboms, nil
+}
+
+func (l Path) appendSBOMs(subject v1.Image, sboms []imgutil.SBOM) error {
+	if len(sboms) == 0 {
+		return nil
+	}
+	subjectDesc, err := partial.Descriptor(subject)
+	if err != nil {
+		return err
+	}
+	for _, sbom := range sboms {
+		artifact, err := imgutil.NewSBOMArtifact(sbom, *subjectDesc)
+		if err != nil {
+			return err
+		}
+		if err = l.AppendImage(artifact, WithAnnotations(map[string]string{imgutil.SBOMAnnotation: "true"})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -14,13 +14,15 @@ import (
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 )
 
 type AppendOption func(*appendOptions)
 
 type appendOptions struct {
-	withoutLayers bool
-	annotations   map[string]string
+	withoutLayers       bool
+	withoutLayerDigests map[v1.Hash]bool
+	annotations         map[string]string
 }
 
 func WithoutLayers() AppendOption {
@@ -29,6 +31,19 @@ func WithoutLayers() AppendOption {
 	}
 }
 
+// WithoutLayerDigests skips writing the layers with the given digests to the `blobs` directory,
+// e.g. because they can be fetched from a registry.
+func WithoutLayerDigests(digests ...v1.Hash) AppendOption {
+	return func(i *appendOptions) {
+		if i.withoutLayerDigests == nil {
+			i.withoutLayerDigests = map[v1.Hash]bool{}
+		}
+		for _, digest := range digests {
+			i.withoutLayerDigests[digest] = true
+		}
+	}
+}
+
 func WithAnnotations(annotations map[string]string) AppendOption {
 	return func(i *appendOptions) {
 		i.annotations = annotations
@@ -53,7 +68,7 @@ func (l Path) AppendImage(img v1.Image, ops ...AppendOption) error {
 	if o.withoutLayers {
 		return l.writeImageWithoutLayers(img, annotations)
 	}
-	return l.appendImage(img, annotations)
+	return l.appendImage(img, annotations, o.withoutLayerDigests)
 }
 
 // writeImageWithoutLayers is the same implementation of ggcr layout writeImage method, removing the writeLayer code
@@ -77,16 +92,22 @@ func (l Path) writeImageWithoutLayers(img v1.Image, annotations map[string]strin
 		return err
 	}
 
+	artifactType, err := partial.ArtifactType(img)
+	if err != nil {
+		return err
+	}
+
 	desc := v1.Descriptor{
-		MediaType:   mt,
-		Size:        int64(len(manifest)),
-		Digest:      d,
-		Annotations: annotations,
+		MediaType:    mt,
+		Size:         int64(len(manifest)),
+		Digest:       d,
+		Annotations:  annotations,
+		ArtifactType: artifactType,
 	}
 	return l.AppendDescriptor(desc)
 }
 
-func (l Path) appendImage(img v1.Image, annotations map[string]string) error {
+func (l Path) appendImage(img v1.Image, annotations map[string]string, withoutDigests map[v1.Hash]bool) error {
 	layers, err := img.Layers()
 	if err != nil {
 		return err
@@ -96,6 +117,12 @@ func (l Path) appendImage(img v1.Image, annotations map[string]string) error {
 	var g errgroup.Group
 	for _, layer := range layers {
 		layer := layer
+		if len(withoutDigests) > 0 {
+			digest, err := layer.Digest()
+			if err == nil && withoutDigests[digest] {
+				continue
+			}
+		}
 		g.Go(func() error {
 			return l.writeLayer(layer)
 		})
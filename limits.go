@@ -0,0 +1,106 @@
+package imgutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SizeLimits are the sizes in bytes of the manifest and config of an image above which saving the image logs a warning
+// or fails with a SizeLimitError, before anything is saved. A limit of zero or less is not checked.
+// For example, many registries reject manifests larger than 4 MiB.
+type SizeLimits struct {
+	WarnManifestSize int
+	MaxManifestSize  int
+	WarnConfigSize   int
+	MaxConfigSize    int
+}
+
+// WithSizeLimits checks the sizes of the manifest and config of the image against the given limits when the image is saved.
+func WithSizeLimits(limits SizeLimits) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.SizeLimits = limits
+	}
+}
+
+// maxOffenders is the number of the largest annotations or labels reported when a limit is exceeded.
+const maxOffenders = 3
+
+// SizeLimitError is returned when saving an image whose manifest or config is larger than its limit (see SizeLimits).
+type SizeLimitError struct {
+	Blob    string // `manifest` or `config`
+	Size    int
+	Limit   int
+	Largest []string // the largest annotations of the manifest or labels of the config
+}
+
+func (e SizeLimitError) Error() string {
+	msg := fmt.Sprintf("%s is %d bytes, larger than the limit of %d bytes", e.Blob, e.Size, e.Limit)
+	if len(e.Largest) > 0 {
+		msg += "; largest: " + strings.Join(e.Largest, ", ")
+	}
+	return msg
+}
+
+// CheckSizeLimits checks the sizes of the manifest and config of the image against the limits provided with WithSizeLimits,
+// logging a warning for the sizes above their warning limit, and returning a SizeLimitError for the sizes above their limit.
+// Images are checked when they are saved.
+func (i *CNBImageCore) CheckSizeLimits() error {
+	limits := i.sizeLimits
+	if limits == (SizeLimits{}) {
+		return nil
+	}
+	manifest, err := i.Manifest()
+	if err != nil {
+		return err
+	}
+	rawManifest, err := i.RawManifest()
+	if err != nil {
+		return err
+	}
+	if err = i.checkSize("manifest", len(rawManifest), limits.WarnManifestSize, limits.MaxManifestSize, "annotation", manifest.Annotations); err != nil {
+		return err
+	}
+	configFile, err := i.ConfigFile()
+	if err != nil {
+		return err
+	}
+	rawConfig, err := i.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	return i.checkSize("config", len(rawConfig), limits.WarnConfigSize, limits.MaxConfigSize, "label", configFile.Config.Labels)
+}
+
+func (i *CNBImageCore) checkSize(blob string, size, warnLimit, maxLimit int, kind string, entries map[string]string) error {
+	switch {
+	case maxLimit > 0 && size > maxLimit:
+		return SizeLimitError{Blob: blob, Size: size, Limit: maxLimit, Largest: largestEntries(kind, entries)}
+	case warnLimit > 0 && size > warnLimit:
+		i.logger.Warnf("%s", SizeLimitError{Blob: blob, Size: size, Limit: warnLimit, Largest: largestEntries(kind, entries)})
+	}
+	return nil
+}
+
+// largestEntries describes the largest of the given annotations or labels, largest first.
+func largestEntries(kind string, entries map[string]string) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	size := func(key string) int { return len(key) + len(entries[key]) }
+	sort.Slice(keys, func(a, b int) bool {
+		if size(keys[a]) != size(keys[b]) {
+			return size(keys[a]) > size(keys[b])
+		}
+		return keys[a] < keys[b]
+	})
+	if len(keys) > maxOffenders {
+		keys = keys[:maxOffenders]
+	}
+	var largest []string
+	for _, key := range keys {
+		largest = append(largest, fmt.Sprintf("%s %q (%d bytes)", kind, key, size(key)))
+	}
+	return largest
+}
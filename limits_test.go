@@ -0,0 +1,72 @@
+package imgutil_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLimits(t *testing.T) {
+	spec.Run(t, "Limits", testLimits, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testLimits(t *testing.T, when spec.G, it spec.S) {
+	when("#CheckSizeLimits", func() {
+		var (
+			logger   *recordingLogger
+			newImage = func(limits imgutil.SizeLimits) *testImage {
+				img := newTestImage(t, imgutil.WithSizeLimits(limits), imgutil.WithLogger(logger))
+				h.AssertNil(t, img.SetLabel("small.label", "some-value"))
+				h.AssertNil(t, img.SetLabel("large.label", strings.Repeat("x", 2048)))
+				h.AssertNil(t, img.SetLabel("medium.label", strings.Repeat("x", 512)))
+				return img
+			}
+		)
+
+		it.Before(func() {
+			logger = &recordingLogger{}
+		})
+
+		it("fails when the config is larger than its limit, reporting the largest labels", func() {
+			img := newImage(imgutil.SizeLimits{MaxConfigSize: 2048})
+
+			err := img.CheckSizeLimits()
+			var limitErr imgutil.SizeLimitError
+			h.AssertEq(t, errors.As(err, &limitErr), true)
+			h.AssertEq(t, limitErr.Blob, "config")
+			h.AssertEq(t, limitErr.Limit, 2048)
+			h.AssertEq(t, limitErr.Largest, []string{
+				`label "large.label" (2059 bytes)`,
+				`label "medium.label" (524 bytes)`,
+				`label "small.label" (21 bytes)`,
+			})
+		})
+
+		it("warns when the config is larger than its warning limit", func() {
+			img := newImage(imgutil.SizeLimits{WarnConfigSize: 2048, MaxConfigSize: 64 * 1024, MaxManifestSize: 64 * 1024})
+
+			h.AssertNil(t, img.CheckSizeLimits())
+			var warnings []string
+			for _, message := range logger.messages {
+				if strings.HasPrefix(message, "WARN ") {
+					warnings = append(warnings, message)
+				}
+			}
+			h.AssertEq(t, len(warnings), 1)
+			h.AssertEq(t, strings.Contains(warnings[0], `larger than the limit of 2048 bytes; largest: label "large.label"`), true)
+		})
+
+		it("doesn't check without limits", func() {
+			img := newImage(imgutil.SizeLimits{})
+
+			h.AssertNil(t, img.CheckSizeLimits())
+			h.AssertEq(t, len(logger.messages), 0)
+		})
+	})
+}
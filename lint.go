@@ -0,0 +1,123 @@
+package imgutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil/mediatypes"
+)
+
+// Lint rules, identifying the problems reported by Lint.
+const (
+	LintMissingPlatform  = "missing-platform"
+	LintHistoryMismatch  = "history-mismatch"
+	LintMixedMediaTypes  = "mixed-media-types"
+	LintDuplicateEnv     = "duplicate-env"
+	LintFutureTimestamps = "future-timestamp"
+)
+
+// futureTolerance is how far in the future timestamps may be before Lint reports them, to allow for clock skew.
+const futureTolerance = 24 * time.Hour
+
+// LintWarning describes a problem found in an image by Lint.
+type LintWarning struct {
+	Rule    string // e.g. LintMissingPlatform
+	Message string
+}
+
+func (w LintWarning) String() string {
+	return w.Rule + ": " + w.Message
+}
+
+// Lint returns warnings for common problems of the given image (e.g. the working image of an imgutil.Image)
+// that registries and runtimes may tolerate, but that usually indicate a mistake:
+// missing platform fields, history that doesn't match the layers, manifests mixing OCI and Docker media types,
+// environment variables set more than once, and timestamps in the future.
+// It can be used as a sanity check before saving an image.
+func Lint(image v1.Image) ([]LintWarning, error) {
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	manifest, err := image.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	var warnings []LintWarning
+	warn := func(rule, format string, v ...interface{}) {
+		warnings = append(warnings, LintWarning{Rule: rule, Message: fmt.Sprintf(format, v...)})
+	}
+
+	if configFile.OS == "" {
+		warn(LintMissingPlatform, "config has no os")
+	}
+	if configFile.Architecture == "" {
+		warn(LintMissingPlatform, "config has no architecture")
+	}
+
+	if len(configFile.History) > 0 {
+		var nonEmpty int
+		for _, history := range configFile.History {
+			if !history.EmptyLayer {
+				nonEmpty++
+			}
+		}
+		if nonEmpty != len(configFile.RootFS.DiffIDs) {
+			warn(LintHistoryMismatch, "history has %d entries for layers, but the image has %d layers", nonEmpty, len(configFile.RootFS.DiffIDs))
+		}
+	}
+
+	if mixed := mixedMediaTypes(manifest); len(mixed) > 0 {
+		warn(LintMixedMediaTypes, "%s manifest references %s", manifest.MediaType, strings.Join(mixed, ", "))
+	}
+
+	seen := map[string]bool{}
+	for _, env := range configFile.Config.Env {
+		key, _, _ := strings.Cut(env, "=")
+		if seen[key] {
+			warn(LintDuplicateEnv, "environment variable %s is set more than once", key)
+		}
+		seen[key] = true
+	}
+
+	future := time.Now().Add(futureTolerance)
+	if configFile.Created.After(future) {
+		warn(LintFutureTimestamps, "image was created in the future (%s)", configFile.Created.UTC().Format(time.RFC3339))
+	}
+	for idx, history := range configFile.History {
+		if history.Created.After(future) {
+			warn(LintFutureTimestamps, "history entry %d was created in the future (%s)", idx, history.Created.UTC().Format(time.RFC3339))
+		}
+	}
+	return warnings, nil
+}
+
+// mixedMediaTypes returns the media types of the config and layers of the given manifest that don't match its own media type
+// (e.g. Docker layers in an OCI manifest).
+func mixedMediaTypes(manifest *v1.Manifest) []string {
+	isOther := mediatypes.IsDocker
+	if mediatypes.IsDocker(manifest.MediaType) {
+		isOther = mediatypes.IsOCI
+	}
+	var mixed []string
+	add := func(mediaType types.MediaType) {
+		if !isOther(mediaType) {
+			return
+		}
+		for _, m := range mixed {
+			if m == string(mediaType) {
+				return
+			}
+		}
+		mixed = append(mixed, string(mediaType))
+	}
+	add(manifest.Config.MediaType)
+	for _, layer := range manifest.Layers {
+		add(layer.MediaType)
+	}
+	return mixed
+}
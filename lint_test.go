@@ -0,0 +1,68 @@
+package imgutil_test
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLint(t *testing.T) {
+	spec.Run(t, "Lint", testLint, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testLint(t *testing.T, when spec.G, it spec.S) {
+	it("doesn't warn about images created by imgutil", func() {
+		img := newTestImage(t, imgutil.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "amd64"}))
+		addLayerWithFiles(t, img, map[string]string{"some-file": "some-content"})
+		h.AssertNil(t, img.SetEnv("SOME_KEY", "some-value"))
+		h.AssertNil(t, img.SetCreatedAtAndHistory())
+
+		warnings, err := imgutil.Lint(img)
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(warnings), 0)
+	})
+
+	it("warns about common problems", func() {
+		dockerLayer, err := random.Layer(64, types.DockerLayer)
+		h.AssertNil(t, err)
+		image, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{Layer: dockerLayer, MediaType: types.DockerLayer})
+		h.AssertNil(t, err)
+		configFile, err := image.ConfigFile()
+		h.AssertNil(t, err)
+		future := v1.Time{Time: time.Now().Add(30 * 24 * time.Hour)}
+		configFile.Created = future
+		configFile.Config.Env = []string{"SOME_KEY=some-value", "OTHER_KEY=other-value", "SOME_KEY=other-value"}
+		configFile.History = []v1.History{{}, {Created: future}}
+		image, err = mutate.ConfigFile(image, configFile)
+		h.AssertNil(t, err)
+		image = mutate.ConfigMediaType(image, types.OCIConfigJSON)
+
+		warnings, err := imgutil.Lint(image)
+		h.AssertNil(t, err)
+		var rules []string
+		for _, warning := range warnings {
+			rules = append(rules, warning.Rule)
+		}
+		h.AssertEq(t, rules, []string{
+			imgutil.LintMissingPlatform,
+			imgutil.LintMissingPlatform,
+			imgutil.LintHistoryMismatch,
+			imgutil.LintMixedMediaTypes,
+			imgutil.LintDuplicateEnv,
+			imgutil.LintFutureTimestamps,
+			imgutil.LintFutureTimestamps,
+		})
+		h.AssertEq(t, warnings[3].String(), "mixed-media-types: application/vnd.oci.image.manifest.v1+json manifest references application/vnd.docker.image.rootfs.diff.tar.gzip")
+		h.AssertEq(t, warnings[4].Message, "environment variable SOME_KEY is set more than once")
+	})
+}
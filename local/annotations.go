@@ -0,0 +1,86 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.AnnotatedImage = (*Image)(nil)
+
+// AnnotationsLabel holds the annotations of the manifest of a local image, as the daemon doesn't store manifests.
+const AnnotationsLabel = "io.buildpacks.imgutil.annotations"
+
+// Annotations returns the annotations of the manifest, including the ones recorded in the AnnotationsLabel label
+// when the image was saved. The label inherited from the base image is removed when the image is created,
+// so use NewReadOnlyImage to read the annotations of an image in the daemon.
+func (i *Image) Annotations() (map[string]string, error) {
+	annotations, err := i.CNBImageCore.Annotations()
+	if err != nil {
+		return nil, err
+	}
+	value, err := i.Label(AnnotationsLabel)
+	if err != nil || value == "" {
+		return annotations, err
+	}
+	recorded, err := parseAnnotationsLabel(value)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range recorded {
+		if _, ok := annotations[k]; !ok {
+			annotations[k] = v
+		}
+	}
+	return annotations, nil
+}
+
+// recordAnnotations records the annotations of the manifest in the AnnotationsLabel label, so that they are saved.
+// The label is recorded from the manifest only, so that the base image annotations describe the current base image.
+// The label is written to the config directly rather than with SetLabel: it isn't set by the caller,
+// so it isn't validated and no event is emitted for it.
+func (i *Image) recordAnnotations() error {
+	annotations, err := i.CNBImageCore.Annotations()
+	if err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return i.resetAnnotationsLabel()
+	}
+	value, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+		if c.Config.Labels == nil {
+			c.Config.Labels = make(map[string]string)
+		}
+		c.Config.Labels[AnnotationsLabel] = string(value)
+	})
+}
+
+// resetAnnotationsLabel removes the AnnotationsLabel label inherited from the base image (or adopted from a new base image),
+// so that the label only records the annotations of this image when it is saved.
+func (i *Image) resetAnnotationsLabel() error {
+	value, err := i.Label(AnnotationsLabel)
+	if err != nil || value == "" {
+		return err
+	}
+	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+		delete(c.Config.Labels, AnnotationsLabel)
+	})
+}
+
+func parseAnnotationsLabel(value string) (map[string]string, error) {
+	annotations := map[string]string{}
+	if value == "" {
+		return annotations, nil
+	}
+	if err := json.Unmarshal([]byte(value), &annotations); err != nil {
+		return nil, fmt.Errorf("failed to parse label %q: %w", AnnotationsLabel, err)
+	}
+	return annotations, nil
+}
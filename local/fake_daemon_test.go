@@ -0,0 +1,478 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/local"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestFakeDaemon(t *testing.T) {
+	spec.Run(t, "FakeDaemon", testFakeDaemon, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testFakeDaemon(t *testing.T, when spec.G, it spec.S) {
+	var (
+		daemon       *h.FakeDaemon
+		dockerClient *client.Client
+		tmpDir       string
+	)
+
+	it.Before(func() {
+		var err error
+		daemon = h.NewFakeDaemon()
+		dockerClient, err = daemon.Client()
+		h.AssertNil(t, err)
+		tmpDir, err = os.MkdirTemp("", "local-fake-daemon")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		daemon.Close()
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	it("saves, reads, reuses, and deletes images", func() {
+		img, err := local.NewImage("some-image", dockerClient)
+		h.AssertNil(t, err)
+		h.AssertEq(t, img.Found(), false)
+		layerPath, diffID, contents := h.RandomLayer(t, tmpDir)
+		h.AssertNil(t, img.AddLayer(layerPath))
+		h.AssertNil(t, img.SetLabel("some-key", "some-value"))
+		h.AssertNil(t, img.Save("some-image:other-tag"))
+
+		inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), "some-image")
+		h.AssertNil(t, err)
+		h.AssertEq(t, inspect.Config.Labels["some-key"], "some-value")
+		h.AssertEq(t, inspect.RootFS.Layers, []string{diffID})
+		h.AssertEq(t, inspect.RepoTags, []string{"some-image:latest", "some-image:other-tag"})
+
+		// rebuild from the saved image
+		next, err := local.NewImage("next-image", dockerClient, local.WithPreviousImage("some-image"))
+		h.AssertNil(t, err)
+		h.AssertNil(t, next.ReuseLayer(diffID))
+		h.AssertNil(t, next.Save())
+		rc, err := next.GetLayer(diffID)
+		h.AssertNil(t, err)
+		actual, err := io.ReadAll(rc)
+		h.AssertNil(t, err)
+		h.AssertNil(t, rc.Close())
+		h.AssertEq(t, actual, contents)
+
+		saved, err := local.NewImage("next-image", dockerClient, local.FromBaseImage("next-image"))
+		h.AssertNil(t, err)
+		h.AssertEq(t, saved.Found(), true)
+		topLayer, err := saved.TopLayer()
+		h.AssertNil(t, err)
+		h.AssertEq(t, topLayer, diffID)
+
+		h.AssertNil(t, saved.Delete())
+		_, _, err = dockerClient.ImageInspectWithRaw(context.TODO(), "next-image")
+		h.AssertEq(t, client.IsErrNotFound(err), true)
+	})
+
+	when("#SetAnnotations", func() {
+		it("records the annotations in a label", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetAnnotations(map[string]string{"some-key": "some-value"}))
+			h.AssertNil(t, img.Save())
+
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), "some-image")
+			h.AssertNil(t, err)
+			h.AssertEq(t, inspect.Config.Labels[local.AnnotationsLabel], `{"some-key":"some-value"}`)
+
+			saved, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			annotations, err := saved.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations, map[string]string{"some-key": "some-value"})
+		})
+
+		it("does not inherit the annotations of the base image", func() {
+			base, err := local.NewImage("some-base-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, base.SetAnnotations(map[string]string{"org.opencontainers.image.ref.name": "some-ref"}))
+			h.AssertNil(t, base.Save())
+
+			img, err := local.NewImage("some-image", dockerClient, local.FromBaseImage("some-base-image"))
+			h.AssertNil(t, err)
+			label, err := img.Label(local.AnnotationsLabel)
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "")
+			h.AssertNil(t, img.SetAnnotations(map[string]string{"some-key": "some-value"}))
+			h.AssertNil(t, img.Save())
+
+			saved, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			annotations, err := saved.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations, map[string]string{"some-key": "some-value"})
+		})
+
+		it("drops the annotations of the previous base image on rebase", func() {
+			oldBase, err := local.NewImage("some-old-base-image", dockerClient)
+			h.AssertNil(t, err)
+			oldBaseLayerPath, oldBaseDiffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, oldBase.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, oldBase.Save())
+			h.AssertEq(t, daemon.SetRepoDigests("some-old-base-image", "some-old-base-image@sha256:"+strings.Repeat("a", 64)), true)
+
+			// a new base image carrying the annotations of another image
+			newBase, err := local.NewImage("some-new-base-image", dockerClient)
+			h.AssertNil(t, err)
+			newBaseLayerPath, _, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, newBase.AddLayer(newBaseLayerPath))
+			h.AssertNil(t, newBase.SetLabel(local.AnnotationsLabel, `{"new-base-key":"new-base-value"}`))
+
+			img, err := local.NewImage("some-image", dockerClient, local.FromBaseImage("some-old-base-image"), imgutil.WithBaseImageAnnotations())
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetAnnotations(map[string]string{"some-key": "some-value"}))
+			h.AssertNil(t, img.Save())
+			label, err := img.Label(local.AnnotationsLabel)
+			h.AssertNil(t, err)
+			h.AssertEq(t, strings.Contains(label, imgutil.BaseDigestAnnotation), true)
+
+			h.AssertNil(t, img.RebaseWithOptions(oldBaseDiffID, newBase, imgutil.AdoptBaseLabels(imgutil.PreferNewBaseValues)))
+			h.AssertNil(t, img.Save())
+
+			saved, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			annotations, err := saved.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations, map[string]string{"some-key": "some-value"})
+		})
+
+		it("records the label without validating it or emitting an event", func() {
+			var events []imgutil.Event
+			img, err := local.NewImage("some-image", dockerClient,
+				imgutil.WithLabelValidator(func(key, _ string) error {
+					if key == local.AnnotationsLabel {
+						return errors.New("unexpected label")
+					}
+					return nil
+				}),
+				imgutil.WithEventHandler(func(event imgutil.Event) { events = append(events, event) }),
+			)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetAnnotations(map[string]string{"some-key": "some-value"}))
+			h.AssertNil(t, img.Save())
+
+			for _, event := range events {
+				h.AssertEq(t, event.Type != imgutil.EventSetLabel, true)
+			}
+		})
+
+		it("leaves the image unchanged when it fails validation", func() {
+			base, err := local.NewImage("some-base-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, base.SetLabel("some-label", "invalid"))
+			h.AssertNil(t, base.Save())
+
+			img, err := local.NewImage("some-image", dockerClient,
+				local.FromBaseImage("some-base-image"),
+				imgutil.WithLabelValidator(func(_, value string) error {
+					if value == "invalid" {
+						return errors.New("invalid value")
+					}
+					return nil
+				}),
+			)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetAnnotations(map[string]string{"some-key": "some-value"}))
+			h.AssertError(t, img.Save(), "invalid value")
+
+			label, err := img.Label(local.AnnotationsLabel)
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "")
+		})
+	})
+
+	when("#WithBaseImageAnnotations", func() {
+		var baseDigest = "sha256:" + strings.Repeat("a", 64)
+
+		it.Before(func() {
+			base, err := local.NewImage("some-base-image", dockerClient)
+			h.AssertNil(t, err)
+			layerPath, _, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, base.AddLayer(layerPath))
+			h.AssertNil(t, base.Save())
+		})
+
+		it("annotates the image with the name and repo digest of the base image", func() {
+			h.AssertEq(t, daemon.SetRepoDigests("some-base-image", "some-other-image@sha256:"+strings.Repeat("b", 64), "some-base-image@"+baseDigest), true)
+
+			img, err := local.NewImage("some-image", dockerClient, local.FromBaseImage("some-base-image"), imgutil.WithBaseImageAnnotations())
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			saved, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			annotations, err := saved.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations[imgutil.BaseNameAnnotation], "some-base-image")
+			h.AssertEq(t, annotations[imgutil.BaseDigestAnnotation], baseDigest)
+		})
+
+		it("annotates the image with its own base image rather than the base image of its base image", func() {
+			h.AssertEq(t, daemon.SetRepoDigests("some-base-image", "some-base-image@"+baseDigest), true)
+			parent, err := local.NewImage("some-parent-image", dockerClient, local.FromBaseImage("some-base-image"), imgutil.WithBaseImageAnnotations())
+			h.AssertNil(t, err)
+			h.AssertNil(t, parent.Save())
+
+			child, err := local.NewImage("some-child-image", dockerClient, local.FromBaseImage("some-parent-image"), imgutil.WithBaseImageAnnotations())
+			h.AssertNil(t, err)
+			h.AssertNil(t, child.Save())
+
+			saved, err := local.NewReadOnlyImage("some-child-image", dockerClient)
+			h.AssertNil(t, err)
+			annotations, err := saved.Annotations()
+			h.AssertNil(t, err)
+			_, ok := annotations[imgutil.BaseDigestAnnotation]
+			h.AssertEq(t, ok, false)
+
+			parentDigest := "sha256:" + strings.Repeat("c", 64)
+			h.AssertEq(t, daemon.SetRepoDigests("some-parent-image", "some-parent-image@"+parentDigest), true)
+			child, err = local.NewImage("some-child-image", dockerClient, local.FromBaseImage("some-parent-image"), imgutil.WithBaseImageAnnotations())
+			h.AssertNil(t, err)
+			h.AssertNil(t, child.Save())
+
+			saved, err = local.NewReadOnlyImage("some-child-image", dockerClient)
+			h.AssertNil(t, err)
+			annotations, err = saved.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations[imgutil.BaseNameAnnotation], "some-parent-image")
+			h.AssertEq(t, annotations[imgutil.BaseDigestAnnotation], parentDigest)
+		})
+
+		it("does not annotate the image when the base image has no repo digests", func() {
+			img, err := local.NewImage("some-image", dockerClient, local.FromBaseImage("some-base-image"), imgutil.WithBaseImageAnnotations())
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			annotations, err := img.Annotations()
+			h.AssertNil(t, err)
+			_, ok := annotations[imgutil.BaseDigestAnnotation]
+			h.AssertEq(t, ok, false)
+		})
+	})
+
+	when("#SetUser and #SetArgsEscaped", func() {
+		it("round-trips them through the daemon for windows images", func() {
+			daemon.OS = "windows"
+			daemon.Architecture = "amd64"
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetUser("S-1-5-93-2-1"))
+			h.AssertNil(t, img.SetArgsEscaped(true))
+			h.AssertNil(t, img.Save())
+
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), "some-image")
+			h.AssertNil(t, err)
+			h.AssertEq(t, inspect.Os, "windows")
+			h.AssertEq(t, inspect.Config.User, "S-1-5-93-2-1")
+			h.AssertEq(t, inspect.Config.ArgsEscaped, true)
+
+			saved, err := local.NewImage("some-image", dockerClient, local.FromBaseImage("some-image"))
+			h.AssertNil(t, err)
+			user, err := saved.User()
+			h.AssertNil(t, err)
+			h.AssertEq(t, user, "S-1-5-93-2-1")
+			argsEscaped, err := saved.ArgsEscaped()
+			h.AssertNil(t, err)
+			h.AssertEq(t, argsEscaped, true)
+		})
+	})
+
+	when("#SaveFileAs", func() {
+		it("saves an archive loaded under each name", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			layerPath, diffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			path, imageID, err := img.SaveFileAs("some-image", "other-image:some-tag")
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			f, err := os.Open(path)
+			h.AssertNil(t, err)
+			defer f.Close()
+			_, err = dockerClient.ImageLoad(context.TODO(), f, true)
+			h.AssertNil(t, err)
+
+			for _, name := range []string{"some-image", "other-image:some-tag"} {
+				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), name)
+				h.AssertNil(t, err)
+				h.AssertEq(t, inspect.ID, imageID)
+				h.AssertEq(t, inspect.RootFS.Layers, []string{diffID})
+			}
+		})
+	})
+	when("#CheckReadAccess and #CheckWriteAccess", func() {
+		it("succeed when the daemon is available", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+
+			canRead, err := img.CheckReadAccess()
+			h.AssertNil(t, err)
+			h.AssertEq(t, canRead, true)
+			canWrite, err := img.CheckWriteAccess()
+			h.AssertNil(t, err)
+			h.AssertEq(t, canWrite, true)
+		})
+
+		it("return an unreachable error when the daemon is not available", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			daemon.Close()
+
+			canWrite, err := img.CheckWriteAccess()
+			h.AssertEq(t, canWrite, false)
+			var accessErr imgutil.AccessError
+			h.AssertEq(t, errors.As(err, &accessErr), true)
+			h.AssertEq(t, accessErr.Reason, imgutil.AccessUnreachable)
+			h.AssertEq(t, accessErr.Write, true)
+		})
+	})
+	when("#NewReadOnlyImage", func() {
+		it("inspects an image in the daemon", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			layerPath, diffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.SetLabel("some-key", "some-value"))
+			h.AssertNil(t, img.Save())
+
+			readOnly, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertEq(t, readOnly.Found(), true)
+			label, err := readOnly.Label("some-key")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "some-value")
+			topLayer, err := readOnly.TopLayer()
+			h.AssertNil(t, err)
+			h.AssertEq(t, topLayer, diffID)
+			identifier, err := readOnly.Identifier()
+			h.AssertNil(t, err)
+			expected, err := img.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, identifier.String(), expected.String())
+		})
+
+		it("reports an image that is not in the daemon", func() {
+			readOnly, err := local.NewReadOnlyImage("missing-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertEq(t, readOnly.Found(), false)
+			_, err = readOnly.Labels()
+			h.AssertError(t, err, `image "missing-image" not found`)
+		})
+
+		it("reads the layers of an image in the daemon", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			layerPath, diffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			readOnly, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			rc, err := readOnly.GetLayer(diffID)
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			expected, err := os.ReadFile(layerPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, contents, expected)
+
+			_, err = readOnly.GetLayer("sha256:" + strings.Repeat("0", 64))
+			h.AssertError(t, err, "failed to find layer with diff ID")
+		})
+
+		it("does not read the layers of an image that is not in the daemon", func() {
+			readOnly, err := local.NewReadOnlyImage("missing-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertEq(t, readOnly.Found(), false)
+			h.AssertEq(t, readOnly.UnderlyingImage() == nil, true)
+			_, err = readOnly.GetLayer("sha256:" + strings.Repeat("0", 64))
+			h.AssertError(t, err, `image "missing-image" not found`)
+		})
+	})
+
+	when("#NewROImage", func() {
+		it("reads the layers of an image in the daemon", func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			layerPath, diffID, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			readOnly, err := local.NewROImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertEq(t, readOnly.Found(), true)
+			rc, err := readOnly.GetLayer(diffID)
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			expected, err := os.ReadFile(layerPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, contents, expected)
+		})
+	})
+
+	when("#Identifier", func() {
+		var repoDigest string
+
+		it.Before(func() {
+			img, err := local.NewImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+			repoDigest = "registry.example.com/some-image@sha256:" + strings.Repeat("a", 64)
+			h.AssertEq(t, daemon.SetRepoDigests("some-image", repoDigest), true)
+		})
+
+		it("exposes the image ID and the repo digests", func() {
+			readOnly, err := local.NewReadOnlyImage("some-image", dockerClient)
+			h.AssertNil(t, err)
+			identifier, err := readOnly.Identifier()
+			h.AssertNil(t, err)
+			idIdentifier, ok := identifier.(local.IDIdentifier)
+			h.AssertEq(t, ok, true)
+
+			id, err := idIdentifier.ID()
+			h.AssertNil(t, err)
+			h.AssertEq(t, id.Hex, identifier.String())
+			digests, err := idIdentifier.Digests()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(digests), 1)
+			h.AssertEq(t, digests[0].String(), repoDigest)
+		})
+
+		it("keeps the repo digests of the base image until the image is changed", func() {
+			img, err := local.NewImage("other-image", dockerClient, local.FromBaseImage("some-image"))
+			h.AssertNil(t, err)
+			identifier, err := img.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, identifier.(local.IDIdentifier).RepoDigests, []string{repoDigest})
+
+			layerPath, _, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+			identifier, err = img.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(identifier.(local.IDIdentifier).RepoDigests), 0)
+		})
+	})
+}
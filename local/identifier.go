@@ -1,9 +1,35 @@
 package local
 
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// IDIdentifier identifies an image in the daemon by its image ID,
+// along with the repo digests the daemon knows for it (e.g. after the image was pulled or pushed).
 type IDIdentifier struct {
-	ImageID string
+	ImageID     string
+	RepoDigests []string
 }
 
 func (i IDIdentifier) String() string {
 	return i.ImageID
 }
+
+// ID returns the image ID as a hash.
+func (i IDIdentifier) ID() (v1.Hash, error) {
+	return v1.NewHash("sha256:" + i.ImageID)
+}
+
+// Digests returns the repo digests of the image as references.
+func (i IDIdentifier) Digests() ([]name.Digest, error) {
+	digests := make([]name.Digest, 0, len(i.RepoDigests))
+	for _, repoDigest := range i.RepoDigests {
+		digest, err := name.NewDigest(repoDigest, name.WeakValidation)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+	return digests, nil
+}
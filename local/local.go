@@ -9,19 +9,28 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
 
 	"github.com/buildpacks/imgutil"
 )
 
+var _ imgutil.OptionsRebaser = (*Image)(nil)
+
 // Image wraps an imgutil.CNBImageCore and implements the methods needed to complete the imgutil.Image interface.
 type Image struct {
 	*imgutil.CNBImageCore
-	repoName       string
-	store          *Store
-	lastIdentifier string
-	daemonOS       string
+	repoName        string
+	store           *Store
+	blobCache       cache.Cache
+	lastIdentifier  string
+	lastRepoDigests []string
+	daemonOS        string
+	saveTimeout     time.Duration
+	logger          imgutil.Logger
+	instrumentation imgutil.Instrumentation
 }
 
 func (i *Image) Kind() string {
@@ -42,7 +51,8 @@ func (i *Image) Found() bool {
 
 func (i *Image) Identifier() (imgutil.Identifier, error) {
 	return IDIdentifier{
-		ImageID: strings.TrimPrefix(i.lastIdentifier, "sha256:"),
+		ImageID:     strings.TrimPrefix(i.lastIdentifier, "sha256:"),
+		RepoDigests: i.lastRepoDigests,
 	}, nil
 }
 
@@ -65,6 +75,11 @@ func (i *Image) GetLayer(diffID string) (io.ReadCloser, error) {
 	if !contains(configFile.RootFS.DiffIDs, layerHash) {
 		return nil, imgutil.ErrLayerNotFound{DiffID: layerHash.String()}
 	}
+	if cached, err := imgutil.CachedLayer(i.blobCache, layerHash); err == nil {
+		// this avoids downloading ALL the image layers from the daemon
+		// if the layer was extracted before (e.g., by a previous build).
+		return cached.Uncompressed()
+	}
 	layer, err := i.LayerByDiffID(layerHash)
 	if err == nil {
 		// this avoids downloading ALL the image layers from the daemon
@@ -77,6 +92,7 @@ func (i *Image) GetLayer(diffID string) (io.ReadCloser, error) {
 	if err = i.ensureLayers(); err != nil {
 		return nil, err
 	}
+	// the layers of the base image are added to the blob cache as they are read (see imgutil.ImageOptions.CacheImages)
 	layer, err = i.LayerByDiffID(layerHash)
 	if err != nil {
 		return nil, err
@@ -93,6 +109,26 @@ func contains(diffIDs []v1.Hash, hash v1.Hash) bool {
 	return false
 }
 
+// ReadFile returns the content of the file at the given absolute path in the image filesystem.
+// Layers are read with GetLayer, so they are downloaded from the daemon if needed.
+func (i *Image) ReadFile(path string) ([]byte, error) {
+	configFile, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return imgutil.NewFileWalker(configFile, i.GetLayer).ReadFile(path)
+}
+
+// WalkFiles calls fn for each entry of the image filesystem.
+// Layers are read with GetLayer, so they are downloaded from the daemon if needed.
+func (i *Image) WalkFiles(fn imgutil.WalkFunc) error {
+	configFile, err := i.ConfigFile()
+	if err != nil {
+		return err
+	}
+	return imgutil.NewFileWalker(configFile, i.GetLayer).Walk(fn)
+}
+
 func (i *Image) ensureLayers() error {
 	if err := i.store.downloadLayersFor(i.lastIdentifier); err != nil {
 		return fmt.Errorf("failed to fetch base layers: %w", err)
@@ -118,7 +154,7 @@ func (i *Image) AddLayer(path string) error {
 	if err != nil {
 		return err
 	}
-	return i.AddLayerWithHistory(layer, emptyHistory)
+	return i.AddNamedLayerWithHistory(layer, imgutil.LayerName(path), emptyHistory)
 }
 
 func calculateChecksum(path string) (string, error) {
@@ -135,19 +171,18 @@ func calculateChecksum(path string) (string, error) {
 }
 
 func (i *Image) AddLayerWithDiffID(path, diffID string) error {
-	layer, err := i.addLayerToStore(path, diffID)
-	if err != nil {
-		return err
-	}
-	return i.AddLayerWithHistory(layer, emptyHistory)
+	return i.AddLayerWithDiffIDAndHistory(path, diffID, emptyHistory)
 }
 
 func (i *Image) AddLayerWithDiffIDAndHistory(path, diffID string, history v1.History) error {
+	if reused, err := i.ReuseLayerIfAutomatic(diffID, history); err != nil || reused {
+		return err
+	}
 	layer, err := i.addLayerToStore(path, diffID)
 	if err != nil {
 		return err
 	}
-	return i.AddLayerWithHistory(layer, history)
+	return i.AddNamedLayerWithHistory(layer, imgutil.LayerName(path), history)
 }
 
 func (i *Image) addLayerToStore(fromPath, withDiffID string) (v1.Layer, error) {
@@ -183,34 +218,94 @@ func (i *Image) AddOrReuseLayerWithHistory(path string, diffID string, history v
 }
 
 func (i *Image) Rebase(baseTopLayerDiffID string, withNewBase imgutil.Image) error {
+	return i.RebaseWithOptions(baseTopLayerDiffID, withNewBase)
+}
+
+func (i *Image) RebaseWithOptions(baseTopLayerDiffID string, withNewBase imgutil.Image, ops ...imgutil.RebaseOption) error {
 	if err := i.ensureLayers(); err != nil {
 		return err
 	}
-	return i.CNBImageCore.Rebase(baseTopLayerDiffID, withNewBase)
+	if err := i.CNBImageCore.RebaseWithOptions(baseTopLayerDiffID, withNewBase, ops...); err != nil {
+		return err
+	}
+	// the annotations describing the previous base image are removed from the manifest, and recorded again on save
+	return i.resetAnnotationsLabel()
 }
 
 func (i *Image) Save(additionalNames ...string) error {
-	err := i.SetCreatedAtAndHistory()
+	return i.SaveAs(i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveAs(name string, additionalNames ...string) error {
+	if err := i.prepareSave(); err != nil {
+		return err
+	}
+	identifier, err := i.store.Save(i, name, additionalNames...)
 	if err != nil {
 		return err
 	}
-	i.lastIdentifier, err = i.store.Save(i, i.Name(), additionalNames...)
-	return err
+	i.setLastIdentifier(identifier)
+	i.logger.Infof("saved image %q with ID %s", name, i.lastIdentifier)
+	i.Emit(imgutil.Event{Type: imgutil.EventSave, Names: append([]string{name}, additionalNames...), Identifier: i.lastIdentifier})
+	return nil
 }
 
-func (i *Image) SaveAs(name string, additionalNames ...string) error {
-	err := i.SetCreatedAtAndHistory()
-	if err != nil {
+// prepareSave validates the image, and then records its annotations in the AnnotationsLabel label,
+// so that an image that fails validation is left unchanged.
+func (i *Image) prepareSave() error {
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return err
+	}
+	if err := i.ScanAddedLayers(); err != nil {
 		return err
 	}
-	i.lastIdentifier, err = i.store.Save(i, name, additionalNames...)
-	return err
+	if err := i.ValidateLabels(); err != nil {
+		return err
+	}
+	if err := i.CheckSizeLimits(); err != nil {
+		return err
+	}
+	return i.recordAnnotations()
+}
+
+// setLastIdentifier records the ID of the saved image.
+// The repo digests of the image are kept only if saving didn't change its ID.
+func (i *Image) setLastIdentifier(identifier string) {
+	if identifier != i.lastIdentifier {
+		i.lastRepoDigests = nil
+	}
+	i.lastIdentifier = identifier
 }
 
 func (i *Image) SaveFile() (string, error) {
 	return i.store.SaveFile(i, i.Name())
 }
 
+// SaveFileAs saves the image as a docker archive that loads it under each of the given names,
+// and returns the filesystem location of the archive and the ID of the image.
+func (i *Image) SaveFileAs(names ...string) (string, string, error) {
+	return i.store.SaveFileAs(i, names...)
+}
+
 func (i *Image) Delete() error {
 	return i.store.Delete(i.lastIdentifier)
 }
+
+// CheckReadAccess reports whether the daemon can be reached to read the image;
+// the daemon has no credentials, so an image that can be reached is readable.
+// Failures are returned as an imgutil.AccessError.
+func (i *Image) CheckReadAccess() (bool, error) {
+	if err := i.store.Ping(); err != nil {
+		return false, imgutil.AccessError{Name: i.repoName, Reason: imgutil.AccessUnreachable, Err: err}
+	}
+	return true, nil
+}
+
+// CheckWriteAccess reports whether the daemon can be reached to load the image.
+// Failures are returned as an imgutil.AccessError.
+func (i *Image) CheckWriteAccess() (bool, error) {
+	if err := i.store.Ping(); err != nil {
+		return false, imgutil.AccessError{Name: i.repoName, Write: true, Reason: imgutil.AccessUnreachable, Err: err}
+	}
+	return true, nil
+}
@@ -3,10 +3,12 @@ package local
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 
 	"github.com/buildpacks/imgutil"
@@ -15,12 +17,13 @@ import (
 // NewImage returns a new image that can be modified and saved to a docker daemon
 // via a tarball in legacy format.
 func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOption) (*Image, error) {
-	options := &imgutil.ImageOptions{}
-	for _, op := range ops {
-		op(options)
+	options, err := imgutil.NewImageOptions(ops...)
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
+	logger := imgutil.GetLogger(*options)
+
 	options.Platform, err = processPlatformOption(options.Platform, dockerClient)
 	if err != nil {
 		return nil, err
@@ -32,11 +35,13 @@ func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOp
 	}
 	if previousImage.image != nil {
 		options.PreviousImage = previousImage.image
+		logger.Debugf("resolved previous image %q to %s", options.PreviousImageRepoName, previousImage.identifier)
 	}
 
 	var (
-		baseIdentifier string
-		store          *Store
+		baseIdentifier  string
+		baseRepoDigests []string
+		store           *Store
 	)
 	baseImage, err := processImageOption(options.BaseImageRepoName, dockerClient, false)
 	if err != nil {
@@ -45,25 +50,86 @@ func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOp
 	if baseImage.image != nil {
 		options.BaseImage = baseImage.image
 		baseIdentifier = baseImage.identifier
+		baseRepoDigests = baseImage.repoDigests
 		store = baseImage.layerStore
+		logger.Debugf("resolved base image %q to %s", options.BaseImageRepoName, baseIdentifier)
 	} else {
 		store = NewStore(dockerClient)
 	}
 
+	if err = options.VerifyImages(); err != nil {
+		return nil, err
+	}
+	if err = options.CheckBasePlatform(); err != nil {
+		return nil, err
+	}
+	if baseImage.image != nil {
+		// the manifest of an image in the daemon is only known from its repo digests
+		options.RecordBaseImageDigest(repoDigest(options.BaseImageRepoName, baseRepoDigests))
+	} else if err = options.RecordBaseImage(); err != nil {
+		return nil, err
+	}
+	options.CacheImages()
+
 	cnbImage, err := imgutil.NewCNBImage(*options)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Image{
-		CNBImageCore:   cnbImage,
-		repoName:       repoName,
-		store:          store,
-		lastIdentifier: baseIdentifier,
-		daemonOS:       options.Platform.OS,
+	image := &Image{
+		CNBImageCore:    cnbImage,
+		repoName:        repoName,
+		store:           store,
+		blobCache:       options.BlobCache,
+		lastIdentifier:  baseIdentifier,
+		lastRepoDigests: baseRepoDigests,
+		daemonOS:        options.Platform.OS,
+		saveTimeout:     options.SaveTimeout,
+		logger:          logger,
+		instrumentation: imgutil.GetInstrumentation(*options),
+	}
+	if err = image.resetAnnotationsLabel(); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// CopyDestination makes imgutil.Copy create the copies of images in the daemon with the given client.
+func CopyDestination(dockerClient DockerClient) imgutil.CopyOption {
+	return imgutil.WithCopyDestination("local", func(name string, base v1.Image, ops ...imgutil.ImageOption) (imgutil.Image, error) {
+		return NewImage(name, dockerClient, append([]imgutil.ImageOption{imgutil.FromBaseImageInstance(base)}, ops...)...)
+	})
+}
+
+// NewReadOnlyImage returns the image with the given name in the daemon for inspection.
+// Unlike NewImage, it only inspects the image, without preparing it to be modified or adding it to a store;
+// its layers are read from the daemon on demand.
+func NewReadOnlyImage(repoName string, dockerClient DockerClient) (*ReadOnlyImage, error) {
+	inspect, history, err := getInspectAndHistory(imgutil.DigestReference(repoName), dockerClient)
+	if err != nil {
+		return nil, err
+	}
+	if inspect == nil {
+		return &ReadOnlyImage{ReadOnlyImage: imgutil.NewReadOnlyImage(repoName, nil, nil)}, nil
+	}
+	configFile, err := configFileFromInspect(*inspect, history)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadOnlyImage{
+		ReadOnlyImage: imgutil.NewReadOnlyImage(repoName, configFile, IDIdentifier{ImageID: strings.TrimPrefix(inspect.ID, "sha256:"), RepoDigests: inspect.RepoDigests}),
+		inspect:       inspect,
+		history:       history,
+		dockerClient:  dockerClient,
 	}, nil
 }
 
+// NewROImage returns the image with the given name in the daemon for reading its layers.
+// It is the same as NewReadOnlyImage.
+func NewROImage(repoName string, dockerClient DockerClient) (*ReadOnlyImage, error) {
+	return NewReadOnlyImage(repoName, dockerClient)
+}
+
 func defaultPlatform(dockerClient DockerClient) (imgutil.Platform, error) {
 	daemonInfo, err := dockerClient.ServerVersion(context.Background())
 	if err != nil {
@@ -80,7 +146,7 @@ func processPlatformOption(requestedPlatform imgutil.Platform, dockerClient Dock
 	if err != nil {
 		return imgutil.Platform{}, err
 	}
-	if (requestedPlatform == imgutil.Platform{}) {
+	if requestedPlatform.IsZero() {
 		return dockerPlatform, nil
 	}
 	if requestedPlatform.OS != "" && requestedPlatform.OS != dockerPlatform.OS {
@@ -91,16 +157,18 @@ func processPlatformOption(requestedPlatform imgutil.Platform, dockerClient Dock
 }
 
 type imageResult struct {
-	image      v1.Image
-	identifier string
-	layerStore *Store
+	image       v1.Image
+	identifier  string
+	repoDigests []string
+	layerStore  *Store
 }
 
 func processImageOption(repoName string, dockerClient DockerClient, downloadLayersOnAccess bool) (imageResult, error) {
 	if repoName == "" {
 		return imageResult{}, nil
 	}
-	inspect, history, err := getInspectAndHistory(repoName, dockerClient)
+	// resolve tag+digest references by digest
+	inspect, history, err := getInspectAndHistory(imgutil.DigestReference(repoName), dockerClient)
 	if err != nil {
 		return imageResult{}, err
 	}
@@ -113,12 +181,32 @@ func processImageOption(repoName string, dockerClient DockerClient, downloadLaye
 		return imageResult{}, err
 	}
 	return imageResult{
-		image:      v1Image,
-		identifier: inspect.ID,
-		layerStore: layerStore,
+		image:       v1Image,
+		identifier:  inspect.ID,
+		repoDigests: inspect.RepoDigests,
+		layerStore:  layerStore,
 	}, nil
 }
 
+// repoDigest returns the digest of the manifest of the image with the given name, from its repo digests,
+// preferring the repo digest of the same repository. It returns an empty string when there is none.
+func repoDigest(repoName string, repoDigests []string) string {
+	var fallback string
+	for _, repoDigest := range repoDigests {
+		digest, err := name.NewDigest(repoDigest, name.WeakValidation)
+		if err != nil {
+			continue
+		}
+		if ref, err := name.ParseReference(repoName, name.WeakValidation); err == nil && ref.Context().Name() == digest.Context().Name() {
+			return digest.DigestStr()
+		}
+		if fallback == "" {
+			fallback = digest.DigestStr()
+		}
+	}
+	return fallback
+}
+
 func getInspectAndHistory(repoName string, dockerClient DockerClient) (*types.ImageInspect, []image.HistoryResponseItem, error) {
 	inspect, _, err := dockerClient.ImageInspectWithRaw(context.Background(), repoName)
 	if err != nil {
@@ -0,0 +1,72 @@
+package local
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// ReadOnlyImage is an image in the daemon that can be inspected, and whose layers can be read.
+// The image is only prepared for reading its layers when one of them is first read,
+// and its layers are only saved from the daemon then.
+type ReadOnlyImage struct {
+	imgutil.ReadOnlyImage
+	inspect      *types.ImageInspect
+	history      []image.HistoryResponseItem
+	dockerClient DockerClient
+
+	once  sync.Once
+	image v1.Image
+	err   error
+}
+
+// GetLayer returns the uncompressed contents of the layer with the given diff ID.
+func (i *ReadOnlyImage) GetLayer(diffID string) (io.ReadCloser, error) {
+	if i.inspect == nil {
+		return nil, fmt.Errorf("image %q not found", i.Name())
+	}
+	layerHash, err := v1.NewHash(diffID)
+	if err != nil {
+		return nil, err
+	}
+	underlyingImage, err := i.underlyingImage()
+	if err != nil {
+		return nil, err
+	}
+	layer, err := underlyingImage.LayerByDiffID(layerHash)
+	if err != nil {
+		return nil, imgutil.ErrLayerNotFound{DiffID: layerHash.String()}
+	}
+	return layer.Uncompressed()
+}
+
+// Annotations returns the annotations of the manifest of the image, recorded in the AnnotationsLabel label when it was saved.
+func (i *ReadOnlyImage) Annotations() (map[string]string, error) {
+	value, err := i.Label(AnnotationsLabel)
+	if err != nil {
+		return nil, err
+	}
+	return parseAnnotationsLabel(value)
+}
+
+// UnderlyingImage returns the image as a v1.Image, or nil if the image is not found.
+func (i *ReadOnlyImage) UnderlyingImage() v1.Image {
+	underlyingImage, _ := i.underlyingImage()
+	return underlyingImage
+}
+
+func (i *ReadOnlyImage) underlyingImage() (v1.Image, error) {
+	if i.inspect == nil {
+		return nil, nil
+	}
+	i.once.Do(func() {
+		i.image, i.err = newV1ImageFacadeFromInspect(*i.inspect, i.history, NewStore(i.dockerClient), true)
+	})
+	return i.image, i.err
+}
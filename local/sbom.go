@@ -0,0 +1,142 @@
+package local
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layer"
+)
+
+var _ imgutil.SBOMStore = (*Image)(nil)
+
+const (
+	// SBOMLabel holds the metadata (media type, layer diff ID, and path) of the SBOMs attached to a local image.
+	SBOMLabel = "io.buildpacks.imgutil.sboms"
+	sbomDir   = "/.imgutil/sbom"
+)
+
+type sbomMetadata struct {
+	MediaType string `json:"mediaType"`
+	DiffID    string `json:"diffID"`
+	Path      string `json:"path"`
+}
+
+// AttachSBOM adds a layer containing the SBOM to the image, and records the layer in the SBOMLabel label,
+// as the daemon cannot store artifacts referring to an image.
+func (i *Image) AttachSBOM(mediaType string, content []byte) error {
+	metadata, err := i.sbomMetadata()
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(content)
+	sbomPath := path.Join(sbomDir, hex.EncodeToString(hash[:]))
+
+	layerContent, err := i.writeSBOMLayer(sbomPath, content)
+	if err != nil {
+		return fmt.Errorf("failed to write SBOM layer: %w", err)
+	}
+	layerHash := sha256.Sum256(layerContent)
+	diffID := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(layerHash[:])}
+	if err = i.AddLayerWithHistory(newInMemoryLayer(diffID, layerContent), emptyHistory); err != nil {
+		return fmt.Errorf("failed to add SBOM layer: %w", err)
+	}
+
+	metadata = append(metadata, sbomMetadata{MediaType: mediaType, DiffID: diffID.String(), Path: sbomPath})
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return i.SetLabel(SBOMLabel, string(value))
+}
+
+// writeSBOMLayer returns the content of a layer holding the SBOM at the given path.
+// The layer is kept in memory, as SBOMs are small, so that no temporary file outlives the image.
+func (i *Image) writeSBOMLayer(sbomPath string, content []byte) ([]byte, error) {
+	osType, err := i.OS()
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	var tw interface {
+		WriteHeader(*tar.Header) error
+		Write([]byte) (int, error)
+		Close() error
+	}
+	if osType == "windows" {
+		tw = layer.NewWindowsWriter(buf)
+	} else {
+		tw = tar.NewWriter(buf)
+	}
+	if err = tw.WriteHeader(&tar.Header{Name: sbomPath, Size: int64(len(content)), Mode: 0644, ModTime: imgutil.NormalizedDateTime}); err != nil {
+		return nil, err
+	}
+	if _, err = tw.Write(content); err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SBOMs returns the SBOMs recorded in the SBOMLabel label, reading their content from the image layers.
+func (i *Image) SBOMs() ([]imgutil.SBOM, error) {
+	metadata, err := i.sbomMetadata()
+	if err != nil {
+		return nil, err
+	}
+	var sboms []imgutil.SBOM
+	for _, m := range metadata {
+		content, err := i.readSBOMFromLayer(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SBOM from layer %s: %w", m.DiffID, err)
+		}
+		sboms = append(sboms, imgutil.SBOM{MediaType: m.MediaType, Content: content})
+	}
+	return sboms, nil
+}
+
+func (i *Image) sbomMetadata() ([]sbomMetadata, error) {
+	value, err := i.Label(SBOMLabel)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var metadata []sbomMetadata
+	if err = json.Unmarshal([]byte(value), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse label %q: %w", SBOMLabel, err)
+	}
+	return metadata, nil
+}
+
+func (i *Image) readSBOMFromLayer(metadata sbomMetadata) ([]byte, error) {
+	rc, err := i.GetLayer(metadata.DiffID)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("failed to find %q in layer", metadata.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/Files")
+		if name == metadata.Path {
+			return io.ReadAll(tr)
+		}
+	}
+}
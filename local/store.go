@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -67,6 +68,12 @@ func (s *Store) Contains(identifier string) bool {
 	return err == nil
 }
 
+// Ping checks that the daemon is available.
+func (s *Store) Ping() error {
+	_, err := s.dockerClient.ServerVersion(context.Background())
+	return err
+}
+
 func (s *Store) Delete(identifier string) error {
 	if !s.Contains(identifier) {
 		return nil
@@ -81,46 +88,71 @@ func (s *Store) Delete(identifier string) error {
 
 func (s *Store) Save(image *Image, withName string, withAdditionalNames ...string) (string, error) {
 	withName = tryNormalizing(withName)
+	ctx := context.Background()
+	if image.saveTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, image.saveTimeout)
+		defer cancel()
+	}
 	var (
 		inspect types.ImageInspect
+		loaded  int64
 		err     error
 	)
+	finish := imgutil.StartOperation(image.instrumentation, imgutil.OperationDaemonLoad, withName)
 
 	// save
 	canOmitBaseLayers := !usesContainerdStorage(s.dockerClient)
 	if canOmitBaseLayers {
 		// During the first save attempt some layers may be excluded.
 		// The docker daemon allows this if the given set of layers already exists in the daemon in the given order.
-		inspect, err = s.doSave(image, withName)
+		inspect, loaded, err = s.doSave(ctx, image, withName)
 	}
 	if !canOmitBaseLayers || err != nil {
 		if err = image.ensureLayers(); err != nil {
+			finish(-1, err)
 			return "", err
 		}
-		inspect, err = s.doSave(image, withName)
+		inspect, loaded, err = s.doSave(ctx, image, withName)
+		finish(loaded, err)
 		if err != nil {
 			saveErr := imgutil.SaveError{}
 			for _, n := range append([]string{withName}, withAdditionalNames...) {
-				saveErr.Errors = append(saveErr.Errors, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+				saveErr.Errors = append(saveErr.Errors, imgutil.SaveDiagnostic{ImageName: n, Reason: saveFailure(err), Cause: err})
 			}
 			return "", saveErr
 		}
+	} else {
+		finish(loaded, nil)
 	}
 
 	// tag additional names
-	var errs []imgutil.SaveDiagnostic
+	var (
+		errs  []imgutil.SaveDiagnostic
+		saved = map[string]string{}
+	)
 	for _, n := range append([]string{withName}, withAdditionalNames...) {
-		if err = s.dockerClient.ImageTag(context.Background(), inspect.ID, n); err != nil {
-			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		if err = s.dockerClient.ImageTag(ctx, inspect.ID, n); err != nil {
+			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Reason: saveFailure(err), Cause: err})
+			continue
 		}
+		saved[n] = inspect.ID
 	}
 	if len(errs) > 0 {
-		return "", imgutil.SaveError{Errors: errs}
+		return "", imgutil.SaveError{Errors: errs, Saved: saved}
 	}
 
 	return inspect.ID, nil
 }
 
+// saveFailure returns the known reason for a daemon error, or zero.
+func saveFailure(err error) imgutil.SaveFailure {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return imgutil.SaveTimedOut
+	}
+	return 0
+}
+
 func tryNormalizing(name string) string {
 	// ensure primary tag is valid
 	t, err := registryName.NewTag(name, registryName.WeakValidation)
@@ -145,9 +177,9 @@ func usesContainerdStorage(docker DockerClient) bool {
 	return false
 }
 
-func (s *Store) doSave(image v1.Image, withName string) (types.ImageInspect, error) {
-	ctx := context.Background()
-	done := make(chan error)
+// doSave loads the image into the daemon and returns the number of bytes sent.
+func (s *Store) doSave(ctx context.Context, image v1.Image, withName string) (types.ImageInspect, int64, error) {
+	done := make(chan error, 1)
 
 	var err error
 	pr, pw := io.Pipe()
@@ -175,30 +207,42 @@ func (s *Store) doSave(image v1.Image, withName string) (types.ImageInspect, err
 		done <- nil
 	}()
 
-	tw := tar.NewWriter(pw)
+	cw := &countingWriter{w: pw}
+	tw := tar.NewWriter(cw)
 	defer tw.Close()
 
-	if err = s.addImageToTar(tw, image, withName); err != nil {
-		return types.ImageInspect{}, err
+	if err = s.addImageToTar(tw, image, []string{withName}); err != nil {
+		return types.ImageInspect{}, cw.n, err
 	}
 	tw.Close()
 	pw.Close()
 	err = <-done
 	if err != nil {
-		return types.ImageInspect{}, fmt.Errorf("loading image %q. first error: %w", withName, err)
+		return types.ImageInspect{}, cw.n, fmt.Errorf("loading image %q. first error: %w", withName, err)
 	}
 
-	inspect, _, err := s.dockerClient.ImageInspectWithRaw(context.Background(), withName)
+	inspect, _, err := s.dockerClient.ImageInspectWithRaw(ctx, withName)
 	if err != nil {
 		if client.IsErrNotFound(err) {
-			return types.ImageInspect{}, fmt.Errorf("saving image %q: %w", withName, err)
+			return types.ImageInspect{}, cw.n, fmt.Errorf("saving image %q: %w", withName, err)
 		}
-		return types.ImageInspect{}, err
+		return types.ImageInspect{}, cw.n, err
 	}
-	return inspect, nil
+	return inspect, cw.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
 }
 
-func (s *Store) addImageToTar(tw *tar.Writer, image v1.Image, withName string) error {
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (s *Store) addImageToTar(tw *tar.Writer, image v1.Image, withNames []string) error {
 	rawConfigFile, err := image.RawConfigFile()
 	if err != nil {
 		return err
@@ -226,7 +270,7 @@ func (s *Store) addImageToTar(tw *tar.Writer, image v1.Image, withName string) e
 	manifestJSON, err := json.Marshal([]map[string]interface{}{
 		{
 			"Config":   configHash + ".json",
-			"RepoTags": []string{withName},
+			"RepoTags": withNames,
 			"Layers":   layerPaths,
 		},
 	})
@@ -291,8 +335,8 @@ func (s *Store) getLayerSize(layer v1.Layer) (int64, error) {
 	if layerFound {
 		return knownLayer.uncompressedSize, nil
 	}
-	// If layer was not seen previously, we need to read it to get the uncompressed size
-	// In practice, we should not get here
+	// If layer was not seen previously (e.g., it was added with AddLayerWithHistory rather than from a file),
+	// we need to read it to get the uncompressed size
 	layerReader, err := layer.Uncompressed()
 	if err != nil {
 		return 0, err
@@ -339,11 +383,24 @@ func ensureReaderClosed(r io.ReadCloser) error {
 }
 
 func (s *Store) SaveFile(image *Image, withName string) (string, error) {
-	withName = tryNormalizing(withName)
+	path, _, err := s.SaveFileAs(image, withName)
+	return path, err
+}
+
+// SaveFileAs saves the image as a docker archive holding all the given names as repo tags,
+// so that it is loaded under each of them, and returns the path to the archive and the ID of the image.
+func (s *Store) SaveFileAs(image *Image, withNames ...string) (path string, imageID string, err error) {
+	if len(withNames) == 0 {
+		return "", "", errors.New("at least one name is required")
+	}
+	repoTags := make([]string, len(withNames))
+	for idx, name := range withNames {
+		repoTags[idx] = tryNormalizing(name)
+	}
 
 	f, err := os.CreateTemp("", "imgutil.local.image.export.*.tar")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary file: %w", err)
+		return "", "", fmt.Errorf("failed to create temporary file: %w", err)
 	}
 	defer func() {
 		f.Close()
@@ -357,7 +414,12 @@ func (s *Store) SaveFile(image *Image, withName string) (string, error) {
 	// The former is only relevant if ReuseLayers() has been called which takes care of resolving them.
 	// The latter case needs to be handled explicitly.
 	if err = image.ensureLayers(); err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	configHash, err := image.ConfigName()
+	if err != nil {
+		return "", "", err
 	}
 
 	errs, _ := errgroup.WithContext(context.Background())
@@ -366,7 +428,7 @@ func (s *Store) SaveFile(image *Image, withName string) (string, error) {
 	// File writer
 	errs.Go(func() error {
 		defer pr.Close()
-		_, err = f.ReadFrom(pr)
+		_, err := f.ReadFrom(pr)
 		return err
 	})
 
@@ -377,14 +439,13 @@ func (s *Store) SaveFile(image *Image, withName string) (string, error) {
 		tw := tar.NewWriter(pw)
 		defer tw.Close()
 
-		return s.addImageToTar(tw, image, withName)
+		return s.addImageToTar(tw, image, repoTags)
 	})
 
-	err = errs.Wait()
-	if err != nil {
-		return "", err
+	if err = errs.Wait(); err != nil {
+		return "", "", err
 	}
-	return f.Name(), nil
+	return f.Name(), configHash.String(), nil
 }
 
 // layers
@@ -24,11 +24,20 @@ import (
 // The underlying layers will return data if they are contained in the store.
 // By storing a pointer to the image store, callers can update the store to force the layers to return data.
 func newV1ImageFacadeFromInspect(dockerInspect types.ImageInspect, history []image.HistoryResponseItem, withStore *Store, downloadLayersOnAccess bool) (v1.Image, error) {
+	configFile, err := configFileFromInspect(dockerInspect, history)
+	if err != nil {
+		return nil, err
+	}
+	layersToSet := newEmptyLayerListFrom(configFile, downloadLayersOnAccess, withStore, dockerInspect.ID)
+	return imageFrom(layersToSet, configFile, imgutil.DockerTypes) // FIXME: this should be configurable with options.MediaTypes
+}
+
+func configFileFromInspect(dockerInspect types.ImageInspect, history []image.HistoryResponseItem) (*v1.ConfigFile, error) {
 	rootFS, err := toV1RootFS(dockerInspect.RootFS)
 	if err != nil {
 		return nil, err
 	}
-	configFile := &v1.ConfigFile{
+	return &v1.ConfigFile{
 		Architecture:  dockerInspect.Architecture, // FIXME: this should come from options.Platform
 		Author:        dockerInspect.Author,
 		Container:     dockerInspect.Container, //nolint
@@ -40,9 +49,7 @@ func newV1ImageFacadeFromInspect(dockerInspect types.ImageInspect, history []ima
 		Config:        toV1Config(dockerInspect.Config),
 		OSVersion:     dockerInspect.OsVersion, // FIXME: this should come from options.Platform
 		Variant:       dockerInspect.Variant,   // FIXME: this should come from options.Platform
-	}
-	layersToSet := newEmptyLayerListFrom(configFile, downloadLayersOnAccess, withStore, dockerInspect.ID)
-	return imageFrom(layersToSet, configFile, imgutil.DockerTypes) // FIXME: this should be configurable with options.MediaTypes
+	}, nil
 }
 
 func imageFrom(layers []v1.Layer, configFile *v1.ConfigFile, requestedTypes imgutil.MediaTypes) (v1.Image, error) {
@@ -250,6 +257,18 @@ func newPopulatedLayer(diffID v1.Hash, fromPath string, uncompressedSize int64)
 	}
 }
 
+func newInMemoryLayer(diffID v1.Hash, content []byte) *v1LayerFacade {
+	return &v1LayerFacade{
+		diffID: diffID,
+		uncompressed: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		},
+		uncompressedSize: func() (int64, error) {
+			return int64(len(content)), nil
+		},
+	}
+}
+
 func newEmptyLayerListFrom(configFile *v1.ConfigFile, downloadOnAccess bool, withStore *Store, withImageIdentifier string) []v1.Layer {
 	layers := make([]v1.Layer, len(configFile.RootFS.DiffIDs))
 	for idx, diffID := range configFile.RootFS.DiffIDs {
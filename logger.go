@@ -0,0 +1,23 @@
+package imgutil
+
+// Logger receives messages about the operations performed by images,
+// such as resolved base images, skipped blobs, or retried requests.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+}
+
+// GetLogger returns the logger provided with WithLogger, or a logger discarding all messages.
+func GetLogger(options ImageOptions) Logger {
+	if options.Logger != nil {
+		return options.Logger
+	}
+	return discardLogger{}
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Debugf(string, ...interface{}) {}
+func (discardLogger) Infof(string, ...interface{})  {}
+func (discardLogger) Warnf(string, ...interface{})  {}
@@ -0,0 +1,196 @@
+package mediatypes
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil/internal/v1util"
+)
+
+// PreserveLayers is a "mutate layer" function for EnsureImage that keeps each layer as it is.
+func PreserveLayers(_ int, layer v1.Layer) (v1.Layer, error) {
+	return layer, nil
+}
+
+// EnsureImage replaces the provided image with a new image that has the requested media types.
+// It does this by constructing a manifest and config from the provided image,
+// and adding the layers from the provided image to the new image with the right media type.
+// The media type of each layer and of the config is converted on its own (see MediaTypes.ConvertLayer and MediaTypes.ConvertConfig),
+// so that a base image mixing OCI and Docker media types gets consistent media types, while the config and layers of artifacts are kept.
+// If requested types are missing or default, it does nothing.
+// While adding the layers, each layer can be additionally mutated by providing a "mutate layer" function.
+// The annotations of the manifest, and the platform and URLs of its config descriptor, are kept.
+func EnsureImage(image v1.Image, requested MediaTypes, mutateLayer func(idx int, layer v1.Layer) (v1.Layer, error)) (v1.Image, bool, error) {
+	if !requested.Converts() {
+		return image, false, nil
+	}
+	// (1) get data from the original image
+	// manifest
+	beforeManifest, err := image.Manifest()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	// config
+	beforeConfig, err := image.ConfigFile()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get config: %w", err)
+	}
+	// layers
+	beforeLayers, err := image.Layers()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get layers: %w", err)
+	}
+	var layersToAdd []v1.Layer
+	for idx, l := range beforeLayers {
+		layer, err := mutateLayer(idx, l)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to mutate layer: %w", err)
+		}
+		layersToAdd = append(layersToAdd, layer)
+	}
+
+	// (2) construct a new image manifest with the right media type
+	manifestType := requested.ManifestType()
+	if manifestType == "" {
+		manifestType = beforeManifest.MediaType
+	}
+	retImage := mutate.MediaType(empty.Image, manifestType)
+	if len(beforeManifest.Annotations) > 0 {
+		retImage = mutate.Annotations(retImage, beforeManifest.Annotations).(v1.Image)
+	}
+
+	// (3) set config with the right media type
+	configType := requested.ConvertConfig(beforeManifest.Config.MediaType)
+	// zero out diff IDs and history, these will be added back when we append the layers
+	beforeHistory := beforeConfig.History
+	beforeConfig.History = []v1.History{}
+	beforeConfig.RootFS.DiffIDs = []v1.Hash{}
+	retImage, err = mutate.ConfigFile(retImage, beforeConfig)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to set config: %w", err)
+	}
+	retImage = mutate.ConfigMediaType(retImage, configType)
+
+	// (4) set layers with the right media type
+	retImage, err = mutate.Append(retImage, layersAddendum(layersToAdd, beforeHistory, requested)...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to append layers: %w", err)
+	}
+
+	// (5) force compute
+	afterLayers, err := retImage.Layers()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get layers: %w", err)
+	}
+	if len(afterLayers) != len(beforeLayers) {
+		return nil, false, fmt.Errorf("expected %d layers; got %d", len(beforeLayers), len(afterLayers))
+	}
+	if beforeManifest.Config.Platform != nil || len(beforeManifest.Config.URLs) > 0 {
+		retImage = v1util.WithConfigDescriptor(retImage, beforeManifest.Config)
+	}
+
+	return retImage, true, nil
+}
+
+// Convert returns a copy of the given image with the manifest, config, and layer media types of the requested types.
+// As Docker media types don't support zstd, layers compressed with zstd are recompressed with gzip when converting to Docker media types;
+// the diff IDs of the layers are unchanged.
+// If requested types are missing or default, it returns the image unchanged.
+func Convert(image v1.Image, requested MediaTypes) (v1.Image, error) {
+	converted, _, err := EnsureImage(image, requested, func(_ int, layer v1.Layer) (v1.Layer, error) {
+		if requested != Docker {
+			return layer, nil
+		}
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		if mediaType != types.OCILayerZStd {
+			return layer, nil
+		}
+		return tarball.LayerFromOpener(layer.Uncompressed)
+	})
+	return converted, err
+}
+
+// EnsureIndex replaces the provided index with a new index that has the requested media types.
+// Every image referenced by the index is converted with EnsureImage, and nested indexes are converted recursively,
+// so that an OCI image index becomes a Docker manifest list (or vice versa) together with all the manifests it references.
+// Platforms, URLs, and annotations of the original descriptors are preserved.
+// If requested types are missing or default, it does nothing.
+func EnsureIndex(index v1.ImageIndex, requested MediaTypes) (v1.ImageIndex, bool, error) {
+	if !requested.Converts() {
+		return index, false, nil
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get index manifest: %w", err)
+	}
+
+	var additions []mutate.IndexAddendum
+	for _, desc := range indexManifest.Manifests {
+		var toAdd mutate.Appendable
+		switch {
+		case desc.MediaType.IsImage():
+			image, err := index.Image(desc.Digest)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to get image %s: %w", desc.Digest, err)
+			}
+			if toAdd, _, err = EnsureImage(image, requested, PreserveLayers); err != nil {
+				return nil, false, fmt.Errorf("failed to ensure media types for image %s: %w", desc.Digest, err)
+			}
+		case desc.MediaType.IsIndex():
+			childIndex, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to get index %s: %w", desc.Digest, err)
+			}
+			if toAdd, _, err = EnsureIndex(childIndex, requested); err != nil {
+				return nil, false, fmt.Errorf("failed to ensure media types for index %s: %w", desc.Digest, err)
+			}
+		default:
+			return nil, false, fmt.Errorf("failed to convert descriptor %s: unsupported media type %q", desc.Digest, desc.MediaType)
+		}
+		additions = append(additions, mutate.IndexAddendum{
+			Add: toAdd,
+			Descriptor: v1.Descriptor{
+				Platform:    desc.Platform,
+				URLs:        desc.URLs,
+				Annotations: desc.Annotations,
+			},
+		})
+	}
+
+	retIndex := mutate.IndexMediaType(empty.Index, requested.IndexType())
+	if len(indexManifest.Annotations) > 0 {
+		retIndex = mutate.Annotations(retIndex, indexManifest.Annotations).(v1.ImageIndex)
+	}
+	return mutate.AppendManifests(retIndex, additions...), true, nil
+}
+
+// layersAddendum creates an Addendum array with the given layers
+// and the media types of the requested types (see MediaTypes.ConvertLayer)
+func layersAddendum(layers []v1.Layer, history []v1.History, requested MediaTypes) []mutate.Addendum {
+	addendums := make([]mutate.Addendum, 0)
+	history = v1util.NormalizedHistory(history, len(layers))
+	if len(history) != len(layers) {
+		history = make([]v1.History, len(layers))
+	}
+	for idx, l := range layers {
+		// try to get a non-empty media type
+		layerType, err := l.MediaType()
+		if err != nil {
+			layerType = ""
+		}
+		addendums = append(addendums, mutate.Addendum{
+			Layer:     l,
+			History:   history[idx],
+			MediaType: requested.ConvertLayer(layerType),
+		})
+	}
+	return addendums
+}
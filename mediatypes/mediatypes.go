@@ -0,0 +1,167 @@
+// Package mediatypes describes the media types of the images saved by imgutil (see imgutil.WithMediaTypes),
+// and how the media types of manifests, configs, layers, and indexes are converted between OCI and Docker media types.
+package mediatypes
+
+import (
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// MediaTypes selects the media types of an image.
+type MediaTypes int
+
+const (
+	// Missing means that no media types were requested; see Preferred.
+	Missing MediaTypes = iota
+	// Default keeps the media types of the base image.
+	Default
+	// OCI selects OCI media types.
+	OCI
+	// Docker selects Docker media types.
+	Docker
+)
+
+// Preferred returns the media types to use for an image, given the requested media types
+// and whether the image is created from a base image:
+// the requested media types if any, otherwise the media types of the base image, or OCI media types without a base image.
+func Preferred(requested MediaTypes, hasBase bool) MediaTypes {
+	switch {
+	case requested != Missing:
+		return requested
+	case hasBase:
+		return Default
+	default:
+		return OCI
+	}
+}
+
+// Of returns the media types of the given manifest or index media type,
+// or Default if it is neither an OCI nor a Docker media type.
+func Of(mediaType types.MediaType) MediaTypes {
+	switch mediaType {
+	case types.OCIManifestSchema1, types.OCIImageIndex:
+		return OCI
+	case types.DockerManifestSchema2, types.DockerManifestList:
+		return Docker
+	default:
+		return Default
+	}
+}
+
+// Converts reports whether media types are converted to t, i.e. whether t is OCI or Docker.
+func (t MediaTypes) Converts() bool {
+	return t == OCI || t == Docker
+}
+
+// ManifestType returns the image manifest media type of t, or "" if t doesn't convert media types.
+func (t MediaTypes) ManifestType() types.MediaType {
+	switch t {
+	case OCI:
+		return types.OCIManifestSchema1
+	case Docker:
+		return types.DockerManifestSchema2
+	default:
+		return ""
+	}
+}
+
+// ConfigType returns the image config media type of t, or "" if t doesn't convert media types.
+func (t MediaTypes) ConfigType() types.MediaType {
+	switch t {
+	case OCI:
+		return types.OCIConfigJSON
+	case Docker:
+		return types.DockerConfigJSON
+	default:
+		return ""
+	}
+}
+
+// IndexType returns the index media type of t (an OCI image index or a Docker manifest list), or "" if t doesn't convert media types.
+func (t MediaTypes) IndexType() types.MediaType {
+	switch t {
+	case OCI:
+		return types.OCIImageIndex
+	case Docker:
+		return types.DockerManifestList
+	default:
+		return ""
+	}
+}
+
+// LayerType returns the gzip compressed layer media type of t, or "" if t doesn't convert media types.
+func (t MediaTypes) LayerType() types.MediaType {
+	switch t {
+	case OCI:
+		return types.OCILayer
+	case Docker:
+		return types.DockerLayer
+	default:
+		return ""
+	}
+}
+
+// ConvertConfig returns the media type that a config with the given media type has once converted to t.
+// Image configs get the config media type of t; the configs of artifacts (e.g. `application/vnd.oci.empty.v1+json`)
+// are not image configs, and keep their media type.
+func (t MediaTypes) ConvertConfig(original types.MediaType) types.MediaType {
+	if !t.Converts() {
+		return original
+	}
+	switch original {
+	case "", types.OCIConfigJSON, types.DockerConfigJSON:
+		return t.ConfigType()
+	default:
+		return original
+	}
+}
+
+// ConvertLayer returns the media type that a layer with the given media type has once converted to t:
+//   - gzip compressed and unknown (empty) layers get the layer media type of t,
+//   - uncompressed and non-distributable (foreign) layers get the matching media type of t,
+//   - zstd compressed layers keep their media type with OCI media types; as Docker media types don't support zstd,
+//     they get the Docker layer media type, and must be recompressed with gzip (as Convert does),
+//   - layers of artifacts (e.g. SBOMs) are not image layers, and keep their media type.
+func (t MediaTypes) ConvertLayer(original types.MediaType) types.MediaType {
+	if !t.Converts() {
+		return original
+	}
+	switch original {
+	case "", types.OCILayer, types.DockerLayer:
+		return t.LayerType()
+	case types.OCILayerZStd:
+		if t == OCI {
+			return original
+		}
+		return types.DockerLayer
+	case types.OCIUncompressedLayer, types.DockerUncompressedLayer:
+		if t == OCI {
+			return types.OCIUncompressedLayer
+		}
+		return types.DockerUncompressedLayer
+	case types.OCIRestrictedLayer, types.DockerForeignLayer:
+		if t == OCI {
+			return types.OCIRestrictedLayer
+		}
+		return types.DockerForeignLayer
+	default:
+		return original
+	}
+}
+
+// IsArtifact reports whether a manifest with the given config media type is an artifact (e.g. an SBOM or a signature)
+// rather than an image, i.e. whether its config is not an image config.
+func IsArtifact(configType types.MediaType) bool {
+	return configType != types.OCIConfigJSON && configType != types.DockerConfigJSON
+}
+
+// IsDocker reports whether the given media type is a Docker media type.
+func IsDocker(mediaType types.MediaType) bool {
+	return strings.HasPrefix(string(mediaType), "application/vnd.docker.")
+}
+
+// IsOCI reports whether the given media type is an OCI media type.
+func IsOCI(mediaType types.MediaType) bool {
+	return strings.HasPrefix(string(mediaType), "application/vnd.oci.")
+}
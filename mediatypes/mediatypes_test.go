@@ -0,0 +1,272 @@
+package mediatypes_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/internal/v1util"
+	"github.com/buildpacks/imgutil/mediatypes"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestMediaTypes(t *testing.T) {
+	spec.Run(t, "MediaTypes", testMediaTypes, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testMediaTypes(t *testing.T, when spec.G, it spec.S) {
+	when("#Preferred", func() {
+		it("prefers the requested media types, then the ones of the base image, then OCI media types", func() {
+			h.AssertEq(t, mediatypes.Preferred(mediatypes.Docker, true), mediatypes.Docker)
+			h.AssertEq(t, mediatypes.Preferred(mediatypes.Missing, true), mediatypes.Default)
+			h.AssertEq(t, mediatypes.Preferred(mediatypes.Missing, false), mediatypes.OCI)
+		})
+	})
+
+	when("#Of", func() {
+		it("returns the media types of manifests and indexes", func() {
+			h.AssertEq(t, mediatypes.Of(types.OCIImageIndex), mediatypes.OCI)
+			h.AssertEq(t, mediatypes.Of(types.DockerManifestSchema2), mediatypes.Docker)
+			h.AssertEq(t, mediatypes.Of(types.DockerManifestSchema1), mediatypes.Default)
+		})
+	})
+
+	when("#ConvertLayer", func() {
+		it("converts image layers", func() {
+			h.AssertEq(t, mediatypes.OCI.ConvertLayer(types.DockerLayer), types.OCILayer)
+			h.AssertEq(t, mediatypes.OCI.ConvertLayer(""), types.OCILayer)
+			h.AssertEq(t, mediatypes.Docker.ConvertLayer(types.OCIUncompressedLayer), types.DockerUncompressedLayer)
+			h.AssertEq(t, mediatypes.Docker.ConvertLayer(types.OCIRestrictedLayer), types.DockerForeignLayer)
+			h.AssertEq(t, mediatypes.OCI.ConvertLayer(types.DockerForeignLayer), types.OCIRestrictedLayer)
+		})
+
+		it("keeps zstd layers with OCI media types", func() {
+			h.AssertEq(t, mediatypes.OCI.ConvertLayer(types.OCILayerZStd), types.OCILayerZStd)
+			h.AssertEq(t, mediatypes.Docker.ConvertLayer(types.OCILayerZStd), types.DockerLayer)
+		})
+
+		it("keeps the layers of artifacts", func() {
+			h.AssertEq(t, mediatypes.Docker.ConvertLayer("application/spdx+json"), types.MediaType("application/spdx+json"))
+		})
+
+		it("keeps every media type with default media types", func() {
+			h.AssertEq(t, mediatypes.Default.ConvertLayer(types.DockerLayer), types.DockerLayer)
+		})
+	})
+
+	when("#ConvertConfig", func() {
+		it("converts image configs and keeps the configs of artifacts", func() {
+			h.AssertEq(t, mediatypes.OCI.ConvertConfig(types.DockerConfigJSON), types.OCIConfigJSON)
+			h.AssertEq(t, mediatypes.Docker.ConvertConfig(types.OCIConfigJSON), types.DockerConfigJSON)
+			h.AssertEq(t, mediatypes.Docker.ConvertConfig("application/vnd.oci.empty.v1+json"), types.MediaType("application/vnd.oci.empty.v1+json"))
+			h.AssertEq(t, mediatypes.IsArtifact("application/vnd.oci.empty.v1+json"), true)
+		})
+	})
+
+	when("#IndexType", func() {
+		it("returns the index media type", func() {
+			h.AssertEq(t, mediatypes.OCI.IndexType(), types.OCIImageIndex)
+			h.AssertEq(t, mediatypes.Docker.IndexType(), types.DockerManifestList)
+			h.AssertEq(t, mediatypes.Default.IndexType(), types.MediaType(""))
+		})
+	})
+
+	// imageWith returns an image with a single layer and the given media types.
+	imageWith := func(mediaTypes mediatypes.MediaTypes) v1.Image {
+		image, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+		image, _, err = mediatypes.EnsureImage(image, mediaTypes, mediatypes.PreserveLayers)
+		h.AssertNil(t, err)
+		return image
+	}
+
+	diffIDsOf := func(image v1.Image) []v1.Hash {
+		configFile, err := image.ConfigFile()
+		h.AssertNil(t, err)
+		return configFile.RootFS.DiffIDs
+	}
+
+	assertMediaTypes := func(image v1.Image, manifestType, configType, layerType types.MediaType) {
+		t.Helper()
+		manifest, err := image.Manifest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, manifest.MediaType, manifestType)
+		h.AssertEq(t, manifest.Config.MediaType, configType)
+		for _, layer := range manifest.Layers {
+			h.AssertEq(t, layer.MediaType, layerType)
+		}
+	}
+
+	when("#EnsureImage", func() {
+		it("converts a base image mixing OCI and Docker media types", func() {
+			image := imageWith(mediatypes.Docker)
+			layer, err := random.Layer(1024, types.OCILayer)
+			h.AssertNil(t, err)
+			image, err = mutate.Append(image, mutate.Addendum{Layer: layer, MediaType: types.OCILayer})
+			h.AssertNil(t, err)
+
+			converted, mutated, err := mediatypes.EnsureImage(image, mediatypes.Docker, mediatypes.PreserveLayers)
+			h.AssertNil(t, err)
+			h.AssertEq(t, mutated, true)
+			assertMediaTypes(converted, types.DockerManifestSchema2, types.DockerConfigJSON, types.DockerLayer)
+			h.AssertEq(t, diffIDsOf(converted), diffIDsOf(image))
+		})
+
+		it("keeps the config of artifacts, the annotations, and the config descriptor", func() {
+			image := mutate.ConfigMediaType(imageWith(mediatypes.OCI), "application/vnd.oci.empty.v1+json")
+			image = mutate.Annotations(image, map[string]string{"some-key": "some-value"}).(v1.Image)
+			image = v1util.WithConfigDescriptor(image, v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64", Features: []string{"some-feature"}}})
+
+			converted, _, err := mediatypes.EnsureImage(image, mediatypes.Docker, mediatypes.PreserveLayers)
+			h.AssertNil(t, err)
+			manifest, err := converted.Manifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest.Config.MediaType, types.MediaType("application/vnd.oci.empty.v1+json"))
+			h.AssertEq(t, manifest.Annotations, map[string]string{"some-key": "some-value"})
+			h.AssertEq(t, manifest.Config.Platform.Features, []string{"some-feature"})
+		})
+
+		it("does nothing with default media types", func() {
+			image := imageWith(mediatypes.Docker)
+
+			converted, mutated, err := mediatypes.EnsureImage(image, mediatypes.Default, mediatypes.PreserveLayers)
+			h.AssertNil(t, err)
+			h.AssertEq(t, mutated, false)
+			h.AssertEq(t, converted == image, true)
+		})
+	})
+
+	when("#Convert", func() {
+		it("converts a Docker image to OCI", func() {
+			image := imageWith(mediatypes.Docker)
+			converted, err := mediatypes.Convert(image, mediatypes.OCI)
+			h.AssertNil(t, err)
+			assertMediaTypes(converted, types.OCIManifestSchema1, types.OCIConfigJSON, types.OCILayer)
+			h.AssertEq(t, diffIDsOf(converted), diffIDsOf(image))
+		})
+
+		it("converts an OCI image to Docker", func() {
+			image := imageWith(mediatypes.OCI)
+			converted, err := mediatypes.Convert(image, mediatypes.Docker)
+			h.AssertNil(t, err)
+			assertMediaTypes(converted, types.DockerManifestSchema2, types.DockerConfigJSON, types.DockerLayer)
+			h.AssertEq(t, diffIDsOf(converted), diffIDsOf(image))
+		})
+
+		when("the image has zstd layers", func() {
+			var zstdImage v1.Image
+
+			it.Before(func() {
+				image := imageWith(mediatypes.OCI)
+				layers, err := image.Layers()
+				h.AssertNil(t, err)
+				zstdLayer, err := tarball.LayerFromOpener(layers[0].Uncompressed, tarball.WithCompression(compression.ZStd), tarball.WithMediaType(types.OCILayerZStd))
+				h.AssertNil(t, err)
+				zstdImage, err = mutate.AppendLayers(image, zstdLayer)
+				h.AssertNil(t, err)
+			})
+
+			it("keeps them when converting to OCI", func() {
+				converted, err := mediatypes.Convert(zstdImage, mediatypes.OCI)
+				h.AssertNil(t, err)
+				manifest, err := converted.Manifest()
+				h.AssertNil(t, err)
+				h.AssertEq(t, manifest.Layers[1].MediaType, types.OCILayerZStd)
+			})
+
+			it("recompresses them with gzip when converting to Docker", func() {
+				converted, err := mediatypes.Convert(zstdImage, mediatypes.Docker)
+				h.AssertNil(t, err)
+				manifest, err := converted.Manifest()
+				h.AssertNil(t, err)
+				h.AssertEq(t, manifest.Layers[1].MediaType, types.DockerLayer)
+				layers, err := converted.Layers()
+				h.AssertNil(t, err)
+				rc, err := layers[1].Compressed()
+				h.AssertNil(t, err)
+				defer rc.Close()
+				magic := make([]byte, 2)
+				_, err = rc.Read(magic)
+				h.AssertNil(t, err)
+				h.AssertEq(t, magic, []byte{0x1f, 0x8b})
+				h.AssertEq(t, diffIDsOf(converted), diffIDsOf(zstdImage))
+			})
+		})
+	})
+
+	when("#EnsureIndex", func() {
+		indexWith := func(mediaTypes mediatypes.MediaTypes) v1.ImageIndex {
+			nested := mutate.AppendManifests(
+				mutate.IndexMediaType(empty.Index, mediaTypes.IndexType()),
+				mutate.IndexAddendum{Add: imageWith(mediaTypes), Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+			)
+			return mutate.AppendManifests(
+				mutate.IndexMediaType(empty.Index, mediaTypes.IndexType()),
+				mutate.IndexAddendum{Add: imageWith(mediaTypes), Descriptor: v1.Descriptor{
+					Platform:    &v1.Platform{OS: "linux", Architecture: "amd64"},
+					Annotations: map[string]string{"some-key": "some-value"},
+				}},
+				mutate.IndexAddendum{Add: nested},
+			)
+		}
+
+		assertIndexMediaTypes := func(index v1.ImageIndex, indexType, manifestType, configType, layerType types.MediaType) {
+			t.Helper()
+			indexManifest, err := index.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, indexManifest.MediaType, indexType)
+			h.AssertEq(t, len(indexManifest.Manifests), 2)
+
+			h.AssertEq(t, indexManifest.Manifests[0].MediaType, manifestType)
+			h.AssertEq(t, indexManifest.Manifests[0].Platform, &v1.Platform{OS: "linux", Architecture: "amd64"})
+			h.AssertEq(t, indexManifest.Manifests[0].Annotations, map[string]string{"some-key": "some-value"})
+			image, err := index.Image(indexManifest.Manifests[0].Digest)
+			h.AssertNil(t, err)
+			assertMediaTypes(image, manifestType, configType, layerType)
+
+			h.AssertEq(t, indexManifest.Manifests[1].MediaType, indexType)
+			nested, err := index.ImageIndex(indexManifest.Manifests[1].Digest)
+			h.AssertNil(t, err)
+			nestedManifest, err := nested.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, nestedManifest.MediaType, indexType)
+			h.AssertEq(t, len(nestedManifest.Manifests), 1)
+			h.AssertEq(t, nestedManifest.Manifests[0].MediaType, manifestType)
+			h.AssertEq(t, nestedManifest.Manifests[0].Platform, &v1.Platform{OS: "linux", Architecture: "arm64"})
+			image, err = nested.Image(nestedManifest.Manifests[0].Digest)
+			h.AssertNil(t, err)
+			assertMediaTypes(image, manifestType, configType, layerType)
+		}
+
+		it("converts an OCI index and the indexes nested in it to Docker", func() {
+			converted, mutated, err := mediatypes.EnsureIndex(indexWith(mediatypes.OCI), mediatypes.Docker)
+			h.AssertNil(t, err)
+			h.AssertEq(t, mutated, true)
+			assertIndexMediaTypes(converted, types.DockerManifestList, types.DockerManifestSchema2, types.DockerConfigJSON, types.DockerLayer)
+		})
+
+		it("converts a Docker manifest list and the lists nested in it to OCI", func() {
+			converted, mutated, err := mediatypes.EnsureIndex(indexWith(mediatypes.Docker), mediatypes.OCI)
+			h.AssertNil(t, err)
+			h.AssertEq(t, mutated, true)
+			assertIndexMediaTypes(converted, types.OCIImageIndex, types.OCIManifestSchema1, types.OCIConfigJSON, types.OCILayer)
+		})
+
+		it("does nothing with default media types", func() {
+			index := indexWith(mediatypes.Docker)
+
+			converted, mutated, err := mediatypes.EnsureIndex(index, mediatypes.Default)
+			h.AssertNil(t, err)
+			h.AssertEq(t, mutated, false)
+			h.AssertEq(t, converted == index, true)
+		})
+	})
+}
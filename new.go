@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"text/template"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -13,20 +14,41 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 
+	"github.com/buildpacks/imgutil/internal/v1util"
 	"github.com/buildpacks/imgutil/layer"
+	"github.com/buildpacks/imgutil/mediatypes"
 )
 
 func NewCNBImage(options ImageOptions) (*CNBImageCore, error) {
 	image := &CNBImageCore{
 		Image:               options.BaseImage, // the working image
 		createdAt:           getCreatedAt(options),
+		explicitCreatedAt:   !options.CreatedAt.IsZero(),
+		normalizedDateTime:  getNormalizedDateTime(options),
 		preferredMediaTypes: GetPreferredMediaTypes(options),
 		preserveHistory:     options.PreserveHistory,
+		historyDetails:      options.HistoryDetails,
+		wrapWindowsLayers:   options.WrapWindowsLayers,
 		previousImage:       options.PreviousImage,
+		additionalPrevious:  options.AdditionalPreviousImages,
+		automaticReuse:      options.AutomaticLayerReuse,
+		verifyDiffIDs:       options.VerifyDiffIDs,
+		estargzLayers:       options.EstargzLayers,
+		eventHandlers:       options.EventHandlers,
+		secretMatchers:      options.SecretMatchers,
+		labelValidator:      options.LabelValidator,
+		sizeLimits:          options.SizeLimits,
+		logger:              GetLogger(options),
 	}
 
-	// ensure base image
 	var err error
+	if options.CreatedByTemplate != "" {
+		if image.createdByTemplate, err = template.New("created-by").Parse(options.CreatedByTemplate); err != nil {
+			return nil, fmt.Errorf("failed to parse created by template: %w", err)
+		}
+	}
+
+	// ensure base image
 	if image.Image == nil {
 		image.Image, err = emptyV1(options.Platform, image.preferredMediaTypes)
 		if err != nil {
@@ -38,6 +60,18 @@ func NewCNBImage(options ImageOptions) (*CNBImageCore, error) {
 	if err = prepareNewWindowsImageIfNeeded(image); err != nil {
 		return nil, err
 	}
+	// the windows base layer of an empty image is the only layer added to an image saved without layers
+	image.withoutLayers = options.WithoutLayers
+
+	// seed config file if requested
+	if options.ConfigFile != nil {
+		if err = image.MutateConfigFile(func(c *v1.ConfigFile) {
+			c.Author = options.ConfigFile.Author
+			c.Config = *options.ConfigFile.Config.DeepCopy()
+		}); err != nil {
+			return nil, err
+		}
+	}
 
 	// set config if requested
 	if options.Config != nil {
@@ -48,6 +82,21 @@ func NewCNBImage(options ImageOptions) (*CNBImageCore, error) {
 		}
 	}
 
+	// annotate base image if requested
+	if options.baseImageDigest != "" {
+		baseName, _ := SplitDigest(options.BaseImageRepoName)
+		if err = image.annotateBaseImage(baseName, options.baseImageDigest); err != nil {
+			return nil, err
+		}
+	}
+
+	// annotate if requested
+	if len(options.Annotations) > 0 {
+		if err = image.SetAnnotations(options.Annotations); err != nil {
+			return nil, err
+		}
+	}
+
 	return image, nil
 }
 
@@ -55,70 +104,43 @@ func getCreatedAt(options ImageOptions) time.Time {
 	if !options.CreatedAt.IsZero() {
 		return options.CreatedAt
 	}
+	return getNormalizedDateTime(options)
+}
+
+func getNormalizedDateTime(options ImageOptions) time.Time {
+	if !options.NormalizedDateTime.IsZero() {
+		return options.NormalizedDateTime
+	}
 	return NormalizedDateTime
 }
 
+// NormalizedDateTime is the time that saving an image normalizes the created time of its config and history to by default,
+// so that saving the same layers and config results in the same digest (see WithNormalizedDateTime).
 var NormalizedDateTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
 
+// GetPreferredMediaTypes returns the media types of the image created with the given options (see mediatypes.Preferred).
 func GetPreferredMediaTypes(options ImageOptions) MediaTypes {
-	if options.MediaTypes != MissingTypes {
-		return options.MediaTypes
-	}
-	if options.MediaTypes == MissingTypes &&
-		options.BaseImage == nil {
-		return OCITypes
-	}
-	return DefaultTypes
+	return mediatypes.Preferred(options.MediaTypes, options.BaseImage != nil)
 }
 
-type MediaTypes int
+// MediaTypes selects the media types of an image; see the mediatypes package.
+type MediaTypes = mediatypes.MediaTypes
 
 const (
-	MissingTypes MediaTypes = iota
-	DefaultTypes
-	OCITypes
-	DockerTypes
+	MissingTypes = mediatypes.Missing
+	DefaultTypes = mediatypes.Default
+	OCITypes     = mediatypes.OCI
+	DockerTypes  = mediatypes.Docker
 )
 
-func (t MediaTypes) ManifestType() types.MediaType {
-	switch t {
-	case OCITypes:
-		return types.OCIManifestSchema1
-	case DockerTypes:
-		return types.DockerManifestSchema2
-	default:
-		return ""
-	}
-}
-
-func (t MediaTypes) ConfigType() types.MediaType {
-	switch t {
-	case OCITypes:
-		return types.OCIConfigJSON
-	case DockerTypes:
-		return types.DockerConfigJSON
-	default:
-		return ""
-	}
-}
-
-func (t MediaTypes) LayerType() types.MediaType {
-	switch t {
-	case OCITypes:
-		return types.OCILayer
-	case DockerTypes:
-		return types.DockerLayer
-	default:
-		return ""
-	}
-}
-
 func emptyV1(withPlatform Platform, withMediaTypes MediaTypes) (v1.Image, error) {
 	configFile := &v1.ConfigFile{
 		Architecture: withPlatform.Architecture,
 		History:      []v1.History{},
 		OS:           withPlatform.OS,
 		OSVersion:    withPlatform.OSVersion,
+		Variant:      withPlatform.Variant,
+		OSFeatures:   withPlatform.OSFeatures,
 		RootFS: v1.RootFS{
 			Type:    "layers",
 			DiffIDs: []v1.Hash{},
@@ -132,129 +154,77 @@ func emptyV1(withPlatform Platform, withMediaTypes MediaTypes) (v1.Image, error)
 	return image, err
 }
 
-func PreserveLayers(_ int, layer v1.Layer) (v1.Layer, error) {
-	return layer, nil
+// PreserveLayers is a "mutate layer" function for EnsureMediaTypesAndLayers that keeps each layer as it is.
+func PreserveLayers(idx int, layer v1.Layer) (v1.Layer, error) {
+	return mediatypes.PreserveLayers(idx, layer)
 }
 
-// EnsureMediaTypesAndLayers replaces the provided image with a new image that has the desired media types.
-// It does this by constructing a manifest and config from the provided image,
-// and adding the layers from the provided image to the new image with the right media type.
-// If requested types are missing or default, it does nothing.
-// While adding the layers, each layer can be additionally mutated by providing a "mutate layer" function.
+// EnsureMediaTypesAndLayers replaces the provided image with a new image that has the desired media types;
+// see mediatypes.EnsureImage.
 func EnsureMediaTypesAndLayers(image v1.Image, requestedTypes MediaTypes, mutateLayer func(idx int, layer v1.Layer) (v1.Layer, error)) (v1.Image, bool, error) {
-	if requestedTypes == MissingTypes || requestedTypes == DefaultTypes {
-		return image, false, nil
-	}
-	// (1) get data from the original image
-	// manifest
-	beforeManifest, err := image.Manifest()
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to get manifest: %w", err)
-	}
-	// config
-	beforeConfig, err := image.ConfigFile()
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to get config: %w", err)
-	}
-	// layers
-	beforeLayers, err := image.Layers()
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to get layers: %w", err)
-	}
-	var layersToAdd []v1.Layer
-	for idx, l := range beforeLayers {
-		layer, err := mutateLayer(idx, l)
-		if err != nil {
-			return nil, false, fmt.Errorf("failed to mutate layer: %w", err)
-		}
-		layersToAdd = append(layersToAdd, layer)
-	}
-
-	// (2) construct a new image manifest with the right media type
-	manifestType := requestedTypes.ManifestType()
-	if manifestType == "" {
-		manifestType = beforeManifest.MediaType
-	}
-	retImage := mutate.MediaType(empty.Image, manifestType)
+	return mediatypes.EnsureImage(image, requestedTypes, mutateLayer)
+}
 
-	// (3) set config with the right media type
-	configType := requestedTypes.ConfigType()
-	if configType == "" {
-		configType = beforeManifest.Config.MediaType
-	}
-	// zero out diff IDs and history, these will be added back when we append the layers
-	beforeHistory := beforeConfig.History
-	beforeConfig.History = []v1.History{}
-	beforeConfig.RootFS.DiffIDs = []v1.Hash{}
-	retImage, err = mutate.ConfigFile(retImage, beforeConfig)
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to set config: %w", err)
-	}
-	retImage = mutate.ConfigMediaType(retImage, configType)
+// ConvertMediaTypes returns a copy of the given image with the media types of the requested types; see mediatypes.Convert.
+func ConvertMediaTypes(image v1.Image, requestedTypes MediaTypes) (v1.Image, error) {
+	return mediatypes.Convert(image, requestedTypes)
+}
 
-	// (4) set layers with the right media type
-	additions := layersAddendum(layersToAdd, beforeHistory, requestedTypes.LayerType())
-	if err != nil {
-		return nil, false, err
-	}
-	retImage, err = mutate.Append(retImage, additions...)
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to append layers: %w", err)
-	}
+// EnsureMediaTypesForIndex replaces the provided index with a new index that has the desired media types;
+// see mediatypes.EnsureIndex.
+func EnsureMediaTypesForIndex(index v1.ImageIndex, requestedTypes MediaTypes) (v1.ImageIndex, bool, error) {
+	return mediatypes.EnsureIndex(index, requestedTypes)
+}
 
-	// (5) force compute
-	afterLayers, err := retImage.Layers()
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to get layers: %w", err)
-	}
-	if len(afterLayers) != len(beforeLayers) {
-		return nil, false, fmt.Errorf("expected %d layers; got %d", len(beforeLayers), len(afterLayers))
-	}
+// NormalizedHistory returns the history entries of the given history that describe layers (i.e. without the entries of empty layers),
+// so that there is one entry per layer; if the history doesn't match the given number of layers, it returns empty entries.
+// See CNBImageCore.RepairHistory to reconcile a history that doesn't match the layers while keeping its entries.
+func NormalizedHistory(history []v1.History, nLayers int) []v1.History {
+	return v1util.NormalizedHistory(history, nLayers)
+}
 
-	return retImage, true, nil
+// HistoryRepair describes how RepairHistory reconciled a history with the layers of an image.
+type HistoryRepair struct {
+	Layers      int // the number of layers of the image
+	Entries     int // the number of history entries describing layers, before the repair
+	Added       int // the number of empty entries added for the bottom layers, which had no entry
+	MarkedEmpty int // the number of extra entries at the bottom that were marked as describing empty layers
 }
 
-// layersAddendum creates an Addendum array with the given layers
-// and the desired media type
-func layersAddendum(layers []v1.Layer, history []v1.History, requestedType types.MediaType) []mutate.Addendum {
-	addendums := make([]mutate.Addendum, 0)
-	history = NormalizedHistory(history, len(layers))
-	if len(history) != len(layers) {
-		history = make([]v1.History, len(layers))
-	}
-	var err error
-	for idx, l := range layers {
-		layerType := requestedType
-		if requestedType == "" {
-			// try to get a non-empty media type
-			if layerType, err = l.MediaType(); err != nil {
-				layerType = ""
-			}
-		}
-		addendums = append(addendums, mutate.Addendum{
-			Layer:     l,
-			History:   history[idx],
-			MediaType: layerType,
-		})
-	}
-	return addendums
+// Repaired reports whether the history was changed.
+func (r HistoryRepair) Repaired() bool {
+	return r.Added > 0 || r.MarkedEmpty > 0
 }
 
-func NormalizedHistory(history []v1.History, nLayers int) []v1.History {
-	if history == nil {
-		return make([]v1.History, nLayers)
-	}
-	// ensure we remove history for empty layers
-	var normalizedHistory []v1.History
+// RepairHistory reconciles the given history with the given number of layers, keeping its entries:
+// entries are assumed to describe the top layers, so empty entries are added for the bottom layers when entries are missing,
+// and the extra entries at the bottom are marked as describing empty layers when there are too many.
+// The returned history has one entry that is not for an empty layer per layer, as NormalizedHistory expects.
+func RepairHistory(history []v1.History, nLayers int) ([]v1.History, HistoryRepair) {
+	repair := HistoryRepair{Layers: nLayers}
 	for _, h := range history {
 		if !h.EmptyLayer {
-			normalizedHistory = append(normalizedHistory, h)
+			repair.Entries++
 		}
 	}
-	if len(normalizedHistory) == nLayers {
-		return normalizedHistory
+	repaired := make([]v1.History, 0, len(history))
+	switch {
+	case repair.Entries < nLayers:
+		repair.Added = nLayers - repair.Entries
+		repaired = append(repaired, make([]v1.History, repair.Added)...)
+		repaired = append(repaired, history...)
+	case repair.Entries > nLayers:
+		for _, h := range history {
+			if !h.EmptyLayer && repair.MarkedEmpty < repair.Entries-nLayers {
+				h.EmptyLayer = true
+				repair.MarkedEmpty++
+			}
+			repaired = append(repaired, h)
+		}
+	default:
+		repaired = append(repaired, history...)
 	}
-	return make([]v1.History, nLayers)
+	return repaired, repair
 }
 
 func prepareNewWindowsImageIfNeeded(image *CNBImageCore) error {
@@ -291,3 +261,20 @@ func prepareNewWindowsImageIfNeeded(image *CNBImageCore) error {
 	}
 	return nil
 }
+
+// TransformLayers returns the image with the entries of each of its layers rewritten by the given transforms (see layer.Transform),
+// e.g. to enforce image hygiene policies. The diff IDs in the config are updated, and the history of the layers is kept.
+func TransformLayers(image v1.Image, transforms ...layer.EntryTransform) (v1.Image, error) {
+	manifest, err := image.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	mediaTypes := OCITypes
+	if manifest.MediaType == types.DockerManifestSchema2 {
+		mediaTypes = DockerTypes
+	}
+	transformed, _, err := EnsureMediaTypesAndLayers(image, mediaTypes, func(_ int, l v1.Layer) (v1.Layer, error) {
+		return layer.Transform(l, transforms...)
+	})
+	return transformed, err
+}
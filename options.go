@@ -1,29 +1,79 @@
 package imgutil
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
 )
 
 type ImageOption func(*ImageOptions)
 
 type ImageOptions struct {
-	BaseImageRepoName     string
+	BaseImageRepoName string
+	baseImageDigest   string
+	// requestedPlatform is the platform provided by the caller, before the constructor defaults it
+	requestedPlatform     Platform
+	PinnedDigest          v1.Hash
 	PreviousImageRepoName string
 	Config                *v1.Config
+	ConfigFile            *v1.ConfigFile
 	CreatedAt             time.Time
+	NormalizedDateTime    time.Time
 	MediaTypes            MediaTypes
 	Platform              Platform
+	Annotations           map[string]string
+	BaseImageAnnotations  bool
+	AutomaticLayerReuse   bool
+	VerifyDiffIDs         bool
 	PreserveHistory       bool
+	HistoryDetails        bool
+	CreatedByTemplate     string
+	StrictPlatformCheck   bool
+	WrapWindowsLayers     bool
+	BaseImageVerifier     ImageVerifier
+	BlobCache             cache.Cache
+	EventHandlers         []EventHandler
+	Instrumentation       Instrumentation
+	Logger                Logger
+	SaveTimeout           time.Duration
+	SecretMatchers        []SecretMatcher
+	LabelValidator        LabelValidator
+	SizeLimits            SizeLimits
 	LayoutOptions
 	RemoteOptions
 
 	// These options must be specified in each implementation's image constructor
 	BaseImage     v1.Image
 	PreviousImage v1.Image
+	// AdditionalPreviousImages are searched for reusable layers after the previous image (see WithAdditionalPreviousImages)
+	AdditionalPreviousImages []v1.Image
 }
 
+// NewImageOptions applies the given options in order, and validates the result (see ImageOptions.Validate).
+// It is the shared first step of each implementation's image constructor. Options that are not provided are defaulted
+// by the constructor once the base image is resolved:
+//   - the platform defaults to the platform of the daemon (local), to linux/amd64 (layout),
+//     or to linux with the architecture of the running program (remote),
+//   - the media types default to the ones of the base image, or to OCI types without a base image (see GetPreferredMediaTypes),
+//   - the created at time defaults to the normalized date time, which defaults to NormalizedDateTime, and the logger to a logger that discards messages.
+func NewImageOptions(ops ...ImageOption) (*ImageOptions, error) {
+	options := &ImageOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	options.requestedPlatform = options.Platform
+	return options, nil
+}
+
+// ImageVerifier checks an image resolved from the given name (e.g. a base or previous image) before it is used.
+type ImageVerifier func(image v1.Image, name string) error
+
 type LayoutOptions struct {
 	PreserveDigest bool
 	WithoutLayers  bool
@@ -32,8 +82,37 @@ type LayoutOptions struct {
 type RemoteOptions struct {
 	RegistrySettings    map[string]RegistrySetting
 	AddEmptyLayerOnSave bool
+	EstargzLayers       bool
+	AssumeLayersMissing bool
+	AssumeLayersPresent bool
+	BlobExistenceCache  BlobExistenceCache
+	OverwritePolicy     OverwritePolicy
+	HTTPRecorderDir     string
+	ConnectTimeout      time.Duration
+	BlobTimeout         time.Duration
+	BandwidthLimit      int64
+	PushChildren        bool
 }
 
+// BlobExistenceCache records which blobs are known to exist in which repositories (e.g. an existcache.Cache).
+// Repositories are named with their registry, e.g. `registry.example.com/some/repo`.
+type BlobExistenceCache interface {
+	Exists(repo string, digest v1.Hash) bool
+	Record(repo string, digest v1.Hash) error
+}
+
+// OverwritePolicy determines what happens when an image is saved to a tag that already exists.
+type OverwritePolicy int
+
+const (
+	// Overwrite pushes the image, replacing the image the tag refers to (if the registry allows it).
+	Overwrite OverwritePolicy = iota
+	// FailIfExists fails to save the image to a tag that refers to another image, with a SaveTagExists error.
+	FailIfExists
+	// SkipIfExists doesn't save the image to a tag that refers to another image.
+	SkipIfExists
+)
+
 type RegistrySetting struct {
 	Insecure bool
 }
@@ -46,6 +125,78 @@ func FromBaseImage(name string) func(*ImageOptions) {
 	}
 }
 
+// FromBaseImageInstance provides an already constructed image as the manifest, config, and layers for the working image,
+// instead of loading it by name. The image can come from another implementation than the one of the image constructor
+// (e.g. an image read from a tarball with the tarball package).
+func FromBaseImageInstance(image v1.Image) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.BaseImage = image
+	}
+}
+
+// WithAdditionalPreviousImages provides more images to reuse layers from, searched in order after the previous image,
+// e.g. older images built before a builder upgrade, whose unchanged app layers are no longer in the previous image.
+// ReuseLayer reuses a layer from the first image that has it, with the history of that image.
+// The images can come from any implementation (see WithPreviousImageInstance).
+func WithAdditionalPreviousImages(images ...v1.Image) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.AdditionalPreviousImages = append(o.AdditionalPreviousImages, images...)
+	}
+}
+
+// WithAnnotations adds the given annotations to the manifest of the working image, as SetAnnotations does.
+func WithAnnotations(annotations map[string]string) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.Annotations = annotations
+	}
+}
+
+// WithAutomaticLayerReuse makes AddLayerWithDiffID and AddLayerWithDiffIDAndHistory reuse the layer with the given diff ID
+// from the previous image (or the additional previous images) when it has it, as ReuseLayerWithHistory does,
+// rather than adding the layer at the given path, so that identical content is neither read nor uploaded again.
+func WithAutomaticLayerReuse() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.AutomaticLayerReuse = true
+	}
+}
+
+// WithDiffIDVerification makes AddLayerWithDiffID and AddLayerWithDiffIDAndHistory hash the tar file when the layer is added,
+// and fail if its diff ID doesn't match the provided one. Without it, the provided diff ID is trusted, and the file is only
+// read when the layer is saved (see layer.WithDiffID). It is supported by the layout and remote implementations.
+func WithDiffIDVerification() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.VerifyDiffIDs = true
+	}
+}
+
+// WithBaseImageAnnotations stamps the org.opencontainers.image.base.name and org.opencontainers.image.base.digest
+// annotations on the manifest of the working image, describing the base image it was created from.
+// The local implementation records them in its annotations label, with the digest of the base image
+// taken from its repo digests, so base images that were never pushed or pulled are not annotated.
+func WithBaseImageAnnotations() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.BaseImageAnnotations = true
+	}
+}
+
+// WithBaseImageVerifier lets a caller verify the base and previous images once they are resolved,
+// before the working image is created from them; if the verifier returns an error, so does the image constructor.
+// It can be used to enforce digest pinning, allowed registries, or signature policies.
+func WithBaseImageVerifier(verifier ImageVerifier) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.BaseImageVerifier = verifier
+	}
+}
+
+// WithBlobCache lets a caller provide a cache for layer blobs (e.g. a blobcache.Cache shared between builds).
+// Layers read from the working image are looked up in the cache first, and added to it when they are fetched
+// from the registry, the daemon, or the layout.
+func WithBlobCache(c cache.Cache) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.BlobCache = c
+	}
+}
+
 // WithConfig lets a caller provided a `config` object for the working image.
 func WithConfig(c *v1.Config) func(*ImageOptions) {
 	return func(o *ImageOptions) {
@@ -53,15 +204,42 @@ func WithConfig(c *v1.Config) func(*ImageOptions) {
 	}
 }
 
+// WithConfigFile lets a caller seed the working image from an existing config file,
+// e.g. when reconstructing an image from metadata.
+// The runtime config (env, labels, user, working dir, etc.) and author are copied to the working image,
+// and the platform is used as the default platform if none was provided before this option.
+// The rootfs and history are ignored, as they describe layers that the working image does not have.
+// If WithConfig is also provided, its config takes precedence over the runtime config of the config file.
+func WithConfigFile(configFile *v1.ConfigFile) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.ConfigFile = configFile
+		if o.Platform.IsZero() {
+			o.Platform = PlatformFromConfig(configFile)
+		}
+	}
+}
+
 // WithCreatedAt lets a caller set the "created at" timestamp for the working image when saved.
-// If not provided, the default is NormalizedDateTime.
+// If not provided, the default is the normalized date time (see WithNormalizedDateTime).
 func WithCreatedAt(t time.Time) func(*ImageOptions) {
 	return func(o *ImageOptions) {
 		o.CreatedAt = t
 	}
 }
 
-// WithDefaultPlatform provides the default Architecture/OS/OSVersion if no base image is provided,
+// WithCreatedByTemplate provides a text/template that sets the `created_by` of the history of added layers
+// whose history doesn't have one, e.g. "cnb: {{.LayerName}}", so that `docker history` is informative.
+// The template is executed with the DiffID of the layer, and its LayerName: for layers added from a file, the base name of the file
+// without its tar extension (see LayerName), otherwise empty.
+// Without WithHistory or WithHistoryDetails, the history is still zeroed on save except for the `created_by` of each layer,
+// whether provided with the layer or set by the template.
+func WithCreatedByTemplate(tmpl string) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.CreatedByTemplate = tmpl
+	}
+}
+
+// WithDefaultPlatform provides the default Architecture/OS/OSVersion/Variant/OSFeatures if no base image is provided,
 // or if the provided image inputs (base and previous) are manifest lists.
 func WithDefaultPlatform(p Platform) func(*ImageOptions) {
 	return func(o *ImageOptions) {
@@ -69,6 +247,24 @@ func WithDefaultPlatform(p Platform) func(*ImageOptions) {
 	}
 }
 
+// WithDigest pins the manifest of the image loaded by the constructor (e.g. the base image, or the image of NewReadOnlyImage)
+// when its name refers to an index: the manifest with the given digest is selected, rather than the manifest matching the platform,
+// so that rebuilds use the same image even if the index changes. If the name refers to an image, its digest must be the given digest.
+// It is supported by the layout and remote implementations.
+func WithDigest(d v1.Hash) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.PinnedDigest = d
+	}
+}
+
+// WithEventHandler registers a callback fired when layers are added or reused, labels are set, or the image is saved.
+// It may be provided more than once.
+func WithEventHandler(handler EventHandler) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.EventHandlers = append(o.EventHandlers, handler)
+	}
+}
+
 // WithHistory if provided will configure the image to preserve history when saved
 // (including any history from the base image if valid).
 func WithHistory() func(*ImageOptions) {
@@ -77,6 +273,39 @@ func WithHistory() func(*ImageOptions) {
 	}
 }
 
+// WithHistoryDetails if provided will configure the image to keep the `created_by` and `comment` of the history
+// of each layer when saved, while still normalizing timestamps and dropping other fields (unlike WithHistory).
+func WithHistoryDetails() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.HistoryDetails = true
+	}
+}
+
+// WithInstrumentation lets a caller observe the duration and size of pulls, uploads, and daemon loads
+// (e.g. to export metrics or tracing spans).
+func WithInstrumentation(i Instrumentation) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.Instrumentation = i
+	}
+}
+
+// WithLabelValidator validates the labels of the image with the given validator (e.g. OCILabelValidator):
+// SetLabel fails with a LabelError when the label is invalid, and so does saving the image when it has an invalid label
+// (e.g. from the base image), before anything is saved.
+func WithLabelValidator(validator LabelValidator) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.LabelValidator = validator
+	}
+}
+
+// WithLogger lets a caller receive messages about what the image is doing
+// (e.g. resolved digests, skipped blobs, retries).
+func WithLogger(l Logger) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.Logger = l
+	}
+}
+
 // WithMediaTypes lets a caller set the desired media types for the manifest and config (including layers referenced in the manifest)
 // to be either OCI media types or Docker media types.
 func WithMediaTypes(m MediaTypes) func(*ImageOptions) {
@@ -85,6 +314,15 @@ func WithMediaTypes(m MediaTypes) func(*ImageOptions) {
 	}
 }
 
+// WithNormalizedDateTime sets the time that saving the image normalizes the created time of its config and history to,
+// instead of NormalizedDateTime (1980-01-01), e.g. the Unix epoch, or the time of the last commit of the source for reproducible builds.
+// The time can also be set before each save with SetNormalizedDateTime. A created time provided with WithCreatedAt takes precedence.
+func WithNormalizedDateTime(t time.Time) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.NormalizedDateTime = t
+	}
+}
+
 // WithPreviousImage loads an existing image as the source for reusable layers.
 // Use with ReuseLayer().
 // If the image is not found, it does nothing.
@@ -93,3 +331,162 @@ func WithPreviousImage(name string) func(*ImageOptions) {
 		o.PreviousImageRepoName = name
 	}
 }
+
+// WithPreviousImageInstance provides an already constructed image as the source for reusable layers, instead of loading it by name.
+// The image can come from another implementation than the one of the image constructor
+// (e.g. a layout image can be the previous image of a remote image, so that a cache on disk feeds pushes to a registry);
+// as an imgutil.Image built on CNBImageCore is also a v1.Image, it can be provided as is.
+// The layers reused from it are read from it when the image is saved.
+func WithPreviousImageInstance(image v1.Image) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.PreviousImage = image
+	}
+}
+
+// WithSaveTimeout sets the deadline of each call to Save or SaveAs, after which the image is no longer saved under
+// the names it was not yet saved under; the returned SaveError lists the names it was saved under.
+// It is supported by the local and remote implementations.
+func WithSaveTimeout(d time.Duration) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.SaveTimeout = d
+	}
+}
+
+// WithStrictPlatformCheck makes image construction fail if the base image platform does not match the requested platform,
+// rather than logging a warning.
+func WithStrictPlatformCheck() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.StrictPlatformCheck = true
+	}
+}
+
+// WithWindowsLayerWrapping makes the image rewrite the tar files added with AddLayer when its OS is windows,
+// so that callers can provide Linux-style tar files: entries are put under `Files/` with the PAX headers expected by
+// the Windows daemon (see layer.WindowsFromFile). Tar files that already are Windows layers are added unchanged.
+// It is supported by the layout and remote implementations.
+func WithWindowsLayerWrapping() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.WrapWindowsLayers = true
+	}
+}
+
+// CheckBasePlatform compares the platform in the config of the resolved base image against the platform requested
+// in the options given to NewImageOptions; the default platform of the constructor isn't checked, so that a base image
+// of any platform can be used when no platform is requested.
+// A mismatch is an error if WithStrictPlatformCheck was provided, and is otherwise reported as a warning to the logger.
+func (o *ImageOptions) CheckBasePlatform() error {
+	if o.BaseImage == nil || o.requestedPlatform.IsZero() {
+		return nil
+	}
+	configFile, err := o.BaseImage.ConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to get config file for base image %q: %w", o.BaseImageRepoName, err)
+	}
+	basePlatform := PlatformFromConfig(configFile)
+	if basePlatform.Satisfies(o.requestedPlatform) {
+		return nil
+	}
+	if o.StrictPlatformCheck {
+		return fmt.Errorf("base image %q has platform %s, which does not match the requested platform %s", o.BaseImageRepoName, basePlatform, o.requestedPlatform)
+	}
+	GetLogger(*o).Warnf("base image %q has platform %s, which does not match the requested platform %s", o.BaseImageRepoName, basePlatform, o.requestedPlatform)
+	return nil
+}
+
+// RecordBaseImage records the digest of the resolved base image, so that it can be used for the base image annotations.
+// It must be called before the base image is converted to the preferred media types, as that changes its digest.
+// A base image with no layers (i.e., one that was not found) is not recorded.
+func (o *ImageOptions) RecordBaseImage() error {
+	if !o.BaseImageAnnotations || o.BaseImage == nil {
+		return nil
+	}
+	layers, err := o.BaseImage.Layers()
+	if err != nil {
+		return fmt.Errorf("failed to get layers for base image %q: %w", o.BaseImageRepoName, err)
+	}
+	if len(layers) == 0 {
+		return nil
+	}
+	digest, err := o.BaseImage.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to get digest for base image %q: %w", o.BaseImageRepoName, err)
+	}
+	o.baseImageDigest = digest.String()
+	return nil
+}
+
+// RecordBaseImageDigest records the given manifest digest of the resolved base image for the base image annotations,
+// for implementations whose base image doesn't come with its manifest (e.g. an image in a daemon).
+// An empty digest is not recorded.
+func (o *ImageOptions) RecordBaseImageDigest(digest string) {
+	if !o.BaseImageAnnotations || o.BaseImage == nil || digest == "" {
+		return
+	}
+	o.baseImageDigest = digest
+}
+
+// CacheImages wraps the resolved base and previous images, including the additional previous images, with the blob cache
+// provided with WithBlobCache, so that their layers are read from the cache when they are cached,
+// and added to the cache as they are fetched otherwise. Without a blob cache, it does nothing.
+func (o *ImageOptions) CacheImages() {
+	if o.BlobCache == nil {
+		return
+	}
+	if o.BaseImage != nil {
+		o.BaseImage = cache.Image(o.BaseImage, o.BlobCache)
+	}
+	if o.PreviousImage != nil {
+		o.PreviousImage = cache.Image(o.PreviousImage, o.BlobCache)
+	}
+	for idx, image := range o.AdditionalPreviousImages {
+		o.AdditionalPreviousImages[idx] = cache.Image(image, o.BlobCache)
+	}
+}
+
+// Validate rejects contradictory options, which would otherwise be resolved differently by each implementation.
+func (o *ImageOptions) Validate() error {
+	var errs []error
+	if o.BaseImage != nil && o.BaseImageRepoName != "" {
+		errs = append(errs, fmt.Errorf("a base image instance and the base image name %q cannot both be provided", o.BaseImageRepoName))
+	}
+	if o.PreviousImage != nil && o.PreviousImageRepoName != "" {
+		errs = append(errs, fmt.Errorf("a previous image instance and the previous image name %q cannot both be provided", o.PreviousImageRepoName))
+	}
+	if o.PinnedDigest != (v1.Hash{}) && o.BaseImageRepoName == "" {
+		errs = append(errs, fmt.Errorf("digest %s is provided without a base image name to select it from", o.PinnedDigest))
+	}
+	if o.Config != nil && o.ConfigFile != nil {
+		errs = append(errs, errors.New("a config and a config file cannot both be provided"))
+	}
+	if o.AssumeLayersMissing && o.AssumeLayersPresent {
+		errs = append(errs, errors.New("layers cannot be assumed to be both missing and present"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid image options: %w", err)
+	}
+	return nil
+}
+
+// VerifyImages runs the base image verifier, if provided, against the resolved base and previous images,
+// including the additional previous images.
+func (o *ImageOptions) VerifyImages() error {
+	if o.BaseImageVerifier == nil {
+		return nil
+	}
+	if o.BaseImage != nil {
+		if err := o.BaseImageVerifier(o.BaseImage, o.BaseImageRepoName); err != nil {
+			return fmt.Errorf("failed to verify base image %q: %w", o.BaseImageRepoName, err)
+		}
+	}
+	if o.PreviousImage != nil {
+		if err := o.BaseImageVerifier(o.PreviousImage, o.PreviousImageRepoName); err != nil {
+			return fmt.Errorf("failed to verify previous image %q: %w", o.PreviousImageRepoName, err)
+		}
+	}
+	for idx, image := range o.AdditionalPreviousImages {
+		if err := o.BaseImageVerifier(image, ""); err != nil {
+			return fmt.Errorf("failed to verify additional previous image %d: %w", idx, err)
+		}
+	}
+	return nil
+}
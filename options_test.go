@@ -0,0 +1,53 @@
+package imgutil_test
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestOptions(t *testing.T) {
+	spec.Run(t, "Options", testOptions, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testOptions(t *testing.T, when spec.G, it spec.S) {
+	when("#NewImageOptions", func() {
+		it("rejects contradictory options", func() {
+			base, err := random.Image(64, 1)
+			h.AssertNil(t, err)
+			digest, err := base.Digest()
+			h.AssertNil(t, err)
+
+			_, err = imgutil.NewImageOptions(
+				imgutil.FromBaseImageInstance(base),
+				imgutil.FromBaseImage("some-base"),
+				imgutil.WithConfig(&v1.Config{}),
+				imgutil.WithConfigFile(&v1.ConfigFile{}),
+			)
+			h.AssertError(t, err, `invalid image options: a base image instance and the base image name "some-base" cannot both be provided`)
+			h.AssertError(t, err, "a config and a config file cannot both be provided")
+
+			_, err = imgutil.NewImageOptions(imgutil.WithPreviousImageInstance(base), imgutil.WithPreviousImage("some-previous"))
+			h.AssertError(t, err, `a previous image instance and the previous image name "some-previous" cannot both be provided`)
+
+			_, err = imgutil.NewImageOptions(imgutil.WithDigest(digest))
+			h.AssertError(t, err, "is provided without a base image name to select it from")
+
+			_, err = imgutil.NewImageOptions(remote.WithAssumeLayersMissing(), remote.WithAssumeLayersPresent())
+			h.AssertError(t, err, "layers cannot be assumed to be both missing and present")
+		})
+
+		it("accepts options that don't contradict each other", func() {
+			options, err := imgutil.NewImageOptions(imgutil.FromBaseImage("some-base"), imgutil.WithConfig(&v1.Config{}))
+			h.AssertNil(t, err)
+			h.AssertEq(t, options.BaseImageRepoName, "some-base")
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package imgutil
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// PlatformFromV1 returns the platform described by an OCI platform; a nil platform gives the zero value.
+func PlatformFromV1(p *v1.Platform) Platform {
+	if p == nil {
+		return Platform{}
+	}
+	return Platform{
+		Architecture: p.Architecture,
+		OS:           p.OS,
+		OSVersion:    p.OSVersion,
+		Variant:      p.Variant,
+		OSFeatures:   p.OSFeatures,
+		Features:     p.Features,
+	}
+}
+
+// PlatformFromConfig returns the platform described by an image config file.
+func PlatformFromConfig(configFile *v1.ConfigFile) Platform {
+	return PlatformFromV1(configFile.Platform())
+}
+
+// V1 returns p as an OCI platform.
+func (p Platform) V1() v1.Platform {
+	return v1.Platform{
+		Architecture: p.Architecture,
+		OS:           p.OS,
+		OSVersion:    p.OSVersion,
+		Variant:      p.Variant,
+		OSFeatures:   p.OSFeatures,
+		Features:     p.Features,
+	}
+}
+
+// IsZero reports whether no field of p is set.
+func (p Platform) IsZero() bool {
+	return p.Architecture == "" && p.OS == "" && p.OSVersion == "" && p.Variant == "" &&
+		len(p.OSFeatures) == 0 && len(p.Features) == 0
+}
+
+// Equal reports whether p and other have the same fields, ignoring the order of features.
+func (p Platform) Equal(other Platform) bool {
+	return p.Satisfies(other) && other.Satisfies(p)
+}
+
+// Satisfies reports whether p meets the requirements of other:
+// every string field set in other must be equal in p, and every feature listed in other must be listed in p.
+func (p Platform) Satisfies(other Platform) bool {
+	return matchesRequired(p.Architecture, other.Architecture) &&
+		matchesRequired(p.OS, other.OS) &&
+		matchesRequired(p.OSVersion, other.OSVersion) &&
+		matchesRequired(p.Variant, other.Variant) &&
+		containsAll(p.OSFeatures, other.OSFeatures) &&
+		containsAll(p.Features, other.Features)
+}
+
+// String returns p in the form os/arch[/variant][:os_version].
+func (p Platform) String() string {
+	v1Platform := p.V1()
+	return v1Platform.String()
+}
+
+func matchesRequired(actual, required string) bool {
+	return required == "" || actual == required
+}
+
+func containsAll(actual, required []string) bool {
+	have := make(map[string]bool, len(actual))
+	for _, val := range actual {
+		have[val] = true
+	}
+	for _, val := range required {
+		if !have[val] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package imgutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ReadOnlyImage has the getters of an image that only need its config.
+// Every Image is a ReadOnlyImage; the NewReadOnlyImage constructors of the local, remote, and layout packages
+// return a ReadOnlyImage for tools that only inspect images, without the cost of preparing an image to be modified.
+type ReadOnlyImage interface {
+	Architecture() (string, error)
+	CreatedAt() (time.Time, error)
+	Entrypoint() ([]string, error)
+	Env(key string) (string, error)
+	// Found reports if image exists in the image store with `Name()`.
+	Found() bool
+	History() ([]v1.History, error)
+	Identifier() (Identifier, error)
+	Label(string) (string, error)
+	Labels() (map[string]string, error)
+	Name() string
+	OS() (string, error)
+	OSVersion() (string, error)
+	// TopLayer returns the diff id for the top layer
+	TopLayer() (string, error)
+	Variant() (string, error)
+	WorkingDir() (string, error)
+}
+
+var _ ReadOnlyImage = Image(nil)
+
+type configImage struct {
+	name       string
+	configFile *v1.ConfigFile
+	identifier Identifier
+}
+
+// NewReadOnlyImage returns a ReadOnlyImage with the given config file and identifier.
+// If the config file is nil, the image is not found, and its getters return an error.
+func NewReadOnlyImage(name string, configFile *v1.ConfigFile, identifier Identifier) ReadOnlyImage {
+	return &configImage{name: name, configFile: configFile, identifier: identifier}
+}
+
+func (i *configImage) config() (*v1.ConfigFile, error) {
+	if i.configFile == nil {
+		return nil, fmt.Errorf("image %q not found", i.name)
+	}
+	return i.configFile, nil
+}
+
+func (i *configImage) Architecture() (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	return configFile.Architecture, nil
+}
+
+func (i *configImage) CreatedAt() (time.Time, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return configFile.Created.Time, nil
+}
+
+func (i *configImage) Entrypoint() ([]string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return nil, err
+	}
+	return configFile.Config.Entrypoint, nil
+}
+
+func (i *configImage) Env(key string) (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	for _, envVar := range configFile.Config.Env {
+		parts := strings.Split(envVar, "=")
+		if len(parts) == 2 && parts[0] == key {
+			return parts[1], nil
+		}
+	}
+	return "", nil
+}
+
+func (i *configImage) Found() bool {
+	return i.configFile != nil
+}
+
+func (i *configImage) History() ([]v1.History, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return nil, err
+	}
+	return configFile.History, nil
+}
+
+func (i *configImage) Identifier() (Identifier, error) {
+	if _, err := i.config(); err != nil {
+		return nil, err
+	}
+	return i.identifier, nil
+}
+
+func (i *configImage) Label(key string) (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	return configFile.Config.Labels[key], nil
+}
+
+func (i *configImage) Labels() (map[string]string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return nil, err
+	}
+	return configFile.Config.Labels, nil
+}
+
+func (i *configImage) Name() string {
+	return i.name
+}
+
+func (i *configImage) OS() (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	return configFile.OS, nil
+}
+
+func (i *configImage) OSVersion() (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	return configFile.OSVersion, nil
+}
+
+func (i *configImage) TopLayer() (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	if len(configFile.RootFS.DiffIDs) == 0 {
+		return "", errors.New("image has no layers")
+	}
+	return configFile.RootFS.DiffIDs[len(configFile.RootFS.DiffIDs)-1].String(), nil
+}
+
+func (i *configImage) Variant() (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	return configFile.Variant, nil
+}
+
+func (i *configImage) WorkingDir() (string, error) {
+	configFile, err := i.config()
+	if err != nil {
+		return "", err
+	}
+	return configFile.Config.WorkingDir, nil
+}
@@ -0,0 +1,269 @@
+package imgutil
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// MergePolicy tells Rebase how to adopt a kind of config value (e.g. labels) from the new base image.
+type MergePolicy int
+
+const (
+	// KeepImageValues leaves the values of the image unchanged. It is the default.
+	KeepImageValues MergePolicy = iota
+	// PreferImageValues adds the values of the new base that the image doesn't set.
+	PreferImageValues
+	// PreferNewBaseValues adds the values of the new base, replacing those of the image.
+	PreferNewBaseValues
+)
+
+type RebaseOption func(*RebaseOptions)
+
+// RebaseOptions describe the config values that Rebase adopts from the new base image,
+// in addition to the OS, architecture and OS version that are always synced.
+type RebaseOptions struct {
+	Labels     MergePolicy
+	Env        MergePolicy
+	Entrypoint MergePolicy
+}
+
+// AdoptBaseLabels makes Rebase merge the labels of the new base image into those of the image with the given policy.
+func AdoptBaseLabels(policy MergePolicy) RebaseOption {
+	return func(o *RebaseOptions) {
+		o.Labels = policy
+	}
+}
+
+// AdoptBaseEnv makes Rebase merge the environment variables of the new base image into those of the image
+// with the given policy.
+func AdoptBaseEnv(policy MergePolicy) RebaseOption {
+	return func(o *RebaseOptions) {
+		o.Env = policy
+	}
+}
+
+// AdoptBaseEntrypoint makes Rebase use the entrypoint of the new base image with the given policy:
+// PreferImageValues sets it only if the image has no entrypoint, and PreferNewBaseValues sets it if the new base has one.
+func AdoptBaseEntrypoint(policy MergePolicy) RebaseOption {
+	return func(o *RebaseOptions) {
+		o.Entrypoint = policy
+	}
+}
+
+// GetRebaseOptions returns the options resulting from applying ops.
+func GetRebaseOptions(ops ...RebaseOption) RebaseOptions {
+	options := RebaseOptions{}
+	for _, op := range ops {
+		op(&options)
+	}
+	return options
+}
+
+// OptionsRebaser is implemented by images that can be rebased with options.
+// The local, remote, and layout images implement it; callers holding an Image type-assert for it,
+// or use RebaseWithOptions.
+type OptionsRebaser interface {
+	// RebaseWithOptions rebases the image as Rebase does, adopting the config values of the new base image selected by the options.
+	RebaseWithOptions(baseTopLayerDiffID string, newBase Image, ops ...RebaseOption) error
+}
+
+// RebaseWithOptions rebases img onto the new base image, adopting the config values selected by the options.
+// Without options, it is the same as img.Rebase; it is an error to give options for an image that isn't an OptionsRebaser.
+func RebaseWithOptions(img Image, baseTopLayerDiffID string, newBase Image, ops ...RebaseOption) error {
+	if len(ops) == 0 {
+		return img.Rebase(baseTopLayerDiffID, newBase)
+	}
+	rebaser, ok := img.(OptionsRebaser)
+	if !ok {
+		return fmt.Errorf("image of kind %q can't be rebased with options", img.Kind())
+	}
+	return rebaser.RebaseWithOptions(baseTopLayerDiffID, newBase, ops...)
+}
+
+// MergeLabels merges labels from the new base image into labels with the given policy, and returns the result.
+func MergeLabels(policy MergePolicy, labels, newBaseLabels map[string]string) map[string]string {
+	if policy == KeepImageValues || len(newBaseLabels) == 0 {
+		return labels
+	}
+	merged := make(map[string]string, len(labels)+len(newBaseLabels))
+	for key, val := range labels {
+		merged[key] = val
+	}
+	for key, val := range newBaseLabels {
+		if _, ok := merged[key]; ok && policy == PreferImageValues {
+			continue
+		}
+		merged[key] = val
+	}
+	return merged
+}
+
+// MergeEntrypoint returns the entrypoint resulting from adopting the entrypoint of the new base image with the given policy.
+func MergeEntrypoint(policy MergePolicy, entrypoint, newBaseEntrypoint []string) []string {
+	switch {
+	case len(newBaseEntrypoint) == 0:
+		return entrypoint
+	case policy == PreferNewBaseValues, policy == PreferImageValues && len(entrypoint) == 0:
+		return newBaseEntrypoint
+	default:
+		return entrypoint
+	}
+}
+
+// mergeConfig adopts the config values of the new base image selected by the options.
+func (o RebaseOptions) mergeConfig(c, newBase *v1.ConfigFile) {
+	c.Config.Labels = MergeLabels(o.Labels, c.Config.Labels, newBase.Config.Labels)
+	c.Config.Entrypoint = MergeEntrypoint(o.Entrypoint, c.Config.Entrypoint, newBase.Config.Entrypoint)
+	if o.Env == KeepImageValues {
+		return
+	}
+	ignoreCase := c.OS == "windows"
+	envKey := func(envVar string) string {
+		key := strings.SplitN(envVar, "=", 2)[0]
+		if ignoreCase {
+			return strings.ToUpper(key)
+		}
+		return key
+	}
+	indexes := map[string]int{}
+	for idx, envVar := range c.Config.Env {
+		indexes[envKey(envVar)] = idx
+	}
+	for _, envVar := range newBase.Config.Env {
+		idx, ok := indexes[envKey(envVar)]
+		switch {
+		case !ok:
+			indexes[envKey(envVar)] = len(c.Config.Env)
+			c.Config.Env = append(c.Config.Env, envVar)
+		case o.Env == PreferNewBaseValues:
+			c.Config.Env[idx] = envVar
+		}
+	}
+}
+
+type RebaseCheckOption func(*rebaseCheckOptions)
+
+type rebaseCheckOptions struct {
+	removedFilesLogger Logger
+}
+
+// WithRemovedFilesCheck compares the filesystems of the current and new base images, and reports to the logger
+// each directory that the app layers (the layers above the current base) add entries to,
+// that exists in the current base but is missing from the new base.
+// It reads every layer of both base images, so it can be slow for large images.
+func WithRemovedFilesCheck(logger Logger) RebaseCheckOption {
+	return func(o *rebaseCheckOptions) {
+		o.removedFilesLogger = logger
+	}
+}
+
+// ValidateRebase checks that img can be rebased onto newBase, given the diff ID of the top layer of its current base,
+// before performing the (destructive) Rebase.
+// It returns an error if the OS, architecture, or variant of newBase don't match those of img,
+// or if prevBaseTopLayer is not a layer of img.
+func ValidateRebase(img, newBase Image, prevBaseTopLayer string, ops ...RebaseCheckOption) error {
+	options := &rebaseCheckOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	configFile, err := img.UnderlyingImage().ConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to get config file: %w", err)
+	}
+	newBaseConfigFile, err := newBase.UnderlyingImage().ConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to get config file for new base image: %w", err)
+	}
+	if err = checkRebasePlatform(PlatformFromConfig(configFile), PlatformFromConfig(newBaseConfigFile)); err != nil {
+		return err
+	}
+
+	idx := indexOf(configFile.RootFS.DiffIDs, prevBaseTopLayer)
+	if idx < 0 {
+		return fmt.Errorf("failed to find base image top layer: %w", ErrLayerNotFound{DiffID: prevBaseTopLayer})
+	}
+
+	if options.removedFilesLogger == nil {
+		return nil
+	}
+	removed, err := removedBaseDirs(img, configFile, idx, newBase, newBaseConfigFile)
+	if err != nil {
+		return err
+	}
+	for _, dir := range removed {
+		options.removedFilesLogger.Warnf("new base image is missing directory %q, which is used by the app layers", dir)
+	}
+	return nil
+}
+
+func checkRebasePlatform(platform, newBasePlatform Platform) error {
+	if platform.OS != newBasePlatform.OS || platform.Architecture != newBasePlatform.Architecture ||
+		(platform.Variant != "" && newBasePlatform.Variant != "" && platform.Variant != newBasePlatform.Variant) {
+		return fmt.Errorf("new base image platform %s does not match image platform %s", newBasePlatform, platform)
+	}
+	return nil
+}
+
+// removedBaseDirs returns the sorted ancestor directories of the entries of the app layers (above topIdx)
+// that are neither created by the app layers, nor present in the new base, but are present in the current base.
+func removedBaseDirs(img Image, configFile *v1.ConfigFile, topIdx int, newBase Image, newBaseConfigFile *v1.ConfigFile) ([]string, error) {
+	walker := NewFileWalker(configFile, img.GetLayer)
+	newBaseWalker := NewFileWalker(newBaseConfigFile, newBase.GetLayer)
+
+	appWalker := FileWalker{Layers: walker.Layers[topIdx+1:], Windows: walker.Windows}
+	appPaths, err := walkPaths(appWalker, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app layers: %w", err)
+	}
+	needed := map[string]bool{}
+	for entry := range appPaths {
+		for dir := path.Dir(entry); dir != "/"; dir = path.Dir(dir) {
+			if !appPaths[dir] {
+				needed[dir] = true
+			}
+		}
+	}
+	if len(needed) == 0 {
+		return nil, nil
+	}
+
+	baseWalker := FileWalker{Layers: walker.Layers[:topIdx+1], Windows: walker.Windows}
+	basePaths, err := walkPaths(baseWalker, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image layers: %w", err)
+	}
+	newBasePaths, err := walkPaths(newBaseWalker, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read new base image layers: %w", err)
+	}
+
+	var removed []string
+	for dir := range needed {
+		if basePaths[dir] && !newBasePaths[dir] {
+			removed = append(removed, dir)
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// walkPaths returns the paths of the entries of the composed filesystem;
+// if withParents is true, it also includes their ancestor directories, as layers don't always hold entries for them.
+func walkPaths(walker FileWalker, withParents bool) (map[string]bool, error) {
+	paths := map[string]bool{}
+	err := walker.Walk(func(entry string, _ *tar.Header, _ io.Reader) error {
+		paths[entry] = true
+		for dir := path.Dir(entry); withParents && dir != "/"; dir = path.Dir(dir) {
+			paths[dir] = true
+		}
+		return nil
+	})
+	return paths, err
+}
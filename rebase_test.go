@@ -0,0 +1,148 @@
+package imgutil_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestRebase(t *testing.T) {
+	spec.Run(t, "Rebase", testRebase, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testRebase(t *testing.T, when spec.G, it spec.S) {
+	var (
+		appLayer    string
+		img         *testImage
+		baseTop     string
+		newBaseWith = func(platform imgutil.Platform, files map[string]string) *testImage {
+			newBase := newTestImage(t, imgutil.WithDefaultPlatform(platform))
+			addLayerWithFiles(t, newBase, files)
+			return newBase
+		}
+		linuxAMD64 = imgutil.Platform{OS: "linux", Architecture: "amd64"}
+	)
+
+	it.Before(func() {
+		img = newTestImage(t, imgutil.WithDefaultPlatform(linuxAMD64))
+		baseTop = addLayerWithFiles(t, img, map[string]string{"etc/some-file": "base", "usr/lib/some-lib": "base"})
+
+		// the app layer has no entries for its parent directories
+		var err error
+		appLayer, err = h.CreateSingleFileLayerTar("/etc/app/some-config", "app", "linux")
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.AddLayer(appLayer))
+	})
+
+	it.After(func() {
+		os.Remove(appLayer)
+	})
+
+	when("#ValidateRebase", func() {
+		it("accepts a new base with the same platform", func() {
+			newBase := newBaseWith(linuxAMD64, map[string]string{"etc/some-file": "new-base"})
+			h.AssertNil(t, imgutil.ValidateRebase(img, newBase, baseTop))
+		})
+
+		it("rejects a new base with another platform", func() {
+			newBase := newBaseWith(imgutil.Platform{OS: "linux", Architecture: "arm64"}, map[string]string{"etc/some-file": "new-base"})
+			err := imgutil.ValidateRebase(img, newBase, baseTop)
+			h.AssertError(t, err, "new base image platform linux/arm64 does not match image platform linux/amd64")
+		})
+
+		it("rejects a top layer that is not in the image", func() {
+			newBase := newBaseWith(linuxAMD64, map[string]string{"etc/some-file": "new-base"})
+			err := imgutil.ValidateRebase(img, newBase, "sha256:0000000000000000000000000000000000000000000000000000000000000000")
+			h.AssertError(t, err, "failed to find base image top layer")
+		})
+
+		when("#WithRemovedFilesCheck", func() {
+			it("warns about directories used by the app layers that are missing from the new base", func() {
+				newBase := newBaseWith(linuxAMD64, map[string]string{"usr/lib/some-lib": "new-base"})
+				logger := &recordingLogger{}
+				h.AssertNil(t, imgutil.ValidateRebase(img, newBase, baseTop, imgutil.WithRemovedFilesCheck(logger)))
+				h.AssertEq(t, logger.messages, []string{`WARN new base image is missing directory "/etc", which is used by the app layers`})
+			})
+
+			it("does not warn when the new base has the directories", func() {
+				newBase := newBaseWith(linuxAMD64, map[string]string{"etc/other-file": "new-base"})
+				logger := &recordingLogger{}
+				h.AssertNil(t, imgutil.ValidateRebase(img, newBase, baseTop, imgutil.WithRemovedFilesCheck(logger)))
+				h.AssertEq(t, len(logger.messages), 0)
+			})
+		})
+	})
+
+	when("#Rebase", func() {
+		it.Before(func() {
+			h.AssertNil(t, img.SetLabel("some.label", "image"))
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "image"))
+			h.AssertNil(t, img.SetEntrypoint("/image-entrypoint"))
+		})
+
+		rebaseOnto := func(ops ...imgutil.RebaseOption) {
+			newBase := newBaseWith(linuxAMD64, map[string]string{"etc/some-file": "new-base"})
+			h.AssertNil(t, newBase.SetLabel("some.label", "new-base"))
+			h.AssertNil(t, newBase.SetLabel("other.label", "new-base"))
+			h.AssertNil(t, newBase.SetEnv("SOME_KEY", "new-base"))
+			h.AssertNil(t, newBase.SetEnv("OTHER_KEY", "new-base"))
+			h.AssertNil(t, newBase.SetEntrypoint("/new-base-entrypoint"))
+			h.AssertNil(t, imgutil.RebaseWithOptions(img, baseTop, newBase, ops...))
+		}
+
+		it("keeps the config values of the image by default", func() {
+			rebaseOnto()
+
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels, map[string]string{"some.label": "image"})
+			val, err := img.Env("OTHER_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "")
+		})
+
+		it("adopts the missing values of the new base", func() {
+			rebaseOnto(
+				imgutil.AdoptBaseLabels(imgutil.PreferImageValues),
+				imgutil.AdoptBaseEnv(imgutil.PreferImageValues),
+				imgutil.AdoptBaseEntrypoint(imgutil.PreferImageValues),
+			)
+
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels, map[string]string{"some.label": "image", "other.label": "new-base"})
+			val, err := img.Env("SOME_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "image")
+			val, err = img.Env("OTHER_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "new-base")
+			entrypoint, err := img.Entrypoint()
+			h.AssertNil(t, err)
+			h.AssertEq(t, entrypoint, []string{"/image-entrypoint"})
+		})
+
+		it("replaces the values of the image with those of the new base", func() {
+			rebaseOnto(
+				imgutil.AdoptBaseLabels(imgutil.PreferNewBaseValues),
+				imgutil.AdoptBaseEnv(imgutil.PreferNewBaseValues),
+				imgutil.AdoptBaseEntrypoint(imgutil.PreferNewBaseValues),
+			)
+
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels, map[string]string{"some.label": "new-base", "other.label": "new-base"})
+			val, err := img.Env("SOME_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "new-base")
+			entrypoint, err := img.Entrypoint()
+			h.AssertNil(t, err)
+			h.AssertEq(t, entrypoint, []string{"/new-base-entrypoint"})
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package imgutil
+
+import (
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// SplitDigest splits a reference of the form name[:tag]@algorithm:hex into name[:tag] and the digest.
+// If ref has no valid digest, it is returned unchanged along with an empty digest.
+func SplitDigest(ref string) (string, string) {
+	idx := strings.LastIndex(ref, "@")
+	if idx < 0 {
+		return ref, ""
+	}
+	if _, err := v1.NewHash(ref[idx+1:]); err != nil {
+		return ref, ""
+	}
+	return ref[:idx], ref[idx+1:]
+}
+
+// DigestReference returns ref without its tag when it has both a tag and a digest,
+// so that it is always resolved by digest: registry/repo:tag@sha256:... becomes registry/repo@sha256:...
+// References without a digest are returned unchanged.
+func DigestReference(ref string) string {
+	base, digest := SplitDigest(ref)
+	if digest == "" {
+		return ref
+	}
+	if idx := strings.LastIndex(base, ":"); idx > strings.LastIndex(base, "/") {
+		base = base[:idx]
+	}
+	return base + "@" + digest
+}
@@ -0,0 +1,89 @@
+package remote_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestAccess(t *testing.T) {
+	spec.Run(t, "Access", testAccess, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testAccess(t *testing.T, when spec.G, it spec.S) {
+	var fakeRegistry *h.FakeRegistry
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry(h.WithBasicAuth("some-user", "some-password"))
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	newImage := func(keychain authn.Keychain) *remote.Image {
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), keychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertNil(t, err)
+		return img
+	}
+
+	when("the credentials are valid", func() {
+		it("can read and write the image", func() {
+			img := newImage(staticKeychain{&authn.Basic{Username: "some-user", Password: "some-password"}})
+
+			canRead, err := img.CheckReadAccess()
+			h.AssertNil(t, err)
+			h.AssertEq(t, canRead, true)
+			canWrite, err := img.CheckWriteAccess()
+			h.AssertNil(t, err)
+			h.AssertEq(t, canWrite, true)
+		})
+	})
+
+	when("the credentials are invalid", func() {
+		it("returns an access denied error", func() {
+			img := newImage(staticKeychain{&authn.Basic{Username: "some-user", Password: "wrong-password"}})
+
+			canRead, err := img.CheckReadAccess()
+			h.AssertEq(t, canRead, false)
+			var accessErr imgutil.AccessError
+			h.AssertEq(t, errors.As(err, &accessErr), true)
+			h.AssertEq(t, accessErr.Reason, imgutil.AccessDenied)
+			h.AssertEq(t, accessErr.Write, false)
+
+			canWrite, err := img.CheckWriteAccess()
+			h.AssertEq(t, canWrite, false)
+			h.AssertEq(t, errors.As(err, &accessErr), true)
+			h.AssertEq(t, accessErr.Reason, imgutil.AccessDenied)
+			h.AssertEq(t, accessErr.Write, true)
+		})
+	})
+
+	when("the registry cannot be reached", func() {
+		it("returns an unreachable error", func() {
+			img := newImage(authn.DefaultKeychain)
+			fakeRegistry.Close()
+
+			canRead, err := img.CheckReadAccess()
+			h.AssertEq(t, canRead, false)
+			var accessErr imgutil.AccessError
+			h.AssertEq(t, errors.As(err, &accessErr), true)
+			h.AssertEq(t, accessErr.Reason, imgutil.AccessUnreachable)
+		})
+	})
+}
+
+type staticKeychain struct {
+	auth authn.Authenticator
+}
+
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return k.auth, nil
+}
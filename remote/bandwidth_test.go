@@ -0,0 +1,69 @@
+package remote_test
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	spec.Run(t, "BandwidthLimit", testBandwidthLimit, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testBandwidthLimit(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		tmpDir       string
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+		var err error
+		tmpDir, err = os.MkdirTemp("", "remote-bandwidth")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	it("limits the rate at which blobs are pushed and pulled", func() {
+		const limit = 64 * 1024
+		layerPath, diffID := h.SeededRandomLayer(t, tmpDir, 1, 3*limit, 1)
+
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.WithBandwidthLimit(limit),
+		)
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.AddLayer(layerPath))
+
+		// the first second of transfer is allowed at once
+		start := time.Now()
+		h.AssertNil(t, img.Save())
+		h.AssertEq(t, time.Since(start) >= 1500*time.Millisecond, true)
+
+		pulled, err := remote.NewImage(fakeRegistry.RepoName("other-image"), authn.DefaultKeychain,
+			remote.FromBaseImage(fakeRegistry.RepoName("some-image")),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.WithBandwidthLimit(limit),
+		)
+		h.AssertNil(t, err)
+		start = time.Now()
+		rc, err := pulled.GetLayer(diffID)
+		h.AssertNil(t, err)
+		_, err = io.Copy(io.Discard, rc)
+		h.AssertNil(t, err)
+		h.AssertNil(t, rc.Close())
+		h.AssertEq(t, time.Since(start) >= 1500*time.Millisecond, true)
+	})
+}
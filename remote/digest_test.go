@@ -0,0 +1,120 @@
+package remote_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestPinnedDigest(t *testing.T) {
+	spec.Run(t, "PinnedDigest", testPinnedDigest, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testPinnedDigest(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		repoName     string
+		amd64Image   v1.Image
+		otherImage   v1.Image
+		amd64Digest  v1.Hash
+		otherDigest  v1.Hash
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+		repoName = fakeRegistry.RepoName("some-base-image")
+
+		var err error
+		amd64Image, err = random.Image(1024, 1)
+		h.AssertNil(t, err)
+		otherImage, err = random.Image(1024, 1)
+		h.AssertNil(t, err)
+		amd64Digest, err = amd64Image.Digest()
+		h.AssertNil(t, err)
+		otherDigest, err = otherImage.Digest()
+		h.AssertNil(t, err)
+		index := mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: amd64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+			mutate.IndexAddendum{Add: otherImage, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64", Variant: "v2"}}},
+		)
+		ref, err := name.ParseReference(repoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ggcrremote.WriteIndex(ref, index))
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	it("selects the manifest with the pinned digest from the index", func() {
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.FromBaseImage(repoName),
+			imgutil.WithDigest(otherDigest),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertNil(t, err)
+
+		topLayer, err := img.TopLayer()
+		h.AssertNil(t, err)
+		expectedConfig, err := otherImage.ConfigFile()
+		h.AssertNil(t, err)
+		h.AssertEq(t, topLayer, expectedConfig.RootFS.DiffIDs[0].String())
+
+		readOnly, err := remote.NewReadOnlyImage(repoName, authn.DefaultKeychain,
+			imgutil.WithDigest(otherDigest),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertNil(t, err)
+		identifier, err := readOnly.Identifier()
+		h.AssertNil(t, err)
+		h.AssertEq(t, identifier.String(), repoName+"@"+otherDigest.String())
+	})
+
+	it("selects the manifest matching the platform without a pinned digest", func() {
+		readOnly, err := remote.NewReadOnlyImage(repoName, authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertNil(t, err)
+		identifier, err := readOnly.Identifier()
+		h.AssertNil(t, err)
+		h.AssertEq(t, identifier.String(), repoName+"@"+amd64Digest.String())
+	})
+
+	it("fails if the index has no manifest with the pinned digest", func() {
+		missing, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+		missingDigest, err := missing.Digest()
+		h.AssertNil(t, err)
+
+		_, err = remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.FromBaseImage(repoName),
+			imgutil.WithDigest(missingDigest),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertError(t, err, "no child with digest "+missingDigest.String())
+	})
+
+	it("fails if the image does not have the pinned digest", func() {
+		imageRepoName := fakeRegistry.RepoName("some-single-image")
+		ref, err := name.ParseReference(imageRepoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ggcrremote.Write(ref, amd64Image))
+
+		_, err = remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.FromBaseImage(imageRepoName),
+			imgutil.WithDigest(otherDigest),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertError(t, err, "not the pinned digest "+otherDigest.String())
+	})
+}
@@ -0,0 +1,248 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/buildpacks/imgutil/layer"
+)
+
+const maxSymlinkHops = 255
+
+// errNotLazy is returned when a file can't be read lazily, because a layer above it is not an eStargz layer of the base image.
+var errNotLazy = errors.New("file can't be read lazily")
+
+// ReadFile returns the content of the file at the given absolute path in the image filesystem.
+// If the layers down to the file are eStargz layers of the base image, only their tables of contents
+// and the file itself are fetched from the registry; otherwise the layers are read entirely.
+func (i *Image) ReadFile(filePath string) ([]byte, error) {
+	content, err := i.readFileLazily(filePath)
+	if errors.Is(err, errNotLazy) {
+		return i.CNBImageCore.ReadFile(filePath)
+	}
+	return content, err
+}
+
+func (i *Image) readFileLazily(filePath string) ([]byte, error) {
+	estargzLayers, err := i.baseEstargzLayers()
+	if err != nil || len(estargzLayers) == 0 {
+		return nil, errNotLazy
+	}
+	manifest, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	readers := map[v1.Hash]*verifiedEstargz{}
+	target := path.Join("/", filePath)
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		entry, reader, err := i.lookupEstargz(manifest.Layers, estargzLayers, readers, target)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("failed to find file %q: %w", filePath, fs.ErrNotExist)
+		}
+		switch entry.Type {
+		case "reg":
+			return reader.readFile(target)
+		case "symlink":
+			target = entry.LinkName
+			if !path.IsAbs(target) {
+				target = path.Join(path.Dir(entry.Name), target)
+			}
+			target = path.Join("/", target)
+		default:
+			return nil, fmt.Errorf("%q is not a regular file", filePath)
+		}
+	}
+	return nil, fmt.Errorf("failed to read file %q: too many links", filePath)
+}
+
+// lookupEstargz returns the topmost entry for the target path, or nil if the path doesn't exist or is removed by a whiteout.
+func (i *Image) lookupEstargz(layers []v1.Descriptor, estargzLayers map[v1.Hash]v1.Descriptor, readers map[v1.Hash]*verifiedEstargz, target string) (*estargz.TOCEntry, *verifiedEstargz, error) {
+	for idx := len(layers) - 1; idx >= 0; idx-- {
+		desc, ok := estargzLayers[layers[idx].Digest]
+		if !ok {
+			return nil, nil, errNotLazy
+		}
+		reader, ok := readers[desc.Digest]
+		if !ok {
+			var err error
+			if reader, err = i.openEstargz(desc); err != nil {
+				return nil, nil, err
+			}
+			readers[desc.Digest] = reader
+		}
+
+		if entry, ok := reader.Lookup(target); ok {
+			return entry, reader, nil
+		}
+		if hiddenByEstargzLayer(reader.Reader, target) {
+			return nil, nil, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// hiddenByEstargzLayer reports if the layer removes the target path (or one of its parents) from the layers below it.
+func hiddenByEstargzLayer(reader *estargz.Reader, target string) bool {
+	if _, ok := reader.Lookup(path.Join("/", layer.OpaqueWhiteout)); ok {
+		return true
+	}
+	for p := target; p != "/"; p = path.Dir(p) {
+		whiteout := layer.Whiteout{Path: p}
+		if _, ok := reader.Lookup(whiteout.Name()); ok {
+			return true
+		}
+		if p == target {
+			continue
+		}
+		if _, ok := reader.Lookup(path.Join(p, layer.OpaqueWhiteout)); ok {
+			return true
+		}
+		if entry, ok := reader.Lookup(p); ok && entry.Type != "dir" {
+			return true
+		}
+	}
+	return false
+}
+
+// baseEstargzLayers returns the eStargz layers of the base image, by digest.
+func (i *Image) baseEstargzLayers() (map[v1.Hash]v1.Descriptor, error) {
+	if i.baseImage == nil {
+		return nil, nil
+	}
+	configFile, err := i.baseImage.ConfigFile()
+	if err != nil || configFile.OS == "windows" {
+		return nil, err
+	}
+	manifest, err := i.baseImage.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	estargzLayers := map[v1.Hash]v1.Descriptor{}
+	for _, desc := range manifest.Layers {
+		if desc.Annotations[layer.EstargzTOCDigestAnnotation] != "" {
+			estargzLayers[desc.Digest] = desc
+		}
+	}
+	return estargzLayers, nil
+}
+
+// verifiedEstargz is an eStargz layer whose table of contents matches the digest in the layer annotation.
+type verifiedEstargz struct {
+	*estargz.Reader
+	verifier estargz.TOCEntryVerifier
+}
+
+// readFile returns the content of the regular file, checking every chunk against its digest in the table of contents.
+func (v *verifiedEstargz) readFile(name string) ([]byte, error) {
+	sr, err := v.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, sr.Size())
+	for offset := int64(0); offset < sr.Size(); {
+		chunk, ok := v.ChunkEntryForOffset(name, offset)
+		if !ok || chunk.ChunkSize <= 0 || chunk.ChunkOffset+chunk.ChunkSize > sr.Size() {
+			return nil, fmt.Errorf("failed to find chunk of file %q at offset %d", name, offset)
+		}
+		chunkVerifier, err := v.verifier.Verifier(chunk)
+		if err != nil {
+			return nil, err
+		}
+		data := content[chunk.ChunkOffset : chunk.ChunkOffset+chunk.ChunkSize]
+		if _, err = sr.ReadAt(data, chunk.ChunkOffset); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if _, err = chunkVerifier.Write(data); err != nil {
+			return nil, err
+		}
+		if !chunkVerifier.Verified() {
+			return nil, fmt.Errorf("failed to verify file %q: digest of chunk at offset %d doesn't match the table of contents", name, chunk.ChunkOffset)
+		}
+		offset = chunk.ChunkOffset + chunk.ChunkSize
+	}
+	return content, nil
+}
+
+func (i *Image) openEstargz(desc v1.Descriptor) (*verifiedEstargz, error) {
+	tocDigest, err := digest.Parse(desc.Annotations[layer.EstargzTOCDigestAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse table of contents digest of eStargz layer %s: %w", desc.Digest, err)
+	}
+
+	reg := getRegistrySetting(i.baseImageRepoName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, i.baseImageRepoName, reg.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	repo := ref.Context()
+	rt, err := transport.NewWithContext(context.Background(), repo.Registry, auth, getTransport(reg.Insecure, i.transportConfig), []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, err
+	}
+	blob := &blobReaderAt{client: &http.Client{Transport: rt}, url: blobURL(repo, desc.Digest)}
+	reader, err := estargz.Open(io.NewSectionReader(blob, 0, desc.Size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open eStargz layer %s: %w", desc.Digest, err)
+	}
+	verifier, err := reader.VerifyTOC(tocDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify eStargz layer %s: %w", desc.Digest, err)
+	}
+	return &verifiedEstargz{Reader: reader, verifier: verifier}, nil
+}
+
+func blobURL(repo name.Repository, digest v1.Hash) string {
+	return fmt.Sprintf("%s://%s/v2/%s/blobs/%s", repo.Registry.Scheme(), repo.RegistryStr(), repo.RepositoryStr(), digest)
+}
+
+// blobReaderAt reads ranges of a blob with HTTP range requests.
+type blobReaderAt struct {
+	client *http.Client
+	url    string
+}
+
+func (b *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, b.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the registry doesn't support range requests
+		if _, err = io.CopyN(io.Discard, resp.Body, off); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("failed to read range of blob %s: unexpected status %s", b.url, resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,172 @@
+package remote_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layer"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestEstargz(t *testing.T) {
+	spec.Run(t, "Estargz", testEstargz, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testEstargz(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir        string
+		server        *httptest.Server
+		baseImageName string
+		mu            sync.Mutex
+		fullBlobReads int
+		tamperedBlobs map[string][]byte
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "remote-estargz")
+		h.AssertNil(t, err)
+
+		tamperedBlobs = map[string][]byte{}
+		handler := registry.New()
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			tampered, ok := tamperedBlobs[r.URL.Path]
+			mu.Unlock()
+			if ok {
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(tampered))
+				return
+			}
+			if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") && r.Header.Get("Range") == "" {
+				mu.Lock()
+				fullBlobReads++
+				mu.Unlock()
+			}
+			handler.ServeHTTP(w, r)
+		}))
+		host := strings.TrimPrefix(server.URL, "http://")
+		baseImageName = host + "/some-base-image"
+
+		dir := filepath.Join(tmpDir, "layer")
+		h.AssertNil(t, os.MkdirAll(filepath.Join(dir, "cnb"), 0755))
+		h.AssertNil(t, os.WriteFile(filepath.Join(dir, "cnb", "order.toml"), []byte("[[order]]"), 0600))
+		h.AssertNil(t, os.Symlink("order.toml", filepath.Join(dir, "cnb", "order-link.toml")))
+		l, err := layer.FromDirectory(dir)
+		h.AssertNil(t, err)
+		layerPath := filepath.Join(tmpDir, "layer.tar")
+		rc, err := l.Uncompressed()
+		h.AssertNil(t, err)
+		f, err := os.Create(layerPath)
+		h.AssertNil(t, err)
+		_, err = f.ReadFrom(rc)
+		h.AssertNil(t, err)
+		h.AssertNil(t, f.Close())
+		h.AssertNil(t, rc.Close())
+
+		baseImage, err := remote.NewImage(baseImageName, authn.DefaultKeychain,
+			remote.WithEstargzLayers(),
+			remote.WithRegistrySetting(host, true),
+		)
+		h.AssertNil(t, err)
+		if err = baseImage.AddLayer(layerPath); err != nil && strings.Contains(err.Error(), "footer buffer") {
+			t.Skipf("eStargz layers can't be built with this Go toolchain: %s", err)
+		}
+		h.AssertNil(t, err)
+		h.AssertNil(t, baseImage.Save())
+	})
+
+	it.After(func() {
+		server.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#WithEstargzLayers", func() {
+		it("saves layers with a table of contents", func() {
+			img, err := remote.NewImage(baseImageName, authn.DefaultKeychain,
+				remote.WithRegistrySetting(strings.TrimPrefix(server.URL, "http://"), true),
+				remote.FromBaseImage(baseImageName),
+			)
+			h.AssertNil(t, err)
+			manifest, err := img.UnderlyingImage().Manifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Layers), 1)
+			h.AssertNotEq(t, manifest.Layers[0].Annotations[layer.EstargzTOCDigestAnnotation], "")
+		})
+	})
+
+	when("#ReadFile", func() {
+		it("reads files from eStargz base layers with range requests", func() {
+			img, err := remote.NewImage("some-image", authn.DefaultKeychain,
+				remote.WithRegistrySetting(strings.TrimPrefix(server.URL, "http://"), true),
+				remote.FromBaseImage(baseImageName),
+			)
+			h.AssertNil(t, err)
+			fullBlobReads = 0
+
+			content, err := img.ReadFile("/cnb/order.toml")
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(content), "[[order]]")
+			content, err = img.ReadFile("/cnb/order-link.toml")
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(content), "[[order]]")
+			_, err = img.ReadFile("/cnb/missing.toml")
+			h.AssertError(t, err, "failed to find file")
+
+			h.AssertEq(t, fullBlobReads, 0)
+		})
+
+		it("fails when the registry serves tampered file contents", func() {
+			ref, err := name.ParseReference(baseImageName, name.Insecure)
+			h.AssertNil(t, err)
+			baseImage, err := ggcrremote.Image(ref)
+			h.AssertNil(t, err)
+			layers, err := baseImage.Layers()
+			h.AssertNil(t, err)
+			digest, err := layers[0].Digest()
+			h.AssertNil(t, err)
+			rc, err := layers[0].Compressed()
+			h.AssertNil(t, err)
+			blob, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertNil(t, rc.Close())
+
+			reader, err := estargz.Open(io.NewSectionReader(bytes.NewReader(blob), 0, int64(len(blob))))
+			h.AssertNil(t, err)
+			entry, ok := reader.Lookup("cnb/order.toml")
+			h.AssertEq(t, ok, true)
+			// change the compressed content of the file, keeping the table of contents
+			blob[entry.Offset+12] ^= 0x01
+			mu.Lock()
+			tamperedBlobs["/v2/some-base-image/blobs/"+digest.String()] = blob
+			mu.Unlock()
+
+			img, err := remote.NewImage("some-image", authn.DefaultKeychain,
+				remote.WithRegistrySetting(strings.TrimPrefix(server.URL, "http://"), true),
+				remote.FromBaseImage(baseImageName),
+			)
+			h.AssertNil(t, err)
+
+			content, err := img.ReadFile("/cnb/order.toml")
+			if err == nil {
+				t.Fatalf("expected tampered file contents to be rejected, got %q", content)
+			}
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"net/http"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// blobExistenceTransport answers the requests checking whether a blob exists from the cache when the blob was recorded,
+// and records the blobs found or uploaded in the registry.
+type blobExistenceTransport struct {
+	inner  http.RoundTripper
+	cache  imgutil.BlobExistenceCache
+	logger imgutil.Logger
+}
+
+func (t *blobExistenceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	repo, digest, ok := blobRequest(req)
+	if !ok {
+		return t.inner.RoundTrip(req)
+	}
+	if req.Method == http.MethodHead && t.cache.Exists(repo, digest) {
+		return &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Docker-Content-Digest": []string{digest.String()}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if (req.Method == http.MethodHead && resp.StatusCode == http.StatusOK) || resp.StatusCode == http.StatusCreated {
+		if err := t.cache.Record(repo, digest); err != nil {
+			t.logger.Debugf("failed to record blob %s in %s: %s", digest, repo, err)
+		}
+	}
+	return resp, nil
+}
+
+// knownBlobs holds blobs known to exist in a repository, without recording others.
+type knownBlobs struct {
+	repo    string
+	digests map[v1.Hash]bool
+}
+
+func (b *knownBlobs) Exists(repo string, digest v1.Hash) bool {
+	return repo == b.repo && b.digests[digest]
+}
+
+func (b *knownBlobs) Record(string, v1.Hash) error {
+	return nil
+}
+
+// previousLayers returns the layers of the previous image, which exist in the repository of the previous image,
+// so that reused layers are not checked for when the image is saved to the same repository.
+// Layers reused in another repository of the same registry are mounted from the repository of the previous image.
+func (i *Image) previousLayers() *knownBlobs {
+	if i.previousImage == nil || i.previousImageRepo == "" {
+		return nil
+	}
+	manifest, err := i.previousImage.Manifest()
+	if err != nil || len(manifest.Layers) == 0 {
+		return nil
+	}
+	known := &knownBlobs{repo: i.previousImageRepo, digests: map[v1.Hash]bool{}}
+	for _, layer := range manifest.Layers {
+		known.digests[layer.Digest] = true
+	}
+	return known
+}
+
+// blobRequest returns the repository and blob digest of the requests checking whether a blob exists (HEAD .../blobs/<digest>),
+// completing a blob upload (PUT .../blobs/uploads/<id>?digest=<digest>), or mounting a blob (POST .../blobs/uploads/?mount=<digest>).
+func blobRequest(req *http.Request) (string, v1.Hash, bool) {
+	repoPath, blobPath, found := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/"), "/blobs/")
+	if !found {
+		return "", v1.Hash{}, false
+	}
+	var digest string
+	switch {
+	case req.Method == http.MethodHead && !strings.HasPrefix(blobPath, "uploads/"):
+		digest = blobPath
+	case req.Method == http.MethodPut && strings.HasPrefix(blobPath, "uploads/"):
+		digest = req.URL.Query().Get("digest")
+	case req.Method == http.MethodPost && strings.HasPrefix(blobPath, "uploads/"):
+		digest = req.URL.Query().Get("mount")
+	}
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		return "", v1.Hash{}, false
+	}
+	return req.URL.Host + "/" + repoPath, hash, true
+}
+
+// layersPresentIn reports whether all the layers of the image are known to exist in the given repository,
+// because WithAssumeLayersPresent was provided, or they are recorded in the blob existence cache or are layers of the previous image.
+func (i *Image) layersPresentIn(repo string) bool {
+	if i.assumeLayersPresent {
+		return true
+	}
+	manifest, err := i.CNBImageCore.Manifest()
+	if err != nil {
+		return false
+	}
+	previousLayers := i.previousLayers()
+	for _, layer := range manifest.Layers {
+		switch {
+		case i.blobExistenceCache != nil && i.blobExistenceCache.Exists(repo, layer.Digest):
+		case previousLayers != nil && previousLayers.Exists(repo, layer.Digest):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package remote_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	"github.com/buildpacks/imgutil/signature"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestFinalize(t *testing.T) {
+	spec.Run(t, "Finalize", testFinalize, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testFinalize(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		img          *remote.Image
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+		var err error
+		img, err = remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain)
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.SetLabel("some-key", "some-value"))
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	it("pushes the image with the digest signed between the phases", func() {
+		digest, err := img.Finalize()
+		h.AssertNil(t, err)
+		h.AssertEq(t, digest.Context().String(), fakeRegistry.RepoName("some-image"))
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		h.AssertNil(t, err)
+		h.AssertNil(t, signature.Attach(digest, signature.NewSigner(key)))
+		h.AssertNil(t, img.Push())
+
+		pushed, err := ggcrremote.Head(digest)
+		h.AssertNil(t, err)
+		h.AssertEq(t, pushed.Digest.String(), digest.DigestStr())
+		h.AssertNil(t, signature.Verify(digest, signature.NewVerifier(&key.PublicKey)))
+	})
+
+	it("fails if the image was not finalized", func() {
+		h.AssertError(t, img.Push(), "image must be finalized before it is pushed")
+	})
+
+	it("fails if the image was modified after it was finalized", func() {
+		digest, err := img.Finalize()
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.SetLabel("some-key", "other-value"))
+
+		h.AssertError(t, img.Push(), "image was modified after it was finalized")
+		_, err = ggcrremote.Head(digest)
+		h.AssertEq(t, err != nil, true)
+	})
+}
@@ -1,13 +1,67 @@
 package remote
 
 import (
+	"strings"
+
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
 )
 
+// DigestIdentifier identifies an image in a registry by the digest of its manifest.
+// Tag is the tag the image was referenced with, if any.
 type DigestIdentifier struct {
 	Digest name.Digest
+	Tag    string
+}
+
+// ParseDigestIdentifier parses an identifier of the form `<repository>[:<tag>]@<digest>`,
+// e.g. the string representation of a DigestIdentifier.
+func ParseDigestIdentifier(identifier string) (DigestIdentifier, error) {
+	digest, err := name.NewDigest(identifier, name.WeakValidation)
+	if err != nil {
+		return DigestIdentifier{}, errors.Wrapf(err, "parsing identifier %q", identifier)
+	}
+	base, _, _ := strings.Cut(identifier, "@")
+	return DigestIdentifier{
+		Digest: digest.Context().Digest(digest.DigestStr()),
+		Tag:    explicitTag(base),
+	}, nil
+}
+
+func newDigestIdentifier(repoName string, ref name.Reference, hash v1.Hash) DigestIdentifier {
+	base, _, _ := strings.Cut(repoName, "@")
+	return DigestIdentifier{
+		Digest: ref.Context().Digest(hash.String()),
+		Tag:    explicitTag(base),
+	}
+}
+
+// explicitTag returns the tag of the given reference, or "" if the reference doesn't have one
+// (rather than the default `latest` tag).
+func explicitTag(ref string) string {
+	tag, err := name.NewTag(ref, name.WeakValidation)
+	if err != nil || !strings.HasSuffix(ref, ":"+tag.TagStr()) {
+		return ""
+	}
+	return tag.TagStr()
 }
 
 func (d DigestIdentifier) String() string {
 	return d.Digest.String()
 }
+
+// Registry returns the registry of the image, e.g. `index.docker.io`.
+func (d DigestIdentifier) Registry() string {
+	return d.Digest.RegistryStr()
+}
+
+// Repository returns the repository of the image within its registry, e.g. `library/ubuntu`.
+func (d DigestIdentifier) Repository() string {
+	return d.Digest.RepositoryStr()
+}
+
+// Hash returns the digest of the image manifest.
+func (d DigestIdentifier) Hash() (v1.Hash, error) {
+	return v1.NewHash(d.Digest.DigestStr())
+}
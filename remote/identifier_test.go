@@ -0,0 +1,69 @@
+package remote_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestDigestIdentifier(t *testing.T) {
+	spec.Run(t, "DigestIdentifier", testDigestIdentifier, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDigestIdentifier(t *testing.T, when spec.G, it spec.S) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	when("#ParseDigestIdentifier", func() {
+		it("parses the registry, repository, tag, and digest", func() {
+			identifier, err := remote.ParseDigestIdentifier("registry.example.com:5000/some/repo:some-tag@" + digest)
+			h.AssertNil(t, err)
+			h.AssertEq(t, identifier.Registry(), "registry.example.com:5000")
+			h.AssertEq(t, identifier.Repository(), "some/repo")
+			h.AssertEq(t, identifier.Tag, "some-tag")
+			hash, err := identifier.Hash()
+			h.AssertNil(t, err)
+			h.AssertEq(t, hash.String(), digest)
+			h.AssertEq(t, identifier.String(), "registry.example.com:5000/some/repo@"+digest)
+		})
+
+		it("defaults the registry and leaves the tag empty", func() {
+			identifier, err := remote.ParseDigestIdentifier("ubuntu@" + digest)
+			h.AssertNil(t, err)
+			h.AssertEq(t, identifier.Registry(), "index.docker.io")
+			h.AssertEq(t, identifier.Repository(), "library/ubuntu")
+			h.AssertEq(t, identifier.Tag, "")
+		})
+
+		it("fails without a digest", func() {
+			_, err := remote.ParseDigestIdentifier("registry.example.com/some/repo:some-tag")
+			h.AssertError(t, err, `parsing identifier "registry.example.com/some/repo:some-tag"`)
+		})
+	})
+
+	when("#Identifier", func() {
+		it("records the tag the image was saved with", func() {
+			fakeRegistry := h.NewFakeRegistry()
+			defer fakeRegistry.Close()
+			img, err := remote.NewImage(fakeRegistry.RepoName("some-image:some-tag"), authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			identifier, err := img.Identifier()
+			h.AssertNil(t, err)
+			digestIdentifier, ok := identifier.(remote.DigestIdentifier)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, digestIdentifier.Registry(), fakeRegistry.Host)
+			h.AssertEq(t, digestIdentifier.Repository(), "some-image")
+			h.AssertEq(t, digestIdentifier.Tag, "some-tag")
+			parsed, err := remote.ParseDigestIdentifier(fakeRegistry.RepoName("some-image:some-tag") + "@" + digestIdentifier.Digest.DigestStr())
+			h.AssertNil(t, err)
+			h.AssertEq(t, parsed.String(), identifier.String())
+		})
+	})
+}
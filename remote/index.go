@@ -0,0 +1,133 @@
+package remote
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// SaveIndex pushes the index to the given reference, and returns the digest reference of the pushed index.
+// Before the index is pushed, the manifests it references are checked to exist in the repository,
+// so that a pushed index never references manifests missing from the registry:
+// with WithPushChildren, the missing manifests (and their blobs) are pushed first, otherwise an error naming them is returned.
+func SaveIndex(repoName string, index v1.ImageIndex, keychain authn.Keychain, ops ...imgutil.ImageOption) (digestRef name.Digest, err error) {
+	options := &imgutil.ImageOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+	reg := getRegistrySetting(repoName, options.RegistrySettings)
+	ref, auth, err := referenceForRepoName(keychain, repoName, reg.Insecure)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	rt, finishRecording := newHTTPRecorder(*options).start("push", repoName, getTransport(reg.Insecure, newTransportConfig(*options)))
+	defer func() { finishRecording(err) }()
+	remoteOps := []remote.Option{remote.WithAuth(auth), remote.WithTransport(rt)}
+
+	if err = ensureIndexChildren(ref.Context(), index, options.PushChildren, remoteOps); err != nil {
+		return name.Digest{}, err
+	}
+	if err = remote.Put(ref, index, remoteOps...); err != nil {
+		return name.Digest{}, errors.Wrapf(err, "pushing index %q", repoName)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		return name.Digest{}, errors.Wrapf(err, "getting digest for index %q", repoName)
+	}
+	return ref.Context().Digest(digest.String()), nil
+}
+
+// SaveIndexAs pushes the index to every given fully-qualified reference, which may be in different repositories or registries,
+// as Image.Save does with its additional names. The options apply to every reference, so with WithPushChildren
+// the manifests missing from each repository are pushed to it before the index.
+// If any reference fails, an imgutil.SaveError is returned with a diagnostic for each failed reference
+// and the digest references of the index for the references it was pushed to.
+func SaveIndexAs(index v1.ImageIndex, keychain authn.Keychain, names []string, ops ...imgutil.ImageOption) error {
+	var (
+		diagnostics []imgutil.SaveDiagnostic
+		saved       = map[string]string{}
+	)
+	for _, n := range names {
+		digestRef, err := SaveIndex(n, index, keychain, ops...)
+		if err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Reason: saveFailure(err), Cause: err})
+			continue
+		}
+		saved[n] = digestRef.DigestStr()
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics, Saved: saved}
+	}
+	return nil
+}
+
+// ensureIndexChildren checks that the manifests referenced by the index exist in the repository,
+// pushing the missing ones if push is set. Nested indexes that are pushed have their own children ensured first.
+func ensureIndexChildren(repo name.Repository, index v1.ImageIndex, push bool, remoteOps []remote.Option) error {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return errors.Wrap(err, "getting index manifest")
+	}
+	var missing []string
+	for _, desc := range indexManifest.Manifests {
+		childRef := repo.Digest(desc.Digest.String())
+		exists, err := manifestExists(childRef, remoteOps)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if !push {
+			missing = append(missing, desc.Digest.String())
+			continue
+		}
+		switch {
+		case desc.MediaType.IsImage():
+			image, err := index.Image(desc.Digest)
+			if err != nil {
+				return errors.Wrapf(err, "getting image %s of index", desc.Digest)
+			}
+			if err = remote.Write(childRef, image, remoteOps...); err != nil {
+				return errors.Wrapf(err, "pushing image %s of index", desc.Digest)
+			}
+		case desc.MediaType.IsIndex():
+			child, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return errors.Wrapf(err, "getting index %s of index", desc.Digest)
+			}
+			if err = ensureIndexChildren(repo, child, push, remoteOps); err != nil {
+				return err
+			}
+			if err = remote.Put(childRef, child, remoteOps...); err != nil {
+				return errors.Wrapf(err, "pushing index %s of index", desc.Digest)
+			}
+		default:
+			missing = append(missing, desc.Digest.String())
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("index references manifests missing from repository %q: %s", repo.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func manifestExists(ref name.Digest, remoteOps []remote.Option) (bool, error) {
+	_, err := remote.Head(ref, remoteOps...)
+	if err == nil {
+		return true, nil
+	}
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, errors.Wrapf(err, "checking manifest %q", ref)
+}
@@ -0,0 +1,90 @@
+package remote_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestUnderlyingIndex(t *testing.T) {
+	spec.Run(t, "UnderlyingIndex", testUnderlyingIndex, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testUnderlyingIndex(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		repoName     string
+		amd64Image   v1.Image
+		index        v1.ImageIndex
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+		repoName = fakeRegistry.RepoName("some-base-image")
+
+		var err error
+		amd64Image, err = random.Image(1024, 1)
+		h.AssertNil(t, err)
+		arm64Image, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+		index = mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: amd64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+			mutate.IndexAddendum{Add: arm64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		)
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	it("returns the index the base image was selected from", func() {
+		ref, err := name.ParseReference(repoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ggcrremote.WriteIndex(ref, index))
+
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.FromBaseImage(repoName),
+			remote.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "amd64"}),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, img.UnderlyingIndex() != nil, true)
+		indexDigest, err := img.UnderlyingIndex().Digest()
+		h.AssertNil(t, err)
+		expectedIndexDigest, err := index.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, indexDigest, expectedIndexDigest)
+		topLayer, err := img.TopLayer()
+		h.AssertNil(t, err)
+		expectedConfig, err := amd64Image.ConfigFile()
+		h.AssertNil(t, err)
+		h.AssertEq(t, topLayer, expectedConfig.RootFS.DiffIDs[0].String())
+	})
+
+	it("returns nil when the base image is not referenced by an index", func() {
+		ref, err := name.ParseReference(repoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ggcrremote.Write(ref, amd64Image))
+
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.FromBaseImage(repoName),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, img.UnderlyingIndex() == nil, true)
+	})
+}
@@ -0,0 +1,62 @@
+package remote
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// write writes the image to ref, reporting the uploaded bytes to the image's instrumentation.
+func (i *Image) write(ref name.Reference, opts ...remote.Option) error {
+	updates := make(chan v1.Update, 16)
+	uploaded := make(chan int64)
+	go func() {
+		var complete int64
+		for update := range updates {
+			complete = update.Complete
+		}
+		uploaded <- complete
+	}()
+
+	finish := imgutil.StartOperation(i.instrumentation, imgutil.OperationLayerUpload, ref.Name())
+	err := remote.Write(ref, i.CNBImageCore, append(opts, remote.WithProgress(updates))...)
+	finish(<-uploaded, err)
+	return err
+}
+
+// countingTransport counts the bytes of the response bodies it reads.
+type countingTransport struct {
+	inner http.RoundTripper
+	count int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	resp.Body = &countingReadCloser{ReadCloser: resp.Body, count: &t.count}
+	return resp, nil
+}
+
+// Count returns the number of bytes read so far.
+func (t *countingTransport) Count() int64 {
+	return atomic.LoadInt64(&t.count)
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	count *int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.count, int64(n))
+	return n, err
+}
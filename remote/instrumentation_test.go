@@ -0,0 +1,98 @@
+package remote_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestInstrumentation(t *testing.T) {
+	spec.Run(t, "Instrumentation", testInstrumentation, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testInstrumentation(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir  string
+		server  *httptest.Server
+		host    string
+		records *recordingInstrumentation
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "remote-instrumentation")
+		h.AssertNil(t, err)
+		server = httptest.NewServer(registry.New())
+		host = strings.TrimPrefix(server.URL, "http://")
+		records = &recordingInstrumentation{}
+	})
+
+	it.After(func() {
+		server.Close()
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	it("reports layer uploads and pulls", func() {
+		img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain,
+			remote.WithRegistrySetting(host, true),
+			imgutil.WithInstrumentation(records),
+		)
+		h.AssertNil(t, err)
+		layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+		h.AssertNil(t, err)
+		defer os.Remove(layerPath)
+		h.AssertNil(t, img.AddLayer(layerPath))
+		h.AssertNil(t, img.Save())
+
+		h.AssertEq(t, len(records.results), 1)
+		h.AssertEq(t, records.ops[0], imgutil.OperationLayerUpload)
+		h.AssertEq(t, records.names[0], host+"/some-image:latest")
+		h.AssertNil(t, records.results[0].Err)
+		if records.results[0].Bytes <= 0 {
+			t.Fatalf("expected uploaded bytes to be reported, got %d", records.results[0].Bytes)
+		}
+
+		_, err = remote.NewImage(host+"/other-image", authn.DefaultKeychain,
+			remote.WithRegistrySetting(host, true),
+			remote.FromBaseImage(host+"/some-image"),
+			imgutil.WithInstrumentation(records),
+		)
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, len(records.results), 2)
+		h.AssertEq(t, records.ops[1], imgutil.OperationPull)
+		h.AssertEq(t, records.names[1], host+"/some-image")
+		h.AssertNil(t, records.results[1].Err)
+		if records.results[1].Bytes <= 0 {
+			t.Fatalf("expected pulled bytes to be reported, got %d", records.results[1].Bytes)
+		}
+	})
+}
+
+type recordingInstrumentation struct {
+	mu      sync.Mutex
+	ops     []imgutil.Operation
+	names   []string
+	results []imgutil.OperationResult
+}
+
+func (r *recordingInstrumentation) Start(op imgutil.Operation, name string) func(imgutil.OperationResult) {
+	return func(result imgutil.OperationResult) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ops = append(r.ops, op)
+		r.names = append(r.names, name)
+		r.results = append(r.results, result)
+	}
+}
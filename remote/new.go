@@ -21,23 +21,40 @@ import (
 
 // NewImage returns a new image that can be modified and saved to an OCI image registry.
 func NewImage(repoName string, keychain authn.Keychain, ops ...imgutil.ImageOption) (*Image, error) {
-	options := &imgutil.ImageOptions{}
-	for _, op := range ops {
-		op(options)
+	options, err := imgutil.NewImageOptions(ops...)
+	if err != nil {
+		return nil, err
 	}
 
 	options.Platform = processPlatformOption(options.Platform)
+	logger := imgutil.GetLogger(*options)
 
-	var err error
-	options.PreviousImage, err = processImageOption(options.PreviousImageRepoName, keychain, options.Platform, options.RegistrySettings)
-	if err != nil {
-		return nil, err
+	config := newTransportConfig(*options)
+	if options.PreviousImageRepoName != "" {
+		options.PreviousImage, _, err = processImageOption(options.PreviousImageRepoName, v1.Hash{}, keychain, *options, config)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	options.BaseImage, err = processImageOption(options.BaseImageRepoName, keychain, options.Platform, options.RegistrySettings)
-	if err != nil {
+	var baseIndex v1.ImageIndex
+	if options.BaseImageRepoName != "" || options.BaseImage == nil {
+		options.BaseImage, baseIndex, err = processImageOption(options.BaseImageRepoName, options.PinnedDigest, keychain, *options, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = options.VerifyImages(); err != nil {
+		return nil, err
+	}
+	if err = options.CheckBasePlatform(); err != nil {
+		return nil, err
+	}
+	if err = options.RecordBaseImage(); err != nil {
 		return nil, err
 	}
+	options.CacheImages()
+	baseImage := options.BaseImage
 	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
 	if options.BaseImage != nil {
 		options.BaseImage, _, err = imgutil.EnsureMediaTypesAndLayers(options.BaseImage, options.MediaTypes, imgutil.PreserveLayers)
@@ -56,10 +73,87 @@ func NewImage(repoName string, keychain authn.Keychain, ops ...imgutil.ImageOpti
 		repoName:            repoName,
 		keychain:            keychain,
 		addEmptyLayerOnSave: options.AddEmptyLayerOnSave,
+		assumeLayersMissing: options.AssumeLayersMissing,
+		assumeLayersPresent: options.AssumeLayersPresent,
+		blobExistenceCache:  options.BlobExistenceCache,
+		overwritePolicy:     options.OverwritePolicy,
+		recorder:            newHTTPRecorder(*options),
+		transportConfig:     config,
+		saveTimeout:         options.SaveTimeout,
 		registrySettings:    options.RegistrySettings,
+		baseImage:           baseImage,
+		baseIndex:           baseIndex,
+		baseImageRepoName:   options.BaseImageRepoName,
+		previousImage:       options.PreviousImage,
+		previousImageRepo:   previousImageRepo(options.PreviousImageRepoName, options.RegistrySettings),
+		logger:              logger,
+		instrumentation:     imgutil.GetInstrumentation(*options),
 	}, nil
 }
 
+// CopyDestination makes imgutil.Copy create the copies of images to the registry with the given keychain.
+func CopyDestination(keychain authn.Keychain) imgutil.CopyOption {
+	return imgutil.WithCopyDestination("remote", func(name string, base v1.Image, ops ...imgutil.ImageOption) (imgutil.Image, error) {
+		return NewImage(name, keychain, append([]imgutil.ImageOption{imgutil.FromBaseImageInstance(base)}, ops...)...)
+	})
+}
+
+// NewReadOnlyImage returns the image with the given name for inspection, selected from an index according to the platform option
+// (or the digest option).
+// Unlike NewImage, it only fetches the manifest and config of the image, without preparing it to be modified.
+func NewReadOnlyImage(repoName string, keychain authn.Keychain, ops ...imgutil.ImageOption) (image imgutil.ReadOnlyImage, err error) {
+	// the image is read as a base image, so that the digest option selects it from the index with the given name
+	options, err := imgutil.NewImageOptions(append([]imgutil.ImageOption{imgutil.FromBaseImage(repoName)}, ops...)...)
+	if err != nil {
+		return nil, err
+	}
+	reg := getRegistrySetting(repoName, options.RegistrySettings)
+	ref, auth, err := referenceForRepoName(keychain, repoName, reg.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	rt, finishRecording := newHTTPRecorder(*options).start("pull", repoName, getTransport(reg.Insecure, newTransportConfig(*options)))
+	defer func() { finishRecording(err) }()
+
+	fetched, _, err := fetchImage(ref, options.PinnedDigest,
+		remote.WithAuth(auth),
+		remote.WithPlatform(processPlatformOption(options.Platform).V1()),
+		remote.WithTransport(rt),
+	)
+	if err != nil {
+		if transportErr, ok := err.(*transport.Error); ok && transportErr.StatusCode == http.StatusNotFound {
+			return imgutil.NewReadOnlyImage(repoName, nil, nil), nil
+		}
+		return nil, errors.Wrapf(err, "connect to repo store %q", repoName)
+	}
+	configFile, err := fetched.ConfigFile()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting config for image %q", repoName)
+	}
+	digest, err := fetched.Digest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting digest for image %q", repoName)
+	}
+	return imgutil.NewReadOnlyImage(repoName, configFile, newDigestIdentifier(repoName, ref, digest)), nil
+}
+
+// previousImageRepo returns the repository of the previous image, named with its registry, or an empty string.
+func previousImageRepo(repoName string, registrySettings map[string]imgutil.RegistrySetting) string {
+	if repoName == "" {
+		return ""
+	}
+	reg := getRegistrySetting(repoName, registrySettings)
+	opts := []name.Option{name.WeakValidation}
+	if reg.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	ref, err := name.ParseReference(repoName, opts...)
+	if err != nil {
+		return ""
+	}
+	return ref.Context().String()
+}
+
 func defaultPlatform() imgutil.Platform {
 	return imgutil.Platform{
 		OS:           "linux",
@@ -68,54 +162,113 @@ func defaultPlatform() imgutil.Platform {
 }
 
 func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform {
-	if (requestedPlatform != imgutil.Platform{}) {
+	if !requestedPlatform.IsZero() {
 		return requestedPlatform
 	}
 	return defaultPlatform()
 }
 
-func processImageOption(repoName string, keychain authn.Keychain, withPlatform imgutil.Platform, withRegistrySettings map[string]imgutil.RegistrySetting) (v1.Image, error) {
+// processImageOption fetches the image with the given name, and the index it was selected from if the name refers to an index.
+// The image is selected from the index by the given digest if it is not empty (see fetchImage).
+// The layers of the image are fetched later with a transport configured by the given configuration.
+func processImageOption(repoName string, digest v1.Hash, keychain authn.Keychain, options imgutil.ImageOptions, config transportConfig) (image v1.Image, index v1.ImageIndex, err error) {
 	if repoName == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
+	withPlatform := options.Platform
+	logger := imgutil.GetLogger(options)
 
-	platform := v1.Platform{
-		Architecture: withPlatform.Architecture,
-		OS:           withPlatform.OS,
-		OSVersion:    withPlatform.OSVersion,
-	}
-	reg := getRegistrySetting(repoName, withRegistrySettings)
+	platform := withPlatform.V1()
+	reg := getRegistrySetting(repoName, options.RegistrySettings)
 	ref, auth, err := referenceForRepoName(keychain, repoName, reg.Insecure)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var image v1.Image
+	rt, finishRecording := newHTTPRecorder(options).start("pull", repoName, getTransport(reg.Insecure, config))
+	defer func() { finishRecording(err) }()
+	counter := &countingTransport{inner: rt}
+	finish := imgutil.StartOperation(imgutil.GetInstrumentation(options), imgutil.OperationPull, repoName)
+	defer func() { finish(counter.Count(), err) }()
+
 	for i := 0; i <= maxRetries; i++ {
 		time.Sleep(100 * time.Duration(i) * time.Millisecond) // wait if retrying
-		image, err = remote.Image(ref,
+		image, index, err = fetchImage(ref, digest,
 			remote.WithAuth(auth),
 			remote.WithPlatform(platform),
-			remote.WithTransport(getTransport(reg.Insecure)),
+			remote.WithTransport(counter),
 		)
+		if errors.Is(err, remote.ErrSchema1) {
+			logger.Warnf("image %q has a deprecated v2 schema 1 manifest, converting it to v2 schema 2", repoName)
+			image, err = schema1Image(ref,
+				remote.WithAuth(auth),
+				remote.WithTransport(counter),
+			)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "convert schema 1 image %q", repoName)
+			}
+		}
 		if err != nil {
 			if err == io.EOF && i != maxRetries {
+				logger.Warnf("failed to fetch image %q, retrying: %s", repoName, err)
 				continue // retry if EOF
 			}
 			if transportErr, ok := err.(*transport.Error); ok && len(transportErr.Errors) > 0 {
 				switch transportErr.StatusCode {
 				case http.StatusNotFound, http.StatusUnauthorized:
-					return emptyImage(withPlatform)
+					logger.Debugf("image %q not found (%d), using an empty image", repoName, transportErr.StatusCode)
+					image, err = emptyImage(withPlatform)
+					return image, nil, err
 				}
 			}
 			if strings.Contains(err.Error(), "no child with platform") {
-				return emptyImage(withPlatform)
+				logger.Debugf("image %q has no manifest for platform %s/%s, using an empty image", repoName, platform.OS, platform.Architecture)
+				image, err = emptyImage(withPlatform)
+				return image, nil, err
 			}
-			return nil, errors.Wrapf(err, "connect to repo store %q", repoName)
+			return nil, nil, errors.Wrapf(err, "connect to repo store %q", repoName)
 		}
 		break
 	}
-	return image, nil
+	if digest, err := image.Digest(); err == nil {
+		logger.Debugf("resolved image %q to %s", repoName, digest)
+	}
+	return image, index, nil
+}
+
+// fetchImage fetches the image with the given reference. If the reference is that of an index, the image is selected from it
+// by the given digest if it is not empty, or according to the platform option otherwise, and the index is returned with the image.
+// If the reference is that of an image, its digest must be the given digest if it is not empty.
+func fetchImage(ref name.Reference, digest v1.Hash, opts ...remote.Option) (v1.Image, v1.ImageIndex, error) {
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	pinned := digest != (v1.Hash{}) && digest != desc.Digest
+	if !desc.MediaType.IsIndex() {
+		if pinned {
+			return nil, nil, errors.Errorf("image %q has digest %s, not the pinned digest %s", ref.Name(), desc.Digest, digest)
+		}
+		image, err := desc.Image()
+		if err != nil {
+			return nil, nil, err
+		}
+		return image, nil, nil
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	var image v1.Image
+	if pinned {
+		image, err = index.Image(digest)
+	} else {
+		image, err = desc.Image()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return image, index, nil
 }
 
 func getRegistrySetting(forRepoName string, givenSettings map[string]imgutil.RegistrySetting) imgutil.RegistrySetting {
@@ -154,6 +307,8 @@ func emptyImage(platform imgutil.Platform) (v1.Image, error) {
 		History:      []v1.History{},
 		OS:           platform.OS,
 		OSVersion:    platform.OSVersion,
+		Variant:      platform.Variant,
+		OSFeatures:   platform.OSFeatures,
 		RootFS: v1.RootFS{
 			Type:    "layers",
 			DiffIDs: []v1.Hash{},
@@ -173,5 +328,6 @@ func NewV1Image(baseImageRepoName string, keychain authn.Keychain, ops ...func(*
 		op(options)
 	}
 	options.Platform = processPlatformOption(options.Platform)
-	return processImageOption(baseImageRepoName, keychain, options.Platform, options.RegistrySettings)
+	image, _, err := processImageOption(baseImageRepoName, options.PinnedDigest, keychain, *options, newTransportConfig(*options))
+	return image, err
 }
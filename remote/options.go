@@ -17,6 +17,95 @@ func AddEmptyLayerOnSave() func(*imgutil.ImageOptions) {
 	}
 }
 
+// WithAssumeLayersMissing skips checking whether the blobs of the image exist in the repository before uploading them,
+// saving a round trip per blob when pushing to a new repository.
+// Blobs that already exist in the repository are uploaded again.
+func WithAssumeLayersMissing() func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.AssumeLayersMissing = true
+	}
+}
+
+// WithAssumeLayersPresent asserts that all the layers of the image exist in the repositories it is saved to
+// (e.g. after a rebase or a metadata-only change), so that saving the image pushes only its config and manifest.
+// The layers are not checked for, so saving an image with a missing layer results in an invalid image.
+func WithAssumeLayersPresent() func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.AssumeLayersPresent = true
+	}
+}
+
+// WithBandwidthLimit limits the rate at which the blobs of the image (e.g. layers) are pushed and pulled to the given bytes per second,
+// so that builds on shared runners don't saturate the network. The limit is shared by all the transfers of the image.
+func WithBandwidthLimit(bytesPerSec int64) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.BandwidthLimit = bytesPerSec
+	}
+}
+
+// WithBlobExistenceCache lets a caller provide a cache of the blobs known to exist in registry repositories
+// (e.g. an existcache.Cache shared between builds).
+// When the image is saved, blobs recorded in the cache are not checked for,
+// and blobs found or uploaded in the repository are recorded.
+func WithBlobExistenceCache(c imgutil.BlobExistenceCache) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.BlobExistenceCache = c
+	}
+}
+
+// WithBlobTimeout sets how long transferring each blob (e.g. a layer) to or from the registry may take,
+// from sending the request until the response body is read.
+// A blob that takes longer fails the operation with an imgutil.BlobTimeoutError naming the blob.
+func WithBlobTimeout(d time.Duration) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.BlobTimeout = d
+	}
+}
+
+// WithConnectTimeout sets how long establishing a connection to the registry (including the TLS handshake) may take.
+func WithConnectTimeout(d time.Duration) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.ConnectTimeout = d
+	}
+}
+
+// WithEstargzLayers compresses the layers added to the image in the eStargz format,
+// so that runtimes supporting lazy pulling can start containers before the layers are fully downloaded.
+// Note that the diff IDs of eStargz layers differ from the diff IDs of the tar files they are created from.
+func WithEstargzLayers() func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.EstargzLayers = true
+	}
+}
+
+// WithHTTPRecorder records the HTTP exchanges of registry operations,
+// and writes a transcript of each operation that fails to a file in the given directory,
+// so that the failure can be reproduced (e.g. when reporting a bug against an unusual registry implementation).
+// Credentials are redacted, and only the bodies of error responses are recorded.
+func WithHTTPRecorder(dir string) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.HTTPRecorderDir = dir
+	}
+}
+
+// WithOverwritePolicy determines what happens when the image is saved to a tag that already exists,
+// which some registries reject (e.g. ECR repositories with immutable tags).
+// Unless the policy is imgutil.Overwrite, the tag is looked up before the image is pushed;
+// a tag that already refers to the image is never pushed again.
+func WithOverwritePolicy(policy imgutil.OverwritePolicy) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.OverwritePolicy = policy
+	}
+}
+
+// WithPushChildren makes SaveIndex push the manifests referenced by the index that are missing from the repository,
+// rather than failing.
+func WithPushChildren() func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.PushChildren = true
+	}
+}
+
 // WithRegistrySetting registers options to use when accessing images in a registry
 // in order to construct the image.
 // The referenced images could include the base image, a previous image, or the image itself.
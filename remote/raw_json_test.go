@@ -0,0 +1,77 @@
+package remote_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestRawJSON(t *testing.T) {
+	spec.Run(t, "RawJSON", testRawJSON, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testRawJSON(t *testing.T, when spec.G, it spec.S) {
+	var fakeRegistry *h.FakeRegistry
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	it("returns the bytes that saving the image pushes, including the empty layer added on save", func() {
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.AddEmptyLayerOnSave(),
+		)
+		h.AssertNil(t, err)
+
+		rawConfig, err := img.RawConfigJSON()
+		h.AssertNil(t, err)
+		rawManifest, err := img.RawManifestJSON()
+		h.AssertNil(t, err)
+
+		h.AssertNil(t, img.Save())
+		identifier, err := img.Identifier()
+		h.AssertNil(t, err)
+		h.AssertEq(t, identifier.String(), fakeRegistry.RepoName("some-image")+"@"+digestOf(t, rawManifest).String())
+		configName, err := img.ConfigName()
+		h.AssertNil(t, err)
+		h.AssertEq(t, configName, digestOf(t, rawConfig))
+		layers, err := img.Layers()
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(layers), 1)
+	})
+
+	it("finalizes the digest that saving the image produces", func() {
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain,
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.AddEmptyLayerOnSave(),
+		)
+		h.AssertNil(t, err)
+
+		digest, err := img.FinalizeDigest()
+		h.AssertNil(t, err)
+
+		h.AssertNil(t, img.Save())
+		identifier, err := img.Identifier()
+		h.AssertNil(t, err)
+		h.AssertEq(t, identifier.String(), fakeRegistry.RepoName("some-image")+"@"+digest.String())
+	})
+}
+
+func digestOf(t *testing.T, b []byte) v1.Hash {
+	t.Helper()
+	digest, _, err := v1.SHA256(bytes.NewReader(b))
+	h.AssertNil(t, err)
+	return digest
+}
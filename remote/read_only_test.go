@@ -0,0 +1,53 @@
+package remote_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestReadOnlyImage(t *testing.T) {
+	spec.Run(t, "ReadOnlyImage", testReadOnlyImage, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testReadOnlyImage(t *testing.T, when spec.G, it spec.S) {
+	var fakeRegistry *h.FakeRegistry
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	it("fetches the config of the image", func() {
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.SetLabel("some.label", "some-value"))
+		h.AssertNil(t, img.Save())
+
+		readOnly, err := remote.NewReadOnlyImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertNil(t, err)
+		h.AssertEq(t, readOnly.Found(), true)
+		label, err := readOnly.Label("some.label")
+		h.AssertNil(t, err)
+		h.AssertEq(t, label, "some-value")
+		identifier, err := readOnly.Identifier()
+		h.AssertNil(t, err)
+		expected, err := img.Identifier()
+		h.AssertNil(t, err)
+		h.AssertEq(t, identifier.String(), expected.String())
+	})
+
+	it("reports a missing image", func() {
+		readOnly, err := remote.NewReadOnlyImage(fakeRegistry.RepoName("missing-image"), authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertNil(t, err)
+		h.AssertEq(t, readOnly.Found(), false)
+	})
+}
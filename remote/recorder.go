@@ -0,0 +1,179 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// maxRecordedBody is the number of bytes recorded from the body of an error response.
+const maxRecordedBody = 4096
+
+// redactedHeaders are the headers whose values are never recorded.
+var redactedHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Amz-Security-Token": true,
+}
+
+// httpRecorder records the HTTP exchanges of registry operations,
+// and writes a transcript of each operation that fails to a file in its directory.
+// A nil recorder records nothing.
+type httpRecorder struct {
+	dir    string
+	logger imgutil.Logger
+}
+
+func newHTTPRecorder(options imgutil.ImageOptions) *httpRecorder {
+	if options.HTTPRecorderDir == "" {
+		return nil
+	}
+	return &httpRecorder{dir: options.HTTPRecorderDir, logger: imgutil.GetLogger(options)}
+}
+
+// start starts recording the given operation on the given image, returning the transport recording the exchanges sent with inner,
+// and a function to call with the result of the operation.
+func (r *httpRecorder) start(operation, imageName string, inner http.RoundTripper) (http.RoundTripper, func(error)) {
+	if r == nil {
+		return inner, func(error) {}
+	}
+	t := &recordingTransport{inner: inner}
+	return t, func(err error) {
+		if err == nil {
+			return
+		}
+		path, writeErr := r.write(operation, imageName, err, t.transcript())
+		if writeErr != nil {
+			r.logger.Warnf("failed to record HTTP transcript of %s %q: %s", operation, imageName, writeErr)
+			return
+		}
+		r.logger.Infof("recorded HTTP transcript of %s %q to %s", operation, imageName, path)
+	}
+}
+
+func (r *httpRecorder) write(operation, imageName string, err error, transcript string) (string, error) {
+	if mkErr := os.MkdirAll(r.dir, 0755); mkErr != nil {
+		return "", mkErr
+	}
+	f, createErr := os.CreateTemp(r.dir, operation+"-*.txt")
+	if createErr != nil {
+		return "", createErr
+	}
+	defer f.Close()
+	header := fmt.Sprintf("operation: %s\nimage: %s\ntime: %s\nerror: %s\n\n",
+		operation, imageName, time.Now().UTC().Format(time.RFC3339), err)
+	if _, writeErr := io.WriteString(f, header+transcript); writeErr != nil {
+		return "", writeErr
+	}
+	return f.Name(), nil
+}
+
+// recordingTransport records the requests it sends and the responses it receives, with credentials redacted.
+// Only the bodies of error responses are recorded.
+type recordingTransport struct {
+	inner     http.RoundTripper
+	mu        sync.Mutex
+	exchanges []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, redactURL(req.URL))
+	writeHeaders(&b, "> ", req.Header)
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(&b, "! %s\n", err)
+		t.record(b.String())
+		return resp, err
+	}
+	fmt.Fprintf(&b, "< %s %s\n", resp.Proto, resp.Status)
+	writeHeaders(&b, "< ", resp.Header)
+	if resp.StatusCode >= http.StatusBadRequest && resp.Body != nil {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxRecordedBody))
+		if len(body) > 0 {
+			fmt.Fprintf(&b, "<\n%s\n", body)
+		}
+		// the body is read again by the caller
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), errReader{readErr}, resp.Body), Closer: resp.Body}
+	}
+	t.record(b.String())
+	return resp, nil
+}
+
+func (t *recordingTransport) record(exchange string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exchanges = append(t.exchanges, exchange)
+}
+
+func (t *recordingTransport) transcript() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.exchanges, "\n")
+}
+
+func writeHeaders(b *strings.Builder, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			if redactedHeaders[http.CanonicalHeaderKey(key)] {
+				value = "REDACTED"
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, key, value)
+		}
+	}
+}
+
+// redactURL removes the credentials of a URL, including the query parameters of signed URLs
+// (e.g. those of blobs redirected to cloud storage).
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("REDACTED")
+	}
+	query := redacted.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		for _, secret := range []string{"sig", "token", "credential", "key", "auth"} {
+			if strings.Contains(lower, secret) {
+				query.Set(key, "REDACTED")
+				break
+			}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader returns its error, or io.EOF if it is nil.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
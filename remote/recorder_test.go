@@ -0,0 +1,92 @@
+package remote_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestHTTPRecorder(t *testing.T) {
+	spec.Run(t, "HTTPRecorder", testHTTPRecorder, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testHTTPRecorder(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		tmpDir       string
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry(h.WithBasicAuth("some-user", "some-password"))
+		var err error
+		tmpDir, err = os.MkdirTemp("", "http-recorder")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	newImage := func(password string) *remote.Image {
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"),
+			staticKeychain{&authn.Basic{Username: "some-user", Password: password}},
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.WithHTTPRecorder(tmpDir),
+		)
+		h.AssertNil(t, err)
+		return img
+	}
+
+	transcripts := func() []string {
+		t.Helper()
+		paths, err := filepath.Glob(filepath.Join(tmpDir, "*.txt"))
+		h.AssertNil(t, err)
+		return paths
+	}
+
+	it("writes a redacted transcript of a failed operation", func() {
+		img := newImage("wrong-password")
+
+		_, err := img.CheckWriteAccess()
+		h.AssertNotEq(t, err, nil)
+
+		paths := transcripts()
+		h.AssertEq(t, len(paths), 1)
+		contents, err := os.ReadFile(paths[0])
+		h.AssertNil(t, err)
+		transcript := string(contents)
+		h.AssertEq(t, strings.Contains(transcript, "operation: check-write-access"), true)
+		h.AssertEq(t, strings.Contains(transcript, "image: "+fakeRegistry.RepoName("some-image")), true)
+		h.AssertEq(t, strings.Contains(transcript, "< HTTP/1.1 401 Unauthorized"), true)
+		h.AssertEq(t, strings.Contains(transcript, "> Authorization: REDACTED"), true)
+		credentials := base64.StdEncoding.EncodeToString([]byte("some-user:wrong-password"))
+		h.AssertEq(t, strings.Contains(transcript, credentials), false)
+	})
+
+	it("writes nothing for successful operations", func() {
+		img := newImage("some-password")
+
+		h.AssertNil(t, img.Save())
+		h.AssertEq(t, img.Found(), true)
+		h.AssertEq(t, newImage("some-password").Found(), true)
+		missing, err := remote.NewImage(fakeRegistry.RepoName("missing-image"),
+			staticKeychain{&authn.Basic{Username: "some-user", Password: "some-password"}},
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.WithHTTPRecorder(tmpDir),
+		)
+		h.AssertNil(t, err)
+		h.AssertEq(t, missing.Found(), false)
+
+		h.AssertEq(t, len(transcripts()), 0)
+	})
+}
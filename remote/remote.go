@@ -1,8 +1,8 @@
 package remote
 
 import (
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -23,13 +23,35 @@ type Image struct {
 	repoName            string
 	keychain            authn.Keychain
 	addEmptyLayerOnSave bool
+	assumeLayersMissing bool
+	assumeLayersPresent bool
+	blobExistenceCache  imgutil.BlobExistenceCache
+	overwritePolicy     imgutil.OverwritePolicy
+	recorder            *httpRecorder
+	transportConfig     transportConfig
+	saveTimeout         time.Duration
 	registrySettings    map[string]imgutil.RegistrySetting
+	sboms               []imgutil.SBOM
+	baseImage           v1.Image // as found in the registry, before media types are converted
+	baseIndex           v1.ImageIndex
+	baseImageRepoName   string
+	previousImage       v1.Image
+	previousImageRepo   string
+	finalizedDigest     *v1.Hash // the digest returned by Finalize, which Push checks
+	logger              imgutil.Logger
+	instrumentation     imgutil.Instrumentation
 }
 
 func (i *Image) Kind() string {
 	return `remote`
 }
 
+// UnderlyingIndex returns the index the base image was selected from, which may reference other platforms of the base image
+// or artifacts (such as attestations) attached to it. It returns nil if the base image is not referenced by an index.
+func (i *Image) UnderlyingIndex() v1.ImageIndex {
+	return i.baseIndex
+}
+
 func (i *Image) Name() string {
 	return i.repoName
 }
@@ -49,7 +71,15 @@ func (i *Image) found() (*v1.Descriptor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return remote.Head(ref, remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure)))
+	rt, finishRecording := i.recorder.start("lookup", i.repoName, getTransport(reg.Insecure, i.transportConfig))
+	desc, err := remote.Head(ref, remote.WithAuth(auth), remote.WithTransport(rt))
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound {
+		finishRecording(nil) // a missing image is not a failure
+	} else {
+		finishRecording(err)
+	}
+	return desc, err
 }
 
 func (i *Image) Identifier() (imgutil.Identifier, error) {
@@ -63,14 +93,7 @@ func (i *Image) Identifier() (imgutil.Identifier, error) {
 		return nil, errors.Wrapf(err, "getting digest for image %q", i.repoName)
 	}
 
-	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", ref.Context().Name(), hash.String()), name.WeakValidation)
-	if err != nil {
-		return nil, errors.Wrap(err, "creating digest reference")
-	}
-
-	return DigestIdentifier{
-		Digest: digestRef,
-	}, nil
+	return newDigestIdentifier(i.repoName, ref, hash), nil
 }
 
 // Valid returns true if the (saved) image is valid.
@@ -78,13 +101,15 @@ func (i *Image) Valid() bool {
 	return i.valid() == nil
 }
 
-func (i *Image) valid() error {
+func (i *Image) valid() (err error) {
 	reg := getRegistrySetting(i.repoName, i.registrySettings)
 	ref, auth, err := referenceForRepoName(i.keychain, i.repoName, reg.Insecure)
 	if err != nil {
 		return err
 	}
-	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure)))
+	rt, finishRecording := i.recorder.start("validate", i.repoName, getTransport(reg.Insecure, i.transportConfig))
+	defer func() { finishRecording(err) }()
+	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(rt))
 	if err != nil {
 		return err
 	}
@@ -112,38 +137,65 @@ func (i *Image) Delete() error {
 	if err != nil {
 		return err
 	}
-	return remote.Delete(ref, remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure)))
+	rt, finishRecording := i.recorder.start("delete", i.repoName, getTransport(reg.Insecure, i.transportConfig))
+	err = remote.Delete(ref, remote.WithAuth(auth), remote.WithTransport(rt))
+	finishRecording(err)
+	return err
 }
 
 // extras
 
+// CheckReadAccess reports whether the image can be read from the registry with the configured credentials;
+// an image that doesn't exist is readable. Failures are returned as an imgutil.AccessError.
 func (i *Image) CheckReadAccess() (bool, error) {
-	var err error
-	if _, err = i.found(); err == nil {
+	_, err := i.found()
+	if err == nil {
 		return true, nil
 	}
-	var canRead bool
-	if transportErr, ok := err.(*transport.Error); ok {
-		if canRead = transportErr.StatusCode != http.StatusUnauthorized &&
-			transportErr.StatusCode != http.StatusForbidden; canRead {
-			err = nil
-		}
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) &&
+		transportErr.StatusCode != http.StatusUnauthorized &&
+		transportErr.StatusCode != http.StatusForbidden {
+		return true, nil
 	}
-	return canRead, err
+	return false, accessError(i.repoName, false, err)
 }
 
-func (i *Image) CheckReadWriteAccess() (bool, error) {
-	if canRead, err := i.CheckReadAccess(); !canRead {
-		return false, err
-	}
+// CheckWriteAccess reports whether the image can be pushed to the registry with the configured credentials.
+// Failures are returned as an imgutil.AccessError.
+func (i *Image) CheckWriteAccess() (bool, error) {
 	reg := getRegistrySetting(i.repoName, i.registrySettings)
 	ref, _, err := referenceForRepoName(i.keychain, i.repoName, reg.Insecure)
 	if err != nil {
 		return false, err
 	}
-	err = remote.CheckPushPermission(ref, i.keychain, http.DefaultTransport)
+	rt, finishRecording := i.recorder.start("check-write-access", i.repoName, getTransport(reg.Insecure, i.transportConfig))
+	err = remote.CheckPushPermission(ref, i.keychain, rt)
+	finishRecording(err)
 	if err != nil {
-		return false, err
+		return false, accessError(i.repoName, true, err)
 	}
 	return true, nil
 }
+
+func (i *Image) CheckReadWriteAccess() (bool, error) {
+	if canRead, err := i.CheckReadAccess(); !canRead {
+		return false, err
+	}
+	return i.CheckWriteAccess()
+}
+
+// accessError classifies a registry error by its status code: requests that never got a response are unreachable.
+func accessError(repoName string, write bool, err error) error {
+	reason := imgutil.AccessUnreachable
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		switch transportErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			reason = imgutil.AccessDenied
+		case http.StatusNotFound:
+			reason = imgutil.AccessNotFound
+		}
+	}
+	return imgutil.AccessError{Name: repoName, Write: write, Reason: reason, Err: err}
+}
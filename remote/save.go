@@ -1,12 +1,20 @@
 package remote
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/static"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 
@@ -27,51 +35,359 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 		return err
 	}
 
-	// add empty layer if needed
-	layers, err := i.Layers()
-	if err != nil {
-		return fmt.Errorf("getting layers: %w", err)
+	if err := i.ensureEmptyLayer(); err != nil {
+		return err
 	}
-	if len(layers) == 0 && i.addEmptyLayerOnSave {
-		if err = i.AddLayerWithHistory(emptyLayer, emptyHistory); err != nil {
-			return fmt.Errorf("adding empty layer: %w", err)
-		}
+
+	if err := i.ScanAddedLayers(); err != nil {
+		return err
+	}
+	if err := i.ValidateLabels(); err != nil {
+		return err
+	}
+	if err := i.CheckSizeLimits(); err != nil {
+		return err
 	}
 
 	// save
-	var diagnostics []imgutil.SaveDiagnostic
+	var (
+		diagnostics []imgutil.SaveDiagnostic
+		saved       = map[string]string{}
+	)
+	ctx := context.Background()
+	if i.saveTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.saveTimeout)
+		defer cancel()
+	}
 	allNames := append([]string{name}, additionalNames...)
+	var savedNames []string
+	pushedRepos := map[string]bool{}
 	for _, n := range allNames {
-		if err := i.doSave(n); err != nil {
-			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		skipped, err := i.doSave(ctx, n, pushedRepos)
+		if err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Reason: saveFailure(err), Cause: err})
+			continue
+		}
+		if skipped {
+			continue
+		}
+		savedNames = append(savedNames, n)
+		if digest, err := i.CNBImageCore.Digest(); err == nil {
+			saved[n] = digest.String()
 		}
 	}
 	if len(diagnostics) > 0 {
-		return imgutil.SaveError{Errors: diagnostics}
+		return imgutil.SaveError{Errors: diagnostics, Saved: saved}
+	}
+	if digest, err := i.CNBImageCore.Digest(); err == nil && len(savedNames) > 0 {
+		i.Emit(imgutil.Event{Type: imgutil.EventSave, Names: savedNames, Identifier: digest.String()})
+	}
+	return nil
+}
+
+// ensureEmptyLayer adds an empty layer to the image if it has no layers and AddEmptyLayerOnSave was provided.
+func (i *Image) ensureEmptyLayer() error {
+	if !i.addEmptyLayerOnSave {
+		return nil
+	}
+	layers, err := i.Layers()
+	if err != nil {
+		return fmt.Errorf("getting layers: %w", err)
+	}
+	if len(layers) > 0 {
+		return nil
+	}
+	if err = i.AddLayerWithHistory(emptyLayer, emptyHistory); err != nil {
+		return fmt.Errorf("adding empty layer: %w", err)
 	}
 	return nil
 }
 
-func (i *Image) doSave(imageName string) error {
+// FinalizeDigest applies the changes that saving the image makes and returns its digest (see imgutil.CNBImageCore.FinalizeDigest).
+// If the image has no layers and AddEmptyLayerOnSave was provided, the empty layer is added to the image, as saving it would.
+func (i *Image) FinalizeDigest() (v1.Hash, error) {
+	if err := i.ensureEmptyLayer(); err != nil {
+		return v1.Hash{}, err
+	}
+	return i.CNBImageCore.FinalizeDigest()
+}
+
+// Finalize is the first phase of a two-phase save, for signing the image before it is pushed:
+// it applies the changes that saving the image makes (see FinalizeDigest), and returns the reference by digest
+// that the image will have in the repository of its name, which can be signed (e.g. with signature.Attach) before Push pushes the image.
+func (i *Image) Finalize() (name.Digest, error) {
+	digest, err := i.FinalizeDigest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+	reg := getRegistrySetting(i.repoName, i.registrySettings)
+	opts := []name.Option{name.WeakValidation}
+	if reg.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	ref, err := name.ParseReference(i.repoName, opts...)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	i.finalizedDigest = &digest
+	return ref.Context().Digest(digest.String()), nil
+}
+
+// Push is the second phase of a two-phase save: it saves the image finalized with Finalize, as Save does.
+// It fails without pushing the image if the image was not finalized, or was modified since, as the signed digest would not match.
+func (i *Image) Push(additionalNames ...string) error {
+	if i.finalizedDigest == nil {
+		return errors.New("image must be finalized before it is pushed")
+	}
+	digest, err := i.FinalizeDigest()
+	if err != nil {
+		return err
+	}
+	if digest != *i.finalizedDigest {
+		return fmt.Errorf("image was modified after it was finalized: digest %s does not match finalized digest %s", digest, *i.finalizedDigest)
+	}
+	return i.Save(additionalNames...)
+}
+
+// RawConfigJSON returns the exact bytes of the config file that saving the image will write (see imgutil.CNBImageCore.RawConfigJSON).
+// If the image has no layers and AddEmptyLayerOnSave was provided, the empty layer is added to the image, as saving it would.
+func (i *Image) RawConfigJSON() ([]byte, error) {
+	if err := i.ensureEmptyLayer(); err != nil {
+		return nil, err
+	}
+	return i.CNBImageCore.RawConfigJSON()
+}
+
+// RawManifestJSON returns the exact bytes of the manifest that saving the image will write (see imgutil.CNBImageCore.RawManifestJSON).
+// If the image has no layers and AddEmptyLayerOnSave was provided, the empty layer is added to the image, as saving it would.
+func (i *Image) RawManifestJSON() ([]byte, error) {
+	if err := i.ensureEmptyLayer(); err != nil {
+		return nil, err
+	}
+	return i.CNBImageCore.RawManifestJSON()
+}
+
+// checkOverwrite looks up the tag when the overwrite policy requires it.
+// It reports whether the tag already refers to the image, and whether the image is skipped because the tag refers to another image.
+func (i *Image) checkOverwrite(ctx context.Context, ref name.Reference, auth authn.Authenticator, rt http.RoundTripper) (exists bool, skipped bool, err error) {
+	if i.overwritePolicy == imgutil.Overwrite {
+		return false, false, nil
+	}
+	if _, ok := ref.(name.Tag); !ok {
+		return false, false, nil
+	}
+	existing, err := remote.Head(ref, remote.WithContext(ctx), remote.WithAuth(auth), remote.WithTransport(rt))
+	if err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("checking if tag %s exists: %w", ref.Name(), err)
+	}
+	digest, err := i.CNBImageCore.Digest()
+	if err != nil {
+		return false, false, err
+	}
+	if existing.Digest == digest {
+		i.logger.Infof("image %s@%s already exists", ref.Name(), digest)
+		return true, false, nil
+	}
+	if i.overwritePolicy == imgutil.SkipIfExists {
+		i.logger.Warnf("skipping %s: tag already refers to %s", ref.Name(), existing.Digest)
+		return false, true, nil
+	}
+	return false, false, fmt.Errorf("tag %s refers to %s: %w", ref.Name(), existing.Digest, imgutil.SaveTagExists)
+}
+
+// saveFailure returns the known reason for a registry error, or zero.
+func saveFailure(err error) imgutil.SaveFailure {
+	var failure imgutil.SaveFailure
+	if errors.As(err, &failure) {
+		return failure
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return imgutil.SaveTimedOut
+	}
+	var transportErr *transport.Error
+	if !errors.As(err, &transportErr) {
+		return 0
+	}
+	for _, diagnostic := range transportErr.Errors {
+		switch diagnostic.Code {
+		case transport.UnauthorizedErrorCode, transport.DeniedErrorCode:
+			return imgutil.SaveUnauthorized
+		case transport.TagInvalidErrorCode:
+			// e.g. ECR rejects pushes to existing tags of repositories with immutable tags with TAG_INVALID
+			msg := strings.ToLower(diagnostic.Message)
+			if strings.Contains(msg, "immutable") || strings.Contains(msg, "already exists") {
+				return imgutil.SaveTagImmutable
+			}
+		}
+	}
+	switch transportErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return imgutil.SaveUnauthorized
+	case http.StatusConflict:
+		return imgutil.SaveTagImmutable
+	case http.StatusRequestEntityTooLarge:
+		return imgutil.SaveBlobTooLarge
+	}
+	return 0
+}
+
+// doSave pushes the image to the given name, and reports whether it was skipped because of the overwrite policy.
+// The blobs and SBOMs are only pushed to the first name of each repository, which is recorded in pushedRepos;
+// for other names in the same repository, only the manifest is pushed.
+func (i *Image) doSave(ctx context.Context, imageName string, pushedRepos map[string]bool) (skipped bool, err error) {
 	reg := getRegistrySetting(i.repoName, i.registrySettings)
 	ref, auth, err := referenceForRepoName(i.keychain, imageName, reg.Insecure)
+	if err != nil {
+		return false, err
+	}
+	rt, finishRecording := i.recorder.start("save", imageName, getTransport(reg.Insecure, i.transportConfig))
+	defer func() { finishRecording(err) }()
+
+	exists, skipped, err := i.checkOverwrite(ctx, ref, auth, rt)
+	if err != nil || exists || skipped {
+		return skipped, err
+	}
+
+	opts := []remote.Option{remote.WithContext(ctx), remote.WithAuth(auth), remote.WithTransport(rt)}
+	repo := ref.Context().String()
+	switch {
+	case pushedRepos[repo]:
+		err = remote.Put(ref, manifestOf{i.CNBImageCore}, opts...)
+	case i.layersPresentIn(repo):
+		i.logger.Debugf("layers of %s are present in %s, pushing only the config and manifest", imageName, repo)
+		err = i.writeConfigAndManifest(ref, remote.WithContext(ctx), remote.WithAuth(auth), remote.WithTransport(i.pushTransport(rt)))
+	default:
+		err = i.write(ref, remote.WithContext(ctx), remote.WithAuth(auth), remote.WithTransport(i.pushTransport(rt)))
+	}
+	if err != nil {
+		return false, err
+	}
+	if digest, err := i.CNBImageCore.Digest(); err == nil {
+		i.logger.Infof("saved image %s@%s", ref.Name(), digest)
+	}
+	if pushedRepos[repo] {
+		return false, nil
+	}
+	if err = i.pushSBOMs(ctx, ref, auth, rt); err != nil {
+		return false, err
+	}
+	pushedRepos[repo] = true
+	return false, nil
+}
+
+// writeConfigAndManifest pushes the config and the manifest of the image, without handling its layers.
+func (i *Image) writeConfigAndManifest(ref name.Reference, opts ...remote.Option) error {
+	manifest, err := i.CNBImageCore.Manifest()
 	if err != nil {
 		return err
 	}
+	rawConfig, err := i.CNBImageCore.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err = remote.WriteLayer(ref.Context(), static.NewLayer(rawConfig, manifest.Config.MediaType), opts...); err != nil {
+		return fmt.Errorf("pushing config: %w", err)
+	}
+	return remote.Put(ref, manifestOf{i.CNBImageCore}, opts...)
+}
 
-	return remote.Write(ref, i.CNBImageCore,
-		remote.WithAuth(auth),
-		remote.WithTransport(getTransport(reg.Insecure)),
-	)
+// manifestOf exposes only the manifest of an image, so that remote.Put pushes the manifest
+// without checking for the blobs of the image.
+type manifestOf struct {
+	image v1.Image
+}
+
+func (m manifestOf) RawManifest() ([]byte, error) {
+	return m.image.RawManifest()
+}
+
+func (m manifestOf) MediaType() (types.MediaType, error) {
+	return m.image.MediaType()
+}
+
+// pushTransport returns the transport used to push blobs, which skips checking for blobs
+// if requested (with WithAssumeLayersMissing or WithBlobExistenceCache),
+// and never checks for the layers of the previous image in its own repository, as its manifest references them.
+// Requests that are not answered are sent with inner.
+func (i *Image) pushTransport(inner http.RoundTripper) http.RoundTripper {
+	t := inner
+	if i.assumeLayersMissing {
+		t = &missingBlobsTransport{inner: t}
+	}
+	if i.blobExistenceCache != nil {
+		t = &blobExistenceTransport{inner: t, cache: i.blobExistenceCache, logger: i.logger}
+	}
+	if previousLayers := i.previousLayers(); previousLayers != nil {
+		t = &blobExistenceTransport{inner: t, cache: previousLayers, logger: i.logger}
+	}
+	return t
 }
 
-func getTransport(insecure bool) http.RoundTripper {
-	if insecure {
-		return &http.Transport{
-			TLSClientConfig: &tls.Config{
+// missingBlobsTransport answers the requests checking whether a blob exists with a 404 Not Found,
+// without sending them to the registry.
+type missingBlobsTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *missingBlobsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodHead || !strings.Contains(req.URL.Path, "/blobs/") {
+		return t.inner.RoundTrip(req)
+	}
+	return &http.Response{
+		Status:     "404 Not Found",
+		StatusCode: http.StatusNotFound,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// transportConfig configures the transports of the operations of an image; zero values mean no timeout and no limit.
+type transportConfig struct {
+	connectTimeout time.Duration
+	blobTimeout    time.Duration
+	limiter        *bandwidthLimiter // shared by the operations of the image
+}
+
+func newTransportConfig(options imgutil.ImageOptions) transportConfig {
+	return transportConfig{
+		connectTimeout: options.ConnectTimeout,
+		blobTimeout:    options.BlobTimeout,
+		limiter:        newBandwidthLimiter(options.BandwidthLimit),
+	}
+}
+
+// getTransport returns the transport of an operation, which applies the given configuration.
+func getTransport(insecure bool, config transportConfig) http.RoundTripper {
+	var t http.RoundTripper = http.DefaultTransport
+	if insecure || config.connectTimeout > 0 {
+		inner := &http.Transport{}
+		if !insecure {
+			inner = http.DefaultTransport.(*http.Transport).Clone()
+		} else {
+			inner.TLSClientConfig = &tls.Config{
 				InsecureSkipVerify: true, // #nosec G402
-			},
+			}
 		}
+		if config.connectTimeout > 0 {
+			inner.DialContext = (&net.Dialer{Timeout: config.connectTimeout}).DialContext
+			inner.TLSHandshakeTimeout = config.connectTimeout
+		}
+		t = inner
+	}
+	if config.limiter != nil {
+		t = &throttlingTransport{inner: t, limiter: config.limiter}
+	}
+	if config.blobTimeout > 0 {
+		t = &blobTimeoutTransport{inner: t, timeout: config.blobTimeout}
 	}
-	return http.DefaultTransport
+	return t
 }
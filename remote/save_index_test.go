@@ -0,0 +1,157 @@
+package remote_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestSaveIndex(t *testing.T) {
+	spec.Run(t, "SaveIndex", testSaveIndex, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testSaveIndex(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		repoName     string
+		amd64Image   v1.Image
+		arm64Image   v1.Image
+		index        v1.ImageIndex
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+		repoName = fakeRegistry.RepoName("some-index")
+
+		var err error
+		amd64Image, err = random.Image(1024, 1)
+		h.AssertNil(t, err)
+		arm64Image, err = random.Image(1024, 1)
+		h.AssertNil(t, err)
+		index = mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: amd64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+			mutate.IndexAddendum{Add: arm64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		)
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	digestRef := func(img v1.Image) name.Digest {
+		digest, err := img.Digest()
+		h.AssertNil(t, err)
+		ref, err := name.NewDigest(repoName+"@"+digest.String(), name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		return ref
+	}
+
+	it("fails without pushing the index if a manifest is missing from the repository", func() {
+		h.AssertNil(t, ggcrremote.Write(digestRef(amd64Image), amd64Image))
+		arm64Digest, err := arm64Image.Digest()
+		h.AssertNil(t, err)
+
+		_, err = remote.SaveIndex(repoName, index, authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertError(t, err, "index references manifests missing from repository")
+		h.AssertError(t, err, arm64Digest.String())
+
+		ref, err := name.ParseReference(repoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		_, err = ggcrremote.Head(ref)
+		h.AssertNotEq(t, err, nil)
+	})
+
+	it("pushes the missing manifests before the index", func() {
+		pushed, err := remote.SaveIndex(repoName, index, authn.DefaultKeychain,
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+			remote.WithPushChildren(),
+		)
+		h.AssertNil(t, err)
+
+		digest, err := index.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, pushed.DigestStr(), digest.String())
+		_, err = ggcrremote.Head(digestRef(arm64Image))
+		h.AssertNil(t, err)
+		saved, err := ggcrremote.Index(pushed)
+		h.AssertNil(t, err)
+		savedDigest, err := saved.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, savedDigest, digest)
+	})
+
+	it("pushes the index if its manifests exist in the repository", func() {
+		h.AssertNil(t, ggcrremote.Write(digestRef(amd64Image), amd64Image))
+		h.AssertNil(t, ggcrremote.Write(digestRef(arm64Image), arm64Image))
+
+		_, err := remote.SaveIndex(repoName, index, authn.DefaultKeychain, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		h.AssertNil(t, err)
+	})
+	when("#SaveIndexAs", func() {
+		var otherRegistry *h.FakeRegistry
+
+		it.Before(func() {
+			otherRegistry = h.NewFakeRegistry()
+		})
+
+		it.After(func() {
+			otherRegistry.Close()
+		})
+
+		it("pushes the index to every reference", func() {
+			names := []string{repoName, fakeRegistry.RepoName("other-index:some-tag"), otherRegistry.RepoName("some-index")}
+
+			err := remote.SaveIndexAs(index, authn.DefaultKeychain, names,
+				remote.WithRegistrySetting(fakeRegistry.Host, true),
+				remote.WithRegistrySetting(otherRegistry.Host, true),
+				remote.WithPushChildren(),
+			)
+			h.AssertNil(t, err)
+
+			digest, err := index.Digest()
+			h.AssertNil(t, err)
+			for _, n := range names {
+				ref, err := name.ParseReference(n, name.WeakValidation, name.Insecure)
+				h.AssertNil(t, err)
+				saved, err := ggcrremote.Index(ref)
+				h.AssertNil(t, err)
+				savedDigest, err := saved.Digest()
+				h.AssertNil(t, err)
+				h.AssertEq(t, savedDigest, digest)
+			}
+		})
+
+		it("reports the references that failed and the ones the index was pushed to", func() {
+			h.AssertNil(t, ggcrremote.Write(digestRef(amd64Image), amd64Image))
+			h.AssertNil(t, ggcrremote.Write(digestRef(arm64Image), arm64Image))
+			otherName := otherRegistry.RepoName("some-index")
+
+			err := remote.SaveIndexAs(index, authn.DefaultKeychain, []string{repoName, otherName},
+				remote.WithRegistrySetting(fakeRegistry.Host, true),
+				remote.WithRegistrySetting(otherRegistry.Host, true),
+			)
+
+			var saveErr imgutil.SaveError
+			h.AssertEq(t, errors.As(err, &saveErr), true)
+			h.AssertEq(t, len(saveErr.Errors), 1)
+			h.AssertEq(t, saveErr.Errors[0].ImageName, otherName)
+			h.AssertError(t, saveErr.Errors[0].Cause, "index references manifests missing from repository")
+			digest, err := index.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, saveErr.Saved, map[string]string{repoName: digest.String()})
+		})
+	})
+}
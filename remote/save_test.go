@@ -0,0 +1,310 @@
+package remote_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/existcache"
+	"github.com/buildpacks/imgutil/layout"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestSaveError(t *testing.T) {
+	spec.Run(t, "SaveError", testSaveError, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testSaveError(t *testing.T, when spec.G, it spec.S) {
+	var fakeRegistry *h.FakeRegistry
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry(h.WithBasicAuth("some-user", "some-password"))
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	newImage := func(password string, ops ...imgutil.ImageOption) *remote.Image {
+		keychain := staticKeychain{&authn.Basic{Username: "some-user", Password: password}}
+		ops = append(ops, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), keychain, ops...)
+		h.AssertNil(t, err)
+		return img
+	}
+
+	it("reports unauthorized pushes", func() {
+		img := newImage("wrong-password")
+
+		err := img.Save()
+		h.AssertEq(t, errors.Is(err, imgutil.SaveUnauthorized), true)
+		var saveErr imgutil.SaveError
+		h.AssertEq(t, errors.As(err, &saveErr), true)
+		h.AssertEq(t, saveErr.Errors[0].Reason, imgutil.SaveUnauthorized)
+		h.AssertEq(t, len(saveErr.Saved), 0)
+	})
+
+	it("reports the digest of the names that were saved", func() {
+		img := newImage("some-password")
+
+		err := img.Save("not@a-valid:name")
+		var saveErr imgutil.SaveError
+		h.AssertEq(t, errors.As(err, &saveErr), true)
+		h.AssertEq(t, len(saveErr.Errors), 1)
+		h.AssertEq(t, saveErr.Errors[0].ImageName, "not@a-valid:name")
+		h.AssertEq(t, saveErr.Errors[0].Reason, imgutil.SaveFailure(0))
+
+		digest, err := img.Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, saveErr.Saved, map[string]string{img.Name(): digest.String()})
+	})
+
+	it("describes diagnostics without a cause", func() {
+		diagnostic := imgutil.SaveDiagnostic{ImageName: "some-image", Reason: imgutil.SaveUnauthorized}
+		h.AssertEq(t, diagnostic.Error(), "some-image: "+imgutil.SaveUnauthorized.Error())
+		h.AssertEq(t, errors.Is(diagnostic, imgutil.SaveUnauthorized), true)
+	})
+
+	when("#WithOverwritePolicy", func() {
+		var existing *remote.Image
+
+		it.Before(func() {
+			existing = newImage("some-password")
+			h.AssertNil(t, existing.SetLabel("some.label", "existing"))
+			h.AssertNil(t, existing.Save())
+		})
+
+		it("fails to save to an existing tag with FailIfExists", func() {
+			img := newImage("some-password", remote.WithOverwritePolicy(imgutil.FailIfExists))
+			h.AssertNil(t, img.SetLabel("some.label", "new"))
+
+			err := img.Save()
+			h.AssertEq(t, errors.Is(err, imgutil.SaveTagExists), true)
+		})
+
+		it("leaves an existing tag unchanged with SkipIfExists", func() {
+			img := newImage("some-password", remote.WithOverwritePolicy(imgutil.SkipIfExists))
+			h.AssertNil(t, img.SetLabel("some.label", "new"))
+			h.AssertNil(t, img.Save())
+
+			saved := newImage("some-password", remote.FromBaseImage(fakeRegistry.RepoName("some-image")))
+			label, err := saved.Label("some.label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "existing")
+		})
+
+		it("doesn't report the names skipped with SkipIfExists as saved", func() {
+			var events []imgutil.Event
+			img := newImage("some-password",
+				remote.WithOverwritePolicy(imgutil.SkipIfExists),
+				imgutil.WithEventHandler(func(e imgutil.Event) {
+					events = append(events, e)
+				}),
+			)
+			h.AssertNil(t, img.SetLabel("some.label", "new"))
+			otherName := fakeRegistry.RepoName("some-image:other-tag")
+
+			err := img.Save(otherName, "not@a-valid:name")
+			var saveErr imgutil.SaveError
+			h.AssertEq(t, errors.As(err, &saveErr), true)
+			digest, err := img.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, saveErr.Saved, map[string]string{otherName: digest.String()})
+
+			h.AssertNil(t, img.Save(otherName))
+			h.AssertEq(t, events, []imgutil.Event{
+				{Type: imgutil.EventSetLabel, Key: "some.label", Value: "new"},
+				{Type: imgutil.EventSave, Names: []string{otherName}, Identifier: digest.String()},
+			})
+		})
+
+		it("accepts a tag that already refers to the image", func() {
+			img := newImage("some-password", remote.WithOverwritePolicy(imgutil.FailIfExists))
+			h.AssertNil(t, img.SetLabel("some.label", "existing"))
+			h.AssertNil(t, img.Save())
+		})
+	})
+
+	when("saving to several tags of the same repository", func() {
+		it("pushes the blobs once and a manifest for each tag", func() {
+			img := newImage("some-password")
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save(img.Name()+"-other", fakeRegistry.RepoName("some-image:other-tag"), fakeRegistry.RepoName("some-image:another-tag")))
+
+			var blobChecks, manifestPuts int
+			for _, req := range fakeRegistry.Requests() {
+				switch {
+				case req.Method == http.MethodHead && strings.Contains(req.Path, "/blobs/"):
+					blobChecks++
+				case req.Method == http.MethodPut && strings.Contains(req.Path, "/manifests/"):
+					manifestPuts++
+				}
+			}
+			h.AssertEq(t, blobChecks, 4) // the layer and config blobs of each repository
+			h.AssertEq(t, manifestPuts, 4)
+			h.AssertEq(t, fakeRegistry.BlobUploads(), 2) // the fake registry shares blobs between repositories
+		})
+	})
+
+	when("#WithAssumeLayersMissing", func() {
+		it("uploads the blobs without checking if they exist", func() {
+			img := newImage("some-password", remote.WithAssumeLayersMissing())
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save())
+
+			for _, req := range fakeRegistry.Requests() {
+				if req.Method == http.MethodHead && strings.Contains(req.Path, "/blobs/") {
+					t.Fatalf("unexpected request %s %s", req.Method, req.Path)
+				}
+			}
+			h.AssertEq(t, fakeRegistry.BlobUploads(), 2)
+			saved := newImage("some-password", remote.FromBaseImage(img.Name()))
+			h.AssertDiffIDs(t, saved.UnderlyingImage(), h.FileDiffID(t, layerPath))
+		})
+	})
+
+	when("#WithAssumeLayersPresent", func() {
+		it("pushes only the config and the manifest", func() {
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			base := newImage("some-password")
+			h.AssertNil(t, base.AddLayer(layerPath))
+			h.AssertNil(t, base.Save())
+			layer, err := base.LastLayer()
+			h.AssertNil(t, err)
+
+			img := newImage("some-password", remote.FromBaseImage(base.Name()), remote.WithAssumeLayersPresent())
+			h.AssertNil(t, img.SetLabel("some.label", "some-value"))
+			pushed := len(fakeRegistry.Requests())
+			h.AssertNil(t, img.SaveAs(fakeRegistry.RepoName("some-image:other-tag")))
+
+			for _, req := range fakeRegistry.Requests()[pushed:] {
+				if strings.Contains(req.Path+req.Query, layer.Digest) {
+					t.Fatalf("unexpected request %s %s", req.Method, req.Path)
+				}
+			}
+			h.AssertEq(t, fakeRegistry.BlobUploads(), 3) // the layer and config of the base image, and the new config
+			saved := newImage("some-password", remote.FromBaseImage(fakeRegistry.RepoName("some-image:other-tag")))
+			h.AssertDiffIDs(t, saved.UnderlyingImage(), h.FileDiffID(t, layerPath))
+		})
+	})
+
+	when("#WithPreviousImageInstance", func() {
+		it("reuses the layers of a layout image", func() {
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			previous, err := layout.NewImage(filepath.Join(t.TempDir(), "previous-image"))
+			h.AssertNil(t, err)
+			h.AssertNil(t, previous.AddLayer(layerPath))
+			h.AssertNil(t, previous.Save())
+			diffID := h.FileDiffID(t, layerPath)
+
+			img := newImage("some-password", imgutil.WithPreviousImageInstance(previous))
+			h.AssertNil(t, img.ReuseLayer(diffID))
+			h.AssertNil(t, img.Save())
+
+			saved := newImage("some-password", remote.FromBaseImage(img.Name()))
+			h.AssertDiffIDs(t, saved.UnderlyingImage(), diffID)
+		})
+	})
+
+	when("#WithBlobExistenceCache", func() {
+		it("doesn't check for the blobs recorded in the cache", func() {
+			blobCache, err := existcache.New(filepath.Join(t.TempDir(), "cache"), 0)
+			h.AssertNil(t, err)
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			img := newImage("some-password", remote.WithBlobExistenceCache(blobCache))
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+			pushed := len(fakeRegistry.Requests())
+
+			img = newImage("some-password", remote.WithBlobExistenceCache(blobCache))
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.SetLabel("some.label", "some-value"))
+			h.AssertNil(t, img.SaveAs(fakeRegistry.RepoName("some-image:other-tag")))
+
+			for _, req := range fakeRegistry.Requests()[pushed:] {
+				if req.Method == http.MethodHead && strings.Contains(req.Path, "/blobs/") && !strings.Contains(req.Path, "sha256:"+configDigest(t, img)) {
+					t.Fatalf("unexpected request %s %s", req.Method, req.Path)
+				}
+			}
+		})
+	})
+
+	when("layers of the previous image are reused", func() {
+		var layerDigest string
+
+		it.Before(func() {
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			previous := newImage("some-password")
+			h.AssertNil(t, previous.AddLayer(layerPath))
+			h.AssertNil(t, previous.Save())
+			layer, err := previous.LastLayer()
+			h.AssertNil(t, err)
+			layerDigest = layer.Digest
+		})
+
+		reuseAndSave := func(name string) []h.RecordedRequest {
+			img := newImage("some-password", remote.WithPreviousImage(fakeRegistry.RepoName("some-image")))
+			previous := newImage("some-password", remote.FromBaseImage(fakeRegistry.RepoName("some-image")))
+			topLayer, err := previous.TopLayer()
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.ReuseLayer(topLayer))
+			pushed := len(fakeRegistry.Requests())
+			h.AssertNil(t, img.SaveAs(name))
+			return fakeRegistry.Requests()[pushed:]
+		}
+
+		it("doesn't check for them in the repository of the previous image", func() {
+			for _, req := range reuseAndSave(fakeRegistry.RepoName("some-image:other-tag")) {
+				if strings.HasSuffix(req.Path, "/blobs/"+layerDigest) {
+					t.Fatalf("unexpected request %s %s", req.Method, req.Path)
+				}
+			}
+		})
+
+		it("doesn't download them when saving to another repository", func() {
+			var checked bool
+			for _, req := range reuseAndSave(fakeRegistry.RepoName("other-image")) {
+				if req.Method == http.MethodGet && strings.HasSuffix(req.Path, "/blobs/"+layerDigest) {
+					t.Fatalf("unexpected request %s %s", req.Method, req.Path)
+				}
+				if req.Method == http.MethodHead && req.Path == "/v2/other-image/blobs/"+layerDigest {
+					checked = true
+				}
+			}
+			h.AssertEq(t, checked, true)
+		})
+	})
+}
+
+func configDigest(t *testing.T, img *remote.Image) string {
+	t.Helper()
+	configName, err := img.UnderlyingImage().ConfigName()
+	h.AssertNil(t, err)
+	return configName.Hex
+}
@@ -0,0 +1,98 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.SBOMStore = (*Image)(nil)
+
+// AttachSBOM records the SBOM; when the image is saved, the SBOM is pushed to the same repository
+// as an OCI artifact whose subject is the saved image, so that it is discoverable through the referrers API.
+func (i *Image) AttachSBOM(mediaType string, content []byte) error {
+	i.sboms = append(i.sboms, imgutil.SBOM{MediaType: mediaType, Content: content})
+	return nil
+}
+
+// SBOMs returns the SBOMs referring to the working image in the registry, followed by the SBOMs attached with AttachSBOM.
+func (i *Image) SBOMs() ([]imgutil.SBOM, error) {
+	reg := getRegistrySetting(i.repoName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, i.repoName, reg.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := i.Digest()
+	if err != nil {
+		return nil, err
+	}
+	opts := []remote.Option{remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure, i.transportConfig))}
+
+	var sboms []imgutil.SBOM
+	referrers, err := remote.Referrers(ref.Context().Digest(digest.String()), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get referrers for image %q: %w", i.repoName, err)
+	}
+	indexManifest, err := referrers.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range indexManifest.Manifests {
+		// registries without support for the referrers API don't copy manifest annotations to the referrers index,
+		// so every referrer needs to be checked
+		artifact, err := remote.Image(ref.Context().Digest(desc.Digest.String()), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get referrer %s: %w", desc.Digest, err)
+		}
+		manifest, err := artifact.Manifest()
+		if err != nil {
+			return nil, err
+		}
+		if manifest.Annotations[imgutil.SBOMAnnotation] != "true" {
+			continue
+		}
+		sbom, err := imgutil.SBOMFromArtifact(artifact)
+		if err != nil {
+			return nil, err
+		}
+		if !imgutil.ContainsSBOM(i.sboms, sbom) {
+			sboms = append(sboms, sbom)
+		}
+	}
+	return append(sboms, i.sboms...), nil
+}
+
+func (i *Image) pushSBOMs(ctx context.Context, ref name.Reference, auth authn.Authenticator, rt http.RoundTripper) error {
+	if len(i.sboms) == 0 {
+		return nil
+	}
+	subject, err := partial.Descriptor(i.CNBImageCore)
+	if err != nil {
+		return err
+	}
+	for _, sbom := range i.sboms {
+		artifact, err := imgutil.NewSBOMArtifact(sbom, *subject)
+		if err != nil {
+			return err
+		}
+		digest, err := artifact.Digest()
+		if err != nil {
+			return err
+		}
+		if err = remote.Write(ref.Context().Digest(digest.String()), artifact,
+			remote.WithContext(ctx),
+			remote.WithAuth(auth),
+			remote.WithTransport(rt),
+		); err != nil {
+			return fmt.Errorf("failed to push SBOM: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+type schema1Manifest struct {
+	FSLayers []struct {
+		BlobSum string `json:"blobSum"`
+	} `json:"fsLayers"`
+	History []struct {
+		V1Compatibility string `json:"v1Compatibility"`
+	} `json:"history"`
+}
+
+// v1Compatibility holds the fields of the v1 image JSON found in each history entry of a schema 1 manifest.
+type v1Compatibility struct {
+	Architecture    string    `json:"architecture"`
+	Author          string    `json:"author"`
+	Comment         string    `json:"comment"`
+	Config          v1.Config `json:"config"`
+	ContainerConfig struct {
+		Cmd []string `json:"Cmd"`
+	} `json:"container_config"`
+	Created   time.Time `json:"created"`
+	OS        string    `json:"os"`
+	ThrowAway bool      `json:"throwaway"`
+}
+
+// schema1Image reads an image with a v2 schema 1 manifest, and converts it to an image with a v2 schema 2 manifest.
+// The config is synthesized from the v1 image JSON of the history entries, and the diff IDs are computed
+// by reading the layers.
+func schema1Image(ref name.Reference, opts ...remote.Option) (v1.Image, error) {
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+	image, err := desc.Schema1()
+	if err != nil {
+		return nil, err
+	}
+	rawManifest, err := image.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	var manifest schema1Manifest
+	if err = json.Unmarshal(rawManifest, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse schema 1 manifest: %w", err)
+	}
+	if len(manifest.History) != len(manifest.FSLayers) {
+		return nil, fmt.Errorf("schema 1 manifest has %d history entries for %d layers", len(manifest.History), len(manifest.FSLayers))
+	}
+	layers, err := image.Layers() // from the bottom layer to the top layer
+	if err != nil {
+		return nil, err
+	}
+
+	// the entries of the manifest are ordered from the top layer to the bottom layer
+	var (
+		history   []v1.History
+		toAppend  []v1.Layer
+		topConfig v1Compatibility
+	)
+	for i := len(manifest.History) - 1; i >= 0; i-- {
+		var compat v1Compatibility
+		if err = json.Unmarshal([]byte(manifest.History[i].V1Compatibility), &compat); err != nil {
+			return nil, fmt.Errorf("failed to parse schema 1 history: %w", err)
+		}
+		history = append(history, v1.History{
+			Author:     compat.Author,
+			Created:    v1.Time{Time: compat.Created},
+			CreatedBy:  strings.Join(compat.ContainerConfig.Cmd, " "),
+			Comment:    compat.Comment,
+			EmptyLayer: compat.ThrowAway,
+		})
+		if !compat.ThrowAway {
+			toAppend = append(toAppend, layers[len(manifest.History)-1-i])
+		}
+		if i == 0 {
+			topConfig = compat
+		}
+	}
+
+	converted, err := mutate.AppendLayers(empty.Image, toAppend...)
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := converted.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	configFile.Architecture = topConfig.Architecture
+	configFile.OS = topConfig.OS
+	configFile.Created = v1.Time{Time: topConfig.Created}
+	configFile.Author = topConfig.Author
+	configFile.Config = topConfig.Config
+	configFile.History = history
+	return mutate.ConfigFile(converted, configFile)
+}
@@ -0,0 +1,109 @@
+package remote_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestSchema1(t *testing.T) {
+	spec.Run(t, "Schema1", testSchema1, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testSchema1(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		layerPath    string
+		repoName     string
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+		repoName = fakeRegistry.RepoName("some-schema1-image")
+
+		var err error
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+		h.AssertNil(t, err)
+		layer, err := tarball.LayerFromFile(layerPath)
+		h.AssertNil(t, err)
+		repo, err := name.NewRepository(repoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ggcrremote.WriteLayer(repo, layer))
+		digest, err := layer.Digest()
+		h.AssertNil(t, err)
+
+		// entries are ordered from the top layer to the bottom layer
+		manifest, err := json.Marshal(map[string]interface{}{
+			"schemaVersion": 1,
+			"name":          "some-schema1-image",
+			"tag":           "latest",
+			"architecture":  "amd64",
+			"fsLayers": []map[string]string{
+				{"blobSum": digest.String()},
+				{"blobSum": digest.String()},
+			},
+			"history": []map[string]string{
+				{"v1Compatibility": `{"id":"top","parent":"bottom","architecture":"amd64","os":"linux","created":"2016-01-01T00:00:00Z",` +
+					`"config":{"Env":["SOME_KEY=some-value"],"Cmd":["/some-cmd"]},"container_config":{"Cmd":["/bin/sh","-c","#(nop) CMD /some-cmd"]},"throwaway":true}`},
+				{"v1Compatibility": `{"id":"bottom","created":"2015-01-01T00:00:00Z","container_config":{"Cmd":["/bin/sh","-c","#(nop) ADD file"]}}`},
+			},
+		})
+		h.AssertNil(t, err)
+		ref, err := name.ParseReference(repoName, name.WeakValidation, name.Insecure)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ggcrremote.Put(ref, rawManifest{manifest: manifest, mediaType: types.DockerManifestSchema1}))
+	})
+
+	it.After(func() {
+		os.Remove(layerPath)
+		fakeRegistry.Close()
+	})
+
+	it("converts a schema 1 base image", func() {
+		img, err := remote.NewImage(
+			fakeRegistry.RepoName("some-new-image"),
+			authn.DefaultKeychain,
+			remote.FromBaseImage(repoName),
+			remote.WithRegistrySetting(fakeRegistry.Host, true),
+		)
+		h.AssertNil(t, err)
+
+		h.AssertDiffIDs(t, img.UnderlyingImage(), h.FileDiffID(t, layerPath))
+		val, err := img.Env("SOME_KEY")
+		h.AssertNil(t, err)
+		h.AssertEq(t, val, "some-value")
+		history, err := img.History()
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(history), 2)
+		h.AssertEq(t, history[1].EmptyLayer, true)
+		h.AssertEq(t, history[1].CreatedBy, "/bin/sh -c #(nop) CMD /some-cmd")
+		imageOS, err := img.OS()
+		h.AssertNil(t, err)
+		h.AssertEq(t, imageOS, "linux")
+		h.AssertNil(t, img.Save())
+	})
+}
+
+type rawManifest struct {
+	manifest  []byte
+	mediaType types.MediaType
+}
+
+func (m rawManifest) RawManifest() ([]byte, error) {
+	return m.manifest, nil
+}
+
+func (m rawManifest) MediaType() (types.MediaType, error) {
+	return m.mediaType, nil
+}
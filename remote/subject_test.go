@@ -0,0 +1,126 @@
+package remote_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestSubject(t *testing.T) {
+	spec.Run(t, "Subject", testSubject, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testSubject(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		subject      *remote.Image
+		subjectDesc  v1.Descriptor
+		subjectRef   name.Digest
+		referrersOf  = func() []v1.Descriptor {
+			t.Helper()
+			referrers, err := ggcrremote.Referrers(subjectRef)
+			h.AssertNil(t, err)
+			indexManifest, err := referrers.IndexManifest()
+			h.AssertNil(t, err)
+			return indexManifest.Manifests
+		}
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry()
+
+		var err error
+		subject, err = remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain)
+		h.AssertNil(t, err)
+		h.AssertNil(t, subject.Save())
+		subjectDesc, err = imgutil.DescriptorFor(subject)
+		h.AssertNil(t, err)
+		subjectRef, err = name.NewDigest(fakeRegistry.RepoName("some-image") + "@" + subjectDesc.Digest.String())
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+	})
+
+	when("#SetSubject", func() {
+		it("pushes the subject and registers the image with the referrers of the subject", func() {
+			attestation, err := remote.NewImage(fakeRegistry.RepoName("some-image:attestation"), authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, attestation.SetSubject(subjectDesc))
+			h.AssertNil(t, attestation.SetLabel("some-key", "some-value"))
+			h.AssertNil(t, attestation.Save())
+
+			subject, err := attestation.Subject()
+			h.AssertNil(t, err)
+			h.AssertEq(t, subject.Digest, subjectDesc.Digest)
+
+			ref, err := name.ParseReference(fakeRegistry.RepoName("some-image:attestation"))
+			h.AssertNil(t, err)
+			pushed, err := ggcrremote.Image(ref)
+			h.AssertNil(t, err)
+			manifest, err := pushed.Manifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest.Subject.Digest, subjectDesc.Digest)
+
+			digest, err := pushed.Digest()
+			h.AssertNil(t, err)
+			referrers := referrersOf()
+			h.AssertEq(t, len(referrers), 1)
+			h.AssertEq(t, referrers[0].Digest, digest)
+		})
+	})
+
+	when("#SetIndexSubject", func() {
+		it("registers the index with the referrers of the subject", func() {
+			index := imgutil.SetIndexSubject(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), subjectDesc)
+			ref, err := name.ParseReference(fakeRegistry.RepoName("some-image:index"))
+			h.AssertNil(t, err)
+			h.AssertNil(t, ggcrremote.WriteIndex(ref, index))
+
+			digest, err := index.Digest()
+			h.AssertNil(t, err)
+			referrers := referrersOf()
+			h.AssertEq(t, len(referrers), 1)
+			h.AssertEq(t, referrers[0].Digest, digest)
+		})
+	})
+	when("#NewReferrersIndex", func() {
+		it("lists the artifacts with their artifact types, referring to the subject", func() {
+			signature := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), "application/vnd.example.signature+json")
+			sbom, err := imgutil.NewSBOMArtifact(imgutil.SBOM{MediaType: "application/spdx+json", Content: []byte("{}")}, subjectDesc)
+			h.AssertNil(t, err)
+
+			index, err := imgutil.NewReferrersIndex(subject, signature, sbom)
+			h.AssertNil(t, err)
+
+			indexManifest, err := index.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, indexManifest.MediaType, types.OCIImageIndex)
+			h.AssertEq(t, indexManifest.Subject.Digest, subjectDesc.Digest)
+			h.AssertEq(t, len(indexManifest.Manifests), 2)
+			for idx, artifactType := range []string{"application/vnd.example.signature+json", "application/spdx+json"} {
+				desc := indexManifest.Manifests[idx]
+				h.AssertEq(t, desc.MediaType, types.OCIManifestSchema1)
+				h.AssertEq(t, desc.ArtifactType, artifactType)
+				artifact, err := index.Image(desc.Digest)
+				h.AssertNil(t, err)
+				manifest, err := artifact.Manifest()
+				h.AssertNil(t, err)
+				h.AssertEq(t, manifest.Subject.Digest, subjectDesc.Digest)
+			}
+		})
+	})
+}
@@ -0,0 +1,101 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// blobTimeoutTransport fails the requests transferring a blob when they, including the reading of the response body,
+// take longer than the timeout. It records the blobs transferred before, so that the error reports the progress of the operation.
+type blobTimeoutTransport struct {
+	inner   http.RoundTripper
+	timeout time.Duration
+
+	mu          sync.Mutex
+	transferred []string
+}
+
+func (t *blobTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/blobs/") {
+		return t.inner.RoundTrip(req)
+	}
+	blob := blobOf(req)
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.inner.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, t.timeoutError(ctx, blob, err)
+	}
+	// a blob is transferred when its upload is committed, or when it is read entirely
+	transferred := resp.StatusCode < http.StatusBadRequest && (req.Method == http.MethodPut || req.Method == http.MethodGet)
+	resp.Body = &blobTimeoutBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, transport: t, blob: blob,
+		complete: req.Method == http.MethodPut, transferred: transferred}
+	return resp, nil
+}
+
+// timeoutError returns an imgutil.BlobTimeoutError if the request of the given context timed out, or err otherwise.
+func (t *blobTimeoutTransport) timeoutError(ctx context.Context, blob string, err error) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return imgutil.BlobTimeoutError{Blob: blob, Timeout: t.timeout, Transferred: append([]string(nil), t.transferred...)}
+}
+
+func (t *blobTimeoutTransport) record(blob string) {
+	if strings.HasPrefix(blob, "/") {
+		return // an upload location
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transferred = append(t.transferred, blob)
+}
+
+// blobOf returns the digest of the blob transferred by the request, or the path of the upload location
+// when the request uploads content before the digest is sent (e.g. go-containerregistry streams blobs with a PATCH).
+func blobOf(req *http.Request) string {
+	if digest := req.URL.Query().Get("digest"); digest != "" {
+		return digest
+	}
+	path := req.URL.Path
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 && !strings.Contains(path[i:], "/uploads/") {
+		return path[i+len("/blobs/"):]
+	}
+	return path
+}
+
+type blobTimeoutBody struct {
+	io.ReadCloser
+	ctx         context.Context
+	cancel      context.CancelFunc
+	transport   *blobTimeoutTransport
+	blob        string
+	complete    bool // whether the response was read entirely, or needn't be (for upload commits)
+	transferred bool // whether the request transfers the blob once the response is complete
+}
+
+func (b *blobTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.complete = true
+	} else if err != nil {
+		err = b.transport.timeoutError(b.ctx, b.blob, err)
+	}
+	return n, err
+}
+
+func (b *blobTimeoutBody) Close() error {
+	defer b.cancel()
+	if b.complete && b.transferred {
+		b.transport.record(b.blob)
+	}
+	return b.ReadCloser.Close()
+}
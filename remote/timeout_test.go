@@ -0,0 +1,82 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestTimeouts(t *testing.T) {
+	spec.Run(t, "Timeouts", testTimeouts, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testTimeouts(t *testing.T, when spec.G, it spec.S) {
+	var (
+		fakeRegistry *h.FakeRegistry
+		tmpDir       string
+	)
+
+	it.Before(func() {
+		fakeRegistry = h.NewFakeRegistry(h.WithLatency(300 * time.Millisecond))
+		var err error
+		tmpDir, err = os.MkdirTemp("", "remote-timeouts")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		fakeRegistry.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	newImage := func(ops ...imgutil.ImageOption) *remote.Image {
+		ops = append(ops, remote.WithRegistrySetting(fakeRegistry.Host, true))
+		img, err := remote.NewImage(fakeRegistry.RepoName("some-image"), authn.DefaultKeychain, ops...)
+		h.AssertNil(t, err)
+		layerPath, _, _ := h.RandomLayer(t, tmpDir)
+		h.AssertNil(t, img.AddLayer(layerPath))
+		return img
+	}
+
+	it("fails the save with the blob that took longer than the blob timeout", func() {
+		img := newImage(remote.WithBlobTimeout(50 * time.Millisecond))
+
+		err := img.Save()
+		var blobErr imgutil.BlobTimeoutError
+		h.AssertEq(t, errors.As(err, &blobErr), true)
+		h.AssertEq(t, strings.HasPrefix(blobErr.Blob, "sha256:"), true)
+		h.AssertEq(t, blobErr.Timeout, 50*time.Millisecond)
+		h.AssertEq(t, errors.Is(err, imgutil.SaveTimedOut), true)
+		h.AssertEq(t, errors.Is(err, context.DeadlineExceeded), true)
+	})
+
+	it("fails the save after the save deadline", func() {
+		img := newImage(imgutil.WithSaveTimeout(100 * time.Millisecond))
+
+		err := img.Save()
+		var saveErr imgutil.SaveError
+		h.AssertEq(t, errors.As(err, &saveErr), true)
+		h.AssertEq(t, saveErr.Errors[0].Reason, imgutil.SaveTimedOut)
+		h.AssertEq(t, len(saveErr.Saved), 0)
+	})
+
+	it("saves within the timeouts", func() {
+		img := newImage(
+			remote.WithBlobTimeout(10*time.Second),
+			remote.WithConnectTimeout(10*time.Second),
+			imgutil.WithSaveTimeout(time.Minute),
+		)
+
+		h.AssertNil(t, img.Save())
+	})
+}
@@ -0,0 +1,76 @@
+package imgutil
+
+import (
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Report describes the image as it was last saved, so that platforms can log its provenance
+// without fetching it again from the image store.
+type Report struct {
+	// Digest is the digest of the manifest. For the docker daemon, which computes its own image ID,
+	// it is the digest the image would have in a registry.
+	Digest          string
+	MediaType       types.MediaType
+	ConfigMediaType types.MediaType
+	// LayerMediaTypes has the media type of each layer, from the bottom layer to the top layer.
+	LayerMediaTypes []types.MediaType
+	LayerCount      int
+	// CompressedSize is the sum of the sizes of the manifest, config, and compressed layers.
+	CompressedSize int64
+	// Reproducible is true when the created time of the config and of every history entry is the normalized date time
+	// (NormalizedDateTime, unless set with WithNormalizedDateTime), so that saving the same layers and config again results in the same digest.
+	Reproducible bool
+}
+
+// Reporter is implemented by images that describe how they were saved.
+// The local, remote, and layout images implement it; callers holding an Image type-assert for it.
+type Reporter interface {
+	// Report describes the image as it was last saved (e.g. its digest, media types, and size).
+	Report() (Report, error)
+}
+
+var _ Reporter = &CNBImageCore{}
+
+// Report returns a Report of the working image. It is meant to be called after Save.
+func (i *CNBImageCore) Report() (Report, error) {
+	var (
+		report Report
+		err    error
+	)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	digest, err := i.Image.Digest()
+	if err != nil {
+		return Report{}, err
+	}
+	report.Digest = digest.String()
+	if report.MediaType, err = i.Image.MediaType(); err != nil {
+		return Report{}, err
+	}
+	manifest, err := i.manifestLocked()
+	if err != nil {
+		return Report{}, err
+	}
+	report.ConfigMediaType = manifest.Config.MediaType
+	report.CompressedSize = manifest.Config.Size
+	if manifestSize, err := i.Image.Size(); err == nil {
+		report.CompressedSize += manifestSize
+	}
+	for _, layer := range manifest.Layers {
+		report.LayerMediaTypes = append(report.LayerMediaTypes, layer.MediaType)
+		report.CompressedSize += layer.Size
+	}
+	report.LayerCount = len(manifest.Layers)
+
+	configFile, err := i.configFileLocked()
+	if err != nil {
+		return Report{}, err
+	}
+	report.Reproducible = configFile.Created.Time.Equal(i.normalizedDateTime)
+	for _, history := range configFile.History {
+		if !history.Created.Time.Equal(i.normalizedDateTime) {
+			report.Reproducible = false
+		}
+	}
+	return report, nil
+}
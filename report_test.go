@@ -0,0 +1,68 @@
+package imgutil_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestReport(t *testing.T) {
+	spec.Run(t, "Report", testReport, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testReport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		layerPath string
+		img       *testImage
+	)
+
+	it.Before(func() {
+		var err error
+		img = newTestImage(t, imgutil.WithMediaTypes(imgutil.OCITypes))
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file", "some-content", "linux")
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.AddLayer(layerPath))
+	})
+
+	it.After(func() {
+		os.Remove(layerPath)
+	})
+
+	it("describes the saved image", func() {
+		// the backends set the created time and history when saving
+		h.AssertNil(t, img.SetCreatedAtAndHistory())
+
+		saveReport, err := img.Report()
+		h.AssertNil(t, err)
+		digest, err := img.UnderlyingImage().Digest()
+		h.AssertNil(t, err)
+		h.AssertEq(t, saveReport.Digest, digest.String())
+		h.AssertEq(t, saveReport.MediaType, types.OCIManifestSchema1)
+		h.AssertEq(t, saveReport.ConfigMediaType, types.OCIConfigJSON)
+		h.AssertEq(t, saveReport.LayerMediaTypes, []types.MediaType{types.OCILayer})
+		h.AssertEq(t, saveReport.LayerCount, 1)
+		lastLayer, err := img.LastLayer()
+		h.AssertNil(t, err)
+		manifestSize, err := img.ManifestSize()
+		h.AssertNil(t, err)
+		if saveReport.CompressedSize <= lastLayer.Size+manifestSize {
+			t.Fatalf("expected compressed size to include the config, got %d", saveReport.CompressedSize)
+		}
+		h.AssertEq(t, saveReport.Reproducible, true)
+	})
+
+	it("is not reproducible when the created time is not normalized", func() {
+		img := newTestImage(t, imgutil.WithCreatedAt(imgutil.NormalizedDateTime.AddDate(1, 0, 0)))
+		h.AssertNil(t, img.SetCreatedAtAndHistory())
+
+		saveReport, err := img.Report()
+		h.AssertNil(t, err)
+		h.AssertEq(t, saveReport.Reproducible, false)
+	})
+}
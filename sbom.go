@@ -0,0 +1,182 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SBOMAnnotation marks manifests (and the descriptors referencing them) that hold an SBOM attached with AttachSBOM.
+const SBOMAnnotation = "io.buildpacks.imgutil.sbom"
+
+// SBOM is a software bill of materials attached to an image.
+type SBOM struct {
+	MediaType string
+	Content   []byte
+}
+
+// SBOMStore is implemented by images that know how to store SBOMs in their backend:
+// as an OCI referrer artifact (remote), as an artifact manifest in the layout (layout), or as a labeled layer (local).
+type SBOMStore interface {
+	// AttachSBOM records the SBOM, it is persisted when the image is saved.
+	AttachSBOM(mediaType string, content []byte) error
+	// SBOMs returns the SBOMs attached to the image, including the ones that are not saved yet.
+	SBOMs() ([]SBOM, error)
+}
+
+// AttachSBOM attaches an SBOM with the given media type (e.g. `application/spdx+json`) to the image.
+// The SBOM is stored in a backend specific way when the image is saved.
+func AttachSBOM(img Image, mediaType string, content io.Reader) error {
+	store, ok := img.(SBOMStore)
+	if !ok {
+		return fmt.Errorf("image %q of kind %q does not support SBOMs", img.Name(), img.Kind())
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("failed to read SBOM: %w", err)
+	}
+	return store.AttachSBOM(mediaType, data)
+}
+
+// GetSBOMs returns the SBOMs attached to the image.
+func GetSBOMs(img Image) ([]SBOM, error) {
+	store, ok := img.(SBOMStore)
+	if !ok {
+		return nil, fmt.Errorf("image %q of kind %q does not support SBOMs", img.Name(), img.Kind())
+	}
+	return store.SBOMs()
+}
+
+// emptyConfigMediaType is the media type of the empty config (`{}`) of OCI artifacts that have no config.
+const emptyConfigMediaType types.MediaType = "application/vnd.oci.empty.v1+json"
+
+var emptyConfig = []byte("{}")
+
+// NewSBOMArtifact returns an OCI artifact manifest holding the SBOM as its only layer.
+// The config of the manifest is the OCI empty config, its artifact type is the SBOM media type,
+// and its subject is the image described by the given descriptor.
+func NewSBOMArtifact(sbom SBOM, subject v1.Descriptor) (v1.Image, error) {
+	layer := static.NewLayer(sbom.Content, types.MediaType(sbom.MediaType))
+	layerDigest, err := layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	configDigest, configSize, err := v1.SHA256(bytes.NewReader(emptyConfig))
+	if err != nil {
+		return nil, err
+	}
+	rawManifest, err := json.Marshal(artifactManifest{
+		Manifest: v1.Manifest{
+			SchemaVersion: 2,
+			MediaType:     types.OCIManifestSchema1,
+			Config:        v1.Descriptor{MediaType: emptyConfigMediaType, Size: configSize, Digest: configDigest},
+			Layers: []v1.Descriptor{{
+				MediaType: types.MediaType(sbom.MediaType),
+				Size:      int64(len(sbom.Content)),
+				Digest:    layerDigest,
+			}},
+			Annotations: map[string]string{SBOMAnnotation: "true"},
+			Subject:     &subject,
+		},
+		ArtifactType: sbom.MediaType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := partial.CompressedToImage(&artifactCore{rawManifest: rawManifest, layer: layer})
+	if err != nil {
+		return nil, err
+	}
+	return &typedArtifact{Image: artifact, artifactType: sbom.MediaType}, nil
+}
+
+// artifactManifest is an image manifest with an artifact type, which v1.Manifest doesn't have.
+type artifactManifest struct {
+	v1.Manifest
+	ArtifactType string `json:"artifactType,omitempty"`
+}
+
+// artifactCore is an artifact with the empty config and a single layer.
+type artifactCore struct {
+	rawManifest []byte
+	layer       v1.Layer
+}
+
+func (a *artifactCore) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+func (a *artifactCore) RawManifest() ([]byte, error) {
+	return a.rawManifest, nil
+}
+
+func (a *artifactCore) RawConfigFile() ([]byte, error) {
+	return emptyConfig, nil
+}
+
+func (a *artifactCore) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	digest, err := a.layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if h != digest {
+		return nil, fmt.Errorf("artifact has no layer with digest %s", h)
+	}
+	return a.layer, nil
+}
+
+// typedArtifact reports the artifact type of the manifest, when it isn't the media type of the config.
+type typedArtifact struct {
+	v1.Image
+	artifactType string
+}
+
+func (a *typedArtifact) ArtifactType() (string, error) {
+	return a.artifactType, nil
+}
+
+// SBOMFromArtifact reads the SBOM held by an artifact created with NewSBOMArtifact.
+func SBOMFromArtifact(artifact v1.Image) (SBOM, error) {
+	layers, err := artifact.Layers()
+	if err != nil {
+		return SBOM{}, err
+	}
+	if len(layers) != 1 {
+		return SBOM{}, fmt.Errorf("expected SBOM artifact to have 1 layer; got %d", len(layers))
+	}
+	mediaType, err := layers[0].MediaType()
+	if err != nil {
+		return SBOM{}, err
+	}
+	rc, err := layers[0].Compressed()
+	if err != nil {
+		return SBOM{}, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return SBOM{}, err
+	}
+	return SBOM{MediaType: string(mediaType), Content: content}, nil
+}
+
+// IsSBOMArtifact reports if the descriptor references an artifact created with NewSBOMArtifact.
+func IsSBOMArtifact(desc v1.Descriptor) bool {
+	return desc.Annotations[SBOMAnnotation] == "true"
+}
+
+// ContainsSBOM reports if the SBOMs include one with the same media type and content as the given SBOM.
+func ContainsSBOM(sboms []SBOM, sbom SBOM) bool {
+	for _, s := range sboms {
+		if s.MediaType == sbom.MediaType && bytes.Equal(s.Content, sbom.Content) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package imgutil
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"regexp"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// SecretMatcher reports whether the contents of the file at the given path in a layer hold a credential,
+// and describes the credential (e.g. `AWS access key ID`).
+type SecretMatcher func(path string, contents []byte) (description string, found bool)
+
+// SecretFinding describes a credential found in a layer added to an image.
+type SecretFinding struct {
+	DiffID      string
+	Path        string
+	Description string
+}
+
+// maxScannedFileSize is the size of the files larger than which only the beginning is scanned for credentials.
+const maxScannedFileSize = 10 * 1024 * 1024
+
+var builtInSecrets = []struct {
+	description string
+	pattern     *regexp.Regexp
+}{
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH |ENCRYPTED )?PRIVATE KEY-----`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+}
+
+// BuiltInSecretMatcher matches a small set of well-known credential formats
+// (AWS access key IDs, private keys, GitHub and Slack tokens, and Google API keys).
+func BuiltInSecretMatcher(_ string, contents []byte) (string, bool) {
+	for _, secret := range builtInSecrets {
+		if secret.pattern.Match(contents) {
+			return secret.description, true
+		}
+	}
+	return "", false
+}
+
+// WithSecretScanning makes saving the image fail with a SecretsFoundError when one of the given matchers finds a credential
+// in a file of a layer added to the image (layers of the base image and reused layers are not scanned).
+// If no matcher is given, BuiltInSecretMatcher is used.
+func WithSecretScanning(matchers ...SecretMatcher) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		if len(matchers) == 0 {
+			matchers = []SecretMatcher{BuiltInSecretMatcher}
+		}
+		o.SecretMatchers = matchers
+	}
+}
+
+// ScanAddedLayers scans the files of the layers added to the image with the matchers provided with WithSecretScanning,
+// and returns a SecretsFoundError describing the credentials found. Images are scanned when they are saved.
+func (i *CNBImageCore) ScanAddedLayers() error {
+	if len(i.secretMatchers) == 0 {
+		return nil
+	}
+	i.mu.RLock()
+	added := append([]v1.Layer(nil), i.addedLayers...)
+	i.mu.RUnlock()
+	var findings []SecretFinding
+	for _, layer := range added {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return err
+		}
+		found, err := i.scanLayer(layer, diffID.String())
+		if err != nil {
+			return fmt.Errorf("failed to scan layer %s for credentials: %w", diffID, err)
+		}
+		findings = append(findings, found...)
+	}
+	if len(findings) > 0 {
+		return SecretsFoundError{Findings: findings}
+	}
+	return nil
+}
+
+func (i *CNBImageCore) scanLayer(layer v1.Layer, diffID string) ([]SecretFinding, error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var findings []SecretFinding
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(io.LimitReader(tr, maxScannedFileSize))
+		if err != nil {
+			return nil, err
+		}
+		for _, matcher := range i.secretMatchers {
+			if description, found := matcher(header.Name, contents); found {
+				findings = append(findings, SecretFinding{DiffID: diffID, Path: header.Name, Description: description})
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package signature attaches and verifies cosign-compatible signatures for images pushed to a registry.
+//
+// Signatures are stored following the cosign tag convention: for an image with digest `sha256:<hex>`,
+// the signatures are layers of an image tagged `sha256-<hex>.sig` in the same repository.
+// Each layer holds a "simple signing" payload identifying the signed image,
+// and the signature of that payload is stored (base64 encoded) in the layer annotations.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// SimpleSigningMediaType is the media type of the layers holding signature payloads.
+	SimpleSigningMediaType types.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// SignatureAnnotation is the layer annotation holding the base64 encoded signature of the payload.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	simpleSigningType = "cosign container image signature"
+)
+
+// Signer signs signature payloads.
+// Implementations may keep the private key elsewhere, e.g. in a KMS.
+type Signer interface {
+	Sign(payload []byte) ([]byte, error)
+}
+
+// Verifier verifies the signature of a signature payload.
+type Verifier interface {
+	Verify(payload, signature []byte) error
+}
+
+// NewSigner returns a Signer using the given key.
+// ECDSA and RSA keys sign the SHA-256 hash of the payload; Ed25519 keys sign the payload itself.
+func NewSigner(key crypto.Signer) Signer {
+	return &keySigner{key: key}
+}
+
+type keySigner struct {
+	key crypto.Signer
+}
+
+func (s *keySigner) Sign(payload []byte) ([]byte, error) {
+	if _, ok := s.key.Public().(ed25519.PublicKey); ok {
+		return s.key.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(payload)
+	return s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// NewVerifier returns a Verifier for signatures made with the private key matching the given ECDSA, RSA, or Ed25519 public key.
+func NewVerifier(key crypto.PublicKey) Verifier {
+	return &keyVerifier{key: key}
+}
+
+type keyVerifier struct {
+	key crypto.PublicKey
+}
+
+func (v *keyVerifier) Verify(payload, signature []byte) error {
+	digest := sha256.Sum256(payload)
+	switch key := v.key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return errors.New("invalid Ed25519 signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", v.key)
+	}
+}
+
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// Payload returns the "simple signing" payload that is signed for the image with the given digest.
+func Payload(digest name.Digest) ([]byte, error) {
+	var payload simpleSigning
+	payload.Critical.Identity.DockerReference = digest.Context().Name()
+	payload.Critical.Image.DockerManifestDigest = digest.DigestStr()
+	payload.Critical.Type = simpleSigningType
+	return json.Marshal(payload)
+}
+
+// SignatureTag returns the tag where the signatures for the image with the given digest are stored.
+func SignatureTag(digest name.Digest) (name.Tag, error) {
+	hash, err := v1.NewHash(digest.DigestStr())
+	if err != nil {
+		return name.Tag{}, err
+	}
+	return digest.Context().Tag(fmt.Sprintf("%s-%s.sig", hash.Algorithm, hash.Hex)), nil
+}
+
+// Attach signs the pushed image with the given digest and pushes the signature,
+// keeping any signature that was previously attached to the image.
+func Attach(digest name.Digest, signer Signer, opts ...remote.Option) error {
+	payload, err := Payload(digest)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign payload: %w", err)
+	}
+	tag, err := SignatureTag(digest)
+	if err != nil {
+		return err
+	}
+
+	sigImage, err := signatureImage(tag, opts...)
+	if err != nil {
+		return err
+	}
+	sigImage, err = mutate.Append(sigImage, mutate.Addendum{
+		Layer:       static.NewLayer(payload, SimpleSigningMediaType),
+		MediaType:   SimpleSigningMediaType,
+		Annotations: map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	})
+	if err != nil {
+		return err
+	}
+	if err = remote.Write(tag, sigImage, opts...); err != nil {
+		return fmt.Errorf("failed to push signature to %q: %w", tag, err)
+	}
+	return nil
+}
+
+func signatureImage(tag name.Tag, opts ...remote.Option) (v1.Image, error) {
+	existing, err := remote.Image(tag, opts...)
+	if err == nil {
+		return existing, nil
+	}
+	if isNotFound(err) {
+		return mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON), nil
+	}
+	return nil, fmt.Errorf("failed to get signatures from %q: %w", tag, err)
+}
+
+func isNotFound(err error) bool {
+	var transportErr *transport.Error
+	return errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound
+}
+
+// Verify returns nil if at least one signature attached to the image with the given digest
+// was made for that image and is accepted by the verifier.
+func Verify(digest name.Digest, verifier Verifier, opts ...remote.Option) error {
+	tag, err := SignatureTag(digest)
+	if err != nil {
+		return err
+	}
+	sigImage, err := remote.Image(tag, opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("no signatures found for image %q", digest)
+		}
+		return fmt.Errorf("failed to get signatures from %q: %w", tag, err)
+	}
+	manifest, err := sigImage.Manifest()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != SimpleSigningMediaType {
+			continue
+		}
+		if err = verifyLayer(sigImage, desc, digest, verifier); err != nil {
+			errs = append(errs, fmt.Errorf("signature %s: %w", desc.Digest, err))
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no signatures found for image %q", digest)
+	}
+	return fmt.Errorf("no valid signature found for image %q: %w", digest, errors.Join(errs...))
+}
+
+func verifyLayer(sigImage v1.Image, desc v1.Descriptor, digest name.Digest, verifier Verifier) error {
+	sig, err := base64.StdEncoding.DecodeString(desc.Annotations[SignatureAnnotation])
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	layer, err := sigImage.LayerByDigest(desc.Digest)
+	if err != nil {
+		return err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	payload, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if err = verifier.Verify(payload, sig); err != nil {
+		return err
+	}
+
+	var signed simpleSigning
+	if err = json.Unmarshal(payload, &signed); err != nil {
+		return fmt.Errorf("failed to parse payload: %w", err)
+	}
+	if signed.Critical.Image.DockerManifestDigest != digest.DigestStr() {
+		return fmt.Errorf("payload was signed for digest %s", signed.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package signature_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/signature"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestSignature(t *testing.T) {
+	spec.Run(t, "Signature", testSignature, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testSignature(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server *httptest.Server
+		digest name.Digest
+		key    *ecdsa.PrivateKey
+	)
+
+	it.Before(func() {
+		server = httptest.NewServer(registry.New())
+		img, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+		ref, err := name.ParseReference(fmt.Sprintf("%s/some-repo:latest", strings.TrimPrefix(server.URL, "http://")))
+		h.AssertNil(t, err)
+		h.AssertNil(t, remote.Write(ref, img))
+		imgDigest, err := img.Digest()
+		h.AssertNil(t, err)
+		digest = ref.Context().Digest(imgDigest.String())
+
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	when("#Attach", func() {
+		it("pushes the signature to the cosign signature tag", func() {
+			h.AssertNil(t, signature.Attach(digest, signature.NewSigner(key)))
+
+			tag, err := signature.SignatureTag(digest)
+			h.AssertNil(t, err)
+			h.AssertEq(t, tag.TagStr(), strings.Replace(digest.DigestStr(), ":", "-", 1)+".sig")
+			sigImage, err := remote.Image(tag)
+			h.AssertNil(t, err)
+			manifest, err := sigImage.Manifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Layers), 1)
+			h.AssertEq(t, manifest.Layers[0].MediaType, signature.SimpleSigningMediaType)
+		})
+
+		it("keeps the signatures previously attached", func() {
+			otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			h.AssertNil(t, err)
+			h.AssertNil(t, signature.Attach(digest, signature.NewSigner(key)))
+			h.AssertNil(t, signature.Attach(digest, signature.NewSigner(otherKey)))
+
+			h.AssertNil(t, signature.Verify(digest, signature.NewVerifier(&key.PublicKey)))
+			h.AssertNil(t, signature.Verify(digest, signature.NewVerifier(&otherKey.PublicKey)))
+		})
+	})
+
+	when("#Verify", func() {
+		it("fails when the image is not signed", func() {
+			err := signature.Verify(digest, signature.NewVerifier(&key.PublicKey))
+			h.AssertError(t, err, "no signatures found")
+		})
+
+		it("fails when no signature matches the key", func() {
+			h.AssertNil(t, signature.Attach(digest, signature.NewSigner(key)))
+			otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			h.AssertNil(t, err)
+
+			err = signature.Verify(digest, signature.NewVerifier(&otherKey.PublicKey))
+			h.AssertError(t, err, "no valid signature found")
+		})
+
+		it("fails when the signature was made for another image", func() {
+			otherDigest := digest.Context().Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)}.String())
+			h.AssertNil(t, signature.Attach(otherDigest, signature.NewSigner(key)))
+			sigTag, err := signature.SignatureTag(otherDigest)
+			h.AssertNil(t, err)
+			sigImage, err := remote.Image(sigTag)
+			h.AssertNil(t, err)
+			tag, err := signature.SignatureTag(digest)
+			h.AssertNil(t, err)
+			h.AssertNil(t, remote.Write(tag, sigImage))
+
+			err = signature.Verify(digest, signature.NewVerifier(&key.PublicKey))
+			h.AssertError(t, err, "payload was signed for digest")
+		})
+	})
+}
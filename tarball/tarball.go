@@ -0,0 +1,90 @@
+// Package tarball reads images from docker-archive tarballs (e.g. the output of `docker save`),
+// so that air-gapped builds can use them as base or previous images without a registry or a daemon.
+package tarball
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	ggcrtarball "github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// Image is an image read from a docker-archive tarball.
+// It is read-only: it implements imgutil.ReadOnlyImage to inspect the image, and v1.Image so that it can be provided
+// as the base image (see imgutil.FromBaseImageInstance) or the previous image (see imgutil.WithPreviousImageInstance)
+// of an image of any implementation. Its layers are read from the tarball when they are used.
+type Image struct {
+	v1.Image
+	imgutil.ReadOnlyImage
+}
+
+var (
+	_ imgutil.ReadOnlyImage = (*Image)(nil)
+	_ v1.Image              = (*Image)(nil)
+)
+
+// Option configures NewImage.
+type Option func(*options)
+
+type options struct {
+	tag string
+}
+
+// WithTag selects the image with the given tag, for tarballs holding several images.
+func WithTag(tag string) Option {
+	return func(o *options) {
+		o.tag = tag
+	}
+}
+
+// NewImage returns the image of the docker-archive tarball at the given path.
+// The tarball must hold a single image, unless the image is selected with WithTag.
+func NewImage(path string, ops ...Option) (*Image, error) {
+	o := &options{}
+	for _, op := range ops {
+		op(o)
+	}
+	var tag *name.Tag
+	if o.tag != "" {
+		t, err := name.NewTag(o.tag, name.WeakValidation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tag %q: %w", o.tag, err)
+		}
+		tag = &t
+	}
+
+	image, err := ggcrtarball.ImageFromPath(path, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image from tarball %q: %w", path, err)
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config for image from tarball %q: %w", path, err)
+	}
+	// the daemon identifies images by the digest of their config, as `docker save` does
+	configName, err := image.ConfigName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config digest for image from tarball %q: %w", path, err)
+	}
+	imageName := path
+	if o.tag != "" {
+		imageName = path + ":" + o.tag
+	}
+	return &Image{
+		Image:         image,
+		ReadOnlyImage: imgutil.NewReadOnlyImage(imageName, configFile, configName),
+	}, nil
+}
+
+// Kind returns `tarball`.
+func (i *Image) Kind() string {
+	return "tarball"
+}
+
+// UnderlyingImage returns the image read from the tarball.
+func (i *Image) UnderlyingImage() v1.Image {
+	return i.Image
+}
@@ -0,0 +1,96 @@
+package tarball_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrtarball "github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layout"
+	"github.com/buildpacks/imgutil/tarball"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestTarball(t *testing.T) {
+	spec.Run(t, "Tarball", testTarball, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testTarball(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir      string
+		tarballPath string
+		diffIDs     []string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "tarball")
+		h.AssertNil(t, err)
+
+		image, err := random.Image(64, 2)
+		h.AssertNil(t, err)
+		image, err = mutate.Config(image, v1.Config{Labels: map[string]string{"some.label": "some-value"}})
+		h.AssertNil(t, err)
+		configFile, err := image.ConfigFile()
+		h.AssertNil(t, err)
+		diffIDs = nil
+		for _, diffID := range configFile.RootFS.DiffIDs {
+			diffIDs = append(diffIDs, diffID.String())
+		}
+		tag, err := name.NewTag("some-image:some-tag")
+		h.AssertNil(t, err)
+		tarballPath = filepath.Join(tmpDir, "app.tar")
+		h.AssertNil(t, ggcrtarball.WriteToFile(tarballPath, tag, image))
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("reads the image of the tarball", func() {
+		img, err := tarball.NewImage(tarballPath)
+		h.AssertNil(t, err)
+		h.AssertEq(t, img.Found(), true)
+		label, err := img.Label("some.label")
+		h.AssertNil(t, err)
+		h.AssertEq(t, label, "some-value")
+		topLayer, err := img.TopLayer()
+		h.AssertNil(t, err)
+		h.AssertEq(t, topLayer, diffIDs[1])
+		identifier, err := img.Identifier()
+		h.AssertNil(t, err)
+		configName, err := img.ConfigName()
+		h.AssertNil(t, err)
+		h.AssertEq(t, identifier.String(), configName.String())
+	})
+
+	it("selects the image by tag", func() {
+		_, err := tarball.NewImage(tarballPath, tarball.WithTag("some-image:some-tag"))
+		h.AssertNil(t, err)
+		_, err = tarball.NewImage(tarballPath, tarball.WithTag("some-image:other-tag"))
+		h.AssertError(t, err, "failed to read image from tarball")
+	})
+
+	it("can be the base and previous image of another image", func() {
+		img, err := tarball.NewImage(tarballPath)
+		h.AssertNil(t, err)
+
+		rebuilt, err := layout.NewImage(filepath.Join(tmpDir, "rebuilt"), imgutil.FromBaseImageInstance(img))
+		h.AssertNil(t, err)
+		h.AssertDiffIDs(t, rebuilt, diffIDs...)
+
+		next, err := layout.NewImage(filepath.Join(tmpDir, "next"), imgutil.WithPreviousImageInstance(img))
+		h.AssertNil(t, err)
+		h.AssertNil(t, next.ReuseLayer(diffIDs[1]))
+		h.AssertNil(t, next.Save())
+		h.AssertDiffIDs(t, next, diffIDs[1])
+	})
+}
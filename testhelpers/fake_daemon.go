@@ -0,0 +1,438 @@
+package testhelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	dockercli "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// FakeDaemon is an in-process HTTP server speaking the subset of the Docker Engine API used by the local package
+// (version, info, image inspect, history, save, load, tag, and remove), so it can be tested without Docker.
+// Images are kept in memory.
+type FakeDaemon struct {
+	OS           string
+	Architecture string
+
+	server *httptest.Server
+
+	mu     sync.Mutex
+	images map[string]*fakeDaemonImage // by image ID
+	tags   map[string]string           // from normalized tag to image ID
+	layers map[string][]byte           // from diff ID to uncompressed layer
+}
+
+type fakeDaemonImage struct {
+	id          string
+	rawConfig   []byte
+	configFile  *v1.ConfigFile
+	repoDigests []string
+}
+
+// NewFakeDaemon starts a fake daemon reporting the current platform as linux/GOARCH. Callers should Close it when done.
+func NewFakeDaemon() *FakeDaemon {
+	daemon := &FakeDaemon{
+		OS:           "linux",
+		Architecture: runtime.GOARCH,
+		images:       make(map[string]*fakeDaemonImage),
+		tags:         make(map[string]string),
+		layers:       make(map[string][]byte),
+	}
+	daemon.server = httptest.NewServer(http.HandlerFunc(daemon.serveHTTP))
+	return daemon
+}
+
+// Client returns a docker client connected to the fake daemon.
+func (d *FakeDaemon) Client() (*dockercli.Client, error) {
+	return dockercli.NewClientWithOpts(
+		dockercli.WithHost("tcp://"+strings.TrimPrefix(d.server.URL, "http://")),
+		dockercli.WithHTTPClient(d.server.Client()),
+		dockercli.WithVersion("1.38"),
+	)
+}
+
+// SetRepoDigests sets the repo digests the daemon reports for the image with the given reference,
+// as if the image had been pulled or pushed with them. It reports whether the image was found.
+func (d *FakeDaemon) SetRepoDigests(ref string, repoDigests ...string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.lookup(ref)
+	if ok {
+		img.repoDigests = repoDigests
+	}
+	return ok
+}
+
+// Close shuts down the fake daemon.
+func (d *FakeDaemon) Close() {
+	d.server.Close()
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+func (d *FakeDaemon) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	switch {
+	case path == "/_ping":
+		_, _ = w.Write([]byte("OK"))
+	case path == "/version" && r.Method == http.MethodGet:
+		writeDaemonJSON(w, types.Version{Os: d.OS, Arch: d.Architecture, APIVersion: "1.38"})
+	case path == "/info" && r.Method == http.MethodGet:
+		writeDaemonJSON(w, system.Info{OSType: d.OS, Architecture: d.Architecture})
+	case path == "/images/load" && r.Method == http.MethodPost:
+		d.load(w, r)
+	case path == "/images/get" && r.Method == http.MethodGet:
+		d.save(w, r.URL.Query()["names"])
+	case strings.HasPrefix(path, "/images/"):
+		ref := strings.TrimPrefix(path, "/images/")
+		switch {
+		case strings.HasSuffix(ref, "/json") && r.Method == http.MethodGet:
+			d.inspect(w, strings.TrimSuffix(ref, "/json"))
+		case strings.HasSuffix(ref, "/history") && r.Method == http.MethodGet:
+			d.history(w, strings.TrimSuffix(ref, "/history"))
+		case strings.HasSuffix(ref, "/tag") && r.Method == http.MethodPost:
+			d.tag(w, strings.TrimSuffix(ref, "/tag"), r.URL.Query().Get("repo"), r.URL.Query().Get("tag"))
+		case r.Method == http.MethodDelete:
+			d.remove(w, ref)
+		default:
+			writeDaemonError(w, http.StatusNotFound, "page not found")
+		}
+	default:
+		writeDaemonError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func writeDaemonJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeDaemonError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf(format, args...)})
+}
+
+// normalizeTag returns the fully qualified form of a tag, e.g. index.docker.io/library/busybox:latest.
+func normalizeTag(ref string) (string, bool) {
+	tag, err := name.NewTag(ref, name.WeakValidation)
+	if err != nil {
+		return "", false
+	}
+	return tag.Name(), true
+}
+
+// familiarTag returns the short form of a tag, as reported by the daemon, e.g. busybox:latest.
+func familiarTag(normalized string) string {
+	normalized = strings.TrimPrefix(normalized, name.DefaultRegistry+"/")
+	return strings.TrimPrefix(normalized, "library/")
+}
+
+// lookup returns the image with the given ID, ID prefix, or tag. The caller must hold d.mu.
+func (d *FakeDaemon) lookup(ref string) (*fakeDaemonImage, bool) {
+	if tag, ok := normalizeTag(ref); ok {
+		if id, ok := d.tags[tag]; ok {
+			return d.images[id], true
+		}
+	}
+	hex := strings.TrimPrefix(ref, "sha256:")
+	if len(hex) < 12 {
+		return nil, false
+	}
+	for id, img := range d.images {
+		if strings.HasPrefix(strings.TrimPrefix(id, "sha256:"), hex) {
+			return img, true
+		}
+	}
+	return nil, false
+}
+
+// repoTags returns the familiar tags referencing the image ID. The caller must hold d.mu.
+func (d *FakeDaemon) repoTags(id string) []string {
+	var tags []string
+	for tag, tagID := range d.tags {
+		if tagID == id {
+			tags = append(tags, familiarTag(tag))
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func (d *FakeDaemon) inspect(w http.ResponseWriter, ref string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.lookup(ref)
+	if !ok {
+		writeDaemonError(w, http.StatusNotFound, "No such image: %s", ref)
+		return
+	}
+	cfg := img.configFile
+	var size int64
+	layers := make([]string, len(cfg.RootFS.DiffIDs))
+	for idx, diffID := range cfg.RootFS.DiffIDs {
+		layers[idx] = diffID.String()
+		size += int64(len(d.layers[diffID.String()]))
+	}
+	exposedPorts := make(nat.PortSet, len(cfg.Config.ExposedPorts))
+	for port := range cfg.Config.ExposedPorts {
+		exposedPorts[nat.Port(port)] = struct{}{}
+	}
+	writeDaemonJSON(w, types.ImageInspect{
+		ID:           img.id,
+		RepoTags:     d.repoTags(img.id),
+		RepoDigests:  img.repoDigests,
+		Created:      cfg.Created.Format(time.RFC3339Nano),
+		Author:       cfg.Author,
+		Architecture: cfg.Architecture,
+		Variant:      cfg.Variant,
+		Os:           cfg.OS,
+		OsVersion:    cfg.OSVersion,
+		Size:         size,
+		Config: &container.Config{
+			User:         cfg.Config.User,
+			ExposedPorts: exposedPorts,
+			Env:          cfg.Config.Env,
+			Cmd:          cfg.Config.Cmd,
+			Volumes:      cfg.Config.Volumes,
+			WorkingDir:   cfg.Config.WorkingDir,
+			Entrypoint:   cfg.Config.Entrypoint,
+			Labels:       cfg.Config.Labels,
+			StopSignal:   cfg.Config.StopSignal,
+			ArgsEscaped:  cfg.Config.ArgsEscaped,
+		},
+		RootFS: types.RootFS{Type: "layers", Layers: layers},
+	})
+}
+
+func (d *FakeDaemon) history(w http.ResponseWriter, ref string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.lookup(ref)
+	if !ok {
+		writeDaemonError(w, http.StatusNotFound, "No such image: %s", ref)
+		return
+	}
+	// the daemon reports history in reverse order
+	history := img.configFile.History
+	items := make([]image.HistoryResponseItem, 0, len(history))
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		items = append(items, image.HistoryResponseItem{
+			ID:        "<missing>",
+			Created:   history[idx].Created.Unix(),
+			CreatedBy: history[idx].CreatedBy,
+			Comment:   history[idx].Comment,
+		})
+	}
+	if len(items) > 0 {
+		items[0].ID = img.id
+		items[0].Tags = d.repoTags(img.id)
+	}
+	writeDaemonJSON(w, items)
+}
+
+type fakeDaemonManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+func (d *FakeDaemon) load(w http.ResponseWriter, r *http.Request) {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeDaemonError(w, http.StatusBadRequest, "reading image tar: %s", err)
+			return
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			writeDaemonError(w, http.StatusBadRequest, "reading image tar: %s", err)
+			return
+		}
+		files[strings.TrimPrefix(hdr.Name, "/")] = contents
+	}
+
+	var manifest []fakeDaemonManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		writeDaemonError(w, http.StatusBadRequest, "reading manifest.json: %s", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var loaded []string
+	for _, entry := range manifest {
+		rawConfig := files[strings.TrimPrefix(entry.Config, "/")]
+		configFile, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+		if err != nil {
+			writeDaemonError(w, http.StatusBadRequest, "reading config %q: %s", entry.Config, err)
+			return
+		}
+		if len(entry.Layers) != len(configFile.RootFS.DiffIDs) {
+			writeDaemonError(w, http.StatusBadRequest, "config has %d layers, manifest has %d", len(configFile.RootFS.DiffIDs), len(entry.Layers))
+			return
+		}
+		// layers omitted from the tar must already exist in the daemon
+		newLayers := make(map[string][]byte)
+		for idx, diffID := range configFile.RootFS.DiffIDs {
+			if _, ok := d.layers[diffID.String()]; ok {
+				continue
+			}
+			contents, ok := files[strings.TrimPrefix(entry.Layers[idx], "/")]
+			if !ok || fmt.Sprintf("sha256:%x", sha256.Sum256(contents)) != diffID.String() {
+				writeDaemonError(w, http.StatusInternalServerError, "layer %s does not exist", diffID)
+				return
+			}
+			newLayers[diffID.String()] = contents
+		}
+		for diffID, contents := range newLayers {
+			d.layers[diffID] = contents
+		}
+		id := fmt.Sprintf("sha256:%x", sha256.Sum256(rawConfig))
+		if _, ok := d.images[id]; !ok {
+			d.images[id] = &fakeDaemonImage{id: id, rawConfig: rawConfig, configFile: configFile}
+		}
+		for _, repoTag := range entry.RepoTags {
+			if tag, ok := normalizeTag(repoTag); ok {
+				d.tags[tag] = id
+			}
+		}
+		loaded = append(loaded, id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	for _, id := range loaded {
+		_ = json.NewEncoder(w).Encode(map[string]string{"stream": "Loaded image ID: " + id + "\n"})
+	}
+}
+
+func (d *FakeDaemon) save(w http.ResponseWriter, refs []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var (
+		images   []*fakeDaemonImage
+		manifest []fakeDaemonManifest
+	)
+	for _, ref := range refs {
+		img, ok := d.lookup(ref)
+		if !ok {
+			writeDaemonError(w, http.StatusNotFound, "No such image: %s", ref)
+			return
+		}
+		images = append(images, img)
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	written := make(map[string]bool)
+	writeFile := func(name string, contents []byte) error {
+		if written[name] {
+			return nil
+		}
+		written[name] = true
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}); err != nil {
+			return err
+		}
+		_, err := tw.Write(contents)
+		return err
+	}
+	for _, img := range images {
+		entry := fakeDaemonManifest{
+			Config:   strings.TrimPrefix(img.id, "sha256:") + ".json",
+			RepoTags: d.repoTags(img.id),
+		}
+		if err := writeFile(entry.Config, img.rawConfig); err != nil {
+			return
+		}
+		for _, diffID := range img.configFile.RootFS.DiffIDs {
+			layerName := diffID.Hex + "/layer.tar"
+			if err := writeFile(layerName, d.layers[diffID.String()]); err != nil {
+				return
+			}
+			entry.Layers = append(entry.Layers, layerName)
+		}
+		manifest = append(manifest, entry)
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+	_ = writeFile("manifest.json", manifestJSON)
+}
+
+func (d *FakeDaemon) tag(w http.ResponseWriter, ref, repo, tag string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.lookup(ref)
+	if !ok {
+		writeDaemonError(w, http.StatusNotFound, "No such image: %s", ref)
+		return
+	}
+	if tag != "" {
+		repo += ":" + tag
+	}
+	normalized, ok := normalizeTag(repo)
+	if !ok {
+		writeDaemonError(w, http.StatusBadRequest, "invalid reference format: %s", repo)
+		return
+	}
+	d.tags[normalized] = img.id
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (d *FakeDaemon) remove(w http.ResponseWriter, ref string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.lookup(ref)
+	if !ok {
+		writeDaemonError(w, http.StatusNotFound, "No such image: %s", ref)
+		return
+	}
+
+	var responses []image.DeleteResponse
+	if normalized, ok := normalizeTag(ref); ok && d.tags[normalized] == img.id {
+		delete(d.tags, normalized)
+		responses = append(responses, image.DeleteResponse{Untagged: familiarTag(normalized)})
+		if len(d.repoTags(img.id)) > 0 {
+			writeDaemonJSON(w, responses)
+			return
+		}
+	} else {
+		for _, tag := range d.repoTags(img.id) {
+			normalized, _ := normalizeTag(tag)
+			delete(d.tags, normalized)
+			responses = append(responses, image.DeleteResponse{Untagged: tag})
+		}
+	}
+	delete(d.images, img.id)
+	responses = append(responses, image.DeleteResponse{Deleted: img.id})
+	writeDaemonJSON(w, responses)
+}
@@ -0,0 +1,136 @@
+package testhelpers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+// FakeRegistry serves the Distribution API in-process, so remote flows can be tested without Docker.
+type FakeRegistry struct {
+	Host string // host:port of the registry
+
+	server         *httptest.Server
+	blobHandler    registry.BlobHandler
+	username       string
+	password       string
+	latency        time.Duration
+	failBlobUpload int
+
+	mu          sync.Mutex
+	blobUploads int
+	requests    []RecordedRequest
+}
+
+// RecordedRequest is a request received by a FakeRegistry.
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+}
+
+type FakeRegistryOption func(registry *FakeRegistry)
+
+// WithBasicAuth requires requests to provide the given credentials, otherwise rejecting them with a 401.
+func WithBasicAuth(username, password string) FakeRegistryOption {
+	return func(r *FakeRegistry) {
+		r.username = username
+		r.password = password
+	}
+}
+
+// WithDiskStorage stores blobs in dir instead of memory.
+func WithDiskStorage(dir string) FakeRegistryOption {
+	return func(r *FakeRegistry) {
+		r.blobHandler = registry.NewDiskBlobHandler(dir)
+	}
+}
+
+// WithLatency delays every response by d.
+func WithLatency(d time.Duration) FakeRegistryOption {
+	return func(r *FakeRegistry) {
+		r.latency = d
+	}
+}
+
+// WithBlobUploadFailure rejects the nth (1-based) completed blob upload with a 400.
+// Note that clients such as go-containerregistry may retry the upload.
+func WithBlobUploadFailure(n int) FakeRegistryOption {
+	return func(r *FakeRegistry) {
+		r.failBlobUpload = n
+	}
+}
+
+// NewFakeRegistry starts a registry on a random local port. Callers should Close it when done.
+func NewFakeRegistry(ops ...FakeRegistryOption) *FakeRegistry {
+	fakeRegistry := &FakeRegistry{}
+	for _, op := range ops {
+		op(fakeRegistry)
+	}
+
+	regOps := []registry.Option{registry.Logger(log.New(io.Discard, "registry ", log.Lshortfile))}
+	if fakeRegistry.blobHandler != nil {
+		regOps = append(regOps, registry.WithBlobHandler(fakeRegistry.blobHandler))
+	}
+	handler := registry.New(regOps...)
+	if fakeRegistry.username != "" {
+		handler = BasicAuth(handler, fakeRegistry.username, fakeRegistry.password, "fake-registry")
+	}
+	fakeRegistry.server = httptest.NewServer(fakeRegistry.intercept(handler))
+	fakeRegistry.Host = strings.TrimPrefix(fakeRegistry.server.URL, "http://")
+	return fakeRegistry
+}
+
+func (r *FakeRegistry) intercept(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.requests = append(r.requests, RecordedRequest{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery})
+		fail := false
+		if req.Method == http.MethodPut && strings.Contains(req.URL.Path, "/blobs/uploads/") {
+			r.blobUploads++
+			fail = r.blobUploads == r.failBlobUpload
+		}
+		r.mu.Unlock()
+
+		if r.latency > 0 {
+			time.Sleep(r.latency)
+		}
+		if fail {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"BLOB_UPLOAD_INVALID","message":"injected blob upload failure"}]}`))
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// RepoName returns a repository name on this registry.
+func (r *FakeRegistry) RepoName(name string) string {
+	return r.Host + "/" + name
+}
+
+// Requests returns the requests received so far, in order.
+func (r *FakeRegistry) Requests() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedRequest(nil), r.requests...)
+}
+
+// BlobUploads returns the number of completed blob uploads received so far, including failed ones.
+func (r *FakeRegistry) BlobUploads() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.blobUploads
+}
+
+// Close shuts down the registry.
+func (r *FakeRegistry) Close() {
+	r.server.Close()
+}
@@ -0,0 +1,85 @@
+package testhelpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// UpdateGoldenEnv, when set to "true", makes the golden assertions write the actual content to the golden files instead of comparing.
+const UpdateGoldenEnv = "IMGUTIL_UPDATE_GOLDEN"
+
+// timestampKeys are removed from JSON documents before comparison, wherever they appear.
+var timestampKeys = []string{"created", "org.opencontainers.image.created"}
+
+// NormalizeJSON returns raw re-encoded with sorted keys and indentation, without timestamps or any of the given keys,
+// so that documents can be compared regardless of when they were created.
+func NormalizeJSON(t *testing.T, raw []byte, stripKeys ...string) []byte {
+	t.Helper()
+	var doc interface{}
+	AssertNil(t, json.Unmarshal(raw, &doc))
+	doc = stripJSONKeys(doc, append(stripKeys, timestampKeys...))
+	normalized, err := json.MarshalIndent(doc, "", "  ") // map keys are sorted when encoded
+	AssertNil(t, err)
+	return append(normalized, '\n')
+}
+
+func stripJSONKeys(doc interface{}, keys []string) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for _, key := range keys {
+			delete(v, key)
+		}
+		for key, val := range v {
+			v[key] = stripJSONKeys(val, keys)
+		}
+	case []interface{}:
+		for idx, val := range v {
+			v[idx] = stripJSONKeys(val, keys)
+		}
+	}
+	return doc
+}
+
+// AssertGoldenManifest compares the normalized manifest of image against the golden file at goldenPath.
+func AssertGoldenManifest(t *testing.T, image v1.Image, goldenPath string, stripKeys ...string) {
+	t.Helper()
+	raw, err := image.RawManifest()
+	AssertNil(t, err)
+	AssertGolden(t, NormalizeJSON(t, raw, stripKeys...), goldenPath)
+}
+
+// AssertGoldenConfig compares the normalized config file of image against the golden file at goldenPath.
+func AssertGoldenConfig(t *testing.T, image v1.Image, goldenPath string, stripKeys ...string) {
+	t.Helper()
+	raw, err := image.RawConfigFile()
+	AssertNil(t, err)
+	AssertGolden(t, NormalizeJSON(t, raw, stripKeys...), goldenPath)
+}
+
+// AssertGolden compares actual against the contents of the golden file at goldenPath, failing with a line diff.
+// If UpdateGoldenEnv is "true", the golden file is written with actual instead.
+func AssertGolden(t *testing.T, actual []byte, goldenPath string) {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) == "true" {
+		AssertNil(t, os.MkdirAll(filepath.Dir(goldenPath), 0750))
+		AssertNil(t, os.WriteFile(goldenPath, actual, 0600))
+		return
+	}
+	expected, err := os.ReadFile(filepath.Clean(goldenPath))
+	if err != nil {
+		t.Fatalf("reading golden file (set %s=true to create it): %s", UpdateGoldenEnv, err)
+	}
+	if bytes.Equal(actual, expected) {
+		return
+	}
+	if diff := cmp.Diff(strings.Split(string(expected), "\n"), strings.Split(string(actual), "\n")); diff != "" {
+		t.Fatalf("%s does not match (-golden +actual):\n%s", goldenPath, diff)
+	}
+}
@@ -463,6 +463,55 @@ func RandomLayer(t *testing.T, tmpDir string) (path string, sha string, contents
 	return path, "sha256:" + sha, contentsBuf.Bytes()
 }
 
+// SeededRandomLayer writes a tar layer to tmpDir containing the given number of files with size bytes of content in total.
+// The content is generated from seed, so the same arguments always produce the same layer, and therefore the same diffID,
+// which lets tests across backends share fixtures.
+func SeededRandomLayer(t *testing.T, tmpDir string, seed, size int64, files int) (path string, diffID string) {
+	t.Helper()
+	if files < 1 {
+		t.Fatalf("expected at least one file, got %d", files)
+	}
+
+	path = filepath.Join(tmpDir, fmt.Sprintf("seeded-%d-%d-%d.tar", seed, size, files))
+	fh, err := os.Create(path)
+	AssertNil(t, err)
+	defer fh.Close()
+
+	hasher := sha256.New()
+	tw := tar.NewWriter(io.MultiWriter(hasher, fh))
+	rnd := rand.New(rand.NewSource(seed)) // #nosec G404
+	for i := 0; i < files; i++ {
+		fileSize := size / int64(files)
+		if i == files-1 {
+			fileSize += size % int64(files)
+		}
+		AssertNil(t, tw.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf("/random/file-%d", i),
+			Mode:     0644,
+			Size:     fileSize,
+			ModTime:  layer.NormalizedModTime,
+			Typeflag: tar.TypeReg,
+		}))
+		_, err = io.CopyN(tw, rnd, fileSize)
+		AssertNil(t, err)
+	}
+	AssertNil(t, tw.Close())
+
+	return path, "sha256:" + hex.EncodeToString(hasher.Sum(nil))
+}
+
+// AssertDiffIDs asserts that the image has exactly the given layer diffIDs, in order.
+func AssertDiffIDs(t *testing.T, image v1.Image, expected ...string) {
+	t.Helper()
+	configFile, err := image.ConfigFile()
+	AssertNil(t, err)
+	actual := make([]string, len(configFile.RootFS.DiffIDs))
+	for idx, diffID := range configFile.RootFS.DiffIDs {
+		actual[idx] = diffID.String()
+	}
+	AssertEq(t, actual, expected)
+}
+
 func RemoteRunnableBaseImage(t *testing.T) v1.Image {
 	testImageName := "busybox"
 	var opts []remote.Option
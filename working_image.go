@@ -0,0 +1,116 @@
+package imgutil
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// setImage replaces the working image, and resets the config file and manifest cached from the previous working image.
+// Callers must hold the lock.
+func (i *CNBImageCore) setImage(image v1.Image) {
+	i.Image = image
+	i.cachedConfigFile = nil
+	i.cachedManifest = nil
+}
+
+// configFile returns the config file of the working image, which is read once for each working image,
+// as reading it may require fetching it from a registry.
+// The returned config file must not be modified; callers mutating it must make a copy.
+func (i *CNBImageCore) configFile() (*v1.ConfigFile, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.configFileLocked()
+}
+
+// configFileLocked is configFile for callers holding the lock.
+func (i *CNBImageCore) configFileLocked() (*v1.ConfigFile, error) {
+	i.cacheMu.Lock()
+	defer i.cacheMu.Unlock()
+	if i.cachedConfigFile != nil {
+		return i.cachedConfigFile, nil
+	}
+	configFile, err := getConfigFile(i.Image)
+	if err != nil {
+		return nil, err
+	}
+	i.cachedConfigFile = configFile
+	return configFile, nil
+}
+
+// manifest returns the manifest of the working image, which is read once for each working image.
+// The returned manifest must not be modified; callers mutating it must make a copy.
+func (i *CNBImageCore) manifest() (*v1.Manifest, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.manifestLocked()
+}
+
+// manifestLocked is manifest for callers holding the lock.
+func (i *CNBImageCore) manifestLocked() (*v1.Manifest, error) {
+	i.cacheMu.Lock()
+	defer i.cacheMu.Unlock()
+	if i.cachedManifest != nil {
+		return i.cachedManifest, nil
+	}
+	manifest, err := getManifest(i.Image)
+	if err != nil {
+		return nil, err
+	}
+	i.cachedManifest = manifest
+	return manifest, nil
+}
+
+// The methods of v1.Image read the working image under the lock, so that they can be called concurrently with modifiers
+// (e.g. when writing the image while it is modified).
+
+func (i *CNBImageCore) Layers() ([]v1.Layer, error) {
+	return i.UnderlyingImage().Layers()
+}
+
+func (i *CNBImageCore) MediaType() (types.MediaType, error) {
+	return i.UnderlyingImage().MediaType()
+}
+
+func (i *CNBImageCore) Size() (int64, error) {
+	return i.UnderlyingImage().Size()
+}
+
+func (i *CNBImageCore) ConfigName() (v1.Hash, error) {
+	return i.UnderlyingImage().ConfigName()
+}
+
+func (i *CNBImageCore) ConfigFile() (*v1.ConfigFile, error) {
+	configFile, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	return configFile.DeepCopy(), nil
+}
+
+func (i *CNBImageCore) RawConfigFile() ([]byte, error) {
+	return i.UnderlyingImage().RawConfigFile()
+}
+
+func (i *CNBImageCore) Digest() (v1.Hash, error) {
+	return i.UnderlyingImage().Digest()
+}
+
+func (i *CNBImageCore) Manifest() (*v1.Manifest, error) {
+	manifest, err := i.manifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.DeepCopy(), nil
+}
+
+func (i *CNBImageCore) RawManifest() ([]byte, error) {
+	return i.UnderlyingImage().RawManifest()
+}
+
+func (i *CNBImageCore) LayerByDigest(digest v1.Hash) (v1.Layer, error) {
+	return i.UnderlyingImage().LayerByDigest(digest)
+}
+
+func (i *CNBImageCore) LayerByDiffID(diffID v1.Hash) (v1.Layer, error) {
+	return i.UnderlyingImage().LayerByDiffID(diffID)
+}